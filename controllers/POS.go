@@ -4,6 +4,7 @@ import (
 	"context"
 	"dog/condb"
 	"dog/models"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -55,6 +56,34 @@ func CreateSale(c *fiber.Ctx) error {
 	}
 
 	sale.SaleID = newSaleID
+	// พนักงานที่ขาย = ผู้ที่ login อยู่ (ไม่เชื่อ employee_id จาก body)
+	sale.EmployeeID, _ = c.Locals("user_id").(string)
+	sale.PaymentMethod = strings.ToUpper(strings.TrimSpace(sale.PaymentMethod))
+	if sale.PaymentMethod == "" {
+		sale.PaymentMethod = models.PayMethodCash
+	}
+	// STORE_CREDIT = จ่ายด้วยเครดิตร้านทั้งหมด; ใช้บางส่วนส่ง store_credit มาคู่กับช่องทางอื่น
+	payAllCredit := sale.PaymentMethod == models.PayMethodStoreCredit
+	if !paymentMethods[sale.PaymentMethod] && !payAllCredit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errPaymentMethod.Error()})
+	}
+	if sale.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must be greater than 0"})
+	}
+	if sale.StoreCredit < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errCreditNegative.Error()})
+	}
+	if sale.GiftCardAmount < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errGiftCardRedeemAmount.Error()})
+	}
+	if (sale.StoreCredit > 0 || payAllCredit) && !hasCreditAccount(sale.CustomerID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errCreditNoCustomer.Error()})
+	}
+	if sale.TaxBuyer != nil {
+		if msg := normalizeTaxBuyer(sale.TaxBuyer); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+		}
+	}
 
 	// 1. เริ่ม Transaction เพื่อความปลอดภัยข้อมูล
 	tx, err := conn.Begin(context.Background())
@@ -67,39 +96,129 @@ func CreateSale(c *fiber.Ctx) error {
 		}
 	}()
 
-	// 2. Insert ข้อมูลการขาย
-	_, err = tx.Exec(context.Background(),
-		`INSERT INTO sales (sale_id, employee_id, customer_id, product_id, quantity, total_price)
-         VALUES ($1, $2, $3, $4, $5, $6)`,
-		sale.SaleID, sale.EmployeeID, sale.CustomerID, sale.ProductID, sale.Quantity, sale.TotalPrice,
-	)
+	// 2. ตรวจสินค้า/variant (สินค้าที่มีไซซ์ต้องส่ง variant_id มา) แล้วล็อกแถวไว้
+	line, err := resolveOrderLine(context.Background(), tx, models.CreateOrderItemReq{
+		ProductID: sale.ProductID,
+		VariantID: sale.VariantID,
+		Quantity:  sale.Quantity,
+	}, true)
+	if err == errProductNotFound || err == errVariantNotFound || err == errVariantRequired {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	sale.ProductID = line.ProductID
+	sale.VariantID = line.VariantID
+	// ราคาคิดฝั่ง server แบบเดียวกับตะกร้าออนไลน์ (price_override ของ variant หรือ sell_price) ไม่เชื่อ total_price จาก client
+	sale.TotalPrice = line.Price.Mul(sale.Quantity)
 
-	// 3. อัพเดต stock ลดจำนวนสินค้าคงเหลือ
-	commandTag, err := tx.Exec(context.Background(),
-		`UPDATE stock SET quantity = quantity - $1, updated_at = NOW() WHERE product_id = $2 AND quantity >= $1`,
-		sale.Quantity, sale.ProductID,
+	// 3. คิด VAT ตามการตั้งค่า; ราคาไม่รวม VAT → บวก VAT เข้า total_price
+	tax, err := loadTaxSettings(context.Background(), tx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	sale.VATRate = tax.EffectiveRate()
+	sale.VATAmount = tax.VATOf(sale.TotalPrice)
+	sale.VATInclusive = tax.PricesIncludeVAT
+	if !sale.VATInclusive {
+		sale.TotalPrice += sale.VATAmount
+	}
+
+	// บัตรของขวัญตัดก่อน แล้วเครดิตร้านจ่ายไม่เกินยอดที่เหลือ
+	var card models.GiftCard
+	var cardAmount models.Money
+	if sale.GiftCardCode != "" {
+		card, err = lockGiftCardTx(context.Background(), tx, sale.GiftCardCode)
+		if err == nil {
+			cardAmount, err = giftCardRedeemAmount(card, sale.GiftCardAmount, sale.TotalPrice)
+		}
+		if err != nil {
+			return c.Status(giftCardErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	remaining := sale.TotalPrice - cardAmount
+	credit := sale.StoreCredit
+	if payAllCredit || credit > remaining {
+		credit = remaining
+	}
+	if remaining == credit && cardAmount > 0 {
+		sale.PaymentMethod = models.PayMethodGiftCard
+	} else if credit > 0 && credit == remaining {
+		sale.PaymentMethod = models.PayMethodStoreCredit
+	}
+
+	// 4. Insert ข้อมูลการขาย
+	_, err = tx.Exec(context.Background(),
+		`INSERT INTO sales (sale_id, employee_id, customer_id, product_id, variant_id, quantity, total_price, tax_buyer,
+             vat_rate, vat_amount, vat_inclusive, payment_method)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		sale.SaleID, sale.EmployeeID, sale.CustomerID, sale.ProductID, sale.VariantID, sale.Quantity, sale.TotalPrice, sale.TaxBuyer,
+		sale.VATRate, sale.VATAmount, sale.VATInclusive, sale.PaymentMethod,
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-	if commandTag.RowsAffected() == 0 {
+
+	// ขายหน้าร้านรับเงินครบทันที ลงสมุดรับเงิน (บัตรของขวัญ + เครดิตร้าน + ส่วนที่เหลือด้วย payment_method)
+	if cardAmount > 0 {
+		err = redeemGiftCardTx(context.Background(), tx, card, paymentEntry{
+			SaleID: sale.SaleID, Amount: cardAmount, Note: "gift card", CreatedBy: sale.EmployeeID,
+		})
+		if err != nil {
+			return c.Status(giftCardErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if credit > 0 {
+		err = redeemStoreCreditTx(context.Background(), tx, sale.CustomerID, paymentEntry{
+			SaleID: sale.SaleID, Amount: credit, Note: "store credit", CreatedBy: sale.EmployeeID,
+		})
+		if err != nil {
+			return c.Status(storeCreditErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if remaining > credit {
+		_, err = insertPaymentTx(context.Background(), tx, paymentEntry{
+			SaleID: sale.SaleID, Method: sale.PaymentMethod, Amount: remaining - credit, Status: models.PaymentSucceeded,
+			CreatedBy: sale.EmployeeID,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	// 5. ตัดสต็อก (products.quantity / product_variants.quantity + stock_movements)
+	_, err = moveStockTx(context.Background(), tx, stockMove{
+		ProductID: sale.ProductID,
+		VariantID: sale.VariantID,
+		Kind:      models.StockMoveSale,
+		Change:    -sale.Quantity,
+		RefType:   "sale",
+		RefID:     sale.SaleID,
+		CreatedBy: sale.EmployeeID,
+	})
+	if err == errInsufficientStock || err == errProductNotFound {
 		// rollback transaction เพราะสต็อกไม่พอหรือ product_id ไม่มี
-		tx.Rollback(context.Background())
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient stock or product not found"})
 	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	// 4. Commit transaction
+	// 6. Commit transaction
 	err = tx.Commit(context.Background())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to commit transaction"})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Sale created and stock updated",
-		"sale_id": newSaleID,
+		"message":        "Sale created and stock updated",
+		"sale_id":        newSaleID,
+		"total_price":    sale.TotalPrice,
+		"vat_amount":     sale.VATAmount,
+		"payment_method": sale.PaymentMethod,
+		"store_credit":   credit,
+		"gift_card":      cardAmount,
 	})
 }
 
@@ -111,7 +230,9 @@ func GetSales(c *fiber.Ctx) error {
 	defer conn.Close(context.Background())
 
 	rows, err := conn.Query(context.Background(),
-		`SELECT id, sale_id, employee_id, customer_id, product_id, quantity, total_price, sale_date, created_at FROM sales ORDER BY id ASC`,
+		`SELECT id, sale_id, employee_id, customer_id, product_id, variant_id, quantity, total_price, vat_rate, vat_amount, vat_inclusive,
+                tax_invoice_no, payment_method, sale_date, created_at
+         FROM sales ORDER BY id ASC`,
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
@@ -122,8 +243,8 @@ func GetSales(c *fiber.Ctx) error {
 	for rows.Next() {
 		var s models.Sale
 		if err := rows.Scan(
-			&s.ID, &s.SaleID, &s.EmployeeID, &s.CustomerID, &s.ProductID,
-			&s.Quantity, &s.TotalPrice, &s.SaleDate, &s.CreatedAt,
+			&s.ID, &s.SaleID, &s.EmployeeID, &s.CustomerID, &s.ProductID, &s.VariantID,
+			&s.Quantity, &s.TotalPrice, &s.VATRate, &s.VATAmount, &s.VATInclusive, &s.TaxInvoiceNo, &s.PaymentMethod, &s.SaleDate, &s.CreatedAt,
 		); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -144,11 +265,15 @@ func GetSaleByID(c *fiber.Ctx) error {
 
 	var s models.Sale
 	err = conn.QueryRow(context.Background(),
-		`SELECT id, sale_id, employee_id, customer_id, product_id, quantity, total_price, sale_date, created_at
+		`SELECT id, sale_id, employee_id, customer_id, product_id, variant_id, quantity, total_price, vat_rate, vat_amount, vat_inclusive,
+                tax_buyer, tax_invoice_no, tax_invoice_at, refunded_amount, payment_method, voided_at, voided_by, void_reason,
+                sale_date, created_at
          FROM sales WHERE sale_id = $1`, saleID,
 	).Scan(
-		&s.ID, &s.SaleID, &s.EmployeeID, &s.CustomerID, &s.ProductID,
-		&s.Quantity, &s.TotalPrice, &s.SaleDate, &s.CreatedAt,
+		&s.ID, &s.SaleID, &s.EmployeeID, &s.CustomerID, &s.ProductID, &s.VariantID,
+		&s.Quantity, &s.TotalPrice, &s.VATRate, &s.VATAmount, &s.VATInclusive,
+		&s.TaxBuyer, &s.TaxInvoiceNo, &s.TaxInvoiceAt, &s.RefundedAmount, &s.PaymentMethod, &s.VoidedAt, &s.VoidedBy, &s.VoidReason,
+		&s.SaleDate, &s.CreatedAt,
 	)
 
 	if err != nil {
@@ -158,57 +283,193 @@ func GetSaleByID(c *fiber.Ctx) error {
 	return c.JSON(s)
 }
 
+var (
+	errSaleImmutable = errors.New("only customer_id can be changed; void the sale and ring it up again")
+	errSaleVoided    = errors.New("sale is already voided")
+)
+
+// ====================
+// แก้ไขการขาย: เปลี่ยนได้เฉพาะลูกค้า (สินค้า/จำนวน/ยอดเงินผูกกับสต็อกและสมุดรับเงินแล้ว)
+// PUT /sales/:sale_id   body: { "customer_id": "CUS001" }
+// ====================
 func UpdateSale(c *fiber.Ctx) error {
+	var req models.UpdateSaleReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
 	conn, err := condb.DB_Lek()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
 	}
 	defer conn.Close(context.Background())
 
-	saleID := c.Params("sale_id")
-
-	var updateData models.Sale
-	if err := c.BodyParser(&updateData); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start transaction"})
 	}
+	defer tx.Rollback(ctx)
 
-	commandTag, err := conn.Exec(context.Background(),
-		`UPDATE sales SET employee_id=$1, customer_id=$2, product_id=$3, quantity=$4, total_price=$5 WHERE sale_id=$6`,
-		updateData.EmployeeID, updateData.CustomerID, updateData.ProductID, updateData.Quantity, updateData.TotalPrice, saleID,
-	)
+	var s models.Sale
+	err = tx.QueryRow(ctx, `
+		SELECT sale_id, employee_id, COALESCE(customer_id, ''), product_id, quantity, total_price, voided_at
+		FROM sales WHERE sale_id = $1 FOR UPDATE
+	`, c.Params("sale_id")).Scan(&s.SaleID, &s.EmployeeID, &s.CustomerID, &s.ProductID, &s.Quantity, &s.TotalPrice, &s.VoidedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Sale not found"})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	if commandTag.RowsAffected() == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Sale not found"})
+	if s.VoidedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errSaleVoided.Error()})
+	}
+	if (req.EmployeeID != nil && *req.EmployeeID != s.EmployeeID) ||
+		(req.ProductID != nil && *req.ProductID != s.ProductID) ||
+		(req.Quantity != nil && *req.Quantity != s.Quantity) ||
+		(req.TotalPrice != nil && *req.TotalPrice != s.TotalPrice) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errSaleImmutable.Error()})
+	}
+	if req.CustomerID == nil || *req.CustomerID == s.CustomerID {
+		return c.JSON(fiber.Map{"message": "Sale updated successfully"})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Sale updated successfully",
-	})
+	// เครดิตร้านตัดจากบัญชีลูกค้าเดิม เปลี่ยนลูกค้าแล้วตอน void จะคืนผิดคน
+	var usedCredit bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM payments WHERE sale_id = $1 AND method = $2)`,
+		s.SaleID, models.PayMethodStoreCredit).Scan(&usedCredit); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if usedCredit {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "customer cannot be changed on a sale paid with store credit"})
+	}
+	if _, err := tx.Exec(ctx, `UPDATE sales SET customer_id = $1 WHERE sale_id = $2`, *req.CustomerID, s.SaleID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to commit transaction"})
+	}
+	return c.JSON(fiber.Map{"message": "Sale updated successfully"})
 }
-func DeleteSale(c *fiber.Ctx) error {
+
+// ====================
+// ยกเลิกการขาย (void) แทนการลบ: คืนของเข้าสต็อก และคืนเงินตามช่องทางที่รับมา
+// (บัตรของขวัญคืนเข้าบัตร, เครดิตร้านคืนเข้าบัญชี, ที่เหลือลงรายการคืนเงินของแต่ละช่องทาง)
+// POST /sales/:sale_id/void   body: { "reason": "คิดเงินผิด" } (required)
+// การขายที่มีใบคืนสินค้าแล้วต้องใช้ขั้นตอนคืนสินค้าแทน
+// ====================
+func VoidSale(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+	var req models.VoidSaleReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+
 	conn, err := condb.DB_Lek()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
 	}
 	defer conn.Close(context.Background())
 
-	saleID := c.Params("sale_id")
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
 
-	commandTag, err := conn.Exec(context.Background(),
-		`DELETE FROM sales WHERE sale_id=$1`, saleID,
-	)
+	var s models.Sale
+	err = tx.QueryRow(ctx, `
+		SELECT sale_id, COALESCE(customer_id, ''), product_id, variant_id, quantity, voided_at
+		FROM sales WHERE sale_id = $1 FOR UPDATE
+	`, c.Params("sale_id")).Scan(&s.SaleID, &s.CustomerID, &s.ProductID, &s.VariantID, &s.Quantity, &s.VoidedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Sale not found"})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	if s.VoidedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errSaleVoided.Error()})
+	}
+	var hasReturns bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM return_requests WHERE sale_id = $1 AND status NOT IN ($2, $3))
+	`, s.SaleID, models.ReturnStatusRejected, models.ReturnStatusCancelled).Scan(&hasReturns); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if hasReturns {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "sale has returns; use the returns flow instead"})
+	}
 
-	if commandTag.RowsAffected() == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Sale not found"})
+	// 1. คืนของเข้าสต็อก
+	if _, err := moveStockTx(ctx, tx, stockMove{
+		ProductID: s.ProductID,
+		VariantID: s.VariantID,
+		Kind:      models.StockMoveReturn,
+		Change:    s.Quantity,
+		RefType:   "sale_void",
+		RefID:     s.SaleID,
+		Note:      reason,
+		CreatedBy: staffID,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Sale deleted successfully",
-	})
+	// 2. คืนเงิน: บัตรของขวัญ/เครดิตร้านคืนเข้าที่เดิม ช่องทางอื่นลงรายการคืนเงินตามยอดรับสุทธิ
+	note := "sale voided"
+	if _, err := restoreGiftCardsTx(ctx, tx, "sale_id", s.SaleID, 0, note, staffID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if hasCreditAccount(s.CustomerID) {
+		if err := restoreCreditTx(ctx, tx, "sale_id", s.SaleID, s.CustomerID, reason, note, staffID); err != nil {
+			return c.Status(storeCreditErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT method, SUM(CASE WHEN kind = 'payment' THEN amount ELSE -amount END)
+		FROM payments
+		WHERE sale_id = $1 AND status = $2 AND method NOT IN ($3, $4)
+		GROUP BY method
+		HAVING SUM(CASE WHEN kind = 'payment' THEN amount ELSE -amount END) > 0
+		ORDER BY method
+	`, s.SaleID, models.PaymentSucceeded, models.PayMethodStoreCredit, models.PayMethodGiftCard)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	var refunds []paymentEntry
+	for rows.Next() {
+		e := paymentEntry{SaleID: s.SaleID, Kind: models.PaymentKindRefund, Status: models.PaymentSucceeded, Note: note, CreatedBy: staffID}
+		if err := rows.Scan(&e.Method, &e.Amount); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		refunds = append(refunds, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	for _, e := range refunds {
+		if _, err := insertPaymentTx(ctx, tx, e); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	// 3. ปิดการขาย
+	if _, err := tx.Exec(ctx, `
+		UPDATE sales SET voided_at = NOW(), voided_by = NULLIF($2,''), void_reason = $3, refunded_amount = total_price
+		WHERE sale_id = $1
+	`, s.SaleID, staffID, reason); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to commit transaction"})
+	}
+	return c.JSON(fiber.Map{"message": "Sale voided", "sale_id": s.SaleID})
 }
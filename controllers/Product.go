@@ -277,18 +277,18 @@ func GetPopularProducts(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit", 12)
 
 	// ปรับสถานะออเดอร์ตามระบบคุณ เช่น paid/shipped/completed
-	validStatuses := []string{"paid", "shipped", "completed"}
+	validStatuses := []string{"paid", "partially_shipped", "shipped", "completed"}
 
 	type PopularItem struct {
-		ID          int       `json:"id"`
-		ProductID   string    `json:"product_id"`
-		Name        string    `json:"name"`
-		SellPrice   float64   `json:"sell_price"`
-		Image       *string   `json:"image"`
-		Recommended bool      `json:"recommended"`
-		Quantity    int       `json:"quantity"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		SoldInRange int64     `json:"sold_in_range"` // ใช้เฉพาะ mode=auto
+		ID          int          `json:"id"`
+		ProductID   string       `json:"product_id"`
+		Name        string       `json:"name"`
+		SellPrice   models.Money `json:"sell_price"`
+		Image       *string      `json:"image"`
+		Recommended bool         `json:"recommended"`
+		Quantity    int          `json:"quantity"`
+		UpdatedAt   time.Time    `json:"updated_at"`
+		SoldInRange int64        `json:"sold_in_range"` // ใช้เฉพาะ mode=auto
 	}
 
 	var rows pgx.Rows
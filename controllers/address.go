@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errAddressNotFound = errors.New("address not found")
+	errAddressRequired = errors.New("shipping address is required")
+)
+
+// normalizeAddress ตัดช่องว่าง, เก็บเฉพาะตัวเลขของเบอร์โทร แล้วตรวจความครบถ้วน
+// คืนข้อความ error ที่จะส่งให้ client (ว่าง = ผ่าน)
+func normalizeAddress(a *models.ShippingAddress) string {
+	a.Recipient = strings.TrimSpace(a.Recipient)
+	a.HouseNo = strings.TrimSpace(a.HouseNo)
+	a.Subdistrict = strings.TrimSpace(a.Subdistrict)
+	a.District = strings.TrimSpace(a.District)
+	a.Province = strings.TrimSpace(a.Province)
+	a.Postcode = strings.TrimSpace(a.Postcode)
+	a.Phone = strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, a.Phone)
+
+	switch {
+	case a.Recipient == "":
+		return "recipient is required"
+	case a.HouseNo == "":
+		return "house_no is required"
+	case a.Subdistrict == "":
+		return "subdistrict is required"
+	case a.District == "":
+		return "district is required"
+	case a.Province == "":
+		return "province is required"
+	}
+	if len(a.Phone) < 9 || len(a.Phone) > 10 || a.Phone[0] != '0' {
+		return "invalid phone"
+	}
+	if len(a.Postcode) != 5 || strings.Trim(a.Postcode, "0123456789") != "" {
+		return "postcode must be 5 digits"
+	}
+	return ""
+}
+
+// canAccessCustomer ลูกค้าเข้าถึงได้เฉพาะข้อมูลตัวเอง, พนักงานเข้าถึงได้ทุกคน
+func canAccessCustomer(c *fiber.Ctx, customerID string) bool {
+	userID, _ := c.Locals("user_id").(string)
+	role, _ := c.Locals("role").(string)
+	return userID == customerID || role == models.RoleStaff || role == models.RoleOwner
+}
+
+const addressColumns = `id, customer_id, label, recipient, phone, house_no, subdistrict, district, province, postcode,
+	is_default, created_at, updated_at`
+
+func scanAddress(row pgx.Row, a *models.CustomerAddress) error {
+	return row.Scan(&a.ID, &a.CustomerID, &a.Label, &a.Recipient, &a.Phone, &a.HouseNo, &a.Subdistrict,
+		&a.District, &a.Province, &a.Postcode, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// insertAddressTx เพิ่มที่อยู่ลงสมุด; ที่อยู่แรกของลูกค้าเป็น default อัตโนมัติ
+func insertAddressTx(ctx context.Context, tx pgx.Tx, customerID string, label *string, a models.ShippingAddress, makeDefault bool) (models.CustomerAddress, error) {
+	var out models.CustomerAddress
+
+	var count int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM customer_addresses WHERE customer_id = $1`, customerID).Scan(&count); err != nil {
+		return out, err
+	}
+	if count == 0 {
+		makeDefault = true
+	}
+	if makeDefault {
+		if _, err := tx.Exec(ctx, `UPDATE customer_addresses SET is_default = FALSE WHERE customer_id = $1 AND is_default`, customerID); err != nil {
+			return out, err
+		}
+	}
+
+	err := scanAddress(tx.QueryRow(ctx, `
+		INSERT INTO customer_addresses
+			(customer_id, label, recipient, phone, house_no, subdistrict, district, province, postcode, is_default)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+		RETURNING `+addressColumns,
+		customerID, label, a.Recipient, a.Phone, a.HouseNo, a.Subdistrict, a.District, a.Province, a.Postcode, makeDefault,
+	), &out)
+	return out, err
+}
+
+// resolveShippingAddressTx เลือกที่อยู่จัดส่งสำหรับออเดอร์: address_id > shipping_address > ที่อยู่ default
+func resolveShippingAddressTx(ctx context.Context, tx pgx.Tx, customerID string, req *models.CreateOrderReq) (*models.ShippingAddress, error) {
+	if req.AddressID != nil {
+		var a models.CustomerAddress
+		err := scanAddress(tx.QueryRow(ctx, `
+			SELECT `+addressColumns+` FROM customer_addresses WHERE id = $1 AND customer_id = $2
+		`, *req.AddressID, customerID), &a)
+		if err == pgx.ErrNoRows {
+			return nil, errAddressNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &a.ShippingAddress, nil
+	}
+
+	if req.ShippingAddress != nil {
+		if req.SaveAddress {
+			if _, err := insertAddressTx(ctx, tx, customerID, nil, *req.ShippingAddress, false); err != nil {
+				return nil, err
+			}
+		}
+		return req.ShippingAddress, nil
+	}
+
+	var a models.CustomerAddress
+	err := scanAddress(tx.QueryRow(ctx, `
+		SELECT `+addressColumns+` FROM customer_addresses WHERE customer_id = $1 AND is_default
+	`, customerID), &a)
+	if err == pgx.ErrNoRows {
+		return nil, errAddressRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a.ShippingAddress, nil
+}
+
+// ====================
+// สมุดที่อยู่ของลูกค้า
+// GET /customers/:customer_id/addresses
+// ====================
+func GetCustomerAddresses(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT `+addressColumns+` FROM customer_addresses
+		WHERE customer_id = $1
+		ORDER BY is_default DESC, id DESC
+	`, customerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	list := []models.CustomerAddress{}
+	for rows.Next() {
+		var a models.CustomerAddress
+		if err := scanAddress(rows, &a); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		list = append(list, a)
+	}
+	return c.JSON(list)
+}
+
+// ====================
+// เพิ่มที่อยู่
+// POST /customers/:customer_id/addresses
+// ====================
+func CreateCustomerAddress(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+
+	var req models.CustomerAddressReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := normalizeAddress(&req.ShippingAddress); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := insertAddressTx(ctx, tx, customerID, req.Label, req.ShippingAddress, req.IsDefault)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(a)
+}
+
+// ====================
+// แก้ไขที่อยู่
+// PUT /customers/:customer_id/addresses/:address_id
+// ====================
+func UpdateCustomerAddress(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+
+	var req models.CustomerAddressReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := normalizeAddress(&req.ShippingAddress); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	if req.IsDefault {
+		if _, err := tx.Exec(ctx, `UPDATE customer_addresses SET is_default = FALSE WHERE customer_id = $1 AND is_default`, customerID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	// is_default = false ใน body ไม่ปลด default (ต้องตั้งที่อยู่อื่นเป็น default แทน)
+	var a models.CustomerAddress
+	err = scanAddress(tx.QueryRow(ctx, `
+		UPDATE customer_addresses
+		SET label=$1, recipient=$2, phone=$3, house_no=$4, subdistrict=$5, district=$6, province=$7, postcode=$8,
+		    is_default = is_default OR $9, updated_at = NOW()
+		WHERE id = $10 AND customer_id = $11
+		RETURNING `+addressColumns,
+		req.Label, req.Recipient, req.Phone, req.HouseNo, req.Subdistrict, req.District, req.Province, req.Postcode,
+		req.IsDefault, c.Params("address_id"), customerID,
+	), &a)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Address not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	return c.JSON(a)
+}
+
+// ====================
+// ตั้งเป็นที่อยู่ default
+// PATCH /customers/:customer_id/addresses/:address_id/default
+// ====================
+func SetDefaultCustomerAddress(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE customer_addresses SET is_default = FALSE WHERE customer_id = $1 AND is_default`, customerID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	res, err := tx.Exec(ctx, `
+		UPDATE customer_addresses SET is_default = TRUE, updated_at = NOW()
+		WHERE id = $1 AND customer_id = $2
+	`, c.Params("address_id"), customerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if res.RowsAffected() == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Address not found"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Default address updated"})
+}
+
+// ====================
+// ลบที่อยู่ (ถ้าลบ default จะเลื่อนที่อยู่ล่าสุดขึ้นมาเป็น default แทน)
+// DELETE /customers/:customer_id/addresses/:address_id
+// ====================
+func DeleteCustomerAddress(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	var wasDefault bool
+	err = tx.QueryRow(ctx, `
+		DELETE FROM customer_addresses WHERE id = $1 AND customer_id = $2
+		RETURNING is_default
+	`, c.Params("address_id"), customerID).Scan(&wasDefault)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Address not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if wasDefault {
+		if _, err := tx.Exec(ctx, `
+			UPDATE customer_addresses SET is_default = TRUE
+			WHERE id = (SELECT id FROM customer_addresses WHERE customer_id = $1 ORDER BY id DESC LIMIT 1)
+		`, customerID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Address deleted"})
+}
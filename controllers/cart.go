@@ -0,0 +1,518 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"dog/condb"
+	"dog/models"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errCartNotFound = errors.New("cart not found")
+	errCartEmpty    = errors.New("cart is empty")
+)
+
+const (
+	cartCookieName = "cart_id"
+	cartCookieTTL  = 30 * 24 * time.Hour
+)
+
+func newCartID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func setCartCookie(c *fiber.Ctx, cartID string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     cartCookieName,
+		Value:    cartID,
+		Expires:  time.Now().Add(cartCookieTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+func clearCartCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     cartCookieName,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+// customerCartTx คืน id ตะกร้าที่เปิดอยู่ของลูกค้า; create = true สร้างใหม่ถ้ายังไม่มี (ไม่มีและไม่สร้าง → "")
+func customerCartTx(ctx context.Context, tx pgx.Tx, customerID string, create bool) (string, error) {
+	var id string
+	err := tx.QueryRow(ctx, `SELECT id FROM carts WHERE customer_id = $1 AND status = 'open'`, customerID).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != pgx.ErrNoRows {
+		return "", err
+	}
+	if !create {
+		return "", nil
+	}
+
+	if id, err = newCartID(); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO carts (id, customer_id) VALUES ($1, $2)
+		ON CONFLICT (customer_id) WHERE status = 'open' AND customer_id IS NOT NULL DO NOTHING
+	`, id, customerID); err != nil {
+		return "", err
+	}
+	// อาจมี request อื่นสร้างไปก่อน → อ่านใบที่มีอยู่จริง
+	err = tx.QueryRow(ctx, `SELECT id FROM carts WHERE customer_id = $1 AND status = 'open'`, customerID).Scan(&id)
+	return id, err
+}
+
+// mergeCartTx รวมตะกร้า anonymous เข้าตะกร้าของลูกค้า (จำนวนของบรรทัดซ้ำบวกกัน)
+// ถ้าลูกค้ายังไม่มีตะกร้า จะยกตะกร้า anonymous ให้ลูกค้าเลย; คืน id ตะกร้าของลูกค้า ("" ถ้าไม่มีทั้งคู่)
+func mergeCartTx(ctx context.Context, tx pgx.Tx, anonID, customerID string) (string, error) {
+	var found bool
+	err := tx.QueryRow(ctx, `
+		SELECT TRUE FROM carts
+		WHERE id = $1 AND status = 'open' AND customer_id IS NULL
+		FOR UPDATE
+	`, anonID).Scan(&found)
+	if err == pgx.ErrNoRows {
+		return customerCartTx(ctx, tx, customerID, false)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	custID, err := customerCartTx(ctx, tx, customerID, false)
+	if err != nil {
+		return "", err
+	}
+	if custID == "" {
+		_, err := tx.Exec(ctx, `UPDATE carts SET customer_id = $1, updated_at = NOW() WHERE id = $2`, customerID, anonID)
+		return anonID, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, price_at_add)
+		SELECT $1, product_id, variant_id, quantity, price_at_add FROM cart_items WHERE cart_id = $2
+		ON CONFLICT (cart_id, product_id, COALESCE(variant_id, 0))
+		DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity, updated_at = NOW()
+	`, custID, anonID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE carts SET status = $1, merged_into = $2, updated_at = NOW() WHERE id = $3
+	`, models.CartStatusMerged, custID, anonID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, custID); err != nil {
+		return "", err
+	}
+	return custID, nil
+}
+
+// currentCartID หาตะกร้าของ request นี้: ลูกค้าที่ login ใช้ตะกร้าของลูกค้า (รวมตะกร้าใน cookie ให้อัตโนมัติ)
+// ถ้ายังไม่ login ใช้ cookie cart_id; create = true สร้างตะกร้าใหม่ถ้ายังไม่มี
+func currentCartID(ctx context.Context, conn *pgx.Conn, c *fiber.Ctx, create bool) (string, error) {
+	userID, _ := c.Locals("user_id").(string)
+	anonID := c.Cookies(cartCookieName)
+
+	if userID == "" {
+		if anonID != "" {
+			var open bool
+			if err := conn.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM carts WHERE id = $1 AND status = 'open' AND customer_id IS NULL)
+			`, anonID).Scan(&open); err != nil {
+				return "", err
+			}
+			if open {
+				return anonID, nil
+			}
+		}
+		if !create {
+			return "", nil
+		}
+		id, err := newCartID()
+		if err != nil {
+			return "", err
+		}
+		if _, err := conn.Exec(ctx, `INSERT INTO carts (id) VALUES ($1)`, id); err != nil {
+			return "", err
+		}
+		setCartCookie(c, id)
+		return id, nil
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	if anonID != "" {
+		if id, err = mergeCartTx(ctx, tx, anonID, userID); err != nil {
+			return "", err
+		}
+	}
+	if id == "" {
+		if id, err = customerCartTx(ctx, tx, userID, create); err != nil {
+			return "", err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	if anonID != "" {
+		clearCartCookie(c)
+	}
+	return id, nil
+}
+
+// loadCart อ่านตะกร้าแล้วคิดราคา/สต็อกใหม่ทุกบรรทัดจากข้อมูลปัจจุบัน
+func loadCart(ctx context.Context, q querier, cartID string) (models.Cart, error) {
+	cart := models.Cart{ID: cartID, Items: []models.CartLine{}}
+	if cartID == "" {
+		return cart, nil
+	}
+
+	var updatedAt time.Time
+	err := q.QueryRow(ctx, `SELECT customer_id, updated_at FROM carts WHERE id = $1`, cartID).Scan(&cart.CustomerID, &updatedAt)
+	if err == pgx.ErrNoRows {
+		return cart, errCartNotFound
+	}
+	if err != nil {
+		return cart, err
+	}
+	cart.UpdatedAt = &updatedAt
+
+	rows, err := q.Query(ctx, `
+		SELECT id, product_id, variant_id, quantity, price_at_add
+		FROM cart_items WHERE cart_id = $1
+		ORDER BY id
+	`, cartID)
+	if err != nil {
+		return cart, err
+	}
+	for rows.Next() {
+		var l models.CartLine
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.VariantID, &l.Quantity, &l.PriceAtAdd); err != nil {
+			rows.Close()
+			return cart, err
+		}
+		cart.Items = append(cart.Items, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return cart, err
+	}
+
+	for i := range cart.Items {
+		l := &cart.Items[i]
+		ol, err := resolveOrderLine(ctx, q, models.CreateOrderItemReq{
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			Quantity:  l.Quantity,
+		}, false)
+		switch err {
+		case nil:
+		case errProductNotFound, errVariantNotFound, errVariantRequired:
+			l.Status = models.CartLineUnavailable
+			cart.HasIssues = true
+			continue
+		default:
+			return cart, err
+		}
+
+		l.Name = ol.Name
+		l.SKU = ol.SKU
+		l.Variant = ol.Variant
+		l.UnitPrice = ol.Price
+		l.PriceChanged = ol.Price != l.PriceAtAdd
+		l.LineTotal = ol.Price.Mul(l.Quantity)
+		l.Available = ol.Available
+		switch {
+		case ol.Available <= 0:
+			l.Status = models.CartLineOutOfStock
+		case ol.Available < l.Quantity:
+			l.Status = models.CartLineInsufficientStock
+		default:
+			l.Status = models.CartLineOK
+		}
+		if l.Status != models.CartLineOK {
+			cart.HasIssues = true
+			continue
+		}
+		cart.ItemCount += l.Quantity
+		cart.Subtotal += l.LineTotal
+	}
+	return cart, nil
+}
+
+// lockCartItemsTx ล็อกตะกร้าที่จะ checkout แล้วคืนรายการในรูปแบบเดียวกับ CreateOrderReq.Items
+// ตะกร้าต้องยังเปิดอยู่ และเป็นของลูกค้าคนนี้ (หรือเป็นตะกร้า anonymous ที่รู้ id)
+func lockCartItemsTx(ctx context.Context, tx pgx.Tx, cartID, customerID string) ([]models.CreateOrderItemReq, error) {
+	var found bool
+	err := tx.QueryRow(ctx, `
+		SELECT TRUE FROM carts
+		WHERE id = $1 AND status = 'open' AND (customer_id = $2 OR customer_id IS NULL)
+		FOR UPDATE
+	`, cartID, customerID).Scan(&found)
+	if err == pgx.ErrNoRows {
+		return nil, errCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT product_id, variant_id, quantity FROM cart_items WHERE cart_id = $1 ORDER BY id`, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.CreateOrderItemReq
+	for rows.Next() {
+		var it models.CreateOrderItemReq
+		if err := rows.Scan(&it.ProductID, &it.VariantID, &it.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errCartEmpty
+	}
+	return items, nil
+}
+
+// ====================
+// ดูตะกร้าปัจจุบัน (ราคา/สต็อกล่าสุด)
+// GET /cart   (login หรือไม่ก็ได้)
+// ====================
+func GetCart(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	cartID, err := currentCartID(ctx, conn, c, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+	}
+	cart, err := loadCart(ctx, conn, cartID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+	}
+	return c.JSON(cart)
+}
+
+// ====================
+// หยิบสินค้าใส่ตะกร้า (มีอยู่แล้วจะบวกจำนวนเพิ่ม)
+// POST /cart/items   body: { "product_id": "...", "variant_id": 1, "quantity": 1 }
+// ====================
+func AddCartItem(c *fiber.Ctx) error {
+	var req models.CartItemReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if (req.ProductID == "" && req.VariantID == nil && req.SKU == "") || req.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid item"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	l, err := resolveOrderLine(ctx, conn, models.CreateOrderItemReq{
+		ProductID: req.ProductID,
+		VariantID: req.VariantID,
+		SKU:       req.SKU,
+		Quantity:  req.Quantity,
+	}, false)
+	switch err {
+	case nil:
+	case errProductNotFound, errVariantNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errVariantRequired:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load product failed"})
+	}
+
+	cartID, err := currentCartID(ctx, conn, c, true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create cart failed"})
+	}
+
+	if _, err := conn.Exec(ctx, `
+		INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, price_at_add)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (cart_id, product_id, COALESCE(variant_id, 0))
+		DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity, updated_at = NOW()
+	`, cartID, l.ProductID, l.VariantID, l.Qty, l.Price); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "add item failed"})
+	}
+	conn.Exec(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID)
+
+	cart, err := loadCart(ctx, conn, cartID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(cart)
+}
+
+// ====================
+// แก้จำนวนของบรรทัดในตะกร้า (quantity = 0 คือลบ)
+// PUT /cart/items/:item_id   body: { "quantity": 2 }
+// ====================
+func UpdateCartItem(c *fiber.Ctx) error {
+	var req models.UpdateCartItemReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if req.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must not be negative"})
+	}
+
+	return updateCartHandler(c, func(ctx context.Context, conn *pgx.Conn, cartID string) (int64, error) {
+		if req.Quantity == 0 {
+			res, err := conn.Exec(ctx, `DELETE FROM cart_items WHERE id = $1 AND cart_id = $2`, c.Params("item_id"), cartID)
+			return res.RowsAffected(), err
+		}
+		res, err := conn.Exec(ctx, `
+			UPDATE cart_items SET quantity = $1, updated_at = NOW()
+			WHERE id = $2 AND cart_id = $3
+		`, req.Quantity, c.Params("item_id"), cartID)
+		return res.RowsAffected(), err
+	})
+}
+
+// ====================
+// ลบบรรทัดในตะกร้า
+// DELETE /cart/items/:item_id
+// ====================
+func DeleteCartItem(c *fiber.Ctx) error {
+	return updateCartHandler(c, func(ctx context.Context, conn *pgx.Conn, cartID string) (int64, error) {
+		res, err := conn.Exec(ctx, `DELETE FROM cart_items WHERE id = $1 AND cart_id = $2`, c.Params("item_id"), cartID)
+		return res.RowsAffected(), err
+	})
+}
+
+// ====================
+// ล้างตะกร้า
+// DELETE /cart
+// ====================
+func ClearCart(c *fiber.Ctx) error {
+	return updateCartHandler(c, func(ctx context.Context, conn *pgx.Conn, cartID string) (int64, error) {
+		_, err := conn.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1`, cartID)
+		// ตะกร้าว่างอยู่แล้วก็ถือว่าสำเร็จ
+		return 1, err
+	})
+}
+
+// updateCartHandler ส่วนที่ใช้ร่วมกันของการแก้ไขตะกร้า; apply คืนจำนวนแถวที่ถูกแก้ (0 = ไม่พบบรรทัดนั้น)
+func updateCartHandler(c *fiber.Ctx, apply func(ctx context.Context, conn *pgx.Conn, cartID string) (int64, error)) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	cartID, err := currentCartID(ctx, conn, c, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+	}
+	if cartID == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "cart not found"})
+	}
+
+	n, err := apply(ctx, conn, cartID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "update cart failed"})
+	}
+	if n == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "cart item not found"})
+	}
+	conn.Exec(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID)
+
+	cart, err := loadCart(ctx, conn, cartID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+	}
+	return c.JSON(cart)
+}
+
+// ====================
+// รวมตะกร้า anonymous เข้าตะกร้าลูกค้า (สำหรับ client ที่ไม่ได้ใช้ cookie)
+// POST /cart/merge   body: { "cart_id": "..." }
+// ====================
+func MergeCart(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	var req models.MergeCartReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+		}
+	}
+	anonID := req.CartID
+	if anonID == "" {
+		anonID = c.Cookies(cartCookieName)
+	}
+	if anonID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cart_id is required"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	cartID, err := mergeCartTx(ctx, tx, anonID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "merge cart failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	clearCartCookie(c)
+
+	cart, err := loadCart(ctx, conn, cartID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+	}
+	return c.JSON(cart)
+}
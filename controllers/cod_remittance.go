@@ -0,0 +1,465 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"dog/condb"
+	"dog/models"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// ขนาดไฟล์ statement สูงสุด (ต่ำกว่า BodyLimit 4 MB ของ fiber)
+const maxCODStatementBytes = 3 << 20
+
+// ชื่อหัวคอลัมน์ที่ขนส่งแต่ละเจ้าใช้ (ตัวพิมพ์เล็ก ตัดช่องว่างแล้ว)
+var codHeaderAliases = map[string][]string{
+	"tracking": {"tracking_no", "tracking", "tracking number", "tracking_number", "awb", "เลขพัสดุ", "หมายเลขพัสดุ"},
+	"amount":   {"cod_amount", "cod", "cod amount", "amount", "ยอด cod", "ยอดเก็บเงิน", "ยอดเก็บเงินปลายทาง"},
+	"fee":      {"fee", "cod_fee", "cod fee", "ค่าธรรมเนียม", "ค่าธรรมเนียม cod"},
+}
+
+// COD_REMIT_GRACE_DAYS จำนวนวันหลังส่งของที่ขนส่งควรโอนเงินแล้ว (default 7) ใช้หาออเดอร์ที่ขาดหายในรายงาน
+func codRemitGraceDays() int {
+	if n, err := strconv.Atoi(os.Getenv("COD_REMIT_GRACE_DAYS")); err == nil && n >= 0 {
+		return n
+	}
+	return 7
+}
+
+// codLine หนึ่งบรรทัดใน statement
+type codLine struct {
+	LineNo     int
+	TrackingNo string
+	Amount     models.Money
+	Fee        models.Money
+}
+
+// report บรรทัดที่ยังไม่ได้จับคู่กับออเดอร์ (order_id/expected/payment_id เป็น NULL)
+func (l codLine) report(result string) models.CODRemittanceLine {
+	return models.CODRemittanceLine{LineNo: l.LineNo, TrackingNo: l.TrackingNo, CODAmount: l.Amount, Fee: l.Fee, Result: result}
+}
+
+// codLineArgs ค่าสำหรับ INSERT cod_remittance_lines ตามลำดับคอลัมน์
+func codLineArgs(remittanceID int64, line models.CODRemittanceLine) []interface{} {
+	return []interface{}{remittanceID, line.LineNo, line.TrackingNo, line.CODAmount, line.Fee, line.OrderID, line.Expected, line.Result, line.PaymentID}
+}
+
+// parseCODStatement อ่าน CSV ของขนส่ง ต้องมีคอลัมน์เลขพัสดุและยอด COD; ค่าธรรมเนียมไม่บังคับ
+// บรรทัดไหนอ่านไม่ได้ทั้งไฟล์ไม่ผ่าน (statement ต้องครบทั้งรอบ)
+func parseCODStatement(data []byte) ([]codLine, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.New("statement is empty or not a CSV file")
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for field, aliases := range codHeaderAliases {
+			for _, a := range aliases {
+				if _, ok := col[field]; !ok && h == a {
+					col[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := col["tracking"]; !ok {
+		return nil, errors.New("statement has no tracking number column")
+	}
+	if _, ok := col["amount"]; !ok {
+		return nil, errors.New("statement has no COD amount column")
+	}
+
+	cell := func(rec []string, field string) string {
+		i, ok := col[field]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+	money := func(s string) (models.Money, error) {
+		s = strings.NewReplacer(",", "", "฿", "", " ", "").Replace(s)
+		if s == "" {
+			return 0, nil
+		}
+		return models.ParseMoney(s)
+	}
+
+	var lines []codLine
+	for lineNo := 2; ; lineNo++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		tracking := strings.ToUpper(cell(rec, "tracking"))
+		if tracking == "" {
+			continue // บรรทัดว่าง/บรรทัดสรุปท้ายไฟล์
+		}
+		amount, err := money(cell(rec, "amount"))
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("line %d: invalid COD amount %q", lineNo, cell(rec, "amount"))
+		}
+		fee, err := money(cell(rec, "fee"))
+		if err != nil || fee < 0 {
+			return nil, fmt.Errorf("line %d: invalid fee %q", lineNo, cell(rec, "fee"))
+		}
+		lines = append(lines, codLine{LineNo: lineNo, TrackingNo: tracking, Amount: amount, Fee: fee})
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("statement has no lines")
+	}
+	return lines, nil
+}
+
+// matchCODLineTx จับคู่บรรทัดกับออเดอร์ด้วยเลขพัสดุ (ขนส่งเดียวกันก่อน) แล้วลงรับเงินถ้าออเดอร์ยังค้างชำระ
+func matchCODLineTx(ctx context.Context, tx pgx.Tx, carrier string, l codLine, ref, staffID string) (models.CODRemittanceLine, error) {
+	out := l.report("")
+
+	var orderID int64
+	err := tx.QueryRow(ctx, `
+		SELECT order_id FROM shipments
+		WHERE UPPER(tracking_no) = $1
+		ORDER BY (LOWER(carrier) = LOWER($2)) DESC, id DESC
+		LIMIT 1
+	`, l.TrackingNo, carrier).Scan(&orderID)
+	if err == pgx.ErrNoRows {
+		out.Result = models.CODNotFound
+		return out, nil
+	}
+	if err != nil {
+		return out, err
+	}
+	out.OrderID = &orderID
+
+	o, err := lockOrderTx(ctx, tx, strconv.FormatInt(orderID, 10))
+	if err != nil {
+		return out, err
+	}
+	var method string
+	var expected models.Money
+	if err := tx.QueryRow(ctx, `SELECT payment_method, GREATEST(total - paid_amount, 0) FROM orders WHERE id = $1`, o.ID).
+		Scan(&method, &expected); err != nil {
+		return out, err
+	}
+	out.Expected = &expected
+	switch {
+	case strings.ToUpper(method) != models.PayMethodCOD:
+		out.Result = models.CODNotCOD
+		return out, nil
+	case expected == 0:
+		out.Result = models.CODAlreadyPaid
+		return out, nil
+	case l.Amount < expected:
+		out.Result = models.CODShort
+	case l.Amount > expected:
+		out.Result = models.CODOver
+	default:
+		out.Result = models.CODMatched
+	}
+
+	// ลงยอดที่ขนส่งเก็บจากลูกค้า (ค่าธรรมเนียมเป็นต้นทุนร้าน ไม่ใช่การคืนเงินลูกค้า)
+	paymentID, err := insertPaymentTx(ctx, tx, paymentEntry{
+		OrderID: o.ID, Method: models.PayMethodCOD, Amount: l.Amount, Status: models.PaymentSucceeded,
+		Provider: carrier, ProviderRef: l.TrackingNo, Note: ref, CreatedBy: staffID,
+	})
+	if err != nil {
+		return out, err
+	}
+	out.PaymentID = &paymentID
+	_, err = syncOrderPaymentTx(ctx, tx, o.ID)
+	return out, err
+}
+
+// ====================
+// นำเข้า statement COD ของขนส่ง แล้วจับคู่ออเดอร์ด้วยเลขพัสดุ
+// POST /admin/cod-remittances   multipart: file (CSV ≤ 3MB), carrier (required), statement_ref, remitted_on (YYYY-MM-DD), dry_run=1
+// dry_run = จับคู่ให้ดูผลแต่ไม่บันทึก
+// ====================
+func ImportCODRemittance(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	carrier := strings.TrimSpace(c.FormValue("carrier"))
+	if carrier == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "carrier is required"})
+	}
+	statementRef := strings.TrimSpace(c.FormValue("statement_ref"))
+	remittedOn := time.Now().In(orderSearchZone)
+	if v := strings.TrimSpace(c.FormValue("remitted_on")); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, orderSearchZone)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "remitted_on must be YYYY-MM-DD"})
+		}
+		remittedOn = d
+	}
+	dryRun := c.FormValue("dry_run") == "1" || c.FormValue("dry_run") == "true"
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "statement file is required (multipart field \"file\")"})
+	}
+	if fh.Size > maxCODStatementBytes {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("statement must be at most %d MB", maxCODStatementBytes>>20)})
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot read statement file"})
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxCODStatementBytes+1))
+	f.Close()
+	if err != nil || len(data) > maxCODStatementBytes {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot read statement file"})
+	}
+	lines, err := parseCODStatement(data)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	sum := sha256.Sum256(data)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+	if err := setActorTx(ctx, tx, staffID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var rep models.CODRemittanceReport
+	err = tx.QueryRow(ctx, `
+		INSERT INTO cod_remittances (carrier, statement_ref, remitted_on, file_name, sha256, imported_by)
+		VALUES ($1, NULLIF($2,''), $3, $4, $5, NULLIF($6,''))
+		ON CONFLICT (sha256) DO NOTHING
+		RETURNING id, created_at
+	`, carrier, statementRef, remittedOn.Format("2006-01-02"), fh.Filename, hex.EncodeToString(sum[:]), staffID).
+		Scan(&rep.ID, &rep.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "this statement file has already been imported"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ref := "COD " + carrier
+	if statementRef != "" {
+		ref += " " + statementRef
+	}
+	seen := map[string]bool{}
+	rep.Lines = []models.CODRemittanceLine{}
+	for _, l := range lines {
+		var line models.CODRemittanceLine
+		if seen[l.TrackingNo] {
+			line = l.report(models.CODDuplicate)
+		} else {
+			seen[l.TrackingNo] = true
+			line, err = matchCODLineTx(ctx, tx, carrier, l, ref, staffID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("line %d: %v", l.LineNo, err)})
+			}
+		}
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO cod_remittance_lines (remittance_id, line_no, tracking_no, cod_amount, fee, order_id, expected, result, payment_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, codLineArgs(rep.ID, line)...).Scan(&line.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		rep.Lines = append(rep.Lines, line)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE cod_remittances r
+		SET line_count = s.n, matched_count = s.matched, cod_total = s.cod, fee_total = s.fee, expected_total = s.expected
+		FROM (
+			SELECT COUNT(*) AS n, COUNT(*) FILTER (WHERE payment_id IS NOT NULL) AS matched,
+			       COALESCE(SUM(cod_amount), 0) AS cod, COALESCE(SUM(fee), 0) AS fee,
+			       COALESCE(SUM(expected) FILTER (WHERE payment_id IS NOT NULL), 0) AS expected
+			FROM cod_remittance_lines WHERE remittance_id = $1
+		) s
+		WHERE r.id = $1
+	`, rep.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := loadCODReport(ctx, tx, &rep); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if dryRun {
+		// rollback ด้วย defer; id ที่ได้เป็นแค่ตัวอย่าง
+		return c.JSON(fiber.Map{"dry_run": true, "report": rep})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rep)
+}
+
+const codRemittanceColumns = `id, carrier, statement_ref, remitted_on, file_name, line_count, matched_count, cod_total, fee_total,
+	expected_total, imported_by, created_at`
+
+func codRemittanceScanDest(r *models.CODRemittance) []interface{} {
+	return []interface{}{&r.ID, &r.Carrier, &r.StatementRef, &r.RemittedOn, &r.FileName, &r.LineCount, &r.MatchedCount,
+		&r.CODTotal, &r.FeeTotal, &r.ExpectedTotal, &r.ImportedBy, &r.CreatedAt}
+}
+
+// loadCODReport เติมหัวรอบโอน, บรรทัด, รายการที่ต้องตรวจ และออเดอร์ที่ยังไม่มียอดโอน
+func loadCODReport(ctx context.Context, q querier, rep *models.CODRemittanceReport) error {
+	if err := q.QueryRow(ctx, `SELECT `+codRemittanceColumns+` FROM cod_remittances WHERE id = $1`, rep.ID).
+		Scan(codRemittanceScanDest(&rep.CODRemittance)...); err != nil {
+		return err
+	}
+	rep.NetTotal = rep.CODTotal - rep.FeeTotal
+
+	rows, err := q.Query(ctx, `
+		SELECT id, line_no, tracking_no, cod_amount, fee, order_id, expected, result, payment_id
+		FROM cod_remittance_lines WHERE remittance_id = $1 ORDER BY line_no
+	`, rep.ID)
+	if err != nil {
+		return err
+	}
+	rep.Lines = []models.CODRemittanceLine{}
+	rep.Issues = []models.CODRemittanceLine{}
+	rep.Short, rep.Over = 0, 0
+	for rows.Next() {
+		var l models.CODRemittanceLine
+		if err := rows.Scan(&l.ID, &l.LineNo, &l.TrackingNo, &l.CODAmount, &l.Fee, &l.OrderID, &l.Expected, &l.Result, &l.PaymentID); err != nil {
+			rows.Close()
+			return err
+		}
+		rep.Lines = append(rep.Lines, l)
+		if l.Result == models.CODMatched {
+			continue
+		}
+		rep.Issues = append(rep.Issues, l)
+		switch {
+		case l.Result == models.CODShort && l.Expected != nil:
+			rep.Short += *l.Expected - l.CODAmount
+		case l.Result == models.CODOver && l.Expected != nil:
+			rep.Over += l.CODAmount - *l.Expected
+		case l.Result == models.CODAlreadyPaid:
+			rep.Over += l.CODAmount
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// ออเดอร์ COD ที่ส่งกับขนส่งนี้ก่อน (remitted_on - grace) แต่ยังค้างชำระ = ขนส่งอาจตกหล่น
+	cutoff := rep.RemittedOn.AddDate(0, 0, -codRemitGraceDays())
+	rows, err = q.Query(ctx, `
+		SELECT DISTINCT ON (o.id) o.id, s.tracking_no, s.shipped_at, o.total - o.paid_amount
+		FROM orders o
+		JOIN shipments s ON s.order_id = o.id
+		WHERE UPPER(o.payment_method) = $1 AND o.status <> $2 AND o.paid_amount < o.total
+		  AND LOWER(s.carrier) = LOWER($3) AND s.shipped_at < $4
+		ORDER BY o.id, s.shipped_at
+		LIMIT 500
+	`, models.PayMethodCOD, models.OrderStatusCancelled, rep.Carrier, cutoff)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	rep.Missing = []models.CODMissingOrder{}
+	for rows.Next() {
+		var m models.CODMissingOrder
+		if err := rows.Scan(&m.OrderID, &m.TrackingNo, &m.ShippedAt, &m.Outstanding); err != nil {
+			return err
+		}
+		rep.Missing = append(rep.Missing, m)
+	}
+	return rows.Err()
+}
+
+// ====================
+// รายการรอบโอน COD ที่นำเข้าแล้ว (ใหม่สุดก่อน)
+// GET /admin/cod-remittances?carrier=kerry&limit=50
+// ====================
+func GetCODRemittances(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT `+codRemittanceColumns+` FROM cod_remittances
+		WHERE $1 = '' OR LOWER(carrier) = LOWER($1)
+		ORDER BY id DESC
+		LIMIT $2
+	`, strings.TrimSpace(c.Query("carrier")), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	list := []models.CODRemittance{}
+	for rows.Next() {
+		var r models.CODRemittance
+		if err := rows.Scan(codRemittanceScanDest(&r)...); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		r.NetTotal = r.CODTotal - r.FeeTotal
+		list = append(list, r)
+	}
+	return c.JSON(list)
+}
+
+// ====================
+// รายงานรอบโอน COD: บรรทัดทั้งหมด, ยอดขาด/เกิน/ไม่พบออเดอร์ และออเดอร์ที่ยังไม่มียอดโอน
+// GET /admin/cod-remittances/:remittance_id
+// ====================
+func GetCODRemittance(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("remittance_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "remittance not found"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rep := models.CODRemittanceReport{}
+	rep.ID = id
+	err = loadCODReport(context.Background(), conn, &rep)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "remittance not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rep)
+}
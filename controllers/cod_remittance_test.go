@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"dog/models"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+// encodeArgs เข้ารหัสค่าตามลำดับที่ pgx ใช้ (BinaryEncoder → TextEncoder ก่อนเช็ค nil pointer)
+// คืนค่า false ถ้าค่านั้นเป็น NULL
+func encodeArgs(t *testing.T, args []interface{}) []bool {
+	t.Helper()
+	ci := pgtype.NewConnInfo()
+	present := make([]bool, len(args))
+	for i, a := range args {
+		var (
+			buf []byte
+			err error
+		)
+		switch v := a.(type) {
+		case pgtype.BinaryEncoder:
+			buf, err = v.EncodeBinary(ci, nil)
+		case pgtype.TextEncoder:
+			buf, err = v.EncodeText(ci, nil)
+		default:
+			// pgx ส่ง pointer ที่เป็น nil เป็น NULL
+			if rv := reflect.ValueOf(a); a != nil && !(rv.Kind() == reflect.Ptr && rv.IsNil()) {
+				buf = []byte{}
+			}
+		}
+		if err != nil {
+			t.Fatalf("arg %d (%T): %v", i, a, err)
+		}
+		present[i] = buf != nil
+	}
+	return present
+}
+
+func TestCODLineArgsUnmatched(t *testing.T) {
+	l := codLine{LineNo: 3, TrackingNo: "TH0001", Amount: 59000, Fee: 1500}
+	tests := []struct {
+		name   string
+		result string
+	}{
+		{"tracking number not found", models.CODNotFound},
+		{"duplicate line in statement", models.CODDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := l.report(tt.result)
+			args := codLineArgs(7, line)
+			present := encodeArgs(t, args)
+			// order_id, expected, payment_id ต้องเป็น NULL
+			for _, i := range []int{5, 6, 8} {
+				if present[i] {
+					t.Errorf("arg %d (%T) should encode as NULL", i, args[i])
+				}
+			}
+			if !present[3] || !present[4] {
+				t.Error("cod_amount and fee must be sent")
+			}
+		})
+	}
+}
+
+func TestParseCODStatement(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []codLine
+		wantErr bool
+	}{
+		{
+			name: "english headers with fee",
+			csv:  "Tracking_No,COD_Amount,Fee\nth001,\"1,250.50\",15\n,,\nTH002,฿300,\n",
+			want: []codLine{
+				{LineNo: 2, TrackingNo: "TH001", Amount: 125050, Fee: 1500},
+				{LineNo: 4, TrackingNo: "TH002", Amount: 30000},
+			},
+		},
+		{
+			name: "thai headers with BOM",
+			csv:  "\xef\xbb\xbfเลขพัสดุ,ยอดเก็บเงิน\nKEX1,99.995\n",
+			want: []codLine{{LineNo: 2, TrackingNo: "KEX1", Amount: 10000}},
+		},
+		{name: "empty file", csv: "", wantErr: true},
+		{name: "no tracking column", csv: "amount\n100\n", wantErr: true},
+		{name: "no amount column", csv: "tracking\nTH1\n", wantErr: true},
+		{name: "only header", csv: "tracking,amount\n", wantErr: true},
+		{name: "zero amount", csv: "tracking,amount\nTH1,0\n", wantErr: true},
+		{name: "bad amount", csv: "tracking,amount\nTH1,abc\n", wantErr: true},
+		{name: "negative fee", csv: "tracking,amount,fee\nTH1,10,-1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCODStatement([]byte(tt.csv))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCODStatement: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lines %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"dog/models"
 	"dog/utils"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 
@@ -235,13 +236,32 @@ func LoginCustomer(c *fiber.Ctx) error {
 	}
 
 	// สร้าง JWT token (ฟังก์ชัน utils.GenerateJWTToken)
-	token, err := utils.GenerateJWTToken(cus.CustomerID)
+	token, err := utils.GenerateJWTToken(cus.CustomerID, models.RoleCustomer)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Token generation failed"})
 	}
 
 	utils.SetJWTCookie(c, token)
 
+	// รวมตะกร้าที่หยิบไว้ก่อน login (cookie cart_id) เข้าตะกร้าของลูกค้า; พลาดก็ยัง login ได้
+	var cartID string
+	if anonID := c.Cookies(cartCookieName); anonID != "" {
+		ctx := context.Background()
+		if tx, err := conn.Begin(ctx); err == nil {
+			id, err := mergeCartTx(ctx, tx, anonID, cus.CustomerID)
+			if err == nil {
+				err = tx.Commit(ctx)
+			}
+			tx.Rollback(ctx)
+			if err != nil {
+				log.Println("login: merge cart failed:", err)
+			} else {
+				cartID = id
+				clearCartCookie(c)
+			}
+		}
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Login successful",
 		"customer": fiber.Map{
@@ -250,6 +270,7 @@ func LoginCustomer(c *fiber.Ctx) error {
 			"lastname":    cus.LastName,
 			"email":       cus.Email,
 		},
-		"token": token,
+		"token":   token,
+		"cart_id": cartID,
 	})
 }
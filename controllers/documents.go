@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"dog/pdfdoc"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shopInfo ข้อมูลร้านจาก env (SHOP_NAME, SHOP_ADDRESS, SHOP_TAX_ID, SHOP_BRANCH, SHOP_PHONE)
+func shopInfo() pdfdoc.Shop {
+	s := pdfdoc.Shop{
+		Name:    os.Getenv("SHOP_NAME"),
+		Address: os.Getenv("SHOP_ADDRESS"),
+		TaxID:   os.Getenv("SHOP_TAX_ID"),
+		Branch:  os.Getenv("SHOP_BRANCH"),
+		Phone:   os.Getenv("SHOP_PHONE"),
+	}
+	if s.Name == "" {
+		s.Name = "LekShop"
+	}
+	return s
+}
+
+// vatBreakdown ใส่ส่วนภาษีจาก VAT ที่บันทึกไว้ ณ ตอนขาย; total รวม VAT แล้วเสมอ
+func vatBreakdown(d *pdfdoc.Document, rate int, vat models.Money) {
+	d.VATRate = rate
+	d.VAT = vat
+	d.VATBase = d.Total - vat
+}
+
+func documentKind(c *fiber.Ctx, def string) (string, bool) {
+	switch kind := c.Query("type", def); kind {
+	case pdfdoc.KindReceipt, pdfdoc.KindTaxInvoice:
+		return kind, true
+	default:
+		return "", false
+	}
+}
+
+func taxBuyerDoc(b *models.TaxBuyer) *pdfdoc.Buyer {
+	return &pdfdoc.Buyer{Name: b.Name, Address: b.Address, TaxID: b.TaxID, Branch: b.Branch}
+}
+
+// pdfUnavailable ตอบ 503 เมื่อไม่มีฟอนต์; เรียกก่อนแตะฐานข้อมูลเพื่อไม่ให้ออกเลขที่ใบกำกับภาษีโดยไม่ได้เอกสาร
+func pdfUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "PDF fonts are not installed"})
+}
+
+func sendPDF(c *fiber.Ctx, d pdfdoc.Document) error {
+	pdf, err := pdfdoc.Render(d)
+	if err == pdfdoc.ErrFontMissing {
+		return pdfUnavailable(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.pdf"`, d.Number))
+	return c.Send(pdf)
+}
+
+// ====================
+// ใบเสร็จ / ใบกำกับภาษีของออเดอร์
+// GET /orders/:order_id/invoice.pdf?type=tax_invoice|receipt   (default tax_invoice)
+// ลูกค้าดูได้เฉพาะออเดอร์ตัวเอง, พนักงานดูได้ทุกออเดอร์
+// ใบกำกับภาษีออกได้เมื่อชำระเงินแล้ว; ขอครั้งแรกจะได้เลขที่ใบกำกับภาษี ครั้งต่อไปใช้เลขเดิม
+// ====================
+func GetOrderInvoicePDF(c *fiber.Ctx) error {
+	kind, ok := documentKind(c, pdfdoc.KindTaxInvoice)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "type must be receipt or tax_invoice"})
+	}
+	if pdfdoc.CheckFonts() != nil {
+		return pdfUnavailable(c)
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var o models.Order
+	err = scanOrder(conn.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, c.Params("order_id")), &o)
+	if err != nil || !canAccessCustomer(c, o.UserID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if o.Status == models.OrderStatusCancelled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "order is cancelled"})
+	}
+
+	number, issuedAt := fmt.Sprintf("RC%06d", o.ID), o.CreatedAt
+	if kind == pdfdoc.KindTaxInvoice {
+		if o.PaymentStatus != models.PayStatusPaid {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "tax invoice is issued after payment"})
+		}
+		number, issuedAt, err = issueTaxInvoice(ctx, conn, "orders", "id", o.ID)
+		if err == errTaxInvoiceUnavailable {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "issue tax invoice failed"})
+		}
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT name, variant, price, quantity FROM order_items WHERE order_id = $1 ORDER BY id
+	`, o.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	d := pdfdoc.Document{
+		Kind:          kind,
+		Number:        number,
+		IssuedAt:      issuedAt,
+		Reference:     fmt.Sprintf("ออเดอร์ #%d", o.ID),
+		Shop:          shopInfo(),
+		Subtotal:      o.Subtotal,
+		Shipping:      o.ShippingFee,
+		Total:         o.Total,
+		PaymentMethod: o.PaymentMethod,
+	}
+	for rows.Next() {
+		var (
+			name    string
+			variant *string
+			price   models.Money
+			qty     int
+		)
+		if err := rows.Scan(&name, &variant, &price, &qty); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if variant != nil && *variant != "" {
+			name += " (" + *variant + ")"
+		}
+		d.Lines = append(d.Lines, pdfdoc.Line{Name: name, Qty: qty, UnitPrice: price, Amount: price.Mul(qty)})
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	rows.Close()
+
+	// บัตรของขวัญที่ซื้อในออเดอร์: แสดงเป็นรายการแต่ไม่อยู่ในฐานภาษี
+	if o.GiftCardTotal > 0 {
+		cards, err := conn.Query(ctx, `SELECT initial_amount FROM gift_cards WHERE order_id = $1 ORDER BY id`, o.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		defer cards.Close()
+		for cards.Next() {
+			var amount models.Money
+			if err := cards.Scan(&amount); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			}
+			d.Lines = append(d.Lines, pdfdoc.Line{Name: "บัตรของขวัญ", Qty: 1, UnitPrice: amount, Amount: amount})
+		}
+		if err := cards.Err(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		d.Subtotal += o.GiftCardTotal
+	}
+	vatBreakdown(&d, o.VATRate, o.VATAmount)
+	d.VATBase -= o.GiftCardTotal
+
+	switch {
+	case o.TaxBuyer != nil:
+		d.Buyer = taxBuyerDoc(o.TaxBuyer)
+	case o.ShippingAddress != nil:
+		a := o.ShippingAddress
+		d.Buyer = &pdfdoc.Buyer{
+			Name:    a.Recipient,
+			Address: strings.Join([]string{a.HouseNo, a.Subdistrict, a.District, a.Province, a.Postcode}, " "),
+		}
+	}
+	return sendPDF(c, d)
+}
+
+// ====================
+// ใบเสร็จของการขายหน้าร้าน (POS)
+// GET /sales/:sale_id/receipt.pdf?type=receipt|tax_invoice   (default receipt)
+// type=tax_invoice ขอครั้งแรกจะได้เลขที่ใบกำกับภาษี (ชุดเดียวกับออเดอร์)
+// ====================
+func GetSaleReceiptPDF(c *fiber.Ctx) error {
+	kind, ok := documentKind(c, pdfdoc.KindReceipt)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "type must be receipt or tax_invoice"})
+	}
+	if pdfdoc.CheckFonts() != nil {
+		return pdfUnavailable(c)
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	var (
+		s       models.Sale
+		name    string
+		options map[string]string
+	)
+	err = conn.QueryRow(context.Background(), `
+		SELECT s.sale_id, s.quantity, s.total_price, s.vat_rate, s.vat_amount, s.vat_inclusive, s.tax_buyer, s.sale_date, s.voided_at,
+		       p.name, v.options
+		FROM sales s
+		JOIN products p ON p.product_id = s.product_id
+		LEFT JOIN product_variants v ON v.id = s.variant_id
+		WHERE s.sale_id = $1
+	`, c.Params("sale_id")).Scan(&s.SaleID, &s.Quantity, &s.TotalPrice, &s.VATRate, &s.VATAmount, &s.VATInclusive, &s.TaxBuyer, &s.SaleDate, &s.VoidedAt,
+		&name, &options)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Sale not found"})
+	}
+	// การขายที่ void แล้วไม่ออกเอกสาร (โดยเฉพาะเลขที่ใบกำกับภาษีที่ต้องเรียงไม่เว้นช่อง)
+	if s.VoidedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "sale is voided"})
+	}
+	if label := variantLabel(options); label != "" {
+		name += " (" + label + ")"
+	}
+
+	number, issuedAt := s.SaleID, s.SaleDate
+	if kind == pdfdoc.KindTaxInvoice {
+		number, issuedAt, err = issueTaxInvoice(context.Background(), conn, "sales", "sale_id", s.SaleID)
+		if err == errTaxInvoiceUnavailable {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "issue tax invoice failed"})
+		}
+	}
+
+	// ราคาไม่รวม VAT: รายการแสดงยอดก่อน VAT แล้วบวก VAT ในส่วนสรุป
+	amount := s.TotalPrice
+	if !s.VATInclusive {
+		amount -= s.VATAmount
+	}
+	qty := s.Quantity
+	if qty < 1 {
+		qty = 1
+	}
+	d := pdfdoc.Document{
+		Kind:     kind,
+		Number:   number,
+		IssuedAt: issuedAt,
+		Shop:     shopInfo(),
+		Lines: []pdfdoc.Line{{
+			Name:      name,
+			Qty:       s.Quantity,
+			UnitPrice: amount.MulRatio(1, int64(qty)),
+			Amount:    amount,
+		}},
+		Subtotal: amount,
+		Total:    s.TotalPrice,
+	}
+	vatBreakdown(&d, s.VATRate, s.VATAmount)
+	if s.TaxBuyer != nil {
+		d.Buyer = taxBuyerDoc(s.TaxBuyer)
+	}
+	return sendPDF(c, d)
+}
@@ -0,0 +1,885 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"dog/condb"
+	"dog/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	errExportKind        = errors.New("kind must be orders or sales")
+	errExportFormat      = errors.New("format must be csv or xlsx")
+	errExportGranularity = errors.New("granularity must be header or lines")
+	errExportTooManyRows = errors.New("too many rows for one xlsx sheet; use csv")
+)
+
+// ไฟล์ export เริ่มด้วย BOM เพื่อให้ Excel เปิดภาษาไทยใน CSV ได้ถูกต้อง
+const utf8BOM = "\xEF\xBB\xBF"
+
+// แถวสูงสุดของหนึ่ง sheet ใน xlsx (รวมหัวตาราง)
+const xlsxMaxRows = 1048576
+
+type exportType int
+
+const (
+	exportText exportType = iota
+	exportInt
+	exportMoney
+	exportTime
+	exportBool
+)
+
+// exportField หนึ่งคอลัมน์ในไฟล์: หัวคอลัมน์ + นิพจน์ SQL + ชนิดข้อมูล
+type exportField struct {
+	title string
+	expr  string
+	typ   exportType
+}
+
+// exportSpec สิ่งที่จะ export; params = ตัวกรองแบบเดียวกับหน้าค้นหา
+type exportSpec struct {
+	Kind        string
+	Format      string
+	Granularity string
+	Params      map[string]string
+}
+
+func (s *exportSpec) normalize() error {
+	if s.Format == "" {
+		s.Format = models.ExportFormatCSV
+	}
+	if s.Granularity == "" {
+		s.Granularity = models.ExportHeader
+	}
+	if s.Params == nil {
+		s.Params = map[string]string{}
+	}
+	switch {
+	case s.Kind != models.ExportKindOrders && s.Kind != models.ExportKindSales:
+		return errExportKind
+	case s.Format != models.ExportFormatCSV && s.Format != models.ExportFormatXLSX:
+		return errExportFormat
+	case s.Granularity != models.ExportHeader && s.Granularity != models.ExportLines:
+		return errExportGranularity
+	}
+	return nil
+}
+
+// fileName ชื่อไฟล์ตอนดาวน์โหลด เช่น orders-lines-20250131-150405.xlsx
+func (s exportSpec) fileName(at time.Time) string {
+	return fmt.Sprintf("%s-%s-%s.%s", s.Kind, s.Granularity, at.In(orderSearchZone).Format("20060102-150405"), s.Format)
+}
+
+func (s exportSpec) contentType() string {
+	if s.Format == models.ExportFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ===== คอลัมน์ของแต่ละแบบ =====
+
+// ชื่อลูกค้า: จากบัญชีลูกค้า หรือชื่อผู้รับ (guest)
+const exportCustomerJoin = `
+	LEFT JOIN LATERAL (
+		SELECT NULLIF(TRIM(COALESCE(firstname,'') || ' ' || COALESCE(lastname,'')), '') AS name, email, phone
+		FROM customer WHERE customer_id = o.user_id
+	) cu ON TRUE`
+
+var orderHeaderFields = []exportField{
+	{"order_id", "o.id", exportInt},
+	{"created_at", "o.created_at", exportTime},
+	{"user_id", "o.user_id", exportText},
+	{"customer_name", "COALESCE(cu.name, o.shipping_address->>'recipient')", exportText},
+	{"email", "COALESCE(cu.email, o.guest_email)", exportText},
+	{"phone", "COALESCE(cu.phone, o.guest_phone)", exportText},
+	{"status", "o.status", exportText},
+	{"payment_method", "o.payment_method", exportText},
+	{"payment_status", "o.payment_status", exportText},
+	{"payment_ref", "o.payment_ref", exportText},
+	{"shipping_method", "o.shipping_method", exportText},
+	{"subtotal", "o.subtotal", exportMoney},
+	{"shipping_fee", "o.shipping_fee", exportMoney},
+	{"vat_rate", "o.vat_rate", exportInt},
+	{"vat_amount", "o.vat_amount", exportMoney},
+	{"total", "o.total", exportMoney},
+	{"paid_amount", "o.paid_amount", exportMoney},
+	{"refunded_amount", "o.refunded_amount", exportMoney},
+	{"tax_invoice_no", "o.tax_invoice_no", exportText},
+	{"tax_buyer_name", "o.tax_buyer->>'name'", exportText},
+	{"tax_buyer_tax_id", "o.tax_buyer->>'tax_id'", exportText},
+	{"recipient", "o.shipping_address->>'recipient'", exportText},
+	{"province", "o.shipping_address->>'province'", exportText},
+	{"postcode", "o.shipping_address->>'postcode'", exportText},
+	{"item_count", "(SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE order_id = o.id)", exportInt},
+}
+
+var orderLineFields = []exportField{
+	{"order_id", "o.id", exportInt},
+	{"created_at", "o.created_at", exportTime},
+	{"user_id", "o.user_id", exportText},
+	{"customer_name", "COALESCE(cu.name, o.shipping_address->>'recipient')", exportText},
+	{"status", "o.status", exportText},
+	{"payment_status", "o.payment_status", exportText},
+	{"line_id", "oi.id", exportInt},
+	{"product_id", "oi.product_id", exportText},
+	{"sku", "oi.sku", exportText},
+	{"name", "oi.name", exportText},
+	{"variant", "oi.variant", exportText},
+	{"quantity", "oi.quantity", exportInt},
+	{"unit_price", "oi.price", exportMoney},
+	{"line_total", "oi.price * oi.quantity", exportMoney},
+	{"vat_amount", "oi.vat_amount", exportMoney},
+}
+
+var saleHeaderFields = []exportField{
+	{"sale_id", "s.sale_id", exportText},
+	{"sale_date", "s.sale_date", exportTime},
+	{"employee_id", "s.employee_id", exportText},
+	{"customer_id", "s.customer_id", exportText},
+	{"net_amount", "s.total_price - s.vat_amount", exportMoney},
+	{"vat_rate", "s.vat_rate", exportInt},
+	{"vat_amount", "s.vat_amount", exportMoney},
+	{"total_price", "s.total_price", exportMoney},
+	{"vat_inclusive", "s.vat_inclusive", exportBool},
+	{"payment_method", "s.payment_method", exportText},
+	{"refunded_amount", "s.refunded_amount", exportMoney},
+	{"tax_invoice_no", "s.tax_invoice_no", exportText},
+	{"tax_buyer_name", "s.tax_buyer->>'name'", exportText},
+	{"tax_buyer_tax_id", "s.tax_buyer->>'tax_id'", exportText},
+}
+
+var saleLineFields = []exportField{
+	{"sale_id", "s.sale_id", exportText},
+	{"sale_date", "s.sale_date", exportTime},
+	{"employee_id", "s.employee_id", exportText},
+	{"customer_id", "s.customer_id", exportText},
+	{"product_id", "s.product_id", exportText},
+	{"sku", "pv.sku", exportText},
+	{"name", "p.name", exportText},
+	{"quantity", "s.quantity", exportInt},
+	{"net_amount", "s.total_price - s.vat_amount", exportMoney},
+	{"vat_amount", "s.vat_amount", exportMoney},
+	{"total_price", "s.total_price", exportMoney},
+}
+
+// saleExportFilter ตัวกรองการขายหน้าร้าน (alias s): from, to (sale_date), sale_id, employee_id, customer_id, product_id
+func saleExportFilter(q func(string) string) ([]string, []interface{}, error) {
+	where := []string{"1=1"}
+	args := []interface{}{}
+	ai := 1
+
+	for _, p := range []struct{ param, column string }{
+		{"sale_id", "s.sale_id"}, {"employee_id", "s.employee_id"},
+		{"customer_id", "s.customer_id"}, {"product_id", "s.product_id"},
+	} {
+		if v := splitCSV(q(p.param)); len(v) > 0 {
+			where = append(where, p.column+" = ANY($"+itoa(ai)+")")
+			args = append(args, v)
+			ai++
+		}
+	}
+	if v := q("from"); v != "" {
+		t, ok := parseSearchTime(v, false)
+		if !ok {
+			return nil, nil, errors.New("from must be YYYY-MM-DD or RFC3339")
+		}
+		where = append(where, "s.sale_date >= $"+itoa(ai))
+		args = append(args, t)
+		ai++
+	}
+	if v := q("to"); v != "" {
+		t, ok := parseSearchTime(v, true)
+		if !ok {
+			return nil, nil, errors.New("to must be YYYY-MM-DD or RFC3339")
+		}
+		where = append(where, "s.sale_date < $"+itoa(ai))
+		args = append(args, t)
+	}
+	return where, args, nil
+}
+
+// exportQuery สร้าง SQL ของ spec; คืน query, นับแถว, args และคอลัมน์
+func exportQuery(spec exportSpec) (string, string, []interface{}, []exportField, error) {
+	q := func(k string) string { return spec.Params[k] }
+
+	var (
+		fields     []exportField
+		from, sort string
+		where      []string
+		args       []interface{}
+		err        error
+	)
+	switch spec.Kind {
+	case models.ExportKindOrders:
+		where, args, err = orderSearchFilter(q)
+		from, sort, fields = `orders o`+exportCustomerJoin, `o.id`, orderHeaderFields
+		if spec.Granularity == models.ExportLines {
+			from = `orders o JOIN order_items oi ON oi.order_id = o.id` + exportCustomerJoin
+			sort, fields = `o.id, oi.id`, orderLineFields
+		}
+	default:
+		where, args, err = saleExportFilter(q)
+		from, sort, fields = `sales s`, `s.id`, saleHeaderFields
+		if spec.Granularity == models.ExportLines {
+			from = `sales s
+				LEFT JOIN products p ON p.product_id = s.product_id
+				LEFT JOIN product_variants pv ON pv.id = s.variant_id`
+			fields = saleLineFields
+		}
+	}
+	if err != nil {
+		return "", "", nil, nil, err
+	}
+
+	exprs := make([]string, len(fields))
+	for i, f := range fields {
+		exprs[i] = f.expr
+	}
+	filter := strings.Join(where, " AND ")
+	query := `SELECT ` + strings.Join(exprs, ", ") + ` FROM ` + from + ` WHERE ` + filter + ` ORDER BY ` + sort
+	count := `SELECT COUNT(*) FROM ` + from + ` WHERE ` + filter
+	return query, count, args, fields, nil
+}
+
+// ===== เขียนไฟล์ =====
+
+type exportSink interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+func newExportSink(format string, w io.Writer, fields []exportField, sheet string) (exportSink, error) {
+	header := make([]interface{}, len(fields))
+	for i, f := range fields {
+		header[i] = f.title
+	}
+	var s exportSink
+	if format == models.ExportFormatXLSX {
+		x, err := newXLSXSink(w, sheet)
+		if err != nil {
+			return nil, err
+		}
+		s = x
+	} else {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return nil, err
+		}
+		s = &csvSink{w: csv.NewWriter(w)}
+	}
+	if err := s.WriteRow(header); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type csvSink struct {
+	w    *csv.Writer
+	rows int
+}
+
+func (s *csvSink) WriteRow(values []interface{}) error {
+	rec := make([]string, len(values))
+	for i, v := range values {
+		rec[i] = exportCellText(v)
+		// กันสูตรใน Excel (CSV injection) เฉพาะข้อความที่ผู้ใช้กรอก
+		if _, ok := v.(string); ok && rec[i] != "" && strings.ContainsRune("=+-@\t\r", rune(rec[i][0])) {
+			rec[i] = "'" + rec[i]
+		}
+	}
+	if err := s.w.Write(rec); err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%1000 == 0 {
+		s.w.Flush()
+	}
+	return s.w.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// xlsxSink เขียนผ่าน StreamWriter (พักข้อมูลลงไฟล์ชั่วคราวของ excelize) แล้วส่งทั้งไฟล์ตอน Close
+type xlsxSink struct {
+	w    io.Writer
+	f    *excelize.File
+	sw   *excelize.StreamWriter
+	bold int
+	row  int
+}
+
+func newXLSXSink(w io.Writer, sheet string) (*xlsxSink, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		f.Close()
+		return nil, err
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxSink{w: w, f: f, sw: sw, bold: bold}, nil
+}
+
+func (s *xlsxSink) WriteRow(values []interface{}) error {
+	if s.row >= xlsxMaxRows {
+		return errExportTooManyRows
+	}
+	s.row++
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		switch x := v.(type) {
+		case models.Money:
+			cells[i] = x.Float64()
+		case int64, bool:
+			cells[i] = x
+		default:
+			cells[i] = exportCellText(v)
+		}
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, s.row)
+	if s.row == 1 {
+		return s.sw.SetRow(cell, cells, excelize.RowOpts{StyleID: s.bold})
+	}
+	return s.sw.SetRow(cell, cells)
+}
+
+func (s *xlsxSink) Close() error {
+	defer s.f.Close()
+	if err := s.sw.Flush(); err != nil {
+		return err
+	}
+	return s.f.Write(s.w)
+}
+
+// exportCellText เวลาแสดงเป็นเวลาไทย, เงินเป็นทศนิยม 2 ตำแหน่ง, NULL เป็นช่องว่าง
+func exportCellText(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case bool:
+		return strconv.FormatBool(x)
+	case models.Money:
+		return x.String()
+	case time.Time:
+		return x.In(orderSearchZone).Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
+
+// runExport query ตาม spec แล้วเขียนลง w ทีละแถว; คืนจำนวนแถวข้อมูล
+func runExport(ctx context.Context, conn *pgx.Conn, spec exportSpec, w io.Writer) (int, error) {
+	query, _, args, fields, err := exportQuery(spec)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	sink, err := newExportSink(spec.Format, w, fields, spec.Kind)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for rows.Next() {
+		values, err := scanExportRow(rows, fields)
+		if err != nil {
+			return n, err
+		}
+		if err := sink.WriteRow(values); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, sink.Close()
+}
+
+func scanExportRow(rows pgx.Rows, fields []exportField) ([]interface{}, error) {
+	dest := make([]interface{}, len(fields))
+	for i, f := range fields {
+		switch f.typ {
+		case exportInt:
+			dest[i] = new(*int64)
+		case exportMoney:
+			dest[i] = new(models.Money)
+		case exportTime:
+			dest[i] = new(*time.Time)
+		case exportBool:
+			dest[i] = new(*bool)
+		default:
+			dest[i] = new(*string)
+		}
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(fields))
+	for i, d := range dest {
+		switch p := d.(type) {
+		case **int64:
+			if *p != nil {
+				values[i] = **p
+			}
+		case *models.Money:
+			values[i] = *p
+		case **time.Time:
+			if *p != nil {
+				values[i] = **p
+			}
+		case **bool:
+			if *p != nil {
+				values[i] = **p
+			}
+		case **string:
+			if *p != nil {
+				values[i] = **p
+			}
+		}
+	}
+	return values, nil
+}
+
+// ===== export ทันที (ไฟล์เล็ก) =====
+
+// ถ้าเกินจำนวนแถวนี้ จะสร้างงานเบื้องหลังแทนการส่งไฟล์ทันที (EXPORT_SYNC_MAX_ROWS, default 5000)
+func exportSyncMaxRows() int {
+	if n, err := strconv.Atoi(os.Getenv("EXPORT_SYNC_MAX_ROWS")); err == nil && n >= 0 {
+		return n
+	}
+	return 5000
+}
+
+// ====================
+// ดาวน์โหลดออเดอร์ / การขายหน้าร้านเป็น CSV หรือ XLSX
+// GET /admin/orders/export?format=csv|xlsx&granularity=header|lines&from=2025-01-01&to=2025-01-31
+// GET /admin/sales/export?...
+// ตัวกรองออเดอร์เหมือน GET /admin/orders; การขาย: from, to, sale_id, employee_id, customer_id, product_id
+// ถ้าแถวเกิน EXPORT_SYNC_MAX_ROWS หรือส่ง async=1 จะสร้างงานเบื้องหลังและตอบ 202 พร้อมงาน (ดาวน์โหลดทีหลังจาก download_url)
+// ====================
+func ExportOrders(c *fiber.Ctx) error { return exportHandler(c, models.ExportKindOrders) }
+
+func ExportSales(c *fiber.Ctx) error { return exportHandler(c, models.ExportKindSales) }
+
+func exportHandler(c *fiber.Ctx, kind string) error {
+	spec := exportSpec{Kind: kind, Format: c.Query("format"), Granularity: c.Query("granularity"), Params: map[string]string{}}
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		switch key := string(k); key {
+		case "format", "granularity", "async":
+		default:
+			spec.Params[key] = string(v)
+		}
+	})
+	if err := spec.normalize(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	_, countSQL, args, _, err := exportQuery(spec)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var count int
+	if err := conn.QueryRow(ctx, countSQL, args...).Scan(&count); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if c.Query("async") == "1" || count > exportSyncMaxRows() {
+		userID, _ := c.Locals("user_id").(string)
+		job, err := queueExportJob(ctx, conn, spec, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Location(exportJobURL(job.ID))
+		return c.Status(fiber.StatusAccepted).JSON(job)
+	}
+
+	c.Set(fiber.HeaderContentType, spec.contentType())
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+spec.fileName(time.Now())+`"`)
+	c.Set("X-Total-Count", strconv.Itoa(count))
+	// ส่งแบบ stream: เปิด connection ใหม่ใน writer เพราะ handler จบก่อนเขียน body
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		conn, err := condb.DB_Lek()
+		if err != nil {
+			log.Println("export:", err)
+			return
+		}
+		defer conn.Close(context.Background())
+		if _, err := runExport(context.Background(), conn, spec, w); err != nil {
+			log.Printf("export %s: %v", spec.Kind, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// ===== งานเบื้องหลัง =====
+
+// ปลุก worker ทันทีที่มีงานใหม่ (ไม่ต้องรอรอบ poll)
+var exportWake = make(chan struct{}, 1)
+
+// EXPORT_DIR ต้องเป็นโฟลเดอร์ที่ทุก instance เห็นร่วมกันถ้ารันหลาย instance
+func exportDir() string {
+	if v := os.Getenv("EXPORT_DIR"); v != "" {
+		return v
+	}
+	return filepath.Join(os.TempDir(), "lekshop-exports")
+}
+
+func exportFilePath(j models.ExportJob) string {
+	return filepath.Join(exportDir(), fmt.Sprintf("export-%d.%s", j.ID, j.Format))
+}
+
+// ไฟล์ที่เสร็จแล้วเก็บไว้ดาวน์โหลดนานเท่านี้ (EXPORT_TTL, default 168h)
+func exportTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("EXPORT_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
+}
+
+func exportJobURL(id int64) string {
+	return fmt.Sprintf("/api/admin/exports/%d", id)
+}
+
+const exportJobColumns = `id, kind, format, granularity, params, status, row_count, file_name, file_size, error,
+	created_by, created_at, started_at, finished_at, expires_at`
+
+func scanExportJob(row pgx.Row, j *models.ExportJob) error {
+	err := row.Scan(&j.ID, &j.Kind, &j.Format, &j.Granularity, &j.Params, &j.Status, &j.RowCount, &j.FileName,
+		&j.FileSize, &j.Error, &j.CreatedBy, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.ExpiresAt)
+	if err == nil && j.Status == models.ExportStatusDone {
+		j.DownloadURL = exportJobURL(j.ID) + "/download"
+	}
+	return err
+}
+
+func jobSpec(j models.ExportJob) exportSpec {
+	return exportSpec{Kind: j.Kind, Format: j.Format, Granularity: j.Granularity, Params: j.Params}
+}
+
+func queueExportJob(ctx context.Context, conn *pgx.Conn, spec exportSpec, createdBy string) (models.ExportJob, error) {
+	var j models.ExportJob
+	err := scanExportJob(conn.QueryRow(ctx, `
+		INSERT INTO export_jobs (kind, format, granularity, params, created_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5,''))
+		RETURNING `+exportJobColumns,
+		spec.Kind, spec.Format, spec.Granularity, spec.Params, createdBy), &j)
+	if err != nil {
+		return j, err
+	}
+	select {
+	case exportWake <- struct{}{}:
+	default:
+	}
+	return j, nil
+}
+
+// StartExportWorker ทำงาน export ที่รอคิวทุก EXPORT_POLL_INTERVAL (default 5s) หรือทันทีเมื่อมีงานใหม่
+// และลบไฟล์ที่หมดอายุ; รันหลาย instance ได้เพราะหยิบงานด้วย SKIP LOCKED
+func StartExportWorker(ctx context.Context) {
+	interval := 5 * time.Second
+	if v := os.Getenv("EXPORT_POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("export: invalid EXPORT_POLL_INTERVAL=%q, using %s", v, interval)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			ran, err := runNextExportJob(ctx)
+			if err != nil {
+				log.Println("export:", err)
+			}
+			if !ran {
+				break
+			}
+		}
+		if err := purgeExpiredExports(ctx); err != nil {
+			log.Println("export purge:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-exportWake:
+		}
+	}
+}
+
+// runNextExportJob หยิบงาน queued หนึ่งงานมาทำ; คืน false ถ้าไม่มีงาน
+func runNextExportJob(ctx context.Context) (bool, error) {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	var j models.ExportJob
+	err = scanExportJob(conn.QueryRow(ctx, `
+		UPDATE export_jobs SET status = $1, started_at = NOW()
+		WHERE id = (
+			SELECT id FROM export_jobs WHERE status = $2
+			ORDER BY id FOR UPDATE SKIP LOCKED LIMIT 1
+		)
+		RETURNING `+exportJobColumns, models.ExportStatusRunning, models.ExportStatusQueued), &j)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	spec := jobSpec(j)
+	n, size, err := writeExportFile(ctx, conn, spec, exportFilePath(j))
+	if err != nil {
+		_, uerr := conn.Exec(ctx, `
+			UPDATE export_jobs SET status = $2, error = $3, finished_at = NOW() WHERE id = $1
+		`, j.ID, models.ExportStatusFailed, err.Error())
+		if uerr != nil {
+			return true, uerr
+		}
+		return true, fmt.Errorf("job %d: %w", j.ID, err)
+	}
+
+	_, err = conn.Exec(ctx, `
+		UPDATE export_jobs
+		SET status = $2, row_count = $3, file_name = $4, file_size = $5, finished_at = NOW(), expires_at = $6
+		WHERE id = $1
+	`, j.ID, models.ExportStatusDone, n, spec.fileName(j.CreatedAt), size, time.Now().Add(exportTTL()))
+	return true, err
+}
+
+// writeExportFile เขียนลงไฟล์ชั่วคราวก่อนแล้ว rename เพื่อไม่ให้ใครดาวน์โหลดไฟล์ที่ยังเขียนไม่เสร็จ
+func writeExportFile(ctx context.Context, conn *pgx.Conn, spec exportSpec, path string) (int, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, 0, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	n, err := runExport(ctx, conn, spec, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, 0, err
+	}
+	st, err := os.Stat(f.Name())
+	if err != nil {
+		return n, 0, err
+	}
+	return n, st.Size(), os.Rename(f.Name(), path)
+}
+
+// purgeExpiredExports ลบไฟล์ที่หมดอายุ และปิดงานที่ค้าง running นานผิดปกติ (instance ตายระหว่างทำ)
+func purgeExpiredExports(ctx context.Context) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, `
+		UPDATE export_jobs SET status = $1, error = 'interrupted', finished_at = NOW()
+		WHERE status = $2 AND started_at < NOW() - INTERVAL '1 hour'
+	`, models.ExportStatusFailed, models.ExportStatusRunning); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(ctx, `
+		UPDATE export_jobs SET status = $1
+		WHERE status = $2 AND expires_at <= NOW()
+		RETURNING id, format
+	`, models.ExportStatusExpired, models.ExportStatusDone)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var j models.ExportJob
+		if err := rows.Scan(&j.ID, &j.Format); err != nil {
+			return err
+		}
+		if err := os.Remove(exportFilePath(j)); err != nil && !os.IsNotExist(err) {
+			log.Println("export purge:", err)
+		}
+	}
+	return rows.Err()
+}
+
+// ====================
+// สร้างงาน export เบื้องหลัง
+// POST /admin/exports   body: { "kind": "orders", "format": "xlsx", "granularity": "lines", "params": { "from": "2025-01-01", "to": "2025-01-31" } }
+// ตอบ 202 พร้อมงาน; เช็กสถานะที่ GET /admin/exports/:job_id แล้วดาวน์โหลดจาก download_url
+// ====================
+func CreateExport(c *fiber.Ctx) error {
+	var req models.CreateExportReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	spec := exportSpec{Kind: req.Kind, Format: req.Format, Granularity: req.Granularity, Params: req.Params}
+	if err := spec.normalize(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, _, _, _, err := exportQuery(spec); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	userID, _ := c.Locals("user_id").(string)
+	job, err := queueExportJob(context.Background(), conn, spec, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	c.Location(exportJobURL(job.ID))
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ====================
+// รายการงาน export ล่าสุด
+// GET /admin/exports?limit=50
+// ====================
+func GetExports(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(),
+		`SELECT `+exportJobColumns+` FROM export_jobs ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	list := []models.ExportJob{}
+	for rows.Next() {
+		var j models.ExportJob
+		if err := scanExportJob(rows, &j); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		list = append(list, j)
+	}
+	return c.JSON(list)
+}
+
+func getExportJob(c *fiber.Ctx) (models.ExportJob, error) {
+	var j models.ExportJob
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return j, err
+	}
+	defer conn.Close(context.Background())
+
+	err = scanExportJob(conn.QueryRow(context.Background(),
+		`SELECT `+exportJobColumns+` FROM export_jobs WHERE id = $1`, c.Params("job_id")), &j)
+	return j, err
+}
+
+// ====================
+// สถานะงาน export
+// GET /admin/exports/:job_id
+// ====================
+func GetExport(c *fiber.Ctx) error {
+	j, err := getExportJob(c)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "export not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(j)
+}
+
+// ====================
+// ดาวน์โหลดไฟล์ export ที่เสร็จแล้ว
+// GET /admin/exports/:job_id/download
+// ====================
+func DownloadExport(c *fiber.Ctx) error {
+	j, err := getExportJob(c)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "export not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	switch {
+	case j.Status == models.ExportStatusExpired, j.ExpiresAt != nil && j.ExpiresAt.Before(time.Now()):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "export file has expired"})
+	case j.Status != models.ExportStatusDone:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "export is " + j.Status})
+	}
+
+	path := exportFilePath(j)
+	if _, err := os.Stat(path); err != nil {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "export file is missing"})
+	}
+	name := jobSpec(j).fileName(j.CreatedAt)
+	if j.FileName != nil {
+		name = *j.FileName
+	}
+	return c.Download(path, name)
+}
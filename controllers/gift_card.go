@@ -0,0 +1,718 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errGiftCardNotFound     = errors.New("gift card not found")
+	errGiftCardInactive     = errors.New("gift card is not active")
+	errGiftCardExpired      = errors.New("gift card has expired")
+	errGiftCardInsufficient = errors.New("insufficient gift card balance")
+	errGiftCardRedeemAmount = errors.New("gift_card_amount must not be negative")
+	errGiftCardAmount       = fmt.Errorf("gift card amount must be between %s and %s", minGiftCardAmount, maxGiftCardAmount)
+	errGiftCardTooMany      = fmt.Errorf("at most %d gift cards per order", maxGiftCardsPerOrder)
+)
+
+const (
+	minGiftCardAmount    = 100 * models.Baht
+	maxGiftCardAmount    = 50000 * models.Baht
+	maxGiftCardsPerOrder = 10
+	maxGiftCardMessage   = 300
+
+	// ไม่มี 0/O/1/I ที่อ่านสลับกันง่าย
+	giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	giftCardCodeLen  = 16
+)
+
+// GIFT_CARD_VALID_DAYS อายุบัตรนับจากวันที่เปิดใช้ (default 365 วัน)
+func giftCardValidDays() int {
+	if n, err := strconv.Atoi(os.Getenv("GIFT_CARD_VALID_DAYS")); err == nil && n > 0 {
+		return n
+	}
+	return 365
+}
+
+// normalizeGiftCardCode ตัดขีด/ช่องว่าง แปลงเป็นตัวพิมพ์ใหญ่ (รูปแบบที่เก็บใน DB)
+func normalizeGiftCardCode(s string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s)))
+}
+
+// formatGiftCardCode แบ่งกลุ่มละ 4 ตัว: XXXX-XXXX-XXXX-XXXX
+func formatGiftCardCode(code string) string {
+	var b strings.Builder
+	for i, r := range code {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// maskGiftCardCode แสดงเฉพาะ 4 ตัวท้าย
+func maskGiftCardCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return formatGiftCardCode(strings.Repeat("*", len(code)-4) + code[len(code)-4:])
+}
+
+func newGiftCardCode() (string, error) {
+	b := make([]byte, giftCardCodeLen)
+	max := big.NewInt(int64(len(giftCardAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = giftCardAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// normalizeGiftCardPurchase ตรวจยอด/ผู้รับของบัตรที่จะออก
+func normalizeGiftCardPurchase(g *models.GiftCardPurchaseReq) error {
+	if g.Amount < minGiftCardAmount || g.Amount > maxGiftCardAmount {
+		return errGiftCardAmount
+	}
+	g.RecipientName = strings.TrimSpace(g.RecipientName)
+	g.RecipientEmail = strings.TrimSpace(g.RecipientEmail)
+	g.Message = strings.TrimSpace(g.Message)
+	if g.RecipientEmail != "" && !strings.Contains(g.RecipientEmail, "@") {
+		return errors.New("invalid recipient_email")
+	}
+	if utf8.RuneCountInString(g.Message) > maxGiftCardMessage {
+		return fmt.Errorf("message must be at most %d characters", maxGiftCardMessage)
+	}
+	return nil
+}
+
+const giftCardColumns = `id, code, initial_amount, balance, status, COALESCE(expires_at <= NOW(), false), channel, payment_method,
+	order_id, purchaser_id, recipient_name, recipient_email, message, expires_at, activated_at, issued_by, created_at, updated_at`
+
+func giftCardScanDest(g *models.GiftCard) []interface{} {
+	return []interface{}{&g.ID, &g.Code, &g.InitialAmount, &g.Balance, &g.Status, &g.Expired, &g.Channel, &g.PaymentMethod,
+		&g.OrderID, &g.PurchaserID, &g.RecipientName, &g.RecipientEmail, &g.Message, &g.ExpiresAt, &g.ActivatedAt, &g.IssuedBy,
+		&g.CreatedAt, &g.UpdatedAt}
+}
+
+// insertGiftCardTx สร้างบัตรใหม่พร้อมรหัสสุ่ม (สุ่มใหม่ถ้าชน); บัตร active ลง ledger ยอดตั้งต้นด้วย
+func insertGiftCardTx(ctx context.Context, tx pgx.Tx, g models.GiftCard) (models.GiftCard, error) {
+	for attempt := 0; ; attempt++ {
+		code, err := newGiftCardCode()
+		if err != nil {
+			return g, err
+		}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO gift_cards (code, initial_amount, balance, status, channel, payment_method, order_id, purchaser_id,
+				recipient_name, recipient_email, message, expires_at, activated_at, issued_by)
+			VALUES ($1, $2, 0, $3, $4, NULLIF($5,''), $6, NULLIF($7,''), $8, $9, $10,
+				CASE WHEN $3 = 'active' THEN NOW() + make_interval(days => $12) END,
+				CASE WHEN $3 = 'active' THEN NOW() END, NULLIF($11,''))
+			ON CONFLICT (code) DO NOTHING
+			RETURNING `+giftCardColumns,
+			code, g.InitialAmount, g.Status, g.Channel, g.PaymentMethod, g.OrderID, g.PurchaserID,
+			g.RecipientName, g.RecipientEmail, g.Message, g.IssuedBy, giftCardValidDays()).Scan(giftCardScanDest(&g)...)
+		if err == pgx.ErrNoRows && attempt < 5 {
+			continue
+		}
+		if err != nil {
+			return g, err
+		}
+		break
+	}
+	if g.Status == models.GiftCardActive {
+		m := giftCardMove{CardID: g.ID, Kind: models.GiftCardTxIssue, Change: g.InitialAmount, OrderID: g.OrderID}
+		if g.IssuedBy != nil {
+			m.CreatedBy = *g.IssuedBy
+		}
+		balance, err := moveGiftCardTx(ctx, tx, m)
+		if err != nil {
+			return g, err
+		}
+		g.Balance = balance
+	}
+	return g, nil
+}
+
+// giftCardMove ข้อมูลที่ใช้บันทึกลง gift_card_transactions หนึ่งรายการ
+type giftCardMove struct {
+	CardID    int64
+	Kind      string
+	Change    models.Money // + เข้า, - ใช้ไป
+	OrderID   *int64
+	SaleID    string
+	Note      string
+	CreatedBy string
+}
+
+// moveGiftCardTx อัปเดต gift_cards.balance แล้วเขียน ledger; ห้ามยอดติดลบ คืนยอดคงเหลือหลังรายการ
+// ใช้ querier เพื่อเรียกจาก activateOrderGiftCardsTx ได้ (ตอนเปิดใช้บัตรที่ซื้อออนไลน์)
+func moveGiftCardTx(ctx context.Context, q querier, m giftCardMove) (models.Money, error) {
+	var balance models.Money
+	err := q.QueryRow(ctx, `
+		UPDATE gift_cards SET balance = balance + $2::numeric, updated_at = NOW()
+		WHERE id = $1 AND balance + $2::numeric >= 0
+		RETURNING balance
+	`, m.CardID, m.Change).Scan(&balance)
+	if err == pgx.ErrNoRows {
+		return 0, errGiftCardInsufficient
+	}
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = q.QueryRow(ctx, `
+		INSERT INTO gift_card_transactions (gift_card_id, kind, amount, balance_after, order_id, sale_id, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6,''), NULLIF($7,''), NULLIF($8,''))
+		RETURNING id
+	`, m.CardID, m.Kind, m.Change, balance, m.OrderID, m.SaleID, m.Note, m.CreatedBy).Scan(&id)
+	return balance, err
+}
+
+// lockGiftCardTx ล็อกบัตรตามรหัส (FOR UPDATE) แล้วตรวจว่าใช้จ่ายได้; ใช้ก่อนตัดยอดเพื่อกันใช้ซ้อนกัน
+func lockGiftCardTx(ctx context.Context, tx pgx.Tx, code string) (models.GiftCard, error) {
+	var g models.GiftCard
+	err := tx.QueryRow(ctx, `SELECT `+giftCardColumns+` FROM gift_cards WHERE code = $1 FOR UPDATE`,
+		normalizeGiftCardCode(code)).Scan(giftCardScanDest(&g)...)
+	if err == pgx.ErrNoRows {
+		return g, errGiftCardNotFound
+	}
+	if err != nil {
+		return g, err
+	}
+	if g.Status != models.GiftCardActive {
+		return g, errGiftCardInactive
+	}
+	if g.Expired {
+		return g, errGiftCardExpired
+	}
+	return g, nil
+}
+
+// giftCardRedeemAmount ยอดที่จะตัดจากบัตร: requested = 0 คือใช้เท่าที่มี; ไม่เกิน payable
+func giftCardRedeemAmount(g models.GiftCard, requested, payable models.Money) (models.Money, error) {
+	if requested < 0 {
+		return 0, errGiftCardRedeemAmount
+	}
+	amount := requested
+	if amount == 0 {
+		amount = g.Balance
+	}
+	if amount > g.Balance {
+		return 0, errGiftCardInsufficient
+	}
+	if amount > payable {
+		amount = payable
+	}
+	return amount, nil
+}
+
+// redeemGiftCardTx ตัดยอดบัตร (ล็อกแล้ว) แล้วลงสมุดรับเงินช่องทาง GIFT_CARD; ระบุ e.OrderID หรือ e.SaleID
+func redeemGiftCardTx(ctx context.Context, tx pgx.Tx, g models.GiftCard, e paymentEntry) error {
+	if e.Amount <= 0 {
+		return nil
+	}
+	m := giftCardMove{CardID: g.ID, Kind: models.GiftCardTxRedeem, Change: -e.Amount, SaleID: e.SaleID, CreatedBy: e.CreatedBy}
+	if e.OrderID != 0 {
+		m.OrderID = &e.OrderID
+	}
+	if _, err := moveGiftCardTx(ctx, tx, m); err != nil {
+		return err
+	}
+	e.Method = models.PayMethodGiftCard
+	e.Status = models.PaymentSucceeded
+	e.ProviderRef = maskGiftCardCode(g.Code)
+	_, err := insertPaymentTx(ctx, tx, e)
+	return err
+}
+
+// restoreGiftCardsTx คืนยอดเข้าบัตรที่ใช้จ่ายออเดอร์/การขาย (col = order_id | sale_id) ไม่เกินยอดที่ใช้สุทธิของแต่ละใบ
+// limit = 0 คือคืนทั้งหมด; ลงรายการคืนเงิน GIFT_CARD ในสมุดรับเงิน คืนยอดที่คืนได้จริง
+func restoreGiftCardsTx(ctx context.Context, tx pgx.Tx, col string, id interface{}, limit models.Money, note, by string) (models.Money, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT t.gift_card_id, -SUM(t.amount)
+		FROM gift_card_transactions t
+		WHERE t.`+col+` = $1 AND t.kind IN ('redeem', 'restore')
+		GROUP BY t.gift_card_id
+		HAVING -SUM(t.amount) > 0
+		ORDER BY t.gift_card_id
+	`, id)
+	if err != nil {
+		return 0, err
+	}
+	type used struct {
+		CardID int64
+		Net    models.Money
+	}
+	var cards []used
+	for rows.Next() {
+		var u used
+		if err := rows.Scan(&u.CardID, &u.Net); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		cards = append(cards, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var restored models.Money
+	for _, u := range cards {
+		amount := u.Net
+		if limit > 0 && restored+amount > limit {
+			amount = limit - restored
+		}
+		if amount <= 0 {
+			break
+		}
+		m := giftCardMove{CardID: u.CardID, Kind: models.GiftCardTxRestore, Change: amount, Note: note, CreatedBy: by}
+		if col == "order_id" {
+			orderID := id.(int64)
+			m.OrderID = &orderID
+		} else {
+			m.SaleID = id.(string)
+		}
+		if _, err := moveGiftCardTx(ctx, tx, m); err != nil {
+			return restored, err
+		}
+		restored += amount
+	}
+	if restored == 0 {
+		return 0, nil
+	}
+
+	e := paymentEntry{Kind: models.PaymentKindRefund, Method: models.PayMethodGiftCard, Amount: restored,
+		Status: models.PaymentSucceeded, Note: note, CreatedBy: by}
+	if col == "order_id" {
+		e.OrderID = id.(int64)
+	} else {
+		e.SaleID = id.(string)
+	}
+	_, err = insertPaymentTx(ctx, tx, e)
+	return restored, err
+}
+
+// activateOrderGiftCardsTx เปิดใช้บัตรที่ซื้อพร้อมออเดอร์ เมื่อเงินก้อนที่ทำให้ชำระครบผ่านการตรวจแล้ว
+// (webhook ที่ลายเซ็นถูกต้อง หรือพนักงานอนุมัติสลิป) payStatus คือผลจาก syncOrderPaymentTx
+func activateOrderGiftCardsTx(ctx context.Context, q querier, orderID int64, payStatus string) error {
+	if payStatus != models.PayStatusPaid {
+		return nil
+	}
+	rows, err := q.Query(ctx, `
+		UPDATE gift_cards
+		SET status = $2, activated_at = NOW(), expires_at = NOW() + make_interval(days => $3), updated_at = NOW()
+		WHERE order_id = $1 AND status = $4
+		RETURNING id, initial_amount
+	`, orderID, models.GiftCardActive, giftCardValidDays(), models.GiftCardPending)
+	if err != nil {
+		return err
+	}
+	var moves []giftCardMove
+	for rows.Next() {
+		m := giftCardMove{Kind: models.GiftCardTxIssue, OrderID: &orderID}
+		if err := rows.Scan(&m.CardID, &m.Change); err != nil {
+			rows.Close()
+			return err
+		}
+		moves = append(moves, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, m := range moves {
+		if _, err := moveGiftCardTx(ctx, q, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// voidOrderGiftCardsTx ยกเลิกบัตรที่ซื้อในออเดอร์ที่ถูกยกเลิก: pending ทิ้งได้เลย, active ที่ยังไม่ถูกใช้ตัดยอดเป็น 0
+// บัตรที่ถูกใช้ไปแล้วคงไว้ (พนักงานตัดสินใจเรื่องคืนเงินเอง)
+func voidOrderGiftCardsTx(ctx context.Context, tx pgx.Tx, orderID int64, reason, by string) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE gift_cards SET status = $2, updated_at = NOW() WHERE order_id = $1 AND status = $3
+	`, orderID, models.GiftCardVoid, models.GiftCardPending); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, `
+		UPDATE gift_cards SET status = $2, updated_at = NOW()
+		WHERE order_id = $1 AND status = $3 AND balance = initial_amount
+		  AND NOT EXISTS (SELECT 1 FROM gift_card_transactions t WHERE t.gift_card_id = gift_cards.id AND t.kind = 'redeem')
+		RETURNING id, balance
+	`, orderID, models.GiftCardVoid, models.GiftCardActive)
+	if err != nil {
+		return err
+	}
+	var moves []giftCardMove
+	for rows.Next() {
+		m := giftCardMove{Kind: models.GiftCardTxVoid, OrderID: &orderID, Note: reason, CreatedBy: by}
+		if err := rows.Scan(&m.CardID, &m.Change); err != nil {
+			rows.Close()
+			return err
+		}
+		m.Change = -m.Change
+		moves = append(moves, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, m := range moves {
+		if _, err := moveGiftCardTx(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func giftCardErrorStatus(err error) int {
+	switch err {
+	case errGiftCardNotFound:
+		return fiber.StatusNotFound
+	case errGiftCardInactive, errGiftCardExpired, errGiftCardInsufficient:
+		return fiber.StatusConflict
+	case errGiftCardAmount, errGiftCardRedeemAmount, errGiftCardTooMany, errPaymentMethod:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// ====================
+// ขายบัตรของขวัญที่หน้าร้าน: เปิดใช้ทันทีและลงสมุดรับเงินค่าบัตร
+// POST /admin/gift-cards   body: { "amount": 1000, "payment_method": "CASH", "customer_id": "...", "recipient_name": "...", "message": "..." }
+// ====================
+func IssueGiftCard(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.IssueGiftCardReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	purchase := models.GiftCardPurchaseReq{Amount: req.Amount, RecipientName: req.RecipientName,
+		RecipientEmail: req.RecipientEmail, Message: req.Message}
+	if err := normalizeGiftCardPurchase(&purchase); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.PaymentMethod = strings.ToUpper(strings.TrimSpace(req.PaymentMethod))
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = models.PayMethodCash
+	}
+	if !paymentMethods[req.PaymentMethod] || req.PaymentMethod == models.PayMethodCOD {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errPaymentMethod.Error()})
+	}
+	issuedBy := staffID // พนักงานที่ขาย = ผู้ที่ login อยู่ เหมือน CreateSale
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	g, err := insertGiftCardTx(ctx, tx, giftCardFromPurchase(purchase, models.GiftCardActive, models.GiftCardChannelPOS,
+		req.PaymentMethod, nil, strings.TrimSpace(req.CustomerID), issuedBy))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := insertPaymentTx(ctx, tx, paymentEntry{
+		GiftCardID: g.ID, Method: req.PaymentMethod, Amount: g.InitialAmount, Status: models.PaymentSucceeded,
+		Note: "gift card sale", CreatedBy: issuedBy,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	g.Code = formatGiftCardCode(g.Code)
+	return c.Status(fiber.StatusCreated).JSON(g)
+}
+
+// giftCardFromPurchase แปลง request เป็นแถว gift_cards ที่จะสร้าง
+func giftCardFromPurchase(p models.GiftCardPurchaseReq, status, channel, method string, orderID *int64, purchaserID, issuedBy string) models.GiftCard {
+	g := models.GiftCard{InitialAmount: p.Amount, Status: status, Channel: channel, OrderID: orderID}
+	if method != "" {
+		g.PaymentMethod = &method
+	}
+	if purchaserID != "" {
+		g.PurchaserID = &purchaserID
+	}
+	if p.RecipientName != "" {
+		g.RecipientName = &p.RecipientName
+	}
+	if p.RecipientEmail != "" {
+		g.RecipientEmail = &p.RecipientEmail
+	}
+	if p.Message != "" {
+		g.Message = &p.Message
+	}
+	if issuedBy != "" {
+		g.IssuedBy = &issuedBy
+	}
+	return g
+}
+
+// ====================
+// ตรวจยอดบัตรของขวัญ (ไม่ต้อง login; ผู้ถือรหัสเท่านั้นที่รู้รหัส)
+// GET /gift-cards/:code/balance
+// ====================
+func GetGiftCardBalance(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	var g models.GiftCard
+	err = conn.QueryRow(context.Background(), `SELECT `+giftCardColumns+` FROM gift_cards WHERE code = $1`,
+		normalizeGiftCardCode(c.Params("code"))).Scan(giftCardScanDest(&g)...)
+	// pending = ยังไม่ชำระ ถือว่ายังไม่มีบัตร
+	if err == pgx.ErrNoRows || (err == nil && g.Status == models.GiftCardPending) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errGiftCardNotFound.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(models.GiftCardBalance{
+		Code:      maskGiftCardCode(g.Code),
+		Balance:   g.Balance,
+		Status:    g.Status,
+		Expired:   g.Expired,
+		ExpiresAt: g.ExpiresAt,
+	})
+}
+
+// ====================
+// บัตรของขวัญที่ลูกค้าซื้อออนไลน์ (รหัสเต็มแสดงเมื่อบัตรเปิดใช้แล้ว)
+// GET /gift-cards/mine
+// ====================
+func GetMyGiftCards(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	list, err := listGiftCards(context.Background(), conn, `purchaser_id = $1 ORDER BY id DESC LIMIT 200`, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	for i := range list {
+		if list[i].Status != models.GiftCardActive {
+			list[i].Code = maskGiftCardCode(normalizeGiftCardCode(list[i].Code))
+		}
+	}
+	return c.JSON(list)
+}
+
+// listGiftCards โหลดบัตรตามเงื่อนไข where (ต่อท้าย WHERE) รหัสจัดรูปแบบแล้ว
+func listGiftCards(ctx context.Context, q querier, where string, args ...interface{}) ([]models.GiftCard, error) {
+	rows, err := q.Query(ctx, `SELECT `+giftCardColumns+` FROM gift_cards WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.GiftCard{}
+	for rows.Next() {
+		var g models.GiftCard
+		if err := rows.Scan(giftCardScanDest(&g)...); err != nil {
+			return nil, err
+		}
+		g.Code = formatGiftCardCode(g.Code)
+		list = append(list, g)
+	}
+	return list, rows.Err()
+}
+
+// ====================
+// รายการบัตรของขวัญ (หลังบ้าน)
+// GET /admin/gift-cards?status=active&code=ABCD&order_id=12&limit=50&offset=0
+// status=expired = active ที่หมดอายุแล้ว
+// ====================
+func AdminGetGiftCards(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	conds := []string{"TRUE"}
+	args := []interface{}{}
+	switch status := c.Query("status"); status {
+	case "":
+	case "expired":
+		conds = append(conds, "status = 'active' AND expires_at <= NOW()")
+	case models.GiftCardActive:
+		conds = append(conds, "status = 'active' AND expires_at > NOW()")
+	case models.GiftCardPending, models.GiftCardVoid:
+		args = append(args, status)
+		conds = append(conds, "status = $"+itoa(len(args)))
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid status"})
+	}
+	if code := normalizeGiftCardCode(c.Query("code")); code != "" {
+		args = append(args, "%"+code+"%")
+		conds = append(conds, "code LIKE $"+itoa(len(args)))
+	}
+	if v := c.Query("order_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid order_id"})
+		}
+		args = append(args, id)
+		conds = append(conds, "order_id = $"+itoa(len(args)))
+	}
+	args = append(args, limit, offset)
+	where := strings.Join(conds, " AND ") + " ORDER BY id DESC LIMIT $" + itoa(len(args)-1) + " OFFSET $" + itoa(len(args))
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	list, err := listGiftCards(context.Background(), conn, where, args...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"items": list, "limit": limit, "offset": offset})
+}
+
+// ====================
+// บัตรของขวัญหนึ่งใบพร้อม ledger (หลังบ้าน)
+// GET /admin/gift-cards/:gift_card_id
+// ====================
+func AdminGetGiftCard(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("gift_card_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errGiftCardNotFound.Error()})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	list, err := listGiftCards(ctx, conn, `id = $1`, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(list) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errGiftCardNotFound.Error()})
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, gift_card_id, kind, amount, balance_after, order_id, sale_id, note, created_by, created_at
+		FROM gift_card_transactions WHERE gift_card_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+	txs := []models.GiftCardTransaction{}
+	for rows.Next() {
+		var t models.GiftCardTransaction
+		if err := rows.Scan(&t.ID, &t.GiftCardID, &t.Kind, &t.Amount, &t.BalanceAfter, &t.OrderID, &t.SaleID, &t.Note,
+			&t.CreatedBy, &t.CreatedAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		txs = append(txs, t)
+	}
+	return c.JSON(fiber.Map{"gift_card": list[0], "transactions": txs})
+}
+
+// ====================
+// ภาระหนี้บัตรของขวัญคงค้าง (ยอดที่ลูกค้ายังใช้ได้) แยกตามเดือนที่หมดอายุ
+// GET /admin/reports/gift-card-liability
+// ====================
+func GiftCardLiability(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	rep := models.GiftCardLiability{AsOf: time.Now(), ByExpiry: []models.GiftCardLiabilityBucket{}}
+	err = conn.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE status = 'active' AND expires_at > $1 AND balance > 0)::int,
+		       COALESCE(SUM(balance) FILTER (WHERE status = 'active' AND expires_at > $1), 0),
+		       COUNT(*) FILTER (WHERE status = 'active' AND expires_at <= $1 AND balance > 0)::int,
+		       COALESCE(SUM(balance) FILTER (WHERE status = 'active' AND expires_at <= $1), 0),
+		       COUNT(*) FILTER (WHERE status = 'pending')::int,
+		       COALESCE(SUM(initial_amount) FILTER (WHERE status = 'pending'), 0),
+		       COALESCE(SUM(initial_amount) FILTER (WHERE status = 'active'), 0)
+		FROM gift_cards
+	`, rep.AsOf).Scan(&rep.ActiveCards, &rep.Outstanding, &rep.ExpiredCards, &rep.ExpiredBalance,
+		&rep.PendingCards, &rep.PendingAmount, &rep.Issued)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := conn.QueryRow(ctx, `
+		SELECT COALESCE(-SUM(t.amount), 0)
+		FROM gift_card_transactions t JOIN gift_cards g ON g.id = t.gift_card_id
+		WHERE g.status = 'active' AND t.kind IN ('redeem', 'restore')
+	`).Scan(&rep.Redeemed); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT to_char(expires_at AT TIME ZONE 'Asia/Bangkok', 'YYYY-MM'), COUNT(*)::int, SUM(balance)
+		FROM gift_cards
+		WHERE status = 'active' AND expires_at > $1 AND balance > 0
+		GROUP BY 1
+		ORDER BY 1
+	`, rep.AsOf)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b models.GiftCardLiabilityBucket
+		if err := rows.Scan(&b.Month, &b.Cards, &b.Balance); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		rep.ByExpiry = append(rep.ByExpiry, b)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rep)
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"dog/models"
+	"errors"
+	"testing"
+)
+
+func TestGiftCardRedeemAmount(t *testing.T) {
+	tests := []struct {
+		name               string
+		balance            models.Money
+		requested, payable models.Money
+		want               models.Money
+		wantErr            error
+	}{
+		{"zero uses the whole balance", 50000, 0, 80000, 50000, nil},
+		{"zero capped at payable", 50000, 0, 30000, 30000, nil},
+		{"requested amount", 50000, 20000, 80000, 20000, nil},
+		{"requested capped at payable", 50000, 20000, 15000, 15000, nil},
+		{"requested equals balance", 50000, 50000, 80000, 50000, nil},
+		{"nothing payable", 50000, 0, 0, 0, nil},
+		{"empty card", 0, 0, 80000, 0, nil},
+		// ขอเกินยอดคงเหลือต้อง error แม้ยอดที่ต้องจ่ายจะน้อยกว่า
+		{"more than balance", 50000, 60000, 10000, 0, errGiftCardInsufficient},
+		{"negative", 50000, -1, 80000, 0, errGiftCardRedeemAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := giftCardRedeemAmount(models.GiftCard{Balance: tt.balance}, tt.requested, tt.payable)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("amount = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,481 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"dog/condb"
+	"dog/models"
+	"dog/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ออเดอร์ของ guest เก็บ user_id = "guest:<อีเมลตัวเล็ก>" จนกว่าจะผูกกับบัญชีลูกค้า
+// (รหัสลูกค้าจริงเป็นตัวเลข จึงไม่ชนกัน และ canAccessCustomer ไม่มีทางตรงกับ guest)
+const guestUserPrefix = "guest:"
+
+var (
+	errGuestTokenInvalid = errors.New("invalid order token")
+	errGuestCodeInvalid  = errors.New("invalid or expired verification code")
+)
+
+// ลองรหัสยืนยันอีเมลผิดได้ไม่เกินเท่านี้ต่อรหัส
+const guestCodeMaxAttempts = 5
+
+func guestUserID(email string) string {
+	return guestUserPrefix + strings.ToLower(email)
+}
+
+// guestOrder ข้อมูลผู้สั่งซื้อแบบไม่มีบัญชี; Token แสดงให้ลูกค้าครั้งเดียว เก็บเฉพาะ hash
+type guestOrder struct {
+	Email string
+	Phone string
+	Token string
+}
+
+// method รองรับ receiver nil (ออเดอร์ปกติ) เพื่อส่งเข้า INSERT ได้ตรง ๆ
+func (g *guestOrder) email() string {
+	if g == nil {
+		return ""
+	}
+	return g.Email
+}
+
+func (g *guestOrder) phone() string {
+	if g == nil {
+		return ""
+	}
+	return g.Phone
+}
+
+func (g *guestOrder) tokenHash() string {
+	if g == nil {
+		return ""
+	}
+	return hashGuestToken(g.Token)
+}
+
+func newGuestToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashGuestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkGuestOrderToken ตรวจ token ของออเดอร์ guest; คืนอีเมลที่ใช้สั่ง
+func checkGuestOrderToken(ctx context.Context, q querier, orderID, token string) (string, error) {
+	var email, hash string
+	err := q.QueryRow(ctx, `
+		SELECT guest_email, guest_token_hash FROM orders
+		WHERE id = $1 AND guest_email IS NOT NULL AND guest_token_hash IS NOT NULL
+	`, orderID).Scan(&email, &hash)
+	if err == pgx.ErrNoRows || (err == nil && token == "") {
+		return "", errGuestTokenInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashGuestToken(token)), []byte(hash)) != 1 {
+		return "", errGuestTokenInvalid
+	}
+	return email, nil
+}
+
+func newGuestEmailCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashGuestEmailCode(email, code string) string {
+	return hashGuestToken(strings.ToLower(email) + ":" + code)
+}
+
+// checkGuestEmailCode ตรวจรหัสยืนยันล่าสุดของอีเมล; นับครั้งที่ลองทันที (นอก transaction หลัก) กันเดารหัส
+// คืน id ของรหัสไว้ปิดด้วย useGuestEmailCodeTx ใน transaction ที่ผูกออเดอร์
+func checkGuestEmailCode(ctx context.Context, q querier, email, code string) (int64, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return 0, errGuestCodeInvalid
+	}
+	var id int64
+	var hash string
+	err := q.QueryRow(ctx, `
+		UPDATE guest_email_verifications SET attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM guest_email_verifications
+			WHERE email = $1 AND used_at IS NULL AND expires_at > NOW()
+			ORDER BY id DESC LIMIT 1
+		) AND attempts < $2
+		RETURNING id, code_hash
+	`, strings.ToLower(email), guestCodeMaxAttempts).Scan(&id, &hash)
+	if err == pgx.ErrNoRows {
+		return 0, errGuestCodeInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashGuestEmailCode(email, code)), []byte(hash)) != 1 {
+		return 0, errGuestCodeInvalid
+	}
+	return id, nil
+}
+
+// useGuestEmailCodeTx ปิดรหัสที่ใช้แล้ว (ใช้ได้ครั้งเดียว แม้ส่งพร้อมกันสองคำขอ)
+func useGuestEmailCodeTx(ctx context.Context, tx pgx.Tx, id int64) error {
+	tag, err := tx.Exec(ctx, `UPDATE guest_email_verifications SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() != 1 {
+		return errGuestCodeInvalid
+	}
+	return nil
+}
+
+// linkGuestOrdersTx ย้ายออเดอร์ guest ทุกใบของอีเมลนี้ (และใบคืนสินค้า) ไปเป็นของลูกค้า; คืนจำนวนออเดอร์
+func linkGuestOrdersTx(ctx context.Context, tx pgx.Tx, email, customerID string) (int64, error) {
+	if err := setActorTx(ctx, tx, customerID); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `UPDATE orders SET user_id = $1, updated_at = NOW() WHERE user_id = $2`,
+		customerID, guestUserID(email))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE return_requests SET customer_id = $1, updated_at = NOW() WHERE customer_id = $2`,
+		customerID, guestUserID(email)); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func normalizeGuestContact(email, phone string) (string, string, string) {
+	email = strings.TrimSpace(email)
+	if a, err := mail.ParseAddress(email); err != nil || a.Address != email {
+		return "", "", "invalid email"
+	}
+	phone = strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(phone) < 9 || len(phone) > 10 || phone[0] != '0' {
+		return "", "", "invalid phone"
+	}
+	return email, phone, ""
+}
+
+// canAccessOrder: พนักงาน, เจ้าของออเดอร์ หรือ guest ที่ถือ token ของออเดอร์นี้
+func canAccessOrder(ctx context.Context, c *fiber.Ctx, q querier, orderID, ownerID string) bool {
+	if canAccessCustomer(c, ownerID) {
+		return true
+	}
+	_, err := checkGuestOrderToken(ctx, q, orderID, guestToken(c))
+	return err == nil
+}
+
+func guestToken(c *fiber.Ctx) string {
+	if t := c.Get("X-Order-Token"); t != "" {
+		return t
+	}
+	return c.Query("token")
+}
+
+// ====================
+// สั่งซื้อโดยไม่ต้องสมัครสมาชิก
+// POST /guest/orders
+// body: เหมือน POST /orders + { "email": "...", "phone": "..." }; ต้องส่ง shipping_address มาทั้งก้อน
+// response มี guest_token สำหรับดูออเดอร์ (แสดงครั้งเดียว)
+// ====================
+func GuestCheckout(c *fiber.Ctx) error {
+	var req models.GuestCheckoutReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	email, phone, msg := normalizeGuestContact(req.Email, req.Phone)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if req.ShippingAddress == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errAddressRequired.Error()})
+	}
+	// guest ไม่มีสมุดที่อยู่
+	req.AddressID = nil
+	req.SaveAddress = false
+
+	token, err := newGuestToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create token failed"})
+	}
+	return placeOrder(c, guestUserID(email), req.CreateOrderReq, &guestOrder{Email: email, Phone: phone, Token: token})
+}
+
+// ====================
+// guest ดูออเดอร์ของตัวเองด้วย token
+// GET /guest/orders/:order_id?token=...   (หรือ header X-Order-Token)
+// ====================
+func GetGuestOrder(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	_, err = checkGuestOrderToken(context.Background(), conn, c.Params("order_id"), guestToken(c))
+	conn.Close(context.Background())
+	if err == errGuestTokenInvalid {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return GetOrderByID(c)
+}
+
+// ====================
+// ขอรหัสยืนยันอีเมลก่อนสมัครสมาชิก/ผูกออเดอร์ guest: ส่งรหัส 6 หลักไปที่อีเมลที่ใช้สั่ง (อายุ 15 นาที, ขอใหม่ได้ทุก 1 นาที)
+// POST /guest/orders/:order_id/verify-email   body: { "token": "..." }
+// ====================
+func SendGuestEmailCode(c *fiber.Ctx) error {
+	var req models.ConvertGuestReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	email, err := checkGuestOrderToken(ctx, conn, c.Params("order_id"), req.Token)
+	if err == errGuestTokenInvalid {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var recent bool
+	if err := conn.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM guest_email_verifications WHERE email = $1 AND created_at > NOW() - INTERVAL '1 minute')
+	`, strings.ToLower(email)).Scan(&recent); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if recent {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "please wait before requesting another code"})
+	}
+
+	code, err := newGuestEmailCode()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create code failed"})
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO guest_email_verifications (email, code_hash, expires_at) VALUES ($1, $2, NOW() + INTERVAL '15 minutes')
+	`, strings.ToLower(email), hashGuestEmailCode(email, code)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	err = utils.SendMail(email, "รหัสยืนยันอีเมล", "รหัสยืนยันอีเมลของคุณคือ "+code+"\nรหัสนี้ใช้ได้ 15 นาที หากคุณไม่ได้ขอรหัสนี้ โปรดละเว้นอีเมลฉบับนี้\n")
+	if err == utils.ErrMailNotConfigured {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "send email failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.JSON(fiber.Map{"message": "verification code sent"})
+}
+
+// ====================
+// สมัครสมาชิกจากออเดอร์ guest: สร้างบัญชีด้วยอีเมล/เบอร์ที่ใช้สั่ง แล้วผูกออเดอร์ guest ทุกใบของอีเมลนั้น
+// POST /guest/orders/:order_id/convert   body: { "token": "...", "code": "123456", "firstname": "...", "lastname": "...", "password": "..." }
+// token ได้ตอนสั่งซื้อ (ใครก็สั่งด้วยอีเมลใดก็ได้) จึงต้องมี code จาก /verify-email เป็นหลักฐานว่าเป็นเจ้าของอีเมล
+// ====================
+func ConvertGuest(c *fiber.Ctx) error {
+	var req models.ConvertGuestReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	req.FirstName = strings.TrimSpace(req.FirstName)
+	req.LastName = strings.TrimSpace(req.LastName)
+	if req.FirstName == "" || len(req.Password) < 8 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "firstname and password (at least 8 characters) are required"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	email, err := checkGuestOrderToken(ctx, conn, c.Params("order_id"), req.Token)
+	if err == errGuestTokenInvalid {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	codeID, err := checkGuestEmailCode(ctx, conn, email, req.Code)
+	if err == errGuestCodeInvalid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customer WHERE LOWER(email) = LOWER($1))`, email).Scan(&exists); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if exists {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "email already registered; log in and claim the order instead"})
+	}
+
+	var phone *string
+	if err := conn.QueryRow(ctx, `SELECT guest_phone FROM orders WHERE id = $1`, c.Params("order_id")).Scan(&phone); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
+	}
+	customerID, err := GenerateNextCustomer(conn)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	if err := useGuestEmailCodeTx(ctx, tx, codeID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": errGuestCodeInvalid.Error()})
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO customer (customer_id, firstname, lastname, email, password, phone, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, customerID, req.FirstName, req.LastName, email, string(hashedPwd), phone); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	linked, err := linkGuestOrdersTx(ctx, tx, email, customerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "link orders failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	token, err := utils.GenerateJWTToken(customerID, models.RoleCustomer)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Token generation failed"})
+	}
+	utils.SetJWTCookie(c, token)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":       "Customer created",
+		"customer_id":   customerID,
+		"token":         token,
+		"linked_orders": linked,
+	})
+}
+
+// ====================
+// ลูกค้าที่มีบัญชีอยู่แล้วผูกออเดอร์ guest เข้าบัญชี (อีเมลบัญชีต้องตรงกับอีเมลที่ใช้สั่ง)
+// POST /orders/:order_id/claim   body: { "token": "...", "code": "123456" }   (code จาก /guest/orders/:order_id/verify-email)
+// ผูกออเดอร์ guest ทุกใบของอีเมลนั้นในครั้งเดียว
+// ====================
+func ClaimGuestOrder(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	var req models.ConvertGuestReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	email, err := checkGuestOrderToken(ctx, conn, c.Params("order_id"), req.Token)
+	if err == errGuestTokenInvalid {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var customerEmail string
+	err = conn.QueryRow(ctx, `SELECT email FROM customer WHERE customer_id = $1`, userID).Scan(&customerEmail)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "customers only"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !strings.EqualFold(strings.TrimSpace(customerEmail), email) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "order email does not match your account"})
+	}
+	codeID, err := checkGuestEmailCode(ctx, conn, email, req.Code)
+	if err == errGuestCodeInvalid {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	if err := useGuestEmailCodeTx(ctx, tx, codeID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": errGuestCodeInvalid.Error()})
+	}
+
+	linked, err := linkGuestOrdersTx(ctx, tx, email, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "link orders failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.JSON(fiber.Map{"message": "orders linked", "linked_orders": linked})
+}
@@ -5,8 +5,11 @@ import (
 	"dog/condb"
 	"dog/models"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v4"
@@ -27,6 +30,22 @@ func paymentStatusInitial(method string) string {
 	}
 }
 
+// คอลัมน์ของตาราง orders ที่ scanOrder อ่าน (เรียงให้ตรงกัน)
+const orderColumns = `id, user_id, subtotal, shipping_method, shipping_fee, total, gift_card_total, status, payment_method, payment_status, payment_ref,
+	paid_amount, shipping_address, cancel_reason, cancelled_at, cancelled_by, expires_at, tax_buyer, vat_rate, vat_amount,
+	tax_invoice_no, tax_invoice_at, refunded_amount, customer_note, created_at, updated_at`
+
+func scanOrder(row pgx.Row, o *models.Order) error {
+	return row.Scan(orderScanDest(o)...)
+}
+
+// orderScanDest ปลายทาง Scan ตามลำดับ orderColumns (ใช้ต่อท้ายคอลัมน์อื่นได้)
+func orderScanDest(o *models.Order) []interface{} {
+	return []interface{}{&o.ID, &o.UserID, &o.Subtotal, &o.ShippingMethod, &o.ShippingFee, &o.Total, &o.GiftCardTotal, &o.Status, &o.PaymentMethod, &o.PaymentStatus, &o.PaymentRef,
+		&o.PaidAmount, &o.ShippingAddress, &o.CancelReason, &o.CancelledAt, &o.CancelledBy, &o.ExpiresAt, &o.TaxBuyer, &o.VATRate, &o.VATAmount,
+		&o.TaxInvoiceNo, &o.TaxInvoiceAt, &o.RefundedAmount, &o.CustomerNote, &o.CreatedAt, &o.UpdatedAt}
+}
+
 func CreateOrder(c *fiber.Ctx) error {
 	userID, _ := c.Locals("user_id").(string)
 	if userID == "" {
@@ -37,12 +56,58 @@ func CreateOrder(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
 	}
-	if len(req.Items) == 0 {
+	return placeOrder(c, userID, req, nil)
+}
+
+// placeOrder ตรวจ request, ตัดสต็อก และสร้างออเดอร์ใน transaction เดียว
+// guest != nil = สั่งซื้อแบบไม่มีบัญชี (userID เป็น guestUserID ของอีเมล)
+func placeOrder(c *fiber.Ctx, userID string, req models.CreateOrderReq, guest *guestOrder) error {
+	if req.CartID != "" && len(req.Items) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "send either items or cart_id, not both"})
+	}
+	if req.CartID == "" && len(req.Items) == 0 && len(req.GiftCards) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "items is empty"})
 	}
 	if req.PaymentMethod == "" {
 		req.PaymentMethod = "COD"
 	}
+	if len(req.GiftCards) > maxGiftCardsPerOrder {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errGiftCardTooMany.Error()})
+	}
+	var giftTotal models.Money
+	for i := range req.GiftCards {
+		if err := normalizeGiftCardPurchase(&req.GiftCards[i]); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		giftTotal += req.GiftCards[i].Amount
+	}
+	// บัตรเปิดใช้เมื่อเงินผ่านการตรวจ (gateway/สลิป) เท่านั้น จึงเก็บเงินปลายทางไม่ได้
+	if len(req.GiftCards) > 0 && strings.EqualFold(req.PaymentMethod, models.PayMethodCOD) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "orders with gift cards cannot be paid by COD"})
+	}
+	if req.GiftCardAmount < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errGiftCardRedeemAmount.Error()})
+	}
+	if req.ShippingAddress != nil {
+		if msg := normalizeAddress(req.ShippingAddress); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+		}
+	}
+	if req.TaxInvoice != nil {
+		if msg := normalizeTaxBuyer(req.TaxInvoice); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+		}
+	}
+	if req.StoreCredit < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errCreditNegative.Error()})
+	}
+	if (req.StoreCredit > 0 || strings.EqualFold(req.PaymentMethod, models.PayMethodStoreCredit)) && guest != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errCreditNoCustomer.Error()})
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(req.Note) > maxOrderNoteLen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("note must be at most %d characters", maxOrderNoteLen)})
+	}
 
 	conn, err := condb.DB_Lek()
 	if err != nil {
@@ -56,81 +121,202 @@ func CreateOrder(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
 	}
 	defer tx.Rollback(ctx)
+	if err := setActorTx(ctx, tx, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
 
-	type calc struct {
-		ProductID string
-		Name      string
-		Price     float64
-		Qty       int
-		Variant   string
-		Line      float64
+	if req.CartID != "" {
+		req.Items, err = lockCartItemsTx(ctx, tx, req.CartID, userID)
+		if err == errCartNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err == errCartEmpty {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load cart failed"})
+		}
 	}
 
-	var lines []calc
-	var grand float64
+	tax, err := loadTaxSettings(ctx, tx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load tax settings failed"})
+	}
+
+	var lines []orderLine
+	var lineVAT []models.Money
+	var subtotal, vat models.Money
+	var totalQty, totalWeight int
 
 	for _, it := range req.Items {
-		if it.ProductID == "" || it.Quantity <= 0 {
+		if (it.ProductID == "" && it.VariantID == nil && it.SKU == "") || it.Quantity <= 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid item"})
 		}
-		var (
-			name      string
-			stockQty  int
-			sellPrice float64
-		)
-		row := tx.QueryRow(ctx, `
-			SELECT name, quantity, sell_price
-			FROM products
-			WHERE product_id = $1
-			FOR UPDATE
-		`, it.ProductID)
-		if err := row.Scan(&name, &stockQty, &sellPrice); err != nil {
+		l, err := resolveOrderLine(ctx, tx, it, true)
+		switch err {
+		case nil:
+		case errProductNotFound:
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("product not found: %s", it.ProductID)})
+		case errVariantNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "variant not found", "product_id": it.ProductID})
+		case errVariantRequired:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "product_id": l.ProductID})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load product failed"})
 		}
-		if stockQty < it.Quantity {
+		if l.Available < l.Qty {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 				"error":       "insufficient stock",
-				"product_id":  it.ProductID,
-				"stock_left":  stockQty,
-				"request_qty": it.Quantity,
+				"product_id":  l.ProductID,
+				"variant_id":  l.VariantID,
+				"stock_left":  l.Available,
+				"request_qty": l.Qty,
 			})
 		}
-		line := float64(it.Quantity) * sellPrice
-		grand += line
-		lines = append(lines, calc{
-			ProductID: it.ProductID,
-			Name:      name,
-			Price:     sellPrice,
-			Qty:       it.Quantity,
-			Variant:   *it.Variant,
-			Line:      line,
-		})
+		subtotal += l.Price.Mul(l.Qty)
+		lineVAT = append(lineVAT, tax.VATOf(l.Price.Mul(l.Qty)))
+		vat += lineVAT[len(lineVAT)-1]
+		totalQty += l.Qty
+		totalWeight += l.Qty * l.WeightGrams
+		lines = append(lines, l)
+	}
+
+	// ออเดอร์ที่มีแต่บัตรของขวัญไม่ต้องจัดส่ง
+	var shipTo *models.ShippingAddress
+	var method *models.ShippingMethod
+	if len(lines) > 0 {
+		shipTo, err = resolveShippingAddressTx(ctx, tx, userID, &req)
+		if err == errAddressNotFound || err == errAddressRequired {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "resolve shipping address failed"})
+		}
+
+		method, err = loadShippingMethodTx(ctx, tx, req.ShippingMethod)
+		if err == errShippingMethodNotFound {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "load shipping method failed"})
+		}
 	}
 
+	var shipCode *string
+	var shipFee models.Money
+	if method != nil {
+		shipCode = &method.Code
+		shipFee = shippingFee(*method, subtotal, totalQty, totalWeight)
+	}
+	// VAT คิดรายบรรทัดแล้วรวม บวก VAT ของค่าส่ง; ราคาไม่รวม VAT ต้องบวกเข้ายอดชำระ
+	vat += tax.VATOf(shipFee)
+	grand := subtotal + shipFee
+	if !tax.PricesIncludeVAT {
+		grand += vat
+	}
+	// บัตรของขวัญ/เครดิตร้านจ่ายได้เฉพาะค่าสินค้า+ค่าส่ง ไม่รวมบัตรของขวัญที่ซื้อในออเดอร์นี้
+	payable := grand
+	grand += giftTotal
+
+	var card models.GiftCard
+	var cardAmount models.Money
+	if req.GiftCardCode != "" {
+		card, err = lockGiftCardTx(ctx, tx, req.GiftCardCode)
+		if err == nil {
+			cardAmount, err = giftCardRedeemAmount(card, req.GiftCardAmount, payable)
+		}
+		if err != nil {
+			return c.Status(giftCardErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		payable -= cardAmount
+	}
+
+	// เครดิตร้านใช้ได้ไม่เกินยอดที่เหลือ; บัตร+เครดิตครอบคลุมทั้งหมด = ไม่ต้องรอจ่าย
+	credit := req.StoreCredit
+	if strings.EqualFold(req.PaymentMethod, models.PayMethodStoreCredit) || credit > payable {
+		credit = payable
+	}
+	due := grand - cardAmount - credit
+	if due == 0 && cardAmount > 0 {
+		req.PaymentMethod = models.PayMethodGiftCard
+	} else if due == 0 && credit > 0 {
+		req.PaymentMethod = models.PayMethodStoreCredit
+	}
+	expiresAt := orderExpiresAt(req.PaymentMethod, time.Now())
+
 	var orderID int64
 	if err := tx.QueryRow(ctx, `
-		INSERT INTO orders (user_id, total, status, payment_method, payment_status)
-		VALUES ($1, $2, 'pending', $3, $4)
+		INSERT INTO orders (user_id, subtotal, shipping_method, shipping_fee, total, status, payment_method, payment_status,
+			shipping_address, expires_at, tax_buyer, vat_rate, vat_amount, customer_note,
+			guest_email, guest_phone, guest_token_hash, gift_card_total)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6, $7, $8, $9, $10, $11, $12, NULLIF($13,''),
+			NULLIF($14,''), NULLIF($15,''), NULLIF($16,''), $17)
 		RETURNING id
-	`, userID, grand, req.PaymentMethod, paymentStatusInitial(req.PaymentMethod)).Scan(&orderID); err != nil {
+	`, userID, subtotal, shipCode, shipFee, grand, req.PaymentMethod, paymentStatusInitial(req.PaymentMethod),
+		shipTo, expiresAt, req.TaxInvoice, tax.EffectiveRate(), vat, req.Note,
+		guest.email(), guest.phone(), guest.tokenHash(), giftTotal).Scan(&orderID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create order failed"})
 	}
 
-	for _, l := range lines {
+	for i, l := range lines {
 		if _, err := tx.Exec(ctx, `
-			INSERT INTO order_items (order_id, product_id, name, price, quantity, variant)
-			VALUES ($1, $2, $3, $4, $5, NULLIF($6,''))
-		`, orderID, l.ProductID, l.Name, l.Price, l.Qty, l.Variant); err != nil {
+			INSERT INTO order_items (order_id, product_id, variant_id, sku, name, price, quantity, variant, vat_amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8,''), $9)
+		`, orderID, l.ProductID, l.VariantID, l.SKU, l.Name, l.Price, l.Qty, l.Variant, lineVAT[i]); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "insert order_items failed"})
 		}
-		if _, err := tx.Exec(ctx, `
-			UPDATE stock SET quantity = quantity - $1, updated_at = NOW()
-			WHERE product_id = $2
-		`, l.Qty, l.ProductID); err != nil {
+		if _, err := moveStockTx(ctx, tx, stockMove{
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			Kind:      models.StockMoveOrder,
+			Change:    -l.Qty,
+			RefType:   "order",
+			RefID:     strconv.FormatInt(orderID, 10),
+			CreatedBy: userID,
+		}); err != nil {
+			if err == errInsufficientStock {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "insufficient stock", "product_id": l.ProductID, "variant_id": l.VariantID})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "update stock failed"})
 		}
 	}
 
+	// บัตรของขวัญที่ซื้อรอเปิดใช้เมื่อออเดอร์ชำระครบ
+	for _, g := range req.GiftCards {
+		if _, err := insertGiftCardTx(ctx, tx, giftCardFromPurchase(g, models.GiftCardPending, models.GiftCardChannelOnline,
+			"", &orderID, userID, "")); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create gift card failed"})
+		}
+	}
+
+	if cardAmount > 0 {
+		if err := redeemGiftCardTx(ctx, tx, card, paymentEntry{OrderID: orderID, Amount: cardAmount, Note: "gift card", CreatedBy: userID}); err != nil {
+			return c.Status(giftCardErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if credit > 0 {
+		err := redeemStoreCreditTx(ctx, tx, userID, paymentEntry{OrderID: orderID, Amount: credit, Note: "store credit", CreatedBy: userID})
+		if err == errCreditInsufficient {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "apply store credit failed"})
+		}
+	}
+	if cardAmount > 0 || credit > 0 {
+		if _, err := syncOrderPaymentTx(ctx, tx, orderID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "update payment status failed"})
+		}
+	}
+
+	if req.CartID != "" {
+		if _, err := tx.Exec(ctx, `
+			UPDATE carts SET status = $1, order_id = $2, updated_at = NOW() WHERE id = $3
+		`, models.CartStatusCheckedOut, orderID, req.CartID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "close cart failed"})
+		}
+	}
+
 	var next *models.NextAction
 	switch strings.ToUpper(req.PaymentMethod) {
 	case "BANK_TRANSFER":
@@ -139,27 +325,47 @@ func CreateOrder(c *fiber.Ctx) error {
 		next = &models.NextAction{
 			Type:        "SHOW_PROMPTPAY",
 			QRImageURL:  fmt.Sprintf("/api/orders/%d/promptpay-qr.png", orderID),
-			PayloadText: fmt.Sprintf("PromptPay สำหรับออเดอร์ #%d ยอดชำระ %.2f บาท", orderID, grand),
+			PayloadText: fmt.Sprintf("PromptPay สำหรับออเดอร์ #%d ยอดชำระ %s บาท", orderID, due),
 		}
 	case "CARD":
-		next = &models.NextAction{
-			Type: "REDIRECT_GATEWAY",
-			URL:  fmt.Sprintf("https://example-gateway.test/pay?order_id=%d&amount=%.2f", orderID, grand),
-		}
+		// URL ของ gateway สร้างหลัง commit เพื่อให้ webhook หาออเดอร์เจอเสมอ
+		next = &models.NextAction{Type: "REDIRECT_GATEWAY"}
 	default:
 		next = &models.NextAction{Type: "NONE"}
 	}
+	next.ExpiresAt = expiresAt
 
 	if err := tx.Commit(ctx); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
 	}
 
-	return c.JSON(models.CreateOrderResp{
-		OrderID:    orderID,
-		Total:      grand,
-		Message:    "สร้างคำสั่งซื้อสำเร็จ",
-		NextAction: next,
-	})
+	if next.Type == "REDIRECT_GATEWAY" {
+		if in, err := startPaymentIntent(ctx, conn, orderID, due); err != nil {
+			// ออเดอร์สร้างแล้ว ลูกค้าเริ่มจ่ายใหม่ได้ภายหลัง
+			log.Printf("payment intent for order %d: %v", orderID, err)
+			next.PayloadText = fmt.Sprintf("ยังเชื่อมต่อระบบชำระเงินไม่ได้ กรุณาลองใหม่ที่ POST /api/orders/%d/payment-intent", orderID)
+		} else if in.RedirectURL != nil {
+			next.URL = *in.RedirectURL
+		}
+	}
+
+	resp := models.CreateOrderResp{
+		OrderID:     orderID,
+		Subtotal:    subtotal,
+		ShippingFee: shipFee,
+		Total:       grand,
+		VATAmount:   vat,
+		StoreCredit: credit,
+		GiftCard:    cardAmount,
+		AmountDue:   due,
+		Message:     "สร้างคำสั่งซื้อสำเร็จ",
+		NextAction:  next,
+	}
+	if guest != nil {
+		resp.GuestToken = guest.Token
+		resp.LookupURL = fmt.Sprintf("/api/guest/orders/%d?token=%s", orderID, guest.Token)
+	}
+	return c.JSON(resp)
 }
 
 func GetOrders(c *fiber.Ctx) error {
@@ -172,12 +378,14 @@ func GetOrders(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	offset, _ := strconv.Atoi(c.Query("offset", "0"))
 	userID := c.Query("user_id", "")
+	// ลูกค้าเห็นเฉพาะออเดอร์ของตัวเอง; พนักงานกรองตาม user_id ได้
+	if !isStaff(c) {
+		userID, _ = c.Locals("user_id").(string)
+	}
 
 	ctx := context.Background()
 
-	query := `
-		SELECT id, user_id, total, status, payment_method, payment_status, payment_ref, created_at, updated_at
-		FROM orders`
+	query := `SELECT ` + orderColumns + ` FROM orders`
 	var rows pgx.Rows // <- ถ้าคุณ import "github.com/jackc/pgx/v4"
 	var qerr error
 
@@ -196,7 +404,7 @@ func GetOrders(c *fiber.Ctx) error {
 	var list []models.Order
 	for rows.Next() {
 		var o models.Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Total, &o.Status, &o.PaymentMethod, &o.PaymentStatus, &o.PaymentRef, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		if err := scanOrder(rows, &o); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
 		list = append(list, o)
@@ -219,17 +427,21 @@ func GetOrderByID(c *fiber.Ctx) error {
 	ctx := context.Background()
 
 	var o models.Order
-	err = conn.QueryRow(ctx, `
-		SELECT id, user_id, total, status, payment_method, payment_status, payment_ref, created_at, updated_at
-		FROM orders WHERE id = $1
-	`, id).Scan(&o.ID, &o.UserID, &o.Total, &o.Status, &o.PaymentMethod, &o.PaymentStatus, &o.PaymentRef, &o.CreatedAt, &o.UpdatedAt)
+	err = scanOrder(conn.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, id), &o)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "order not found"})
 	}
+	// ดูได้เฉพาะพนักงาน, ลูกค้าเจ้าของออเดอร์ หรือ guest ที่มี token ของออเดอร์ (ไม่บอกว่ามีออเดอร์นี้อยู่)
+	if !canAccessCustomer(c, o.UserID) {
+		if _, err := checkGuestOrderToken(ctx, conn, id, guestToken(c)); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "order not found"})
+		}
+	}
 
 	rows, err := conn.Query(ctx, `
-		SELECT id, order_id, product_id, name, price, quantity, variant
-		FROM order_items WHERE order_id = $1
+		SELECT oi.id, oi.order_id, oi.product_id, oi.variant_id, oi.sku, oi.name, oi.price, oi.quantity, oi.vat_amount, oi.variant,
+		       `+shippedQtySQL+`
+		FROM order_items oi WHERE oi.order_id = $1 ORDER BY oi.id
 	`, id)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
@@ -239,22 +451,54 @@ func GetOrderByID(c *fiber.Ctx) error {
 	var items []models.OrderItem
 	for rows.Next() {
 		var it models.OrderItem
-		if err := rows.Scan(&it.ID, &it.OrderID, &it.ProductID, &it.Name, &it.Price, &it.Quantity, &it.Variant); err != nil {
+		if err := rows.Scan(&it.ID, &it.OrderID, &it.ProductID, &it.VariantID, &it.SKU, &it.Name, &it.Price, &it.Quantity, &it.VATAmount, &it.Variant, &it.ShippedQuantity); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
 		items = append(items, it)
 	}
 
+	shipments, err := getShipments(ctx, conn, o.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	returns, err := listReturns(ctx, conn, `order_id = $1 ORDER BY id`, o.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	timeline, err := orderTimeline(ctx, conn, o, shipments, returns, isStaff(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	return c.JSON(fiber.Map{
-		"order": o,
-		"items": items,
+		"order":     o,
+		"items":     items,
+		"shipments": shipments,
+		"returns":   returns,
+		"timeline":  timeline,
 	})
 }
 
+// canSetOrderStatus สถานะที่พนักงานเปลี่ยนเองผ่าน PUT ได้: เริ่มเตรียมของ (processing) หลังชำระแล้ว หรือ COD ที่รอเก็บเงิน
+// paid มาจากสมุดรับเงิน, shipped/partially_shipped จากการส่งของ, cancelled จาก /cancel
+func canSetOrderStatus(from, to, paymentMethod string) bool {
+	if to != models.OrderStatusProcessing {
+		return false
+	}
+	return from == models.OrderStatusPaid ||
+		(from == models.OrderStatusPending && strings.ToUpper(paymentMethod) == models.PayMethodCOD)
+}
+
 type updateOrderReq struct {
 	Status        *string `json:"status"`
 	PaymentStatus *string `json:"payment_status"`
 	PaymentRef    *string `json:"payment_ref"`
+
+	// ต้องส่งมาด้วยเมื่อเปลี่ยน status เป็น shipped
+	Carrier    string `json:"carrier,omitempty"`
+	TrackingNo string `json:"tracking_no,omitempty"`
 }
 
 func UpdateOrder(c *fiber.Ctx) error {
@@ -270,42 +514,89 @@ func UpdateOrder(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid payload"})
 	}
 
+	// ยกเลิกต้องผ่าน /cancel เพื่อคืนสต็อก; ส่งของต้องมีเลขพัสดุ
+	shipping := false
+	if req.Status != nil {
+		switch *req.Status {
+		case models.OrderStatusCancelled:
+			return c.Status(400).JSON(fiber.Map{"error": "use POST /admin/orders/:order_id/cancel to cancel an order"})
+		case models.OrderStatusShipped:
+			req.Carrier = strings.TrimSpace(req.Carrier)
+			req.TrackingNo = strings.TrimSpace(req.TrackingNo)
+			if req.Carrier == "" || req.TrackingNo == "" {
+				return c.Status(400).JSON(fiber.Map{"error": "carrier and tracking_no are required when status is shipped"})
+			}
+			shipping = true
+			req.Status = nil
+		}
+	}
+
+	// payment_status คำนวณจากสมุดรับเงินเท่านั้น; รับเงินต้องบันทึกผ่าน /payments (ระบุช่องทางและเลขอ้างอิง)
+	if req.PaymentStatus != nil || req.PaymentRef != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "payment_status is derived from payments; use POST /admin/orders/:order_id/payments"})
+	}
+	if req.Status == nil && !shipping {
+		return c.Status(400).JSON(fiber.Map{"error": "no fields to update"})
+	}
+
 	ctx := context.Background()
-	sets := []string{}
-	args := []interface{}{}
-	i := 1
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+	staffID, _ := c.Locals("user_id").(string)
+	if err := setActorTx(ctx, tx, staffID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "begin tx failed"})
+	}
 
-	if req.Status != nil {
-		sets = append(sets, fmt.Sprintf("status = $%d", i))
-		args = append(args, *req.Status)
-		i++
+	o, err := lockOrderTx(ctx, tx, id)
+	if err == errOrderNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "order not found"})
 	}
-	if req.PaymentStatus != nil {
-		sets = append(sets, fmt.Sprintf("payment_status = $%d", i))
-		args = append(args, *req.PaymentStatus)
-		i++
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	if req.PaymentRef != nil {
-		sets = append(sets, fmt.Sprintf("payment_ref = $%d", i))
-		args = append(args, *req.PaymentRef)
-		i++
+	var paymentMethod string
+	if err := tx.QueryRow(ctx, `SELECT payment_method FROM orders WHERE id = $1`, o.ID).Scan(&paymentMethod); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	orderID, status := o.ID, o.Status
 
-	if len(sets) == 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "no fields to update"})
+	if req.Status != nil {
+		if !canSetOrderStatus(status, *req.Status, paymentMethod) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "cannot change status from " + status + " to " + *req.Status})
+		}
+		if _, err := tx.Exec(ctx, `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`, *req.Status, orderID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		status = *req.Status
 	}
-	sets = append(sets, "updated_at = NOW()")
 
-	args = append(args, id)
-	query := fmt.Sprintf("UPDATE orders SET %s WHERE id = $%d", strings.Join(sets, ","), i)
+	if shipping {
+		if !canShipOrder(status, paymentMethod) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "order cannot be shipped in status " + status})
+		}
+		_, err := shipOrderTx(ctx, tx, orderID, models.ShipOrderReq{Carrier: req.Carrier, TrackingNo: req.TrackingNo}, staffID)
+		if status, ok := shipErrorStatus(err); ok {
+			return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "create shipment failed"})
+		}
+	}
 
-	if _, err := conn.Exec(ctx, query, args...); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "commit failed"})
 	}
 
 	return c.JSON(fiber.Map{"message": "updated"})
 }
 
+// ====================
+// ลบออเดอร์ถาวร (purge) — เฉพาะเจ้าของร้าน
+// ไม่คืนสต็อก ถ้าต้องการคืนสต็อกให้ใช้ POST /orders/:order_id/cancel; ออเดอร์ที่มีรายการในสมุดรับเงินลบไม่ได้
+// ====================
 func DeleteOrder(c *fiber.Ctx) error {
 	conn, err := condb.DB_Lek()
 	if err != nil {
@@ -316,12 +607,40 @@ func DeleteOrder(c *fiber.Ctx) error {
 	id := c.Params("order_id")
 	ctx := context.Background()
 
-	res, err := conn.Exec(ctx, `DELETE FROM orders WHERE id = $1`, id)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := lockOrderTx(ctx, tx, id)
+	if err == errOrderNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	// สมุดรับเงินห้ามหาย: ออเดอร์ที่มีรายการเงินแล้วต้องยกเลิก/คืนเงินแทน
+	var hasPayments bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM payments WHERE order_id = $1)`, o.ID).Scan(&hasPayments); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if hasPayments {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "order has payments; cancel or refund it instead of deleting"})
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM order_items WHERE order_id = $1`, o.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	res, err := tx.Exec(ctx, `DELETE FROM orders WHERE id = $1`, o.ID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 	if res.RowsAffected() == 0 {
 		return c.Status(404).JSON(fiber.Map{"error": "order not found"})
 	}
-	return c.JSON(fiber.Map{"message": "deleted"})
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.JSON(fiber.Map{"message": "purged"})
 }
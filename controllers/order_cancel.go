@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var errOrderNotFound = errors.New("order not found")
+
+// สถานะที่ admin ยังยกเลิกได้ (ยังไม่ส่งของ)
+var adminCancellableStatuses = []string{
+	models.OrderStatusPending,
+	models.OrderStatusPaid,
+	models.OrderStatusProcessing,
+}
+
+type lockedOrder struct {
+	ID            int64
+	UserID        string
+	Status        string
+	PaymentStatus string
+	ExpiresAt     *time.Time
+}
+
+// lockOrderTx ล็อกแถว orders (FOR UPDATE) เพื่อกันยกเลิก/แก้ไขซ้อนกัน
+func lockOrderTx(ctx context.Context, tx pgx.Tx, orderID string) (lockedOrder, error) {
+	var o lockedOrder
+	err := tx.QueryRow(ctx, `
+		SELECT id, user_id, status, payment_status, expires_at
+		FROM orders WHERE id = $1
+		FOR UPDATE
+	`, orderID).Scan(&o.ID, &o.UserID, &o.Status, &o.PaymentStatus, &o.ExpiresAt)
+	if err == pgx.ErrNoRows {
+		return o, errOrderNotFound
+	}
+	return o, err
+}
+
+// cancelOrderTx คืนสต็อกของทุกรายการในออเดอร์, บันทึกเหตุผล และ void/refund การชำระเงิน
+// ต้องเรียกหลัง lockOrderTx ใน transaction เดียวกัน; คืนค่า payment_status ใหม่
+func cancelOrderTx(ctx context.Context, tx pgx.Tx, o lockedOrder, reason, cancelledBy string) (string, error) {
+	if err := setActorTx(ctx, tx, cancelledBy); err != nil {
+		return "", err
+	}
+	rows, err := tx.Query(ctx, `SELECT product_id, variant_id, quantity FROM order_items WHERE order_id = $1`, o.ID)
+	if err != nil {
+		return "", err
+	}
+	type line struct {
+		ProductID string
+		VariantID *int64
+		Qty       int
+	}
+	var lines []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.ProductID, &l.VariantID, &l.Qty); err != nil {
+			rows.Close()
+			return "", err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	for _, l := range lines {
+		if _, err := moveStockTx(ctx, tx, stockMove{
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			Kind:      models.StockMoveCancel,
+			Change:    l.Qty,
+			RefType:   "order",
+			RefID:     strconv.FormatInt(o.ID, 10),
+			Note:      reason,
+			CreatedBy: cancelledBy,
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE orders
+		SET status = $1, cancel_reason = $2, cancelled_at = NOW(), cancelled_by = $3, updated_at = NOW()
+		WHERE id = $4
+	`, models.OrderStatusCancelled, reason, cancelledBy, o.ID); err != nil {
+		return "", err
+	}
+
+	// สลิปที่ยังรอตรวจไม่ต้องตรวจแล้ว
+	if _, err := tx.Exec(ctx, `
+		UPDATE payment_slips SET status = $2, reason = 'order cancelled', reviewed_by = NULLIF($3,''), reviewed_at = NOW()
+		WHERE order_id = $1 AND status = $4
+	`, o.ID, models.SlipStatusRejected, cancelledBy, models.SlipStatusSubmitted); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE payments SET status = $2, updated_at = NOW()
+		WHERE order_id = $1 AND kind = 'payment' AND status IN ($3, $4)
+	`, o.ID, models.PaymentVoided, models.PaymentPending, models.PaymentReview); err != nil {
+		return "", err
+	}
+
+	// เครดิตร้าน/บัตรของขวัญที่ใช้จ่ายคืนเข้าบัญชีลูกค้า/บัตรทันที; บัตรที่ซื้อในออเดอร์ถูกยกเลิก
+	if err := restoreOrderCreditTx(ctx, tx, o, reason, cancelledBy); err != nil {
+		return "", err
+	}
+	if _, err := restoreGiftCardsTx(ctx, tx, "order_id", o.ID, 0, "order cancelled", cancelledBy); err != nil {
+		return "", err
+	}
+	if err := voidOrderGiftCardsTx(ctx, tx, o.ID, reason, cancelledBy); err != nil {
+		return "", err
+	}
+
+	// ได้รับเงินแล้ว → refund_pending, ยังไม่ได้รับ → voided (คำนวณจากสมุดรับเงิน)
+	return syncOrderPaymentTx(ctx, tx, o.ID)
+}
+
+// ====================
+// ลูกค้ายกเลิกออเดอร์ของตัวเอง (เฉพาะสถานะ pending)
+// POST /orders/:order_id/cancel   body: { "reason": "..." }
+// ====================
+func CancelOrder(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req models.CancelOrderReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "customer_request"
+	}
+
+	return cancelOrderHandler(c, reason, userID, func(o lockedOrder) (int, string) {
+		if o.UserID != userID {
+			// ไม่บอกว่ามีออเดอร์นี้อยู่ ถ้าไม่ใช่เจ้าของ
+			return fiber.StatusNotFound, "order not found"
+		}
+		if o.Status != models.OrderStatusPending {
+			return fiber.StatusConflict, "only pending orders can be cancelled"
+		}
+		return 0, ""
+	})
+}
+
+// ====================
+// admin ยกเลิกออเดอร์ที่ยังไม่ส่งของ
+// POST /admin/orders/:order_id/cancel   body: { "reason": "..." } (required)
+// ====================
+func AdminCancelOrder(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.CancelOrderReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+
+	return cancelOrderHandler(c, reason, staffID, func(o lockedOrder) (int, string) {
+		for _, s := range adminCancellableStatuses {
+			if o.Status == s {
+				return 0, ""
+			}
+		}
+		return fiber.StatusConflict, "order cannot be cancelled in status " + o.Status
+	})
+}
+
+// cancelOrderHandler ส่วนที่ใช้ร่วมกันของ CancelOrder/AdminCancelOrder;
+// check คืน (status, message) ถ้าไม่อนุญาต หรือ (0, "") ถ้ายกเลิกได้
+func cancelOrderHandler(c *fiber.Ctx, reason, cancelledBy string, check func(lockedOrder) (int, string)) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := lockOrderTx(ctx, tx, c.Params("order_id"))
+	if err == errOrderNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if status, msg := check(o); status != 0 {
+		if status == fiber.StatusNotFound {
+			return c.Status(status).JSON(fiber.Map{"error": msg})
+		}
+		return c.Status(status).JSON(fiber.Map{"error": msg, "status": o.Status})
+	}
+
+	payStatus, err := cancelOrderTx(ctx, tx, o, reason, cancelledBy)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cancel order failed"})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "order cancelled",
+		"order_id":       o.ID,
+		"status":         models.OrderStatusCancelled,
+		"payment_status": payStatus,
+		"reason":         reason,
+	})
+}
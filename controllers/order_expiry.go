@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// เวลาที่ให้ลูกค้าชำระเงินก่อนออเดอร์ถูกยกเลิกอัตโนมัติ แยกตามวิธีชำระเงิน
+// ตั้งค่าผ่าน env ORDER_EXPIRY_<PAYMENT_METHOD> เช่น ORDER_EXPIRY_PROMPTPAY=15m, ORDER_EXPIRY_BANK_TRANSFER=24h
+// ค่า "0" หรือ "off" = ไม่หมดอายุ; วิธีที่ไม่มีค่า default และไม่ได้ตั้ง env ก็ไม่หมดอายุ (เช่น COD)
+var defaultOrderExpiry = map[string]time.Duration{
+	models.PayMethodPromptPay: 15 * time.Minute,
+	models.PayMethodCard:      30 * time.Minute,
+}
+
+const (
+	orderExpiryReason    = "payment_timeout"
+	orderExpiryActor     = "system"
+	orderExpiryBatchSize = 100
+)
+
+// orderExpiryWindow ระยะเวลารอชำระของวิธีชำระเงินนี้ (0 = ไม่หมดอายุ)
+func orderExpiryWindow(paymentMethod string) time.Duration {
+	method := strings.ToUpper(strings.TrimSpace(paymentMethod))
+	v := strings.TrimSpace(os.Getenv("ORDER_EXPIRY_" + method))
+	if v == "" {
+		return defaultOrderExpiry[method]
+	}
+	if v == "0" || strings.EqualFold(v, "off") {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("order expiry: invalid ORDER_EXPIRY_%s=%q, using default", method, v)
+		return defaultOrderExpiry[method]
+	}
+	return d
+}
+
+// orderExpiresAt เวลาหมดอายุของออเดอร์ใหม่ (nil = ไม่หมดอายุ)
+func orderExpiresAt(paymentMethod string, now time.Time) *time.Time {
+	d := orderExpiryWindow(paymentMethod)
+	if d <= 0 {
+		return nil
+	}
+	t := now.Add(d).UTC().Truncate(time.Second)
+	return &t
+}
+
+// isOrderExpirable ออเดอร์ที่ยกเลิกอัตโนมัติได้: ยัง pending, ยังไม่ได้รับเงิน และเลยเวลาแล้ว
+// partially_paid ต้องตรวจเพิ่มว่ารับเงินเฉพาะจากเครดิตร้าน/บัตรของขวัญ (paidOnlyWithCreditTx)
+func isOrderExpirable(o lockedOrder, now time.Time) bool {
+	if o.Status != models.OrderStatusPending || o.ExpiresAt == nil || o.ExpiresAt.After(now) {
+		return false
+	}
+	return o.PaymentStatus == models.PayStatusPending || o.PaymentStatus == models.PayStatusFailed ||
+		o.PaymentStatus == models.PayStatusPartial
+}
+
+// StartOrderExpiryScheduler วนยกเลิกออเดอร์ที่หมดเวลาชำระทุก ORDER_EXPIRY_SCAN_INTERVAL (default 1m)
+// จนกว่า ctx ถูกยกเลิก; รันหลาย instance พร้อมกันได้เพราะแต่ละออเดอร์ล็อกแถวก่อนยกเลิก
+func StartOrderExpiryScheduler(ctx context.Context) {
+	interval := time.Minute
+	if v := os.Getenv("ORDER_EXPIRY_SCAN_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("order expiry: invalid ORDER_EXPIRY_SCAN_INTERVAL=%q, using %s", v, interval)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := ExpireUnpaidOrders(ctx); err != nil {
+			log.Println("order expiry:", err)
+		} else if n > 0 {
+			log.Printf("order expiry: cancelled %d unpaid orders", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireUnpaidOrders ยกเลิกออเดอร์ที่เลยเวลาชำระ (คืนสต็อกผ่าน cancelOrderTx) คืนจำนวนที่ยกเลิก
+func ExpireUnpaidOrders(ctx context.Context) (int, error) {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(context.Background())
+
+	cancelled := 0
+	for {
+		rows, err := conn.Query(ctx, `
+			SELECT id FROM orders
+			WHERE status = $1 AND expires_at IS NOT NULL AND expires_at <= NOW()
+			  AND (payment_status IN ($2, $3)
+			       OR (payment_status = $5 AND NOT EXISTS (
+			           SELECT 1 FROM payments p
+			           WHERE p.order_id = orders.id AND p.kind = 'payment' AND p.status = 'succeeded' AND p.method NOT IN ($6, $7))))
+			ORDER BY expires_at
+			LIMIT $4
+		`, models.OrderStatusPending, models.PayStatusPending, models.PayStatusFailed, orderExpiryBatchSize,
+			models.PayStatusPartial, models.PayMethodStoreCredit, models.PayMethodGiftCard)
+		if err != nil {
+			return cancelled, err
+		}
+		var ids []string
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return cancelled, err
+			}
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return cancelled, err
+		}
+
+		batch := 0
+		for _, id := range ids {
+			ok, err := expireOrder(ctx, conn, id)
+			if err != nil {
+				log.Printf("order expiry: order %s: %v", id, err)
+				continue
+			}
+			if ok {
+				batch++
+			}
+		}
+		cancelled += batch
+		// batch ไม่เต็ม = หมดแล้ว; ทั้ง batch ยกเลิกไม่ได้เลย = กันวนไม่รู้จบ
+		if len(ids) < orderExpiryBatchSize || batch == 0 {
+			return cancelled, nil
+		}
+	}
+}
+
+// expireOrder ยกเลิกออเดอร์เดียวใน transaction ของตัวเอง; ตรวจเงื่อนไขซ้ำหลังล็อกแถว
+// (ลูกค้าอาจจ่ายเงินหรือ admin เปลี่ยนสถานะระหว่างนั้น) คืน false ถ้าไม่ต้องยกเลิกแล้ว
+func expireOrder(ctx context.Context, conn *pgx.Conn, orderID string) (bool, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := lockOrderTx(ctx, tx, orderID)
+	if err == errOrderNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !isOrderExpirable(o, time.Now()) {
+		return false, nil
+	}
+	// จ่ายด้วยเครดิตร้าน/บัตรของขวัญบางส่วนแล้วไม่จ่ายส่วนที่เหลือ = ยกเลิกและคืนยอด
+	if o.PaymentStatus == models.PayStatusPartial {
+		ok, err := paidOnlyWithCreditTx(ctx, tx, o.ID)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if _, err := cancelOrderTx(ctx, tx, o, orderExpiryReason, orderExpiryActor); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// วันที่ใน from/to แบบ YYYY-MM-DD ตีความเป็นวันตามเวลาไทย
+var orderSearchZone = time.FixedZone("ICT", 7*60*60)
+
+// การเรียงที่รองรับ: คอลัมน์ keyset + ทิศทาง
+var orderSearchSorts = map[string]struct {
+	column string
+	desc   bool
+}{
+	"newest":     {"o.created_at", true},
+	"oldest":     {"o.created_at", false},
+	"total_desc": {"o.total", true},
+	"total_asc":  {"o.total", false},
+}
+
+// cursor = base64url("<ค่าคอลัมน์ที่เรียง>|<order id>") ของแถวสุดท้ายในหน้าก่อน
+func encodeOrderCursor(sort string, o models.Order) string {
+	v := o.CreatedAt.UTC().Format(time.RFC3339Nano)
+	if orderSearchSorts[sort].column == "o.total" {
+		v = o.Total.String()
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(v + "|" + strconv.FormatInt(o.ID, 10)))
+}
+
+func decodeOrderCursor(sort, cursor string) (interface{}, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, errInvalidCursor
+	}
+	i := strings.LastIndexByte(string(raw), '|')
+	if i < 0 {
+		return nil, 0, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw[i+1:]), 10, 64)
+	if err != nil {
+		return nil, 0, errInvalidCursor
+	}
+	v := string(raw[:i])
+	if orderSearchSorts[sort].column == "o.total" {
+		m, err := models.ParseMoney(v)
+		if err != nil {
+			return nil, 0, errInvalidCursor
+		}
+		return m, id, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return nil, 0, errInvalidCursor
+	}
+	return t, id, nil
+}
+
+// parseSearchTime รับ YYYY-MM-DD (ต้นวันเวลาไทย) หรือ RFC3339; endOfDay = เลื่อนไปต้นวันถัดไป (ใช้กับ to แบบไม่รวม)
+func parseSearchTime(s string, endOfDay bool) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, orderSearchZone); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// orderSearchFilter สร้างเงื่อนไข WHERE (alias o) จากพารามิเตอร์ค้นหา; ใช้ร่วมกับ export
+// q คืนค่าพารามิเตอร์ตามชื่อ ("" = ไม่กรอง); placeholder เริ่มที่ $1 ต่อได้ที่ $len(args)+1
+func orderSearchFilter(q func(string) string) ([]string, []interface{}, error) {
+	where := []string{"1=1"}
+	args := []interface{}{}
+	ai := 1
+
+	if v := splitCSV(q("status")); len(v) > 0 {
+		where = append(where, "o.status = ANY($"+itoa(ai)+")")
+		args = append(args, v)
+		ai++
+	}
+	if v := splitCSV(q("payment_status")); len(v) > 0 {
+		where = append(where, "o.payment_status = ANY($"+itoa(ai)+")")
+		args = append(args, v)
+		ai++
+	}
+	if v := splitCSV(strings.ToUpper(q("payment_method"))); len(v) > 0 {
+		where = append(where, "UPPER(o.payment_method) = ANY($"+itoa(ai)+")")
+		args = append(args, v)
+		ai++
+	}
+	if v := strings.TrimSpace(q("user_id")); v != "" {
+		where = append(where, "o.user_id = $"+itoa(ai))
+		args = append(args, v)
+		ai++
+	}
+	if v := q("from"); v != "" {
+		t, ok := parseSearchTime(v, false)
+		if !ok {
+			return nil, nil, errors.New("from must be YYYY-MM-DD or RFC3339")
+		}
+		where = append(where, "o.created_at >= $"+itoa(ai))
+		args = append(args, t)
+		ai++
+	}
+	if v := q("to"); v != "" {
+		t, ok := parseSearchTime(v, true)
+		if !ok {
+			return nil, nil, errors.New("to must be YYYY-MM-DD or RFC3339")
+		}
+		where = append(where, "o.created_at < $"+itoa(ai))
+		args = append(args, t)
+		ai++
+	}
+	if v := strings.TrimSpace(q("customer")); v != "" {
+		// ชื่อ-นามสกุล / อีเมล / เบอร์โทร ของลูกค้า หรือชื่อผู้รับในที่อยู่จัดส่ง
+		where = append(where, `(EXISTS (SELECT 1 FROM customer cu WHERE cu.customer_id = o.user_id AND (
+				COALESCE(cu.firstname,'') || ' ' || COALESCE(cu.lastname,'') ILIKE $`+itoa(ai)+`
+				OR COALESCE(cu.email,'') ILIKE $`+itoa(ai)+` OR COALESCE(cu.phone,'') ILIKE $`+itoa(ai)+`))
+			OR COALESCE(o.shipping_address->>'recipient','') ILIKE $`+itoa(ai)+`
+			OR COALESCE(o.shipping_address->>'phone','') ILIKE $`+itoa(ai)+`)`)
+		args = append(args, "%"+v+"%")
+		ai++
+	}
+	if v := strings.TrimSpace(q("product")); v != "" {
+		// product_id / sku ตรงตัว หรือชื่อสินค้าบางส่วน
+		where = append(where, `EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = o.id AND (
+				oi.product_id = $`+itoa(ai)+` OR oi.sku = $`+itoa(ai)+` OR oi.name ILIKE '%' || $`+itoa(ai)+` || '%'))`)
+		args = append(args, v)
+		ai++
+	}
+	for _, p := range []struct{ param, op string }{{"min_total", ">="}, {"max_total", "<="}} {
+		v := strings.TrimSpace(q(p.param))
+		if v == "" {
+			continue
+		}
+		m, err := models.ParseMoney(v)
+		if err != nil {
+			return nil, nil, errors.New("invalid " + p.param)
+		}
+		where = append(where, "o.total "+p.op+" $"+itoa(ai))
+		args = append(args, m)
+		ai++
+	}
+	return where, args, nil
+}
+
+// ====================
+// ค้นหาออเดอร์ (หลังบ้าน)
+// GET /admin/orders?status=pending,paid&payment_status=paid&payment_method=COD&from=2025-01-01&to=2025-01-31
+// ตัวกรองอื่น: customer (ชื่อ/อีเมล/เบอร์), product (product_id/sku/ชื่อ), min_total, max_total, user_id
+// sort=newest|oldest|total_desc|total_asc, limit (<= 200), cursor = next_cursor จากหน้าก่อน
+// summary = จำนวนและยอดรวมของทุกออเดอร์ที่ตรงเงื่อนไข (ส่งใน header X-Total-Count / X-Total-Amount ด้วย)
+// ====================
+func AdminSearchOrders(c *fiber.Ctx) error {
+	sort := c.Query("sort", "newest")
+	sortBy, ok := orderSearchSorts[sort]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "sort must be newest, oldest, total_desc or total_asc"})
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	where, args, err := orderSearchFilter(func(k string) string { return c.Query(k) })
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	ai := len(args) + 1
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	filter := strings.Join(where, " AND ")
+
+	var resp models.OrderSearchResp
+	err = conn.QueryRow(ctx, `SELECT COUNT(*), COALESCE(SUM(o.total), 0) FROM orders o WHERE `+filter, args...).
+		Scan(&resp.Summary.Count, &resp.Summary.Total)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	dir, cmp := "ASC", ">"
+	if sortBy.desc {
+		dir, cmp = "DESC", "<"
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		v, id, err := decodeOrderCursor(sort, cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		filter += " AND (" + sortBy.column + ", o.id) " + cmp + " ($" + itoa(ai) + ", $" + itoa(ai+1) + ")"
+		args = append(args, v, id)
+		ai += 2
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+orderColumns+`, cu.customer_name, cu.customer_email, cu.customer_phone, ic.item_count
+		FROM orders o
+		LEFT JOIN LATERAL (
+			SELECT TRIM(COALESCE(firstname,'') || ' ' || COALESCE(lastname,'')) AS customer_name,
+			       email AS customer_email, phone AS customer_phone
+			FROM customer WHERE customer_id = o.user_id
+		) cu ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT COALESCE(SUM(quantity), 0)::int AS item_count FROM order_items WHERE order_id = o.id
+		) ic ON TRUE
+		WHERE `+filter+`
+		ORDER BY `+sortBy.column+` `+dir+`, o.id `+dir+`
+		LIMIT $`+itoa(ai), append(args, limit+1)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	resp.Items = []models.OrderSearchRow{}
+	for rows.Next() {
+		var r models.OrderSearchRow
+		var name *string
+		dest := append(orderScanDest(&r.Order), &name, &r.CustomerEmail, &r.CustomerPhone, &r.ItemCount)
+		if err := rows.Scan(dest...); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if name != nil {
+			r.CustomerName = *name
+		} else if r.ShippingAddress != nil {
+			r.CustomerName = r.ShippingAddress.Recipient
+		}
+		resp.Items = append(resp.Items, r)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(resp.Items) > limit {
+		resp.Items = resp.Items[:limit]
+		resp.NextCursor = encodeOrderCursor(sort, resp.Items[limit-1].Order)
+	}
+
+	c.Set("X-Total-Count", strconv.FormatInt(resp.Summary.Count, 10))
+	c.Set("X-Total-Amount", resp.Summary.Total.String())
+	return c.JSON(resp)
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"dog/models"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	o := models.Order{
+		ID:        4021,
+		Total:     125050,
+		CreatedAt: time.Date(2025, 3, 1, 9, 30, 15, 123456789, orderSearchZone),
+	}
+
+	for _, sort := range []string{"newest", "oldest"} {
+		v, id, err := decodeOrderCursor(sort, encodeOrderCursor(sort, o))
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if got, ok := v.(time.Time); !ok || !got.Equal(o.CreatedAt) || id != o.ID {
+			t.Errorf("%s: got %v (%T), %d; want %v, %d", sort, v, v, id, o.CreatedAt, o.ID)
+		}
+	}
+	for _, sort := range []string{"total_desc", "total_asc"} {
+		v, id, err := decodeOrderCursor(sort, encodeOrderCursor(sort, o))
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if got, ok := v.(models.Money); !ok || got != o.Total || id != o.ID {
+			t.Errorf("%s: got %v (%T), %d; want %s, %d", sort, v, v, id, o.Total, o.ID)
+		}
+	}
+}
+
+func TestDecodeOrderCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"not base64", "newest", "!!!"},
+		{"padded base64", "newest", base64.URLEncoding.EncodeToString([]byte("2025-03-01T02:30:15Z|1"))},
+		{"no separator", "newest", enc("2025-03-01T02:30:15Z")},
+		{"id not a number", "newest", enc("2025-03-01T02:30:15Z|abc")},
+		{"date without time", "newest", enc("2025-03-01|1")},
+		{"total cursor on time sort", "newest", enc("1250.50|1")},
+		{"time cursor on total sort", "total_desc", enc("2025-03-01T02:30:15Z|1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeOrderCursor(tt.sort, tt.cursor); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("err = %v, want %v", err, errInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestOrderSearchFilter(t *testing.T) {
+	ict := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, orderSearchZone) }
+
+	tests := []struct {
+		name      string
+		params    map[string]string
+		wantWhere []string // ส่วนที่ต้องอยู่ในเงื่อนไขแต่ละข้อ (ถัดจาก 1=1)
+		wantArgs  []interface{}
+		wantErr   string
+	}{
+		{name: "no filters", params: nil},
+		{
+			name:      "csv filters",
+			params:    map[string]string{"status": "pending, paid,", "payment_method": "cod,promptpay"},
+			wantWhere: []string{"o.status = ANY($1)", "UPPER(o.payment_method) = ANY($2)"},
+			wantArgs:  []interface{}{[]string{"pending", "paid"}, []string{"COD", "PROMPTPAY"}},
+		},
+		{
+			name:      "date range in thai time with exclusive end",
+			params:    map[string]string{"payment_status": "paid", "from": "2025-01-01", "to": "2025-01-31"},
+			wantWhere: []string{"o.payment_status = ANY($1)", "o.created_at >= $2", "o.created_at < $3"},
+			wantArgs:  []interface{}{[]string{"paid"}, ict(2025, 1, 1), ict(2025, 2, 1)},
+		},
+		{
+			name:      "rfc3339 range is used as is",
+			params:    map[string]string{"from": "2025-01-01T10:00:00+07:00", "to": "2025-01-01T12:00:00Z"},
+			wantWhere: []string{"o.created_at >= $1", "o.created_at < $2"},
+			wantArgs: []interface{}{
+				time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC),
+				time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:      "customer, product and totals",
+			params:    map[string]string{"user_id": " u-1 ", "customer": "สมชาย", "product": "SKU-9", "min_total": "100.5", "max_total": "2000"},
+			wantWhere: []string{"o.user_id = $1", "ILIKE $2", "oi.sku = $3", "o.total >= $4", "o.total <= $5"},
+			wantArgs:  []interface{}{"u-1", "%สมชาย%", "SKU-9", models.Money(10050), models.Money(200000)},
+		},
+		{name: "bad from", params: map[string]string{"from": "01/02/2025"}, wantErr: "from must be YYYY-MM-DD or RFC3339"},
+		{name: "bad to", params: map[string]string{"to": "2025-13-01"}, wantErr: "to must be YYYY-MM-DD or RFC3339"},
+		{name: "bad min_total", params: map[string]string{"min_total": "abc"}, wantErr: "invalid min_total"},
+		{name: "bad max_total", params: map[string]string{"max_total": "1,000"}, wantErr: "invalid max_total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := orderSearchFilter(func(k string) string { return tt.params[k] })
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("orderSearchFilter: %v", err)
+			}
+			if len(where) != len(tt.wantWhere)+1 || where[0] != "1=1" {
+				t.Fatalf("where = %q, want 1=1 + %d conditions", where, len(tt.wantWhere))
+			}
+			for i, w := range tt.wantWhere {
+				if !strings.Contains(where[i+1], w) {
+					t.Errorf("where[%d] = %q, want it to contain %q", i+1, where[i+1], w)
+				}
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+			for i, want := range tt.wantArgs {
+				if wt, ok := want.(time.Time); ok {
+					if got, ok := args[i].(time.Time); !ok || !got.Equal(wt) {
+						t.Errorf("args[%d] = %v, want %v", i, args[i], wt)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(args[i], want) {
+					t.Errorf("args[%d] = %#v, want %#v", i, args[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	maxOrderNoteLen    = 500
+	maxOrderCommentLen = 2000
+)
+
+// setActorTx บอก trigger orders_log_status ว่าใครเป็นคนแก้ออเดอร์ใน transaction นี้
+func setActorTx(ctx context.Context, tx pgx.Tx, actor string) error {
+	_, err := tx.Exec(ctx, `SELECT set_config('app.actor', $1, true)`, actor)
+	return err
+}
+
+// isStaff ผู้เรียกเป็นพนักงานหรือเจ้าของร้าน (ต้องผ่าน JWTMiddleware/OptionalJWT ก่อน)
+func isStaff(c *fiber.Ctx) bool {
+	role, _ := c.Locals("role").(string)
+	return role == models.RoleStaff || role == models.RoleOwner
+}
+
+func getOrderComments(ctx context.Context, conn *pgx.Conn, orderID int64, includeInternal bool) ([]models.OrderComment, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT id, order_id, author_id, author_role, body, internal, created_at
+		FROM order_comments
+		WHERE order_id = $1 AND ($2 OR NOT internal)
+		ORDER BY id
+	`, orderID, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.OrderComment{}
+	for rows.Next() {
+		var m models.OrderComment
+		if err := rows.Scan(&m.ID, &m.OrderID, &m.AuthorID, &m.AuthorRole, &m.Body, &m.Internal, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// orderTimeline รวมเหตุการณ์ของออเดอร์ (สร้าง, เปลี่ยนสถานะ/การชำระเงิน, ส่งของ, ความเห็น, คืนสินค้า, ใบกำกับภาษี)
+// เรียงตามเวลา; staff = false จะไม่รวมความเห็นภายใน
+func orderTimeline(ctx context.Context, conn *pgx.Conn, o models.Order, shipments []models.Shipment,
+	returns []models.ReturnRequest, staff bool) ([]models.TimelineEntry, error) {
+	var tl []models.TimelineEntry
+
+	created := models.TimelineEntry{At: o.CreatedAt, Type: models.TimelineCreated, Actor: &o.UserID}
+	if o.CustomerNote != nil {
+		created.Text = *o.CustomerNote
+	}
+	tl = append(tl, created)
+
+	rows, err := conn.Query(ctx, `
+		SELECT field, from_value, to_value, actor, created_at
+		FROM order_status_history
+		WHERE order_id = $1 AND from_value IS NOT NULL
+		ORDER BY id
+	`, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e models.TimelineEntry
+		if err := rows.Scan(&e.Type, &e.From, &e.To, &e.Actor, &e.At); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if e.Type == models.TimelineStatus && e.To == models.OrderStatusCancelled && o.CancelReason != nil {
+			e.Text = *o.CancelReason
+		}
+		tl = append(tl, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range shipments {
+		tl = append(tl, models.TimelineEntry{
+			At:    s.ShippedAt,
+			Type:  models.TimelineShipment,
+			Actor: s.ShippedBy,
+			Text:  s.Carrier + " " + s.TrackingNo,
+			Ref:   s.ID,
+		})
+	}
+
+	comments, err := getOrderComments(ctx, conn, o.ID, staff)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range comments {
+		tl = append(tl, models.TimelineEntry{
+			At:       m.CreatedAt,
+			Type:     models.TimelineComment,
+			Actor:    m.AuthorID,
+			Text:     m.Body,
+			Internal: m.Internal,
+			Ref:      m.ID,
+		})
+	}
+
+	for _, r := range returns {
+		tl = append(tl, models.TimelineEntry{
+			At:    r.CreatedAt,
+			Type:  models.TimelineReturn,
+			Actor: r.RequestedBy,
+			To:    r.Status,
+			Text:  r.Reason,
+			Ref:   r.ID,
+		})
+		if r.RefundedAt != nil {
+			tl = append(tl, models.TimelineEntry{
+				At:    *r.RefundedAt,
+				Type:  models.TimelineRefund,
+				Actor: r.RefundedBy,
+				Text:  r.RefundAmount.String(),
+				Ref:   r.ID,
+			})
+		}
+	}
+
+	if o.TaxInvoiceNo != nil && o.TaxInvoiceAt != nil {
+		tl = append(tl, models.TimelineEntry{At: *o.TaxInvoiceAt, Type: models.TimelineTaxInvoice, Text: *o.TaxInvoiceNo})
+	}
+
+	sort.SliceStable(tl, func(i, j int) bool { return tl[i].At.Before(tl[j].At) })
+	return tl, nil
+}
+
+// ====================
+// พนักงานเพิ่มความเห็นบนออเดอร์
+// POST /admin/orders/:order_id/comments   body: { "body": "...", "internal": true }
+// internal = false ลูกค้าจะเห็นใน timeline ด้วย
+// ====================
+func AddOrderComment(c *fiber.Ctx) error {
+	var req models.CreateOrderCommentReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "body is required"})
+	}
+	if utf8.RuneCountInString(req.Body) > maxOrderCommentLen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("body must be at most %d characters", maxOrderCommentLen)})
+	}
+	internal := true
+	if req.Internal != nil {
+		internal = *req.Internal
+	}
+	userID, _ := c.Locals("user_id").(string)
+	role, _ := c.Locals("role").(string)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	var m models.OrderComment
+	err = conn.QueryRow(context.Background(), `
+		INSERT INTO order_comments (order_id, author_id, author_role, body, internal)
+		SELECT id, NULLIF($2,''), NULLIF($3,''), $4, $5 FROM orders WHERE id = $1
+		RETURNING id, order_id, author_id, author_role, body, internal, created_at
+	`, c.Params("order_id"), userID, role, req.Body, internal).
+		Scan(&m.ID, &m.OrderID, &m.AuthorID, &m.AuthorRole, &m.Body, &m.Internal, &m.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(m)
+}
@@ -0,0 +1,512 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"dog/payment"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var errPaymentUnavailable = errors.New("payment gateway is not available")
+
+// path ของหน้าจ่ายเงินปลอม (ฝั่ง storefront เรียกผ่าน /api)
+const fakeCheckoutPath = "/api/payments/fake/checkout"
+
+// InitPaymentProviders ลงทะเบียน provider ตาม PAYMENT_PROVIDER (เรียกจาก main หลังโหลด .env)
+// ไม่ตั้ง = ปิดการจ่ายผ่าน gateway; fake ใช้ได้เฉพาะเมื่อ APP_ENV ไม่ใช่ production และต้องตั้ง PAYMENT_FAKE_SECRET
+func InitPaymentProviders() error {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "":
+		return nil
+	case payment.FakeName:
+		if strings.EqualFold(os.Getenv("APP_ENV"), "production") {
+			return errors.New("PAYMENT_PROVIDER=fake is not allowed when APP_ENV=production")
+		}
+		secret := os.Getenv("PAYMENT_FAKE_SECRET")
+		if secret == "" {
+			return errors.New("PAYMENT_FAKE_SECRET is required when PAYMENT_PROVIDER=fake")
+		}
+		payment.Register(payment.NewFake(secret, fakeCheckoutPath))
+		return nil
+	default:
+		return fmt.Errorf("unknown PAYMENT_PROVIDER %q", name)
+	}
+}
+
+// FakePaymentsEnabled provider จำลองถูกเปิดไว้ (ใช้ตัดสินว่าจะลงทะเบียนหน้าจ่ายเงินปลอมหรือไม่)
+func FakePaymentsEnabled() bool {
+	_, ok := fakeProvider()
+	return ok
+}
+
+// paymentProvider provider ที่ใช้อยู่ตาม PAYMENT_PROVIDER; ไม่ได้ตั้ง = ErrUnknownProvider
+func paymentProvider() (payment.Provider, error) {
+	return payment.Get(os.Getenv("PAYMENT_PROVIDER"))
+}
+
+// PAYMENT_WEBHOOK_BASE_URL = URL ที่ provider เรียกกลับมาถึงแอปนี้ (default http://localhost:8080)
+func paymentWebhookURL(provider string) string {
+	base := strings.TrimRight(os.Getenv("PAYMENT_WEBHOOK_BASE_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return base + "/payments/webhook/" + provider
+}
+
+// PAYMENT_RETURN_URL = หน้า storefront ที่ลูกค้ากลับมาหลังจ่าย (default /)
+func paymentReturnURL(orderID int64) string {
+	u := os.Getenv("PAYMENT_RETURN_URL")
+	if u == "" {
+		u = "/"
+	}
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%sorder_id=%d", u, sep, orderID)
+}
+
+const paymentIntentColumns = `id, order_id, provider, provider_ref, amount, status, refunded_amount, redirect_url,
+	failure_reason, created_at, updated_at`
+
+func scanPaymentIntent(row pgx.Row, in *models.PaymentIntent) error {
+	return row.Scan(&in.ID, &in.OrderID, &in.Provider, &in.ProviderRef, &in.Amount, &in.Status, &in.RefundedAmount,
+		&in.RedirectURL, &in.FailureReason, &in.CreatedAt, &in.UpdatedAt)
+}
+
+// startPaymentIntent สร้างรายการชำระเงินที่ gateway (ใช้ของเดิมถ้ายังรอจ่ายยอดเดียวกัน)
+func startPaymentIntent(ctx context.Context, conn *pgx.Conn, orderID int64, amount models.Money) (models.PaymentIntent, error) {
+	var in models.PaymentIntent
+	p, err := paymentProvider()
+	if err != nil {
+		return in, errPaymentUnavailable
+	}
+
+	err = scanPaymentIntent(conn.QueryRow(ctx, `
+		SELECT `+paymentIntentColumns+` FROM payment_intents
+		WHERE order_id = $1 AND provider = $2 AND status = $3 AND amount = $4
+		ORDER BY id DESC LIMIT 1
+	`, orderID, p.Name(), models.IntentRequiresPayment, amount), &in)
+	if err == nil {
+		return in, nil
+	}
+	if err != pgx.ErrNoRows {
+		return in, err
+	}
+
+	intent, err := p.CreateIntent(ctx, payment.IntentRequest{
+		OrderRef:   strconv.FormatInt(orderID, 10),
+		Amount:     int64(amount),
+		Currency:   "THB",
+		ReturnURL:  paymentReturnURL(orderID),
+		WebhookURL: paymentWebhookURL(p.Name()),
+	})
+	if err != nil {
+		return in, fmt.Errorf("%s: %w", p.Name(), err)
+	}
+	err = scanPaymentIntent(conn.QueryRow(ctx, `
+		INSERT INTO payment_intents (order_id, provider, provider_ref, amount, redirect_url)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+paymentIntentColumns,
+		orderID, p.Name(), intent.Ref, amount, intent.RedirectURL), &in)
+	if err != nil {
+		return in, err
+	}
+	// ทุกความพยายามจ่ายลงสมุดรับเงิน ผลมาทาง webhook
+	_, err = insertPaymentTx(ctx, conn, paymentEntry{
+		OrderID:     orderID,
+		Method:      models.PayMethodCard,
+		Amount:      amount,
+		Status:      models.PaymentPending,
+		Provider:    p.Name(),
+		ProviderRef: intent.Ref,
+	})
+	return in, err
+}
+
+// reserveProviderRefundTx กันยอดคืนเงินไว้กับ intent ที่จ่ายผ่าน gateway (nil = ออเดอร์ไม่ได้จ่ายผ่าน gateway)
+// ยังไม่เรียก gateway: ผู้เรียกลงรายการคืนเงินสถานะ pending แล้ว commit ก่อน จึงค่อยเรียก completeProviderRefund
+// (ถ้าเรียก gateway ใน tx แล้ว commit ไม่ผ่าน ลูกค้าได้เงินคืนแต่ไม่มีบันทึก และกดซ้ำจะคืนซ้ำ)
+func reserveProviderRefundTx(ctx context.Context, tx pgx.Tx, orderID int64, amount models.Money) (*models.PaymentIntent, error) {
+	var in models.PaymentIntent
+	err := scanPaymentIntent(tx.QueryRow(ctx, `
+		SELECT `+paymentIntentColumns+` FROM payment_intents
+		WHERE order_id = $1 AND status = $2
+		ORDER BY id DESC LIMIT 1
+		FOR UPDATE
+	`, orderID, models.IntentSucceeded), &in)
+	if err == pgx.ErrNoRows || amount <= 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if in.RefundedAmount+amount > in.Amount {
+		return nil, errReturnRefundTooHigh
+	}
+	if _, err := payment.Get(in.Provider); err != nil {
+		return nil, errPaymentUnavailable
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE payment_intents
+		SET refunded_amount = refunded_amount + $2,
+		    status = CASE WHEN refunded_amount + $2 >= amount THEN $3 ELSE status END,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, in.ID, amount, models.IntentRefunded)
+	return &in, err
+}
+
+// returnRefundKey idempotency key ของการคืนเงินผ่าน gateway ต่อใบคืนสินค้า (เรียกซ้ำกี่ครั้งก็คืนครั้งเดียว)
+func returnRefundKey(returnID int64) string {
+	return "return-" + strconv.FormatInt(returnID, 10)
+}
+
+// completeProviderRefund เรียก gateway คืนเงินของใบคืนสินค้าที่ลงรายการ pending ไว้แล้ว แล้วปิดรายการเป็น succeeded
+// ไม่มีรายการ pending (ไม่ได้คืนผ่าน gateway หรือปิดไปแล้ว) = ไม่ทำอะไร
+// gateway ล้มเหลว: รายการคงเป็น pending ให้พนักงานลองใหม่ (key เดิม gateway ไม่คืนซ้ำ)
+func completeProviderRefund(ctx context.Context, conn *pgx.Conn, returnID int64) error {
+	var (
+		paymentID           int64
+		amount              models.Money
+		provider, intentRef string
+	)
+	err := conn.QueryRow(ctx, `
+		SELECT p.id, p.amount, p.provider, p.provider_ref
+		FROM return_requests r JOIN payments p ON p.id = r.refund_payment_id
+		WHERE r.id = $1 AND p.status = $2 AND p.provider IS NOT NULL
+	`, returnID, models.PaymentPending).Scan(&paymentID, &amount, &provider, &intentRef)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p, err := payment.Get(provider)
+	if err != nil {
+		return errPaymentUnavailable
+	}
+	refundRef, err := p.Refund(ctx, intentRef, int64(amount), returnRefundKey(returnID))
+	if err != nil {
+		return fmt.Errorf("%s refund: %w", provider, err)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := settleProviderRefundTx(ctx, tx, paymentID, refundRef); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// settleProviderRefundTx ปิดรายการคืนเงิน pending ของ gateway เป็น succeeded พร้อมเลขอ้างอิง แล้วอัปเดตใบคืนสินค้าและออเดอร์
+// คืน false ถ้ารายการปิดไปแล้ว (เช่น webhook มาก่อน)
+func settleProviderRefundTx(ctx context.Context, tx pgx.Tx, paymentID int64, refundRef string) (bool, error) {
+	var orderID int64
+	err := tx.QueryRow(ctx, `
+		UPDATE payments
+		SET status = $2, provider_ref = COALESCE(NULLIF($3,''), provider_ref), settled_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND kind = $4 AND status = $5
+		RETURNING order_id
+	`, paymentID, models.PaymentSucceeded, refundRef, models.PaymentKindRefund, models.PaymentPending).Scan(&orderID)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE return_requests SET refund_ref = NULLIF($2,''), updated_at = NOW() WHERE refund_payment_id = $1
+	`, paymentID, refundRef); err != nil {
+		return false, err
+	}
+	if _, err := syncOrderPaymentTx(ctx, tx, orderID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ====================
+// เริ่ม/เริ่มใหม่การจ่ายด้วยบัตร (เช่น ปิดหน้าจ่ายเงินไปก่อน หรือจ่ายไม่ผ่าน)
+// POST /orders/:order_id/payment-intent
+// ====================
+func CreatePaymentIntent(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var o models.Order
+	if err := scanOrder(conn.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, c.Params("order_id")), &o); err != nil ||
+		o.UserID != userID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	amount := orderOutstanding(o, time.Now())
+	if strings.ToUpper(o.PaymentMethod) != models.PayMethodCard || amount <= 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "order has no outstanding card payment"})
+	}
+
+	in, err := startPaymentIntent(ctx, conn, o.ID, amount)
+	if err == errPaymentUnavailable {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"intent": in, "redirect_url": in.RedirectURL})
+}
+
+// ====================
+// webhook จาก payment gateway (ตรวจลายเซ็น, ประมวลผลแต่ละ event ครั้งเดียว)
+// POST /payments/webhook/:provider
+// ====================
+func PaymentWebhook(c *fiber.Ctx) error {
+	p, err := payment.Get(c.Params("provider"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	ev, err := p.VerifyWebhook(c.Body(), func(k string) string { return c.Get(k) })
+	if errors.Is(err, payment.ErrNoWebhookSecret) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if ev.ID == "" || ev.IntentRef == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event id and intent_ref are required"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	// event เดิมส่งซ้ำ: ตอบ 200 โดยไม่ทำอะไร (แถวถูกล็อกจน tx แรกจบ จึงไม่ประมวลผลซ้อน)
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO payment_webhook_events (provider, event_id, type, intent_ref, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, event_id) DO NOTHING
+	`, p.Name(), ev.ID, ev.Type, ev.IntentRef, c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.JSON(fiber.Map{"received": true, "duplicate": true})
+	}
+
+	result, err := applyPaymentEventTx(ctx, tx, p.Name(), ev)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := tx.Exec(ctx, `UPDATE payment_webhook_events SET result = $3 WHERE provider = $1 AND event_id = $2`,
+		p.Name(), ev.ID, result); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.JSON(fiber.Map{"received": true, "result": result})
+}
+
+// applyPaymentEventTx ปรับ intent และออเดอร์ตาม event; คืนผลสั้น ๆ ที่บันทึกไว้กับ event
+func applyPaymentEventTx(ctx context.Context, tx pgx.Tx, provider string, ev payment.Event) (string, error) {
+	var in models.PaymentIntent
+	err := scanPaymentIntent(tx.QueryRow(ctx, `
+		SELECT `+paymentIntentColumns+` FROM payment_intents WHERE provider = $1 AND provider_ref = $2 FOR UPDATE
+	`, provider, ev.IntentRef), &in)
+	if err == pgx.ErrNoRows {
+		return "unknown_intent", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// คืนเงิน: RefundReturn ลงรายการ pending ไว้แล้ว; ถ้ายังไม่ถูกปิด (เช่น process ตายหลังเรียก gateway) ปิดจาก event นี้
+	if ev.Type == payment.EventRefundSucceeded {
+		var paymentID int64
+		err := tx.QueryRow(ctx, `
+			SELECT id FROM payments
+			WHERE kind = $1 AND status = $2 AND provider = $3 AND provider_ref = $4 AND amount = $5
+			ORDER BY id LIMIT 1
+			FOR UPDATE
+		`, models.PaymentKindRefund, models.PaymentPending, provider, in.ProviderRef, models.Money(ev.Amount)).Scan(&paymentID)
+		if err == pgx.ErrNoRows {
+			return "refund_confirmed", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := settleProviderRefundTx(ctx, tx, paymentID, ev.RefundRef); err != nil {
+			return "", err
+		}
+		return "refund_settled", nil
+	}
+
+	o, err := lockOrderTx(ctx, tx, strconv.FormatInt(in.OrderID, 10))
+	if err != nil {
+		return "", err
+	}
+	if err := setActorTx(ctx, tx, "payment:"+provider); err != nil {
+		return "", err
+	}
+
+	switch ev.Type {
+	case payment.EventPaymentSucceeded:
+		if _, err := tx.Exec(ctx, `
+			UPDATE payment_intents SET status = $2, failure_reason = NULL, updated_at = NOW() WHERE id = $1
+		`, in.ID, models.IntentSucceeded); err != nil {
+			return "", err
+		}
+		// ลงยอดที่ได้รับจริง (อาจไม่ตรงยอดที่ขอ) แล้วให้สมุดรับเงินตัดสิน payment_status
+		received := models.Money(ev.Amount)
+		ok, err := settlePaymentTx(ctx, tx, "provider_ref", in.ProviderRef, models.PaymentSucceeded, received, "")
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			if _, err := insertPaymentTx(ctx, tx, paymentEntry{
+				OrderID: o.ID, Method: models.PayMethodCard, Amount: received, Status: models.PaymentSucceeded,
+				Provider: provider, ProviderRef: in.ProviderRef,
+			}); err != nil {
+				return "", err
+			}
+		}
+		payStatus, err := syncOrderPaymentTx(ctx, tx, o.ID)
+		if err != nil {
+			return "", err
+		}
+		if err := activateOrderGiftCardsTx(ctx, tx, o.ID, payStatus); err != nil {
+			return "", err
+		}
+		switch {
+		case o.PaymentStatus == models.PayStatusPaid:
+			// จ่ายซ้ำ (เช่น สองแท็บ) ยอดเกินจะเห็นในรายงานกระทบยอด
+			return "duplicate_payment", nil
+		case received != in.Amount:
+			return "amount_mismatch", nil
+		}
+		return payStatus, nil
+
+	case payment.EventPaymentFailed:
+		if in.Status != models.IntentRequiresPayment {
+			return "ignored", nil
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE payment_intents SET status = $2, failure_reason = NULLIF($3,''), updated_at = NOW() WHERE id = $1
+		`, in.ID, models.IntentFailed, ev.Reason); err != nil {
+			return "", err
+		}
+		if _, err := settlePaymentTx(ctx, tx, "provider_ref", in.ProviderRef, models.PaymentFailed, 0, ""); err != nil {
+			return "", err
+		}
+		if _, err := syncOrderPaymentTx(ctx, tx, o.ID); err != nil {
+			return "", err
+		}
+		return "failed", nil
+	}
+	return "ignored", nil
+}
+
+// ===== หน้าจ่ายเงินปลอม (PAYMENT_PROVIDER=fake) =====
+
+func fakeProvider() (*payment.Fake, bool) {
+	p, err := paymentProvider()
+	if err != nil {
+		return nil, false
+	}
+	f, ok := p.(*payment.Fake)
+	return f, ok
+}
+
+// ====================
+// หน้าจ่ายเงินจำลอง: เลือกผลลัพธ์ success / fail / duplicate และหน่วงเวลาได้
+// GET /payments/fake/checkout/:ref
+// ====================
+func FakeCheckoutPage(c *fiber.Ctx) error {
+	f, ok := fakeProvider()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "fake gateway is disabled"})
+	}
+	req, ok := f.Intent(c.Params("ref"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": payment.ErrUnknownIntent.Error()})
+	}
+
+	action := html.EscapeString(fakeCheckoutPath + "/" + c.Params("ref"))
+	var b strings.Builder
+	fmt.Fprintf(&b, `<!doctype html><html><head><meta charset="utf-8"><title>Fake gateway</title></head><body>
+<h1>Fake payment gateway</h1>
+<p>Order #%s — %s THB</p>
+<form method="post" action="%s">
+<label>Delay (seconds) <input name="delay" type="number" min="0" max="300" value="0"></label>
+<button name="outcome" value="%s">Pay (success)</button>
+<button name="outcome" value="%s">Decline</button>
+<button name="outcome" value="%s">Success, webhook sent twice</button>
+</form></body></html>`,
+		html.EscapeString(req.OrderRef), models.Money(req.Amount), action, payment.FakeSucceed, payment.FakeFail, payment.FakeDuplicate)
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.SendString(b.String())
+}
+
+// ====================
+// จำลองผลการจ่าย: provider ปลอมส่ง webhook ที่เซ็นแล้วกลับมาที่ /payments/webhook/fake
+// POST /payments/fake/checkout/:ref   form/json: { "outcome": "success|fail|duplicate", "delay": 5 }
+// ====================
+func FakeCheckoutComplete(c *fiber.Ctx) error {
+	f, ok := fakeProvider()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "fake gateway is disabled"})
+	}
+	var req struct {
+		Outcome string `json:"outcome" form:"outcome"`
+		Delay   int    `json:"delay" form:"delay"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if req.Delay < 0 || req.Delay > 300 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "delay must be 0-300 seconds"})
+	}
+
+	ref := c.Params("ref")
+	err := f.Simulate(ref, req.Outcome, time.Duration(req.Delay)*time.Second)
+	if err == payment.ErrUnknownIntent {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("fake payment %s: %s in %ds", ref, req.Outcome, req.Delay)
+
+	// กดจากหน้าเว็บ → กลับไปหน้าร้าน, เรียกจาก API → ตอบ JSON
+	if intent, ok := f.Intent(ref); ok && strings.Contains(c.Get(fiber.HeaderAccept), "text/html") {
+		return c.Redirect(intent.ReturnURL, fiber.StatusSeeOther)
+	}
+	return c.JSON(fiber.Map{"message": "payment simulated", "outcome": req.Outcome, "delay": req.Delay})
+}
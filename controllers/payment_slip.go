@@ -0,0 +1,551 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"dog/condb"
+	"dog/models"
+	"dog/slipqr"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errSlipNotFound      = errors.New("payment slip not found")
+	errSlipNotSubmitted  = errors.New("payment slip has already been reviewed")
+	errSlipDuplicate     = errors.New("this slip has already been used for another order")
+	errOrderNotAwaitSlip = errors.New("order is not awaiting a bank transfer")
+)
+
+// ข้อจำกัดไฟล์สลิป
+const (
+	maxSlipBytes     = 3 << 20 // 3 MB (ต่ำกว่า BodyLimit 4 MB ของ fiber)
+	minSlipDimension = 200     // พิกเซล (ด้านสั้น)
+)
+
+// ชนิดไฟล์ที่รับ → นามสกุลไฟล์
+var slipContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// SLIP_DIR ที่เก็บไฟล์สลิป (default ./uploads/slips); ห้ามชี้ไปใต้ ./static
+func slipDir() string {
+	if v := os.Getenv("SLIP_DIR"); v != "" {
+		return v
+	}
+	return filepath.Join(".", "uploads", "slips")
+}
+
+const paymentSlipColumns = `ps.id, ps.order_id, ps.content_type, ps.file_size, ps.amount, ps.transferred_at, ps.bank_ref,
+	ps.qr_ref, ps.qr_bank_code, ps.verification, ps.verification_notes,
+	ps.status, ps.reason, ps.uploaded_by, ps.reviewed_by, ps.reviewed_at, ps.created_at`
+
+func paymentSlipScanDest(s *models.PaymentSlip) []interface{} {
+	return []interface{}{&s.ID, &s.OrderID, &s.ContentType, &s.FileSize, &s.Amount, &s.TransferredAt, &s.BankRef,
+		&s.QRRef, &s.QRBankCode, &s.Verification, &s.VerifyNotes,
+		&s.Status, &s.Reason, &s.UploadedBy, &s.ReviewedBy, &s.ReviewedAt, &s.CreatedAt}
+}
+
+func slipImageURL(id int64) string {
+	return fmt.Sprintf("/api/payment-slips/%d/image", id)
+}
+
+// readSlipImage อ่านไฟล์ที่อัปโหลดและตรวจว่าเป็นรูป JPEG/PNG จริง ขนาดพอให้อ่านได้
+func readSlipImage(c *fiber.Ctx) ([]byte, string, string) {
+	fh, err := c.FormFile("slip")
+	if err != nil {
+		return nil, "", "slip image is required (multipart field \"slip\")"
+	}
+	if fh.Size > maxSlipBytes {
+		return nil, "", fmt.Sprintf("slip image must be at most %d MB", maxSlipBytes>>20)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", "cannot read slip image"
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxSlipBytes+1))
+	if err != nil || len(data) > maxSlipBytes {
+		return nil, "", "cannot read slip image"
+	}
+
+	ct := http.DetectContentType(data)
+	if _, ok := slipContentTypes[ct]; !ok {
+		return nil, "", "slip must be a JPEG or PNG image"
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", "slip image is corrupted"
+	}
+	if cfg.Width < minSlipDimension || cfg.Height < minSlipDimension {
+		return nil, "", fmt.Sprintf("slip image must be at least %dx%d pixels", minSlipDimension, minSlipDimension)
+	}
+	return data, ct, ""
+}
+
+func lockPaymentSlipTx(ctx context.Context, tx pgx.Tx, slipID string) (models.PaymentSlip, error) {
+	var s models.PaymentSlip
+	err := tx.QueryRow(ctx, `SELECT `+paymentSlipColumns+` FROM payment_slips ps WHERE ps.id = $1 FOR UPDATE`, slipID).
+		Scan(paymentSlipScanDest(&s)...)
+	if err == pgx.ErrNoRows {
+		return s, errSlipNotFound
+	}
+	return s, err
+}
+
+func slipErrorStatus(err error) int {
+	switch err {
+	case errSlipNotFound, errOrderNotFound:
+		return fiber.StatusNotFound
+	case errSlipNotSubmitted, errSlipDuplicate, errOrderNotAwaitSlip:
+		return fiber.StatusConflict
+	case errPaymentAmount:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// ====================
+// ลูกค้าอัปโหลดสลิปโอนเงินของออเดอร์ BANK_TRANSFER
+// POST /orders/:order_id/payment-slip   multipart: slip (JPEG/PNG ≤ 3MB), amount, transferred_at (RFC3339), bank_ref (ไม่บังคับ)
+// เจ้าของออเดอร์ใช้ JWT, guest ส่ง token ของออเดอร์ (header X-Order-Token หรือ ?token=)
+// อัปโหลดซ้ำก่อนตรวจ = แทนใบเดิม; หลังถูกปฏิเสธอัปโหลดใหม่ได้
+// อ่าน QR บนสลิปแล้วตั้ง verification = verified | suspicious | unreadable ให้คิวตรวจ
+// ====================
+func UploadPaymentSlip(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	token := guestToken(c)
+	if userID == "" && token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	data, contentType, msg := readSlipImage(c)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	var amount *models.Money
+	if v := strings.TrimSpace(c.FormValue("amount")); v != "" {
+		m, err := models.ParseMoney(v)
+		if err != nil || m <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid amount"})
+		}
+		amount = &m
+	}
+	var transferredAt *time.Time
+	if v := strings.TrimSpace(c.FormValue("transferred_at")); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "transferred_at must be RFC3339"})
+		}
+		transferredAt = &t
+	}
+	bankRef := strings.TrimSpace(c.FormValue("bank_ref"))
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	qr, qrErr := slipqr.Decode(data)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := lockOrderTx(ctx, tx, c.Params("order_id"))
+	if err == nil && (userID == "" || o.UserID != userID) {
+		if _, gerr := checkGuestOrderToken(ctx, tx, c.Params("order_id"), token); gerr != nil {
+			err = errOrderNotFound
+		} else {
+			userID = o.UserID
+		}
+	}
+	if err != nil {
+		return c.Status(slipErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	var paymentMethod string
+	so := slipOrder{ID: o.ID}
+	if err := tx.QueryRow(ctx, `SELECT payment_method, total, paid_amount, created_at FROM orders WHERE id = $1`, o.ID).
+		Scan(&paymentMethod, &so.Total, &so.Paid, &so.CreatedAt); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	switch {
+	case strings.ToUpper(paymentMethod) != models.PayMethodBankTransfer,
+		o.Status != models.OrderStatusPending,
+		o.PaymentStatus != models.PayStatusReview && o.PaymentStatus != models.PayStatusPending &&
+			o.PaymentStatus != models.PayStatusFailed && o.PaymentStatus != models.PayStatusPartial:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errOrderNotAwaitSlip.Error(), "status": o.Status, "payment_status": o.PaymentStatus})
+	}
+
+	// สลิปใบเดียวกันใช้จ่ายได้ออเดอร์เดียว
+	var used bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM payment_slips WHERE sha256 = $1 AND order_id <> $2 AND status <> $3)
+	`, digest, o.ID, models.SlipStatusRejected).Scan(&used); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if used {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errSlipDuplicate.Error()})
+	}
+
+	v, err := verifySlipTx(ctx, tx, so, qr, qrErr, amount, transferredAt, bankRef, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := setActorTx(ctx, tx, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE payment_slips SET status = $2 WHERE order_id = $1 AND status = $3
+	`, o.ID, models.SlipStatusSuperseded, models.SlipStatusSubmitted); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE payments SET status = $2, updated_at = NOW() WHERE order_id = $1 AND slip_id IS NOT NULL AND status = $3
+	`, o.ID, models.PaymentVoided, models.PaymentReview); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	fileName := fmt.Sprintf("%d-%s%s", o.ID, digest[:16], slipContentTypes[contentType])
+	var s models.PaymentSlip
+	err = tx.QueryRow(ctx, `
+		INSERT INTO payment_slips AS ps (order_id, file_name, content_type, file_size, sha256, amount, transferred_at, bank_ref,
+			uploaded_by, qr_ref, qr_bank_code, verification, verification_notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8,''), $9, NULLIF($10,''), NULLIF($11,''), $12, $13)
+		RETURNING `+paymentSlipColumns,
+		o.ID, fileName, contentType, len(data), digest, amount, transferredAt, bankRef, userID,
+		v.qrRef(), v.qrBankCode(), v.Result, v.Notes).
+		Scan(paymentSlipScanDest(&s)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	// สลิปรอตรวจ = รายการรับเงินสถานะ review (ยอดที่แจ้ง หรือยอดค้างทั้งหมด)
+	slipAmount := so.outstanding()
+	if amount != nil {
+		slipAmount = *amount
+	}
+	if slipAmount > 0 {
+		if _, err := insertPaymentTx(ctx, tx, paymentEntry{
+			OrderID: o.ID, Method: models.PayMethodBankTransfer, Amount: slipAmount, Status: models.PaymentReview,
+			ProviderRef: v.qrRef(), SlipID: s.ID, CreatedBy: userID,
+		}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if _, err := syncOrderPaymentTx(ctx, tx, o.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := os.MkdirAll(slipDir(), 0o750); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "save slip failed"})
+	}
+	path := filepath.Join(slipDir(), fileName)
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "save slip failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		os.Remove(path)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	s.ImageURL = slipImageURL(s.ID)
+	return c.Status(fiber.StatusCreated).JSON(s)
+}
+
+// ====================
+// สลิปทั้งหมดของออเดอร์ (เจ้าของออเดอร์, พนักงาน หรือ guest ที่มี token ของออเดอร์)
+// GET /orders/:order_id/payment-slips
+// ====================
+func GetOrderPaymentSlips(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var ownerID string
+	err = conn.QueryRow(ctx, `SELECT user_id FROM orders WHERE id = $1`, c.Params("order_id")).Scan(&ownerID)
+	if err == pgx.ErrNoRows || (err == nil && !canAccessOrder(ctx, c, conn, c.Params("order_id"), ownerID)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	rows, err := conn.Query(ctx, `SELECT `+paymentSlipColumns+` FROM payment_slips ps WHERE ps.order_id = $1 ORDER BY ps.id`,
+		c.Params("order_id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	list := []models.PaymentSlip{}
+	for rows.Next() {
+		var s models.PaymentSlip
+		if err := rows.Scan(paymentSlipScanDest(&s)...); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		s.ImageURL = slipImageURL(s.ID)
+		list = append(list, s)
+	}
+	return c.JSON(list)
+}
+
+// ====================
+// รูปสลิป (เจ้าของออเดอร์, พนักงาน หรือ guest ที่มี token ของออเดอร์)
+// GET /payment-slips/:slip_id/image
+// ====================
+func GetPaymentSlipImage(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var fileName, contentType, ownerID, orderID string
+	err = conn.QueryRow(ctx, `
+		SELECT ps.file_name, ps.content_type, o.user_id, o.id::text
+		FROM payment_slips ps JOIN orders o ON o.id = ps.order_id
+		WHERE ps.id = $1
+	`, c.Params("slip_id")).Scan(&fileName, &contentType, &ownerID, &orderID)
+	if err == pgx.ErrNoRows || (err == nil && !canAccessOrder(ctx, c, conn, orderID, ownerID)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errSlipNotFound.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	data, err := os.ReadFile(filepath.Join(slipDir(), filepath.Base(fileName)))
+	if err != nil {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "slip image is missing"})
+	}
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(data)
+}
+
+// ====================
+// คิวตรวจสลิป (หลังบ้าน) เรียงจากเก่าไปใหม่
+// GET /admin/payment-slips?status=submitted&verification=suspicious,unreadable&limit=50
+// ====================
+func AdminGetPaymentSlips(c *fiber.Ctx) error {
+	status := c.Query("status", models.SlipStatusSubmitted)
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	where := []string{"ps.status = $1"}
+	args := []interface{}{status}
+	if v := splitCSV(c.Query("verification")); len(v) > 0 {
+		args = append(args, v)
+		where = append(where, "ps.verification = ANY($"+itoa(len(args))+")")
+	}
+	args = append(args, limit)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT `+paymentSlipColumns+`, o.total, o.status, o.payment_status, o.user_id,
+		       NULLIF(TRIM(COALESCE(cu.firstname,'') || ' ' || COALESCE(cu.lastname,'')), '')
+		FROM payment_slips ps
+		JOIN orders o ON o.id = ps.order_id
+		LEFT JOIN customer cu ON cu.customer_id = o.user_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY ps.id
+		LIMIT $`+itoa(len(args)), args...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	list := []models.PaymentSlipQueueItem{}
+	for rows.Next() {
+		var q models.PaymentSlipQueueItem
+		dest := append(paymentSlipScanDest(&q.PaymentSlip), &q.OrderTotal, &q.OrderStatus, &q.PaymentStatus, &q.UserID, &q.CustomerName)
+		if err := rows.Scan(dest...); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		q.ImageURL = slipImageURL(q.ID)
+		q.AmountMismatch = q.Amount != nil && *q.Amount != q.OrderTotal
+		list = append(list, q)
+	}
+	return c.JSON(list)
+}
+
+// reviewSlip ล็อกสลิปและออเดอร์ แล้วเรียก apply ใน transaction เดียวกัน
+func reviewSlip(c *fiber.Ctx, apply func(ctx context.Context, tx pgx.Tx, s models.PaymentSlip, o lockedOrder, staffID string) error) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := lockPaymentSlipTx(ctx, tx, c.Params("slip_id"))
+	if err == nil && s.Status != models.SlipStatusSubmitted {
+		err = errSlipNotSubmitted
+	}
+	if err != nil {
+		return c.Status(slipErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	o, err := lockOrderTx(ctx, tx, strconv.FormatInt(s.OrderID, 10))
+	if err != nil {
+		return c.Status(slipErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := setActorTx(ctx, tx, staffID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := apply(ctx, tx, s, o, staffID); err != nil {
+		return c.Status(slipErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = tx.QueryRow(ctx, `SELECT `+paymentSlipColumns+` FROM payment_slips ps WHERE ps.id = $1`, s.ID).
+		Scan(paymentSlipScanDest(&s)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	s.ImageURL = slipImageURL(s.ID)
+	return c.JSON(s)
+}
+
+// ====================
+// อนุมัติสลิป: ลงรับเงินในสมุดรับเงิน ยอดครบ → ออเดอร์เป็น paid, ไม่ครบ (มัดจำ) → partially_paid
+// POST /admin/payment-slips/:slip_id/approve   body: { "ref": "...", "amount": 500 } (ไม่บังคับ)
+// ====================
+func ApprovePaymentSlip(c *fiber.Ctx) error {
+	var req models.ApproveSlipReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+		}
+	}
+
+	return reviewSlip(c, func(ctx context.Context, tx pgx.Tx, s models.PaymentSlip, o lockedOrder, staffID string) error {
+		if o.Status != models.OrderStatusPending || o.PaymentStatus == models.PayStatusPaid {
+			return errOrderNotAwaitSlip
+		}
+		// เลขจาก QR เชื่อถือได้กว่าที่ลูกค้าพิมพ์เอง
+		ref := strings.TrimSpace(req.Ref)
+		if ref == "" && s.QRRef != nil {
+			ref = *s.QRRef
+		}
+		if ref == "" && s.BankRef != nil {
+			ref = *s.BankRef
+		}
+		if ref == "" {
+			ref = fmt.Sprintf("SLIP-%d", s.ID)
+		}
+		if req.Amount < 0 {
+			return errPaymentAmount
+		}
+		amount := req.Amount
+		if amount == 0 && s.Amount != nil {
+			amount = *s.Amount
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE payment_slips SET status = $2, reviewed_by = NULLIF($3,''), reviewed_at = NOW() WHERE id = $1
+		`, s.ID, models.SlipStatusApproved, staffID); err != nil {
+			return err
+		}
+		// ยอดครบ → ออเดอร์เป็น paid, ไม่ครบ → partially_paid (คำนวณจากสมุดรับเงิน)
+		if err := slipPaymentTx(ctx, tx, s, o.ID, models.PaymentSucceeded, amount, ref, staffID); err != nil {
+			return err
+		}
+		payStatus, err := syncOrderPaymentTx(ctx, tx, o.ID)
+		if err != nil {
+			return err
+		}
+		return activateOrderGiftCardsTx(ctx, tx, o.ID, payStatus)
+	})
+}
+
+// slipPaymentTx ปิดรายการรับเงินของสลิป (สลิปก่อนมีสมุดรับเงินจะไม่มีรายการ → สร้างใหม่)
+// amount = 0 ใช้ยอดเดิมของรายการ หรือยอดค้างชำระของออเดอร์
+func slipPaymentTx(ctx context.Context, tx pgx.Tx, s models.PaymentSlip, orderID int64, status string, amount models.Money, ref, staffID string) error {
+	ok, err := settlePaymentTx(ctx, tx, "slip_id", s.ID, status, amount, ref)
+	if err != nil || ok {
+		return err
+	}
+	if amount == 0 {
+		if err := tx.QueryRow(ctx, `SELECT GREATEST(total - paid_amount, 0) FROM orders WHERE id = $1`, orderID).
+			Scan(&amount); err != nil {
+			return err
+		}
+	}
+	if amount == 0 {
+		return nil
+	}
+	_, err = insertPaymentTx(ctx, tx, paymentEntry{
+		OrderID: orderID, Method: models.PayMethodBankTransfer, Amount: amount, Status: status,
+		ProviderRef: ref, SlipID: s.ID, CreatedBy: staffID,
+	})
+	return err
+}
+
+// ====================
+// ปฏิเสธสลิป: รายการรับเงินของสลิปเป็น failed ให้ลูกค้าอัปโหลดใหม่ (ยังหมดอายุตามเวลาเดิม)
+// POST /admin/payment-slips/:slip_id/reject   body: { "reason": "ยอดไม่ตรง" } (required)
+// ====================
+func RejectPaymentSlip(c *fiber.Ctx) error {
+	var req models.RejectSlipReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+
+	return reviewSlip(c, func(ctx context.Context, tx pgx.Tx, s models.PaymentSlip, o lockedOrder, staffID string) error {
+		if _, err := tx.Exec(ctx, `
+			UPDATE payment_slips SET status = $2, reason = $3, reviewed_by = NULLIF($4,''), reviewed_at = NOW() WHERE id = $1
+		`, s.ID, models.SlipStatusRejected, reason, staffID); err != nil {
+			return err
+		}
+		if err := slipPaymentTx(ctx, tx, s, o.ID, models.PaymentFailed, 0, "", staffID); err != nil {
+			return err
+		}
+		_, err := syncOrderPaymentTx(ctx, tx, o.ID)
+		return err
+	})
+}
@@ -0,0 +1,542 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errPaymentAmount       = errors.New("amount must be greater than 0")
+	errPaymentKind         = errors.New("kind must be payment or refund")
+	errPaymentMethod       = errors.New("invalid payment method")
+	errPaymentMethodNeeded = errors.New("method is required")
+	errPaymentRefNeeded    = errors.New("ref is required")
+	errRefundExceedsPaid   = errors.New("refund exceeds amount received")
+	errSaleNotFoundPayment = errors.New("sale not found")
+)
+
+// ช่องทางที่บันทึกในสมุดรับเงินได้
+var paymentMethods = map[string]bool{
+	models.PayMethodCOD:          true,
+	models.PayMethodBankTransfer: true,
+	models.PayMethodPromptPay:    true,
+	models.PayMethodCard:         true,
+	models.PayMethodCash:         true,
+}
+
+const paymentColumns = `id, order_id, sale_id, gift_card_id, kind, method, amount, status, provider, provider_ref, slip_id, note,
+	created_by, settled_at, created_at, updated_at`
+
+func paymentScanDest(p *models.Payment) []interface{} {
+	return []interface{}{&p.ID, &p.OrderID, &p.SaleID, &p.GiftCardID, &p.Kind, &p.Method, &p.Amount, &p.Status, &p.Provider, &p.ProviderRef,
+		&p.SlipID, &p.Note, &p.CreatedBy, &p.SettledAt, &p.CreatedAt, &p.UpdatedAt}
+}
+
+// paymentEntry รายการที่จะลงสมุดรับเงิน (ระบุ OrderID, SaleID หรือ GiftCardID อย่างใดอย่างหนึ่ง)
+type paymentEntry struct {
+	OrderID     int64
+	SaleID      string
+	GiftCardID  int64  // ค่าบัตรของขวัญที่ขายหน้าร้าน
+	Kind        string // default payment
+	Method      string
+	Amount      models.Money
+	Status      string
+	Provider    string
+	ProviderRef string
+	SlipID      int64
+	Note        string
+	CreatedBy   string
+}
+
+// insertPaymentTx ลงรายการใหม่; succeeded ได้ settled_at = ตอนนี้
+func insertPaymentTx(ctx context.Context, q querier, e paymentEntry) (int64, error) {
+	if e.Kind == "" {
+		e.Kind = models.PaymentKindPayment
+	}
+	var orderID *int64
+	if e.OrderID != 0 {
+		orderID = &e.OrderID
+	}
+	var slipID, giftCardID *int64
+	if e.SlipID != 0 {
+		slipID = &e.SlipID
+	}
+	if e.GiftCardID != 0 {
+		giftCardID = &e.GiftCardID
+	}
+	var id int64
+	err := q.QueryRow(ctx, `
+		INSERT INTO payments (order_id, sale_id, kind, method, amount, status, provider, provider_ref, slip_id, note, created_by,
+			settled_at, gift_card_id)
+		VALUES ($1, NULLIF($2,''), $3, UPPER($4), $5, $6, NULLIF($7,''), NULLIF($8,''), $9, NULLIF($10,''), NULLIF($11,''),
+			CASE WHEN $6 = 'succeeded' THEN NOW() END, $12)
+		RETURNING id
+	`, orderID, e.SaleID, e.Kind, e.Method, e.Amount, e.Status, e.Provider, e.ProviderRef, slipID, e.Note, e.CreatedBy,
+		giftCardID).Scan(&id)
+	return id, err
+}
+
+// settlePaymentTx เปลี่ยนสถานะรายการที่ยังไม่จบ (pending/review); amount > 0 = แก้ยอดตามที่ได้รับจริง
+// คืน false ถ้าไม่มีรายการที่ตรงเงื่อนไข
+func settlePaymentTx(ctx context.Context, tx pgx.Tx, where string, arg interface{}, status string, amount models.Money, ref string) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+		UPDATE payments
+		SET status = $2,
+		    amount = CASE WHEN $3::numeric > 0 THEN $3::numeric ELSE amount END,
+		    provider_ref = COALESCE(NULLIF($4,''), provider_ref),
+		    settled_at = CASE WHEN $2 = 'succeeded' THEN NOW() END,
+		    updated_at = NOW()
+		WHERE `+where+` = $1 AND kind = 'payment' AND status IN ('pending', 'review')
+	`, arg, status, amount, ref)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// paymentTotals ยอดรวมจากสมุดรับเงินของออเดอร์หรือการขาย
+type paymentTotals struct {
+	Paid, Refunded models.Money
+	Review         bool
+	LastStatus     *string // สถานะของความพยายามจ่ายล่าสุด
+	LastRef        *string // เลขอ้างอิงของการรับเงินสำเร็จล่าสุด
+}
+
+func loadPaymentTotals(ctx context.Context, q querier, col string, id interface{}) (paymentTotals, error) {
+	var t paymentTotals
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount) FILTER (WHERE kind = 'payment' AND status = 'succeeded'), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE kind = 'refund' AND status = 'succeeded'), 0),
+		       COUNT(*) FILTER (WHERE kind = 'payment' AND status = 'review') > 0,
+		       (SELECT status FROM payments WHERE `+col+` = $1 AND kind = 'payment' ORDER BY id DESC LIMIT 1),
+		       (SELECT provider_ref FROM payments WHERE `+col+` = $1 AND kind = 'payment' AND status = 'succeeded'
+		        ORDER BY settled_at DESC, id DESC LIMIT 1)
+		FROM payments WHERE `+col+` = $1
+	`, id).Scan(&t.Paid, &t.Refunded, &t.Review, &t.LastStatus, &t.LastRef)
+	return t, err
+}
+
+// derivePaymentStatus payment_status ของออเดอร์จากยอดในสมุดรับเงิน
+// ไม่มีรายการเลย = คงค่าเดิม (current) เช่น COD ที่ยังไม่เก็บเงิน
+func derivePaymentStatus(orderStatus, current string, total models.Money, t paymentTotals) string {
+	net := t.Paid - t.Refunded
+	switch {
+	case orderStatus == models.OrderStatusCancelled:
+		if net > 0 {
+			return models.PayStatusRefund
+		}
+		if t.Paid > 0 {
+			return models.PayStatusRefunded
+		}
+		return models.PayStatusVoided
+	case t.Paid > 0 && net <= 0:
+		return models.PayStatusRefunded
+	case t.Paid > 0 && t.Paid >= total:
+		return models.PayStatusPaid
+	case t.Review:
+		return models.PayStatusReview
+	case t.Paid > 0:
+		return models.PayStatusPartial
+	case t.LastStatus == nil:
+		return current
+	case *t.LastStatus == models.PaymentFailed:
+		return models.PayStatusFailed
+	}
+	return models.PayStatusPending
+}
+
+// syncOrderPaymentTx คำนวณ payment_status / paid_amount / payment_ref ของออเดอร์ใหม่จากสมุดรับเงิน
+// ออเดอร์ที่รอชำระและได้รับเงินครบจะเปลี่ยนเป็น paid ด้วย; ต้องล็อกออเดอร์ก่อนเรียก
+// ไม่เปิดใช้บัตรของขวัญ — เรียก activateOrderGiftCardsTx เองเฉพาะเงินที่ตรวจแล้ว (webhook / พนักงานอนุมัติสลิป)
+func syncOrderPaymentTx(ctx context.Context, q querier, orderID int64) (string, error) {
+	var status, current string
+	var total models.Money
+	if err := q.QueryRow(ctx, `SELECT status, payment_status, total FROM orders WHERE id = $1`, orderID).
+		Scan(&status, &current, &total); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", errOrderNotFound
+		}
+		return "", err
+	}
+	t, err := loadPaymentTotals(ctx, q, "order_id", orderID)
+	if err != nil {
+		return "", err
+	}
+	payStatus := derivePaymentStatus(status, current, total, t)
+
+	var updated string
+	err = q.QueryRow(ctx, `
+		UPDATE orders
+		SET payment_status = $2, paid_amount = $3, payment_ref = COALESCE($4, payment_ref),
+		    status = CASE WHEN $2 = $5 AND status = $6 THEN $7 ELSE status END,
+		    updated_at = NOW()
+		WHERE id = $1
+		RETURNING payment_status
+	`, orderID, payStatus, t.Paid-t.Refunded, t.LastRef,
+		models.PayStatusPaid, models.OrderStatusPending, models.OrderStatusPaid).Scan(&updated)
+	if err != nil {
+		return "", err
+	}
+	return updated, nil
+}
+
+func paymentErrorStatus(err error) int {
+	switch err {
+	case errOrderNotFound, errSaleNotFoundPayment:
+		return fiber.StatusNotFound
+	case errPaymentAmount, errPaymentKind, errPaymentMethod, errPaymentMethodNeeded, errPaymentRefNeeded, errRefundExceedsPaid:
+		return fiber.StatusBadRequest
+	case errPaymentUnavailable:
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusInternalServerError
+}
+
+// normalizePaymentReq ตรวจ request บันทึกรับ/คืนเงิน; method ว่าง = defaultMethod
+func normalizePaymentReq(req *models.RecordPaymentReq, defaultMethod string) error {
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	if req.Kind == "" {
+		req.Kind = models.PaymentKindPayment
+	}
+	if req.Kind != models.PaymentKindPayment && req.Kind != models.PaymentKindRefund {
+		return errPaymentKind
+	}
+	req.Method = strings.ToUpper(strings.TrimSpace(req.Method))
+	if req.Method == "" {
+		req.Method = strings.ToUpper(defaultMethod)
+	}
+	if req.Method == "" {
+		return errPaymentMethodNeeded
+	}
+	if !paymentMethods[req.Method] {
+		return errPaymentMethod
+	}
+	if req.Amount <= 0 {
+		return errPaymentAmount
+	}
+	req.Ref = strings.TrimSpace(req.Ref)
+	req.Note = strings.TrimSpace(req.Note)
+	return nil
+}
+
+func listPayments(ctx context.Context, q querier, col string, id interface{}) ([]models.Payment, error) {
+	rows, err := q.Query(ctx, `SELECT `+paymentColumns+` FROM payments WHERE `+col+` = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Payment{}
+	for rows.Next() {
+		var p models.Payment
+		if err := rows.Scan(paymentScanDest(&p)...); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+func paymentSummary(total models.Money, t paymentTotals) models.PaymentSummary {
+	return models.PaymentSummary{
+		Total:    total,
+		Paid:     t.Paid,
+		Refunded: t.Refunded,
+		Net:      t.Paid - t.Refunded,
+		Balance:  total - t.Paid,
+	}
+}
+
+// ====================
+// สมุดรับ/คืนเงินของออเดอร์ (หลังบ้าน)
+// GET /admin/orders/:order_id/payments
+// ====================
+func GetOrderPayments(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var orderID int64
+	var total models.Money
+	var payStatus string
+	err = conn.QueryRow(ctx, `SELECT id, total, payment_status FROM orders WHERE id = $1`, c.Params("order_id")).
+		Scan(&orderID, &total, &payStatus)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errOrderNotFound.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	list, err := listPayments(ctx, conn, "order_id", orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	t, err := loadPaymentTotals(ctx, conn, "order_id", orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	summary := paymentSummary(total, t)
+	summary.PaymentStatus = payStatus
+	return c.JSON(fiber.Map{"payments": list, "summary": summary})
+}
+
+// ====================
+// พนักงานบันทึกรับเงิน (มัดจำ/เงินสด/โอน) หรือคืนเงินของออเดอร์ แล้วคำนวณ payment_status ใหม่
+// POST /admin/orders/:order_id/payments   body: { "kind": "payment|refund", "method": "CASH", "amount": 500, "ref": "RC-0012", "note": "มัดจำ" }
+// method และ ref ต้องส่งมาเสมอ
+// ====================
+func RecordOrderPayment(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+	var req models.RecordPaymentReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := lockOrderTx(ctx, tx, c.Params("order_id"))
+	if err != nil {
+		return c.Status(paymentErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	// ทางเดียวที่ลงรับเงินออเดอร์ด้วยมือ: ต้องระบุช่องทางและเลขอ้างอิง (เลขสลิป/ใบเสร็จ) ทุกครั้ง
+	if err := normalizePaymentReq(&req, ""); err != nil {
+		return c.Status(paymentErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.Ref == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errPaymentRefNeeded.Error()})
+	}
+	if err := recordPaymentTx(ctx, tx, "order_id", o.ID, paymentEntry{OrderID: o.ID}, req, staffID); err != nil {
+		return c.Status(paymentErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := setActorTx(ctx, tx, staffID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	payStatus, err := syncOrderPaymentTx(ctx, tx, o.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "payment recorded", "payment_status": payStatus})
+}
+
+// recordPaymentTx ลงรายการที่พนักงานบันทึกเอง; คืนเงินได้ไม่เกินยอดรับสุทธิ
+func recordPaymentTx(ctx context.Context, tx pgx.Tx, col string, id interface{}, e paymentEntry, req models.RecordPaymentReq, staffID string) error {
+	if req.Kind == models.PaymentKindRefund {
+		t, err := loadPaymentTotals(ctx, tx, col, id)
+		if err != nil {
+			return err
+		}
+		if req.Amount > t.Paid-t.Refunded {
+			return errRefundExceedsPaid
+		}
+	}
+	e.Kind = req.Kind
+	e.Method = req.Method
+	e.Amount = req.Amount
+	e.Status = models.PaymentSucceeded
+	e.ProviderRef = req.Ref
+	e.Note = req.Note
+	e.CreatedBy = staffID
+	_, err := insertPaymentTx(ctx, tx, e)
+	return err
+}
+
+// ====================
+// สมุดรับ/คืนเงินของการขายหน้าร้าน
+// GET /admin/sales/:sale_id/payments
+// ====================
+func GetSalePayments(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var saleID string
+	var total models.Money
+	err = conn.QueryRow(ctx, `SELECT sale_id, total_price FROM sales WHERE sale_id = $1`, c.Params("sale_id")).Scan(&saleID, &total)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errSaleNotFoundPayment.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	list, err := listPayments(ctx, conn, "sale_id", saleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	t, err := loadPaymentTotals(ctx, conn, "sale_id", saleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"payments": list, "summary": paymentSummary(total, t)})
+}
+
+// ====================
+// บันทึกรับ/คืนเงินของการขายหน้าร้าน (เช่น จ่ายสองช่องทาง)
+// POST /admin/sales/:sale_id/payments   body เหมือน /admin/orders/:order_id/payments
+// ====================
+func RecordSalePayment(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+	var req models.RecordPaymentReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	var saleID, method string
+	err = tx.QueryRow(ctx, `SELECT sale_id, payment_method FROM sales WHERE sale_id = $1 FOR UPDATE`, c.Params("sale_id")).
+		Scan(&saleID, &method)
+	if err == pgx.ErrNoRows {
+		err = errSaleNotFoundPayment
+	}
+	if err != nil {
+		return c.Status(paymentErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := normalizePaymentReq(&req, method); err != nil {
+		return c.Status(paymentErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := recordPaymentTx(ctx, tx, "sale_id", saleID, paymentEntry{SaleID: saleID}, req, staffID); err != nil {
+		return c.Status(paymentErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "payment recorded"})
+}
+
+// ====================
+// กระทบยอดรายวันแยกตามช่องทาง: ยอดที่ควรได้ (ออเดอร์/การขายของวันนั้น) เทียบยอดที่รับจริงในวันนั้น
+// GET /admin/reports/reconciliation?date=2024-06-01   (default = วันนี้ เวลาไทย)
+// ====================
+func PaymentReconciliation(c *fiber.Ctx) error {
+	day := time.Now().In(orderSearchZone)
+	if v := c.Query("date"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, orderSearchZone)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "date must be YYYY-MM-DD"})
+		}
+		day = d
+	}
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, orderSearchZone)
+	to := from.AddDate(0, 0, 1)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	// expected/outstanding จากออเดอร์+การขายที่เกิดในวันนั้น, received/refunded จากรายการที่ settle ในวันนั้น
+	rows, err := conn.Query(context.Background(), `
+		WITH expected AS (
+			SELECT UPPER(payment_method) AS method, SUM(total) AS amount,
+			       SUM(GREATEST(total - paid_amount, 0)) FILTER (WHERE payment_status NOT IN ('paid', 'refunded')) AS outstanding,
+			       COUNT(*) AS orders, 0 AS sales
+			FROM orders
+			WHERE created_at >= $1::timestamptz AND created_at < $2::timestamptz AND status <> 'cancelled'
+			GROUP BY 1
+			UNION ALL
+			SELECT UPPER(s.payment_method), SUM(s.total_price),
+			       SUM(GREATEST(s.total_price - COALESCE(p.net, 0), 0)),
+			       0, COUNT(*)
+			FROM sales s
+			LEFT JOIN (
+				SELECT sale_id, SUM(CASE WHEN kind = 'payment' THEN amount ELSE -amount END) AS net
+				FROM payments WHERE sale_id IS NOT NULL AND status = 'succeeded' GROUP BY sale_id
+			) p ON p.sale_id = s.sale_id
+			WHERE s.sale_date >= $1::timestamptz AND s.sale_date < $2::timestamptz AND s.voided_at IS NULL
+			GROUP BY 1
+			UNION ALL
+			SELECT UPPER(g.payment_method), SUM(g.initial_amount), 0, 0, COUNT(*)
+			FROM gift_cards g
+			WHERE g.channel = 'pos' AND g.created_at >= $1::timestamptz AND g.created_at < $2::timestamptz
+			GROUP BY 1
+		), received AS (
+			SELECT method,
+			       SUM(amount) FILTER (WHERE kind = 'payment') AS received,
+			       SUM(amount) FILTER (WHERE kind = 'refund') AS refunded,
+			       COUNT(*)::int AS payments
+			FROM payments
+			WHERE status = 'succeeded' AND settled_at >= $1::timestamptz AND settled_at < $2::timestamptz
+			GROUP BY method
+		), e AS (
+			SELECT method, SUM(amount) AS amount, SUM(COALESCE(outstanding, 0)) AS outstanding,
+			       SUM(orders)::int AS orders, SUM(sales)::int AS sales
+			FROM expected GROUP BY method
+		)
+		SELECT COALESCE(e.method, r.method), COALESCE(e.amount, 0), COALESCE(r.received, 0), COALESCE(r.refunded, 0),
+		       COALESCE(e.outstanding, 0), COALESCE(e.orders, 0), COALESCE(e.sales, 0), COALESCE(r.payments, 0)
+		FROM e FULL OUTER JOIN received r ON r.method = e.method
+		ORDER BY 1
+	`, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	report := models.ReconciliationReport{Date: from.Format("2006-01-02"), Rows: []models.ReconciliationRow{}}
+	report.Totals.Method = "ALL"
+	for rows.Next() {
+		var r models.ReconciliationRow
+		if err := rows.Scan(&r.Method, &r.Expected, &r.Received, &r.Refunded, &r.Outstanding, &r.Orders, &r.Sales, &r.Payments); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		r.Net = r.Received - r.Refunded
+		r.Difference = r.Net - r.Expected
+		report.Rows = append(report.Rows, r)
+
+		t := &report.Totals
+		t.Expected += r.Expected
+		t.Received += r.Received
+		t.Refunded += r.Refunded
+		t.Net += r.Net
+		t.Difference += r.Difference
+		t.Outstanding += r.Outstanding
+		t.Orders += r.Orders
+		t.Sales += r.Sales
+		t.Payments += r.Payments
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(report)
+}
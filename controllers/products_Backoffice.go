@@ -13,21 +13,29 @@ import (
 )
 
 type ProductPublic struct {
-	ID              int      `json:"id"`
-	SKU             string   `json:"product_id"`
-	Name            string   `json:"name"`
-	Brand           string   `json:"brand,omitempty"`
-	Category        string   `json:"category,omitempty"`
-	Gender          string   `json:"gender,omitempty"`
-	Price           float64  `json:"price"`
-	OriginalPrice   *float64 `json:"original_price,omitempty"`
-	DiscountPercent int      `json:"discount_percent"`
-	Image           string   `json:"image,omitempty"`
-	Popularity      int      `json:"popularity_score"`
-	CreatedAt       string   `json:"created_at"`
-	Stock           int      `json:"stock"`
+	ID              int           `json:"id"`
+	SKU             string        `json:"product_id"`
+	Name            string        `json:"name"`
+	Brand           string        `json:"brand,omitempty"`
+	Category        string        `json:"category,omitempty"`
+	Gender          string        `json:"gender,omitempty"`
+	Price           models.Money  `json:"price"`
+	OriginalPrice   *models.Money `json:"original_price,omitempty"`
+	DiscountPercent int           `json:"discount_percent"`
+	Image           string        `json:"image,omitempty"`
+	Popularity      int           `json:"popularity_score"`
+	CreatedAt       string        `json:"created_at"`
+	Stock           int           `json:"stock"`
+	Sizes           []string      `json:"sizes"` // ไซซ์ที่ยังมีของ (จาก product_variants)
 }
 
+// ไซซ์ที่ยังมีของของสินค้าแต่ละตัว (ใช้ใน SELECT ของหน้า catalog)
+const availableSizesSQL = `ARRAY(
+    SELECT DISTINCT v.options->>'size' FROM product_variants v
+    WHERE v.product_id = p.product_id AND v.active AND v.quantity > 0 AND v.options ? 'size'
+    ORDER BY 1
+  ) AS sizes`
+
 type ProductsListResp struct {
 	Items []ProductPublic `json:"items"`
 	Total int             `json:"total"`
@@ -52,13 +60,20 @@ func AddStock(c *fiber.Ctx) error {
 	gender := c.FormValue("gender")
 
 	quantity, _ := strconv.Atoi(c.FormValue("quantity"))
-	costPrice, _ := strconv.ParseFloat(c.FormValue("cost_price"), 64)
-	sellPrice, _ := strconv.ParseFloat(c.FormValue("sell_price"), 64)
+	weightGrams, _ := strconv.Atoi(c.FormValue("weight_grams"))
+	costPrice, err := models.ParseMoney(c.FormValue("cost_price"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cost_price"})
+	}
+	sellPrice, err := models.ParseMoney(c.FormValue("sell_price"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid sell_price"})
+	}
 
 	// ราคาปกติ: "ไม่บังคับ"
-	var originalPricePtr *float64
+	var originalPricePtr *models.Money
 	if op := c.FormValue("original_price"); op != "" {
-		if v, err := strconv.ParseFloat(op, 64); err == nil {
+		if v, err := models.ParseMoney(op); err == nil {
 			originalPricePtr = &v
 		}
 	}
@@ -77,32 +92,52 @@ func AddStock(c *fiber.Ctx) error {
 		imagePath = &ip
 	}
 
-	// Insert or Update
-	_, err = db.Exec(context.Background(), `
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	// Insert or Update (quantity ไม่แตะตรงนี้ ปรับผ่าน ledger ด้านล่าง)
+	_, err = tx.Exec(ctx, `
 		INSERT INTO products
-			(product_id, name, brand, category, gender, quantity, cost_price, sell_price, original_price, image, recommended, created_at, updated_at)
+			(product_id, name, brand, category, gender, quantity, cost_price, sell_price, original_price, image, recommended, weight_grams, created_at, updated_at)
 		VALUES
-			($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11, now(), now())
+			($1,$2,$3,$4,$5,0,$6,$7,$8,$9,$10,$11, now(), now())
 		ON CONFLICT (product_id) DO UPDATE
 		SET
 			name            = EXCLUDED.name,
 			brand           = EXCLUDED.brand,
 			category        = EXCLUDED.category,
 			gender          = EXCLUDED.gender,
-			quantity        = EXCLUDED.quantity,
 			cost_price      = EXCLUDED.cost_price,
 			sell_price      = EXCLUDED.sell_price,
 			original_price  = EXCLUDED.original_price,
 			image           = COALESCE(EXCLUDED.image, products.image),
 			recommended     = EXCLUDED.recommended,
+			weight_grams    = EXCLUDED.weight_grams,
 			updated_at      = now()
 			`,
 		productID, name, brand, category, gender,
-		quantity, costPrice, sellPrice, originalPricePtr, imagePath, recommended,
+		costPrice, sellPrice, originalPricePtr, imagePath, recommended, weightGrams,
 	)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Insert failed: " + err.Error()})
 	}
+
+	// รับของเข้า: quantity ในฟอร์มคือยอดคงเหลือที่ต้องการ
+	staffID, _ := c.Locals("user_id").(string)
+	if _, err := setStockTx(ctx, tx, productID, quantity, models.StockMoveReceive, c.FormValue("note"), staffID); err != nil {
+		if err == errProductHasVariants {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Stock update failed: " + err.Error()})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Commit failed"})
+	}
 	// แปลง string เป็น *string (nil ถ้าว่าง)
 	toPtr := func(s string) *string {
 		if s == "" {
@@ -118,6 +153,7 @@ func AddStock(c *fiber.Ctx) error {
 		Category:      toPtr(category),
 		Gender:        toPtr(gender),
 		Quantity:      quantity,
+		WeightGrams:   weightGrams,
 		CostPrice:     &costPrice,
 		SellPrice:     sellPrice,
 		OriginalPrice: originalPricePtr,
@@ -140,26 +176,48 @@ func UpdateStock(c *fiber.Ctx) error {
 
 	productID := c.Params("product_id")
 	var input struct {
-		Name        string  `json:"name"`
-		Quantity    int     `json:"quantity"`
-		CostPrice   float64 `json:"cost_price"`
-		SellPrice   float64 `json:"sell_price"`
-		Recommended bool    `json:"recommended"`
+		Name        string       `json:"name"`
+		Quantity    int          `json:"quantity"`
+		CostPrice   models.Money `json:"cost_price"`
+		SellPrice   models.Money `json:"sell_price"`
+		Recommended bool         `json:"recommended"`
+		Note        string       `json:"note"` // เหตุผลการปรับสต็อก (ถ้ามี)
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
 	}
 
-	_, err = db.Exec(context.Background(),
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx,
 		`UPDATE products
-		 SET name=$1, quantity=$2, cost_price=$3, sell_price=$4, recommended=$5, updated_at=NOW()
-		 WHERE product_id=$6`,
-		input.Name, input.Quantity, input.CostPrice, input.SellPrice, input.Recommended, productID,
+		 SET name=$1, cost_price=$2, sell_price=$3, recommended=$4, updated_at=NOW()
+		 WHERE product_id=$5`,
+		input.Name, input.CostPrice, input.SellPrice, input.Recommended, productID,
 	)
-
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Update failed"})
 	}
+	if res.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	staffID, _ := c.Locals("user_id").(string)
+	if _, err := setStockTx(ctx, tx, productID, input.Quantity, models.StockMoveAdjustment, input.Note, staffID); err != nil {
+		if err == errProductHasVariants {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Update failed"})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Update failed"})
+	}
 
 	return c.JSON(fiber.Map{"message": "Product updated", "productID": productID})
 }
@@ -176,19 +234,37 @@ func UpdateStockQuantity(c *fiber.Ctx) error {
 
 	productID := c.Params("product_id")
 	var input struct {
-		Quantity int `json:"quantity"`
+		Quantity int    `json:"quantity"`
+		Note     string `json:"note"` // เหตุผลการปรับสต็อก (ถ้ามี)
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Cannot parse JSON"})
 	}
+	if input.Quantity < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "quantity must not be negative"})
+	}
 
-	_, err = db.Exec(context.Background(),
-		"UPDATE products SET quantity=$1, updated_at=NOW() WHERE product_id=$2",
-		input.Quantity, productID,
-	)
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback(ctx)
+
+	staffID, _ := c.Locals("user_id").(string)
+	_, err = setStockTx(ctx, tx, productID, input.Quantity, models.StockMoveAdjustment, input.Note, staffID)
+	if err == errProductNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+	}
+	if err == errProductHasVariants {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Update failed"})
 	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Update failed"})
+	}
 
 	return c.JSON(fiber.Map{
 		"message":   "Stock quantity updated",
@@ -273,6 +349,7 @@ func GetProducts(c *fiber.Ctx) error {
 	priceMin := strings.TrimSpace(c.Query("price_min"))
 	priceMax := strings.TrimSpace(c.Query("price_max"))
 	inStock := c.Query("in_stock") == "true"
+	sizes := splitCSV(c.Query("sizes")) // กรองสินค้าที่มีไซซ์เหล่านี้เหลืออยู่
 
 	where := []string{"1=1"}
 	args := []interface{}{}
@@ -301,6 +378,12 @@ func GetProducts(c *fiber.Ctx) error {
 		args = append(args, brands)
 		ai++
 	}
+	if len(sizes) > 0 {
+		where = append(where, `EXISTS (SELECT 1 FROM product_variants v
+			WHERE v.product_id = p.product_id AND v.active AND v.quantity > 0 AND v.options->>'size' = ANY($`+itoa(ai)+`))`)
+		args = append(args, sizes)
+		ai++
+	}
 	// ✅ เพิ่มเงื่อนไขราคาเฉพาะเมื่อ parse สำเร็จ
 	if v, ok := parseFloatSafe(priceMin); ok {
 		where = append(where, "p.sell_price >= $"+itoa(ai))
@@ -341,7 +424,8 @@ SELECT
   COALESCE(p.image,'') AS image,
   p.popularity_score AS popularity, -- ✅ alias ให้ตรงฟิลด์สแกน
   to_char(p.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS created_at, -- ✅ เวลามาตรฐาน
-  p.quantity AS stock
+  p.quantity AS stock,
+  ` + availableSizesSQL + `
 FROM products p
 WHERE ` + strings.Join(where, " AND ") + `
 `
@@ -370,7 +454,7 @@ WHERE ` + strings.Join(where, " AND ") + `
 		if err := rows.Scan(
 			&p.ID, &p.SKU, &p.Name, &p.Brand, &p.Category, &p.Gender,
 			&p.Price, &p.OriginalPrice, &p.DiscountPercent, &p.Image,
-			&p.Popularity, &p.CreatedAt, &p.Stock,
+			&p.Popularity, &p.CreatedAt, &p.Stock, &p.Sizes,
 		); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -418,18 +502,28 @@ SELECT
   COALESCE(p.image,'') AS image,
   p.popularity_score AS popularity,
   to_char(p.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS created_at,
-  p.quantity AS stock
+  p.quantity AS stock,
+  `+availableSizesSQL+`
 FROM products p
 WHERE p.product_id = $1 OR CAST(p.id AS TEXT) = $1
 `, id).Scan(
 		&p.ID, &p.SKU, &p.Name, &p.Brand, &p.Category, &p.Gender,
 		&p.Price, &p.OriginalPrice, &p.DiscountPercent, &p.Image,
-		&p.Popularity, &p.CreatedAt, &p.Stock,
+		&p.Popularity, &p.CreatedAt, &p.Stock, &p.Sizes,
 	)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "not found"})
 	}
-	return c.JSON(p)
+
+	// ไซซ์ทั้งหมด (รวมที่หมด) พร้อมราคา/สต็อก สำหรับหน้ารายละเอียดสินค้า
+	variants, err := getVariantSizes(context.Background(), conn, p.SKU)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(struct {
+		ProductPublic
+		Variants []models.VariantSize `json:"variants"`
+	}{p, variants})
 }
 
 // ====================
@@ -555,7 +649,7 @@ func GetProductFacets(c *fiber.Ctx) error {
 	}
 
 	// price range
-	var min, max *float64
+	var min, max *models.Money
 	_ = conn.QueryRow(context.Background(),
 		`SELECT MIN(sell_price), MAX(sell_price) FROM products `+w, args...).
 		Scan(&min, &max)
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"dog/promptpay"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	errPromptPayNotConfigured = errors.New("PromptPay is not configured")
+	errOrderNotPayable        = errors.New("order has no outstanding PromptPay payment")
+)
+
+// ขนาดรูป QR (พิกเซล)
+const (
+	promptPayQRSize    = 320
+	promptPayQRMinSize = 128
+	promptPayQRMaxSize = 1024
+)
+
+// promptPayTarget หมายเลขพร้อมเพย์ของร้านจาก PROMPTPAY_ID (เบอร์มือถือ / เลขประจำตัวผู้เสียภาษี / e-Wallet)
+func promptPayTarget() string {
+	return strings.TrimSpace(os.Getenv("PROMPTPAY_ID"))
+}
+
+// orderOutstanding ยอดที่ยังต้องชำระของออเดอร์ (0 = ไม่มียอดค้าง)
+func orderOutstanding(o models.Order, now time.Time) models.Money {
+	if o.Status == models.OrderStatusCancelled {
+		return 0
+	}
+	switch o.PaymentStatus {
+	case models.PayStatusPending, models.PayStatusFailed, models.PayStatusPartial:
+	default:
+		return 0
+	}
+	// จ่ายมัดจำแล้วไม่หมดอายุ (ไม่ถูกยกเลิกอัตโนมัติ)
+	if o.PaidAmount == 0 && o.ExpiresAt != nil && !o.ExpiresAt.After(now) {
+		return 0
+	}
+	if o.PaidAmount >= o.Total {
+		return 0
+	}
+	return o.Total - o.PaidAmount
+}
+
+// orderPromptPay โหลดออเดอร์แล้วสร้าง payload สำหรับยอดค้างชำระ
+// ดูได้เฉพาะพนักงาน, เจ้าของออเดอร์ หรือ guest ที่มี token ของออเดอร์ (ไม่งั้นตอบเหมือนไม่มีออเดอร์)
+func orderPromptPay(ctx context.Context, c *fiber.Ctx, orderID string) (models.Order, models.Money, string, error) {
+	var o models.Order
+	target := promptPayTarget()
+	if target == "" {
+		return o, 0, "", errPromptPayNotConfigured
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return o, 0, "", err
+	}
+	defer conn.Close(context.Background())
+
+	if err := scanOrder(conn.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, orderID), &o); err != nil {
+		return o, 0, "", errOrderNotFound
+	}
+	if !canAccessOrder(ctx, c, conn, orderID, o.UserID) {
+		return models.Order{}, 0, "", errOrderNotFound
+	}
+	if strings.ToUpper(o.PaymentMethod) != models.PayMethodPromptPay {
+		return o, 0, "", errOrderNotPayable
+	}
+	amount := orderOutstanding(o, time.Now())
+	if amount <= 0 {
+		return o, 0, "", errOrderNotPayable
+	}
+
+	payload, err := promptpay.Payload(target, int64(amount))
+	if err == promptpay.ErrInvalidTarget {
+		log.Printf("promptpay: invalid PROMPTPAY_ID %q", promptpay.MaskTarget(target))
+		return o, 0, "", errPromptPayNotConfigured
+	}
+	return o, amount, payload, err
+}
+
+func promptPayErrorStatus(err error) int {
+	switch err {
+	case errOrderNotFound:
+		return fiber.StatusNotFound
+	case errOrderNotPayable:
+		return fiber.StatusConflict
+	case errPromptPayNotConfigured:
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusInternalServerError
+}
+
+// ====================
+// รูป QR พร้อมเพย์ของยอดค้างชำระ
+// GET /orders/:order_id/promptpay-qr.png?size=320   (guest ส่ง ?token= ได้ เพราะ <img> ใส่ header ไม่ได้)
+// header X-PromptPay-Payload = ข้อความใน QR
+// ====================
+func GetOrderPromptPayQR(c *fiber.Ctx) error {
+	_, _, payload, err := orderPromptPay(context.Background(), c, c.Params("order_id"))
+	if err != nil {
+		return c.Status(promptPayErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	size := c.QueryInt("size", promptPayQRSize)
+	if size < promptPayQRMinSize || size > promptPayQRMaxSize {
+		size = promptPayQRSize
+	}
+	png, err := promptpay.PNG(payload, size)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// ยอดเปลี่ยนได้ (ชำระแล้ว/หมดเวลา) จึงห้าม cache
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set("X-PromptPay-Payload", payload)
+	c.Set(fiber.HeaderContentType, "image/png")
+	return c.Send(png)
+}
+
+// ====================
+// ข้อมูลพร้อมเพย์ของยอดค้างชำระ (ข้อความ payload สำหรับแอปที่สร้าง QR เอง)
+// GET /orders/:order_id/promptpay
+// ====================
+func GetOrderPromptPay(c *fiber.Ctx) error {
+	o, amount, payload, err := orderPromptPay(context.Background(), c, c.Params("order_id"))
+	if err != nil {
+		return c.Status(promptPayErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"order_id":     o.ID,
+		"amount":       amount,
+		"payee":        promptpay.MaskTarget(promptPayTarget()),
+		"payload":      payload,
+		"qr_image_url": fmt.Sprintf("/api/orders/%d/promptpay-qr.png", o.ID),
+		"expires_at":   o.ExpiresAt,
+	})
+}
@@ -0,0 +1,900 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errReturnNotFound      = errors.New("return not found")
+	errReturnItemNotFound  = errors.New("return item not found")
+	errReturnQtyExceeded   = errors.New("return quantity exceeds shipped quantity")
+	errOrderNotReturnable  = errors.New("only shipped orders can be returned")
+	errInvalidReturnReason = errors.New("invalid reason")
+	errReturnRefundTooHigh = errors.New("refund exceeds amount paid")
+	errSaleNotFound        = errors.New("sale not found")
+)
+
+func validReturnReason(r string) bool {
+	for _, v := range models.ReturnReasons {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+const returnColumns = `id, source, order_id, sale_id, customer_id, status, reason, note, requested_by,
+	review_note, reviewed_by, reviewed_at, received_by, received_at,
+	refund_amount, refund_shipping, refund_ref, refund_to_credit, refunded_by, refunded_at, created_at, updated_at`
+
+func scanReturn(row pgx.Row, r *models.ReturnRequest) error {
+	return row.Scan(&r.ID, &r.Source, &r.OrderID, &r.SaleID, &r.CustomerID, &r.Status, &r.Reason, &r.Note, &r.RequestedBy,
+		&r.ReviewNote, &r.ReviewedBy, &r.ReviewedAt, &r.ReceivedBy, &r.ReceivedAt,
+		&r.RefundAmount, &r.RefundShipping, &r.RefundRef, &r.RefundToCredit, &r.RefundedBy, &r.RefundedAt, &r.CreatedAt, &r.UpdatedAt)
+}
+
+const returnItemColumns = `id, return_id, order_item_id, product_id, variant_id, name, quantity, reason,
+	paid_amount, refund_amount, disposition`
+
+func scanReturnItem(row pgx.Row, it *models.ReturnItem) error {
+	return row.Scan(&it.ID, &it.ReturnID, &it.OrderItemID, &it.ProductID, &it.VariantID, &it.Name, &it.Quantity, &it.Reason,
+		&it.PaidAmount, &it.RefundAmount, &it.Disposition)
+}
+
+// listReturns โหลดใบคืนสินค้าตามเงื่อนไข where (ต่อท้าย WHERE) พร้อมรายการสินค้า
+func listReturns(ctx context.Context, q querier, where string, args ...interface{}) ([]models.ReturnRequest, error) {
+	rows, err := q.Query(ctx, `SELECT `+returnColumns+` FROM return_requests WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.ReturnRequest{}
+	index := map[int64]int{}
+	var ids []int64
+	for rows.Next() {
+		var r models.ReturnRequest
+		if err := scanReturn(rows, &r); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[r.ID] = len(list)
+		ids = append(ids, r.ID)
+		list = append(list, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return list, nil
+	}
+
+	rows, err = q.Query(ctx, `SELECT `+returnItemColumns+` FROM return_items WHERE return_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it models.ReturnItem
+		if err := scanReturnItem(rows, &it); err != nil {
+			return nil, err
+		}
+		r := &list[index[it.ReturnID]]
+		r.Items = append(r.Items, it)
+	}
+	return list, rows.Err()
+}
+
+func getReturn(ctx context.Context, q querier, id string) (models.ReturnRequest, error) {
+	list, err := listReturns(ctx, q, `id = $1`, id)
+	if err != nil {
+		return models.ReturnRequest{}, err
+	}
+	if len(list) == 0 {
+		return models.ReturnRequest{}, errReturnNotFound
+	}
+	return list[0], nil
+}
+
+// lockReturnTx ล็อกใบคืนสินค้า (ไม่รวมรายการ) กันอนุมัติ/คืนเงินซ้อนกัน
+func lockReturnTx(ctx context.Context, tx pgx.Tx, id string) (models.ReturnRequest, error) {
+	var r models.ReturnRequest
+	err := scanReturn(tx.QueryRow(ctx, `SELECT `+returnColumns+` FROM return_requests WHERE id = $1 FOR UPDATE`, id), &r)
+	if err == pgx.ErrNoRows {
+		return r, errReturnNotFound
+	}
+	return r, err
+}
+
+// normalizeReturnReq ตรวจ reason ของใบคืนและแต่ละบรรทัด (บรรทัดที่ไม่ระบุใช้ reason ของใบ)
+func normalizeReturnReq(req *models.CreateReturnReq) error {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if !validReturnReason(req.Reason) {
+		return errInvalidReturnReason
+	}
+	for i := range req.Items {
+		it := &req.Items[i]
+		it.Reason = strings.TrimSpace(it.Reason)
+		if it.Reason == "" {
+			it.Reason = req.Reason
+		}
+		if !validReturnReason(it.Reason) {
+			return errInvalidReturnReason
+		}
+	}
+	return nil
+}
+
+// returnedQtySQL จำนวนที่อยู่ในใบคืนที่ยังไม่ถูกปฏิเสธ/ยกเลิก
+const returnedQtySQL = `
+	SELECT COALESCE(SUM(ri.quantity), 0)
+	FROM return_items ri JOIN return_requests rr ON rr.id = ri.return_id
+	WHERE rr.status NOT IN ('rejected', 'cancelled') AND `
+
+func insertReturnTx(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) (int64, error) {
+	var id int64
+	err := tx.QueryRow(ctx, `
+		INSERT INTO return_requests (source, order_id, sale_id, customer_id, reason, note, requested_by)
+		VALUES ($1, $2, $3, NULLIF($4,''), $5, NULLIF($6,''), NULLIF($7,''))
+		RETURNING id
+	`, r.Source, r.OrderID, r.SaleID, r.CustomerID, r.Reason, r.Note, r.RequestedBy).Scan(&id)
+	return id, err
+}
+
+func insertReturnItemTx(ctx context.Context, tx pgx.Tx, it models.ReturnItem) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO return_items (return_id, order_item_id, product_id, variant_id, name, quantity, reason, paid_amount, refund_amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+	`, it.ReturnID, it.OrderItemID, it.ProductID, it.VariantID, it.Name, it.Quantity, it.Reason, it.PaidAmount)
+	return err
+}
+
+// createOrderReturnTx เปิดใบคืนสินค้าของออเดอร์ (ต้องส่งของแล้ว อย่างน้อยบางส่วน); ยอดที่จ่ายของแต่ละบรรทัดรวม VAT กรณีราคาไม่รวม VAT
+// customerID ว่าง = พนักงานเปิดให้ (ไม่ตรวจเจ้าของ)
+func createOrderReturnTx(ctx context.Context, tx pgx.Tx, orderID, customerID string, req models.CreateReturnReq, requestedBy string) (int64, error) {
+	o, err := lockOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return 0, err
+	}
+	if customerID != "" && o.UserID != customerID {
+		return 0, errOrderNotFound
+	}
+	if o.Status != models.OrderStatusShipped && o.Status != models.OrderStatusPartiallyShipped {
+		return 0, errOrderNotReturnable
+	}
+
+	returnID, err := insertReturnTx(ctx, tx, models.ReturnRequest{
+		Source:      models.ReturnSourceOrder,
+		OrderID:     &o.ID,
+		CustomerID:  &o.UserID,
+		Reason:      req.Reason,
+		Note:        &req.Note,
+		RequestedBy: &requestedBy,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, in := range req.Items {
+		if in.OrderItemID == nil || in.Quantity <= 0 {
+			return 0, errReturnItemNotFound
+		}
+		var (
+			it       = models.ReturnItem{ReturnID: returnID, OrderItemID: in.OrderItemID, Quantity: in.Quantity, Reason: in.Reason}
+			variant  *string
+			qty      int
+			shipped  int
+			linePaid models.Money
+		)
+		err := tx.QueryRow(ctx, `
+			SELECT oi.product_id, oi.variant_id, oi.name, oi.variant, oi.quantity, `+shippedQtySQL+`,
+			       oi.price * oi.quantity + CASE WHEN o.total > o.subtotal + o.shipping_fee THEN oi.vat_amount ELSE 0 END
+			FROM order_items oi JOIN orders o ON o.id = oi.order_id
+			WHERE oi.id = $1 AND oi.order_id = $2
+		`, *in.OrderItemID, o.ID).Scan(&it.ProductID, &it.VariantID, &it.Name, &variant, &qty, &shipped, &linePaid)
+		if err == pgx.ErrNoRows {
+			return 0, errReturnItemNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		if variant != nil && *variant != "" {
+			it.Name += " (" + *variant + ")"
+		}
+
+		var returned int
+		if err := tx.QueryRow(ctx, returnedQtySQL+`ri.order_item_id = $1`, *in.OrderItemID).Scan(&returned); err != nil {
+			return 0, err
+		}
+		// คืนได้เฉพาะของที่ส่งถึงลูกค้าแล้ว
+		if returned+in.Quantity > shipped {
+			return 0, errReturnQtyExceeded
+		}
+		it.PaidAmount = linePaid.MulRatio(int64(in.Quantity), int64(qty))
+		if err := insertReturnItemTx(ctx, tx, it); err != nil {
+			return 0, err
+		}
+	}
+	return returnID, nil
+}
+
+// createSaleReturnTx เปิดใบคืนสินค้าของการขายหน้าร้าน (หนึ่งการขาย = หนึ่งสินค้า)
+func createSaleReturnTx(ctx context.Context, tx pgx.Tx, saleID string, req models.CreateReturnReq, requestedBy string) (int64, error) {
+	var (
+		productID, name string
+		customerID      *string // ลูกค้า walk-in ไม่มี customer_id
+		variantID       *int64
+		options         map[string]string
+		qty             int
+		total           models.Money
+	)
+	err := tx.QueryRow(ctx, `
+		SELECT s.product_id, s.variant_id, s.customer_id, s.quantity, s.total_price, p.name, v.options
+		FROM sales s
+		JOIN products p ON p.product_id = s.product_id
+		LEFT JOIN product_variants v ON v.id = s.variant_id
+		WHERE s.sale_id = $1 AND s.voided_at IS NULL
+		FOR UPDATE OF s
+	`, saleID).Scan(&productID, &variantID, &customerID, &qty, &total, &name, &options)
+	if err == pgx.ErrNoRows {
+		return 0, errSaleNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if label := variantLabel(options); label != "" {
+		name += " (" + label + ")"
+	}
+	if customerID != nil && *customerID == "" {
+		customerID = nil
+	}
+
+	var returned int
+	if err := tx.QueryRow(ctx, returnedQtySQL+`rr.sale_id = $1`, saleID).Scan(&returned); err != nil {
+		return 0, err
+	}
+
+	returnID, err := insertReturnTx(ctx, tx, models.ReturnRequest{
+		Source:      models.ReturnSourceSale,
+		SaleID:      &saleID,
+		CustomerID:  customerID,
+		Reason:      req.Reason,
+		Note:        &req.Note,
+		RequestedBy: &requestedBy,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, in := range req.Items {
+		if in.Quantity <= 0 {
+			return 0, errReturnItemNotFound
+		}
+		returned += in.Quantity
+		if returned > qty {
+			return 0, errReturnQtyExceeded
+		}
+		if err := insertReturnItemTx(ctx, tx, models.ReturnItem{
+			ReturnID:   returnID,
+			ProductID:  productID,
+			VariantID:  variantID,
+			Name:       name,
+			Quantity:   in.Quantity,
+			Reason:     in.Reason,
+			PaidAmount: total.MulRatio(int64(in.Quantity), int64(qty)),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return returnID, nil
+}
+
+// returnErrorStatus แปลง error ของขั้นตอนคืนสินค้าเป็น HTTP status (0 = error ภายใน)
+func returnErrorStatus(err error) int {
+	switch err {
+	case errReturnNotFound, errOrderNotFound, errSaleNotFound:
+		return fiber.StatusNotFound
+	case errReturnItemNotFound, errInvalidReturnReason, errReturnRefundTooHigh, errCreditNoCustomer:
+		return fiber.StatusBadRequest
+	case errReturnQtyExceeded, errOrderNotReturnable:
+		return fiber.StatusConflict
+	case errPaymentUnavailable:
+		return fiber.StatusServiceUnavailable
+	}
+	return 0
+}
+
+// createReturnHandler เปิดใบคืนใน transaction แล้วตอบใบคืนที่สร้าง
+func createReturnHandler(c *fiber.Ctx, create func(ctx context.Context, tx pgx.Tx) (int64, error)) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := create(ctx, tx)
+	if status := returnErrorStatus(err); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create return failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	r, err := getReturn(ctx, conn, strconv.FormatInt(id, 10))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(r)
+}
+
+// ====================
+// ลูกค้าขอคืนสินค้าในออเดอร์ของตัวเอง (ออเดอร์ต้องส่งของแล้ว)
+// POST /orders/:order_id/returns
+// body: { "reason": "wrong_size", "note": "...", "items": [{ "order_item_id": 1, "quantity": 1, "reason": "" }] }
+// ====================
+func CreateOrderReturn(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req models.CreateReturnReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "items is empty"})
+	}
+	if err := normalizeReturnReq(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "reasons": models.ReturnReasons})
+	}
+
+	return createReturnHandler(c, func(ctx context.Context, tx pgx.Tx) (int64, error) {
+		return createOrderReturnTx(ctx, tx, c.Params("order_id"), userID, req, userID)
+	})
+}
+
+// ====================
+// พนักงานเปิดใบคืนสินค้าให้ (ออเดอร์หรือการขายหน้าร้าน)
+// POST /admin/returns   body: { "order_id": 1 } หรือ { "sale_id": "SALE001" } + reason, note, items
+// การขายหน้าร้าน: items: [{ "quantity": 1 }]
+// ====================
+func AdminCreateReturn(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.CreateReturnReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if (req.OrderID == 0) == (req.SaleID == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "send either order_id or sale_id"})
+	}
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "items is empty"})
+	}
+	if err := normalizeReturnReq(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "reasons": models.ReturnReasons})
+	}
+
+	return createReturnHandler(c, func(ctx context.Context, tx pgx.Tx) (int64, error) {
+		if req.SaleID != "" {
+			return createSaleReturnTx(ctx, tx, req.SaleID, req, staffID)
+		}
+		return createOrderReturnTx(ctx, tx, strconv.FormatInt(req.OrderID, 10), "", req, staffID)
+	})
+}
+
+// ====================
+// ลูกค้าดูใบคืนสินค้าของตัวเอง
+// GET /returns
+// GET /returns/:return_id   (ลูกค้าเจ้าของ หรือพนักงาน)
+// ====================
+func GetMyReturns(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	list, err := listReturns(context.Background(), conn, `customer_id = $1 ORDER BY id DESC`, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func GetReturnByID(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	r, err := getReturn(context.Background(), conn, c.Params("return_id"))
+	if err == errReturnNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	customerID := ""
+	if r.CustomerID != nil {
+		customerID = *r.CustomerID
+	}
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": errReturnNotFound.Error()})
+	}
+	return c.JSON(r)
+}
+
+// ====================
+// รายการใบคืนสินค้า (หลังบ้าน)
+// GET /admin/returns?status=requested&limit=50&offset=0
+// ====================
+func AdminGetReturns(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	list, err := listReturns(context.Background(), conn,
+		`($1 = '' OR status = $1) ORDER BY id DESC LIMIT $2 OFFSET $3`, c.Query("status"), limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// returnTransition ล็อกใบคืน ตรวจสถานะปัจจุบัน แล้วรัน step ใน transaction เดียวกัน
+// check คืน (status, message) ถ้าไม่อนุญาต
+func returnTransition(c *fiber.Ctx, check func(r models.ReturnRequest) (int, string),
+	step func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error) error {
+	return returnTransitionThen(c, check, step, nil)
+}
+
+// returnTransitionThen เหมือน returnTransition แล้วเรียก after หลัง commit (งานภายนอกที่ห้ามอยู่ใน tx เช่นเรียก gateway)
+// after ล้มเหลว: สถานะใบคืนถูกบันทึกแล้ว ตอบ 502 พร้อมข้อมูลใบคืน
+func returnTransitionThen(c *fiber.Ctx, check func(r models.ReturnRequest) (int, string),
+	step func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error,
+	after func(ctx context.Context, conn *pgx.Conn, r models.ReturnRequest) error) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	r, err := lockReturnTx(ctx, tx, c.Params("return_id"))
+	if err == errReturnNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if status, msg := check(r); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg, "status": r.Status})
+	}
+
+	err = step(ctx, tx, r)
+	if status := returnErrorStatus(err); status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	var afterErr error
+	if after != nil {
+		afterErr = after(ctx, conn, r)
+	}
+	r, err = getReturn(ctx, conn, strconv.FormatInt(r.ID, 10))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if afterErr != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": afterErr.Error(), "return": r})
+	}
+	return c.JSON(r)
+}
+
+func requireReturnStatus(want string) func(r models.ReturnRequest) (int, string) {
+	return func(r models.ReturnRequest) (int, string) {
+		if r.Status != want {
+			return fiber.StatusConflict, "return must be " + want
+		}
+		return 0, ""
+	}
+}
+
+// ====================
+// ลูกค้ายกเลิกคำขอคืนของตัวเอง (ก่อนพนักงานอนุมัติ)
+// POST /returns/:return_id/cancel
+// ====================
+func CancelReturn(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	return returnTransition(c, func(r models.ReturnRequest) (int, string) {
+		if r.CustomerID == nil || *r.CustomerID != userID {
+			return fiber.StatusNotFound, errReturnNotFound.Error()
+		}
+		return requireReturnStatus(models.ReturnStatusRequested)(r)
+	}, func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error {
+		_, err := tx.Exec(ctx, `UPDATE return_requests SET status = $1, updated_at = NOW() WHERE id = $2`,
+			models.ReturnStatusCancelled, r.ID)
+		return err
+	})
+}
+
+// ====================
+// พนักงานอนุมัติ/ปฏิเสธคำขอคืน
+// POST /admin/returns/:return_id/approve   body: { "note": "...", "items": [{ "item_id": 1, "refund_amount": 250 }] }
+// POST /admin/returns/:return_id/reject    body: { "note": "..." } (required)
+// ====================
+func ApproveReturn(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.ReviewReturnReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+		}
+	}
+
+	return returnTransition(c, requireReturnStatus(models.ReturnStatusRequested),
+		func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error {
+			for _, it := range req.Items {
+				if it.RefundAmount < 0 {
+					return errReturnRefundTooHigh
+				}
+				tag, err := tx.Exec(ctx, `
+					UPDATE return_items SET refund_amount = $1
+					WHERE id = $2 AND return_id = $3 AND $1 <= paid_amount
+				`, it.RefundAmount, it.ItemID, r.ID)
+				if err != nil {
+					return err
+				}
+				if tag.RowsAffected() == 0 {
+					var exists bool
+					if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM return_items WHERE id = $1 AND return_id = $2)`,
+						it.ItemID, r.ID).Scan(&exists); err != nil {
+						return err
+					}
+					if !exists {
+						return errReturnItemNotFound
+					}
+					return errReturnRefundTooHigh
+				}
+			}
+			return setReturnReviewTx(ctx, tx, r.ID, models.ReturnStatusApproved, req.Note, staffID)
+		})
+}
+
+func RejectReturn(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.ReviewReturnReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Note == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "note is required"})
+	}
+
+	return returnTransition(c, requireReturnStatus(models.ReturnStatusRequested),
+		func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error {
+			return setReturnReviewTx(ctx, tx, r.ID, models.ReturnStatusRejected, req.Note, staffID)
+		})
+}
+
+func setReturnReviewTx(ctx context.Context, tx pgx.Tx, returnID int64, status, note, staffID string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE return_requests
+		SET status = $1, review_note = NULLIF($2,''), reviewed_by = NULLIF($3,''), reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $4
+	`, status, strings.TrimSpace(note), staffID, returnID)
+	return err
+}
+
+// ====================
+// พนักงานรับของคืน และกำหนดว่าแต่ละบรรทัดคืนเข้าสต็อกหรือตัดทิ้ง
+// POST /admin/returns/:return_id/receive
+// body: { "items": [{ "item_id": 1, "disposition": "restock" }, { "item_id": 2, "disposition": "write_off" }] }
+// ====================
+func ReceiveReturn(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.ReceiveReturnReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	dispositions := map[int64]string{}
+	for _, it := range req.Items {
+		if it.Disposition != models.DispositionRestock && it.Disposition != models.DispositionWriteOff {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "disposition must be restock or write_off"})
+		}
+		dispositions[it.ItemID] = it.Disposition
+	}
+
+	return returnTransition(c, requireReturnStatus(models.ReturnStatusApproved),
+		func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error {
+			items, err := listReturnItemsTx(ctx, tx, r.ID)
+			if err != nil {
+				return err
+			}
+			if len(dispositions) != len(items) {
+				return errReturnItemNotFound
+			}
+			for _, it := range items {
+				d, ok := dispositions[it.ID]
+				if !ok {
+					return errReturnItemNotFound
+				}
+				if _, err := tx.Exec(ctx, `UPDATE return_items SET disposition = $1 WHERE id = $2`, d, it.ID); err != nil {
+					return err
+				}
+				if d != models.DispositionRestock {
+					continue
+				}
+				if _, err := moveStockTx(ctx, tx, stockMove{
+					ProductID: it.ProductID,
+					VariantID: it.VariantID,
+					Kind:      models.StockMoveReturn,
+					Change:    it.Quantity,
+					RefType:   "return",
+					RefID:     strconv.FormatInt(r.ID, 10),
+					Note:      it.Reason,
+					CreatedBy: staffID,
+				}); err != nil {
+					return err
+				}
+			}
+			_, err = tx.Exec(ctx, `
+				UPDATE return_requests SET status = $1, received_by = NULLIF($2,''), received_at = NOW(), updated_at = NOW()
+				WHERE id = $3
+			`, models.ReturnStatusReceived, staffID, r.ID)
+			return err
+		})
+}
+
+func listReturnItemsTx(ctx context.Context, tx pgx.Tx, returnID int64) ([]models.ReturnItem, error) {
+	rows, err := tx.Query(ctx, `SELECT `+returnItemColumns+` FROM return_items WHERE return_id = $1 ORDER BY id`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []models.ReturnItem
+	for rows.Next() {
+		var it models.ReturnItem
+		if err := scanReturnItem(rows, &it); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// ====================
+// พนักงานบันทึกการคืนเงิน (หลังรับของคืน)
+// POST /admin/returns/:return_id/refund   body: { "refund_shipping": false, "ref": "...", "store_credit": false }
+// ยอดคืน = ผลรวม refund_amount ของทุกบรรทัด (+ ค่าส่งถ้าขอ); รวมทุกใบต้องไม่เกินยอดที่ลูกค้าจ่าย
+// store_credit = คืนเป็นเครดิตร้าน; ออเดอร์/การขายที่จ่ายด้วย STORE_CREDIT ทั้งหมดคืนเป็นเครดิตเสมอ
+// จ่ายผ่าน gateway (ไม่ส่ง ref): บันทึกรายการคืนเงิน pending แล้ว commit ก่อนเรียก gateway
+// gateway ล้มเหลว = 502 (ใบคืนเป็น refunded แล้ว รายการเงินยัง pending) ให้เรียก .../refund/retry
+// ====================
+func RefundReturn(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+
+	var req models.RefundReturnReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+		}
+	}
+
+	return returnTransitionThen(c, func(r models.ReturnRequest) (int, string) {
+		if req.RefundShipping && r.Source != models.ReturnSourceOrder {
+			return fiber.StatusBadRequest, "refund_shipping is only for orders"
+		}
+		return requireReturnStatus(models.ReturnStatusReceived)(r)
+	}, func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error {
+		var amount models.Money
+		if err := tx.QueryRow(ctx, `SELECT COALESCE(SUM(refund_amount), 0) FROM return_items WHERE return_id = $1`, r.ID).
+			Scan(&amount); err != nil {
+			return err
+		}
+
+		// ล็อกออเดอร์/การขายต้นทางก่อนบวกยอดคืนสะสม
+		var paid, refunded models.Money
+		if r.Source == models.ReturnSourceOrder {
+			var shippingFee models.Money
+			var shippingRefunded bool
+			if err := tx.QueryRow(ctx, `
+				SELECT total, refunded_amount, shipping_fee,
+				       EXISTS (SELECT 1 FROM return_requests WHERE order_id = orders.id AND refund_shipping AND status = 'refunded')
+				FROM orders WHERE id = $1 FOR UPDATE
+			`, *r.OrderID).Scan(&paid, &refunded, &shippingFee, &shippingRefunded); err != nil {
+				return err
+			}
+			if req.RefundShipping && !shippingRefunded {
+				amount += shippingFee
+			} else {
+				req.RefundShipping = false
+			}
+		} else {
+			if err := tx.QueryRow(ctx, `SELECT total_price, refunded_amount FROM sales WHERE sale_id = $1 FOR UPDATE`, *r.SaleID).
+				Scan(&paid, &refunded); err != nil {
+				return err
+			}
+		}
+		if refunded+amount > paid {
+			return errReturnRefundTooHigh
+		}
+
+		var method string
+		var err error
+		if r.Source == models.ReturnSourceOrder {
+			err = tx.QueryRow(ctx, `SELECT payment_method FROM orders WHERE id = $1`, *r.OrderID).Scan(&method)
+		} else {
+			err = tx.QueryRow(ctx, `SELECT payment_method FROM sales WHERE sale_id = $1`, *r.SaleID).Scan(&method)
+		}
+		if err != nil {
+			return err
+		}
+		toCredit := req.StoreCredit || strings.EqualFold(method, models.PayMethodStoreCredit)
+		if toCredit {
+			if r.CustomerID == nil || !hasCreditAccount(*r.CustomerID) {
+				return errCreditNoCustomer
+			}
+			method = models.PayMethodStoreCredit
+		}
+		// จ่ายด้วยบัตรของขวัญ → คืนเข้าบัตรเดิม ไม่ผ่าน gateway
+		toCard := !toCredit && strings.EqualFold(method, models.PayMethodGiftCard)
+		var gateway *models.PaymentIntent
+
+		if r.Source == models.ReturnSourceOrder {
+			if _, err := tx.Exec(ctx, `UPDATE orders SET refunded_amount = refunded_amount + $1, updated_at = NOW() WHERE id = $2`,
+				amount, *r.OrderID); err != nil {
+				return err
+			}
+			// จ่ายผ่าน gateway และพนักงานไม่ได้ระบุเลขโอนคืนเอง → กันยอดไว้ แล้วเรียก gateway หลัง commit
+			if strings.TrimSpace(req.Ref) == "" && !toCredit && !toCard {
+				if gateway, err = reserveProviderRefundTx(ctx, tx, *r.OrderID, amount); err != nil {
+					return err
+				}
+			}
+		} else {
+			if _, err := tx.Exec(ctx, `UPDATE sales SET refunded_amount = refunded_amount + $1 WHERE sale_id = $2`,
+				amount, *r.SaleID); err != nil {
+				return err
+			}
+		}
+
+		// ลงสมุดรับเงินเป็นรายการคืนเงิน (ช่องทางเดียวกับที่ลูกค้าจ่าย หรือ STORE_CREDIT)
+		// คืนผ่าน gateway: รายการเป็น pending ผูก intent ไว้ (provider_ref = intent) จนกว่า gateway ตอบ
+		var paymentID *int64
+		if amount > 0 {
+			e := paymentEntry{
+				Kind: models.PaymentKindRefund, Method: method, Amount: amount, Status: models.PaymentSucceeded,
+				ProviderRef: strings.TrimSpace(req.Ref), Note: "return #" + strconv.FormatInt(r.ID, 10), CreatedBy: staffID,
+			}
+			col, id := "sale_id", interface{}(nil)
+			if r.Source == models.ReturnSourceOrder {
+				e.OrderID = *r.OrderID
+				col, id = "order_id", e.OrderID
+			} else {
+				e.SaleID = *r.SaleID
+				id = e.SaleID
+			}
+			// คืนเข้าบัตรได้ไม่เกินยอดที่ใช้จากบัตร ส่วนที่เหลือ (จ่ายด้วยเครดิตร้าน) คืนเป็นเครดิตร้าน
+			if toCard {
+				restored, err := restoreGiftCardsTx(ctx, tx, col, id, amount, e.Note, staffID)
+				if err != nil {
+					return err
+				}
+				e.Amount -= restored
+				if e.Amount > 0 {
+					if r.CustomerID == nil || !hasCreditAccount(*r.CustomerID) {
+						return errCreditNoCustomer
+					}
+					toCredit = true
+					e.Method = models.PayMethodStoreCredit
+				}
+			}
+			if gateway != nil {
+				e.Status, e.Provider, e.ProviderRef = models.PaymentPending, gateway.Provider, gateway.ProviderRef
+			}
+			if e.Amount > 0 {
+				id, err := insertPaymentTx(ctx, tx, e)
+				if err != nil {
+					return err
+				}
+				paymentID = &id
+			}
+			if toCredit {
+				if _, err := moveStoreCreditTx(ctx, tx, creditMove{
+					CustomerID: *r.CustomerID, Kind: models.StoreCreditIssue, Change: e.Amount,
+					OrderID: e.OrderID, SaleID: e.SaleID, ReturnID: r.ID, CreatedBy: staffID,
+				}); err != nil {
+					return err
+				}
+			}
+			if r.Source == models.ReturnSourceOrder {
+				if err := setActorTx(ctx, tx, staffID); err != nil {
+					return err
+				}
+				if _, err := syncOrderPaymentTx(ctx, tx, e.OrderID); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE return_requests
+			SET status = $1, refund_amount = $2, refund_shipping = $3, refund_ref = NULLIF($4,''),
+			    refunded_by = NULLIF($5,''), refunded_at = NOW(), updated_at = NOW(), refund_to_credit = $7,
+			    refund_payment_id = $8
+			WHERE id = $6
+		`, models.ReturnStatusRefunded, amount, req.RefundShipping, strings.TrimSpace(req.Ref), staffID, r.ID, toCredit, paymentID)
+		return err
+	}, func(ctx context.Context, conn *pgx.Conn, r models.ReturnRequest) error {
+		return completeProviderRefund(ctx, conn, r.ID)
+	})
+}
+
+// ====================
+// ลองคืนเงินผ่าน gateway อีกครั้ง (ครั้งก่อน gateway ล้มเหลว รายการคืนเงินยังเป็น pending)
+// POST /admin/returns/:return_id/refund/retry
+// ใช้ idempotency key เดิมของใบคืน gateway จึงไม่คืนเงินซ้ำแม้ครั้งก่อนสำเร็จไปแล้ว
+// ====================
+func RetryReturnRefund(c *fiber.Ctx) error {
+	return returnTransitionThen(c, requireReturnStatus(models.ReturnStatusRefunded),
+		func(ctx context.Context, tx pgx.Tx, r models.ReturnRequest) error { return nil },
+		func(ctx context.Context, conn *pgx.Conn, r models.ReturnRequest) error {
+			return completeProviderRefund(ctx, conn, r.ID)
+		})
+}
@@ -0,0 +1,529 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errShippingMethodNotFound = errors.New("shipping method not found")
+	errShipItemNotFound       = errors.New("order item not found in this order")
+	errShipQtyExceeded        = errors.New("quantity exceeds the remaining quantity to ship")
+	errNothingToShip          = errors.New("all items have already been shipped")
+)
+
+// shippingFee คำนวณค่าส่งของวิธีส่งหนึ่งวิธี จากยอดสินค้า, จำนวนชิ้นรวม และน้ำหนักรวม (กรัม)
+func shippingFee(m models.ShippingMethod, subtotal models.Money, totalQty, totalWeight int) models.Money {
+	if m.FreeOver != nil && subtotal >= *m.FreeOver {
+		return 0
+	}
+
+	var measure int
+	switch m.RateType {
+	case models.ShipRateWeight:
+		measure = totalWeight
+	case models.ShipRateQuantity:
+		measure = totalQty
+	default:
+		return m.BaseFee
+	}
+	if len(m.Tiers) == 0 {
+		return m.BaseFee
+	}
+
+	tiers := append([]models.ShippingTier(nil), m.Tiers...)
+	sort.SliceStable(tiers, func(i, j int) bool {
+		// UpTo = 0 (ไม่จำกัด) ไว้ท้ายสุด
+		if tiers[i].UpTo == 0 || tiers[j].UpTo == 0 {
+			return tiers[j].UpTo == 0 && tiers[i].UpTo != 0
+		}
+		return tiers[i].UpTo < tiers[j].UpTo
+	})
+	for _, t := range tiers {
+		if t.UpTo == 0 || measure <= t.UpTo {
+			return t.Fee
+		}
+	}
+	// เกินขั้นสุดท้าย ใช้ราคาขั้นสุดท้าย
+	return tiers[len(tiers)-1].Fee
+}
+
+const shippingMethodColumns = `id, code, name, carrier, rate_type, base_fee, tiers, free_over, active, sort_order, created_at, updated_at`
+
+func scanShippingMethod(row pgx.Row, m *models.ShippingMethod) error {
+	return row.Scan(&m.ID, &m.Code, &m.Name, &m.Carrier, &m.RateType, &m.BaseFee, &m.Tiers, &m.FreeOver,
+		&m.Active, &m.SortOrder, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// loadShippingMethodTx โหลดวิธีส่งที่เปิดใช้ตาม code; code ว่าง = วิธีแรกตาม sort_order
+// ถ้ายังไม่ได้ตั้งค่าวิธีส่งไว้เลย จะคืน nil, nil (ไม่คิดค่าส่ง)
+func loadShippingMethodTx(ctx context.Context, tx pgx.Tx, code string) (*models.ShippingMethod, error) {
+	var m models.ShippingMethod
+	var err error
+	if code == "" {
+		err = scanShippingMethod(tx.QueryRow(ctx, `
+			SELECT `+shippingMethodColumns+` FROM shipping_methods
+			WHERE active ORDER BY sort_order, id LIMIT 1
+		`), &m)
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+	} else {
+		err = scanShippingMethod(tx.QueryRow(ctx, `
+			SELECT `+shippingMethodColumns+` FROM shipping_methods
+			WHERE code = $1 AND active
+		`, code), &m)
+		if err == pgx.ErrNoRows {
+			return nil, errShippingMethodNotFound
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func validateShippingMethod(m *models.ShippingMethod) string {
+	m.Code = strings.ToUpper(strings.TrimSpace(m.Code))
+	m.Name = strings.TrimSpace(m.Name)
+	m.Carrier = strings.TrimSpace(m.Carrier)
+	switch {
+	case m.Code == "":
+		return "code is required"
+	case m.Name == "":
+		return "name is required"
+	case m.RateType != models.ShipRateFlat && m.RateType != models.ShipRateWeight && m.RateType != models.ShipRateQuantity:
+		return "rate_type must be flat, weight or quantity"
+	case m.BaseFee < 0:
+		return "base_fee must not be negative"
+	}
+	for _, t := range m.Tiers {
+		if t.UpTo < 0 || t.Fee < 0 {
+			return "invalid tier"
+		}
+	}
+	if m.Tiers == nil {
+		m.Tiers = []models.ShippingTier{}
+	}
+	return ""
+}
+
+// ====================
+// วิธีจัดส่งที่เปิดใช้ (หน้าร้าน)
+// GET /shipping-methods
+// ====================
+func GetShippingMethods(c *fiber.Ctx) error {
+	return listShippingMethods(c, true)
+}
+
+// ====================
+// วิธีจัดส่งทั้งหมด (หลังบ้าน)
+// GET /admin/shipping-methods
+// ====================
+func AdminGetShippingMethods(c *fiber.Ctx) error {
+	return listShippingMethods(c, false)
+}
+
+func listShippingMethods(c *fiber.Ctx, activeOnly bool) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT `+shippingMethodColumns+` FROM shipping_methods
+		WHERE active OR NOT $1
+		ORDER BY sort_order, id
+	`, activeOnly)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	list := []models.ShippingMethod{}
+	for rows.Next() {
+		var m models.ShippingMethod
+		if err := scanShippingMethod(rows, &m); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		list = append(list, m)
+	}
+	return c.JSON(list)
+}
+
+// ====================
+// คำนวณค่าส่งทุกวิธีสำหรับตะกร้า (หน้าร้าน)
+// POST /shipping-methods/quote   body: { "items": [{ "product_id": "...", "variant_id": 1, "quantity": 1 }] } (รายการแบบเดียวกับ POST /orders)
+// ====================
+func QuoteShipping(c *fiber.Ctx) error {
+	var req models.ShippingQuoteReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "items is empty"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+
+	var subtotal models.Money
+	var totalQty, totalWeight int
+	for _, it := range req.Items {
+		if (it.ProductID == "" && it.SKU == "") || it.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid item"})
+		}
+		// คิดราคาแบบเดียวกับตอนสั่งซื้อ (price_override ของ variant) ให้ยอดส่งฟรีตรงกับ checkout
+		l, err := resolveOrderLine(ctx, conn, it, false)
+		switch err {
+		case nil:
+		case errProductNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "product not found: " + it.ProductID})
+		case errVariantNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "variant not found", "product_id": it.ProductID})
+		case errVariantRequired:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "product_id": l.ProductID})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		subtotal += l.Price.Mul(it.Quantity)
+		totalQty += it.Quantity
+		totalWeight += it.Quantity * l.WeightGrams
+	}
+
+	rows, err := conn.Query(ctx, `SELECT `+shippingMethodColumns+` FROM shipping_methods WHERE active ORDER BY sort_order, id`)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	quotes := []models.ShippingQuote{}
+	for rows.Next() {
+		var m models.ShippingMethod
+		if err := scanShippingMethod(rows, &m); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		quotes = append(quotes, models.ShippingQuote{
+			Code:    m.Code,
+			Name:    m.Name,
+			Carrier: m.Carrier,
+			Fee:     shippingFee(m, subtotal, totalQty, totalWeight),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"subtotal":     subtotal,
+		"total_qty":    totalQty,
+		"total_weight": totalWeight,
+		"methods":      quotes,
+	})
+}
+
+// ====================
+// เพิ่มวิธีจัดส่ง (หลังบ้าน)
+// POST /admin/shipping-methods
+// ====================
+func CreateShippingMethod(c *fiber.Ctx) error {
+	var m models.ShippingMethod
+	if err := c.BodyParser(&m); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := validateShippingMethod(&m); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	err = scanShippingMethod(conn.QueryRow(context.Background(), `
+		INSERT INTO shipping_methods (code, name, carrier, rate_type, base_fee, tiers, free_over, active, sort_order)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		RETURNING `+shippingMethodColumns,
+		m.Code, m.Name, m.Carrier, m.RateType, m.BaseFee, m.Tiers, m.FreeOver, m.Active, m.SortOrder,
+	), &m)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(m)
+}
+
+// ====================
+// แก้ไขวิธีจัดส่ง (หลังบ้าน)
+// PUT /admin/shipping-methods/:id
+// ====================
+func UpdateShippingMethod(c *fiber.Ctx) error {
+	var m models.ShippingMethod
+	if err := c.BodyParser(&m); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := validateShippingMethod(&m); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	err = scanShippingMethod(conn.QueryRow(context.Background(), `
+		UPDATE shipping_methods
+		SET code=$1, name=$2, carrier=$3, rate_type=$4, base_fee=$5, tiers=$6, free_over=$7, active=$8, sort_order=$9,
+		    updated_at=NOW()
+		WHERE id=$10
+		RETURNING `+shippingMethodColumns,
+		m.Code, m.Name, m.Carrier, m.RateType, m.BaseFee, m.Tiers, m.FreeOver, m.Active, m.SortOrder, c.Params("id"),
+	), &m)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Shipping method not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(m)
+}
+
+// ===== Shipments =====
+
+// สถานะที่ส่งของได้ (COD ส่งได้ตั้งแต่ pending เพราะเก็บเงินปลายทาง; ส่งบางส่วนแล้วส่งต่อได้)
+func canShipOrder(status, paymentMethod string) bool {
+	switch status {
+	case models.OrderStatusPaid, models.OrderStatusProcessing, models.OrderStatusPartiallyShipped:
+		return true
+	case models.OrderStatusPending:
+		return strings.ToUpper(paymentMethod) == models.PayMethodCOD
+	}
+	return false
+}
+
+// shippedQtySQL จำนวนที่ส่งไปแล้วของ order_items แถว oi
+const shippedQtySQL = `(SELECT COALESCE(SUM(si.quantity), 0) FROM shipment_items si WHERE si.order_item_id = oi.id)`
+
+// shipOrderTx บันทึกพัสดุหนึ่งกล่อง (ทั้งหมดที่เหลือ หรือเฉพาะ req.Items) แล้วตั้งสถานะออเดอร์
+// เป็น shipped เมื่อส่งครบทุกรายการ ไม่งั้น partially_shipped; ต้องล็อกแถวออเดอร์ไว้ก่อนเรียก
+func shipOrderTx(ctx context.Context, tx pgx.Tx, orderID int64, req models.ShipOrderReq, shippedBy string) (models.Shipment, error) {
+	var s models.Shipment
+	if err := setActorTx(ctx, tx, shippedBy); err != nil {
+		return s, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT oi.id, oi.product_id, oi.name, oi.variant, oi.quantity - `+shippedQtySQL+`
+		FROM order_items oi WHERE oi.order_id = $1 ORDER BY oi.id
+	`, orderID)
+	if err != nil {
+		return s, err
+	}
+	var lines []models.ShipmentItem // Quantity = ที่ยังค้างส่ง
+	for rows.Next() {
+		var it models.ShipmentItem
+		if err := rows.Scan(&it.OrderItemID, &it.ProductID, &it.Name, &it.Variant, &it.Quantity); err != nil {
+			rows.Close()
+			return s, err
+		}
+		lines = append(lines, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+
+	want := map[int64]int{}
+	if len(req.Items) == 0 {
+		for _, it := range lines {
+			want[it.OrderItemID] = it.Quantity
+		}
+	}
+	for _, in := range req.Items {
+		if in.Quantity <= 0 {
+			return s, errShipQtyExceeded
+		}
+		want[in.OrderItemID] += in.Quantity
+	}
+
+	remaining := 0
+	for _, it := range lines {
+		qty, ok := want[it.OrderItemID]
+		delete(want, it.OrderItemID)
+		if qty > it.Quantity {
+			return s, errShipQtyExceeded
+		}
+		remaining += it.Quantity - qty
+		if ok && qty > 0 {
+			it.Quantity = qty
+			s.Items = append(s.Items, it)
+		}
+	}
+	if len(want) > 0 {
+		return s, errShipItemNotFound
+	}
+	if len(s.Items) == 0 {
+		return s, errNothingToShip
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO shipments (order_id, carrier, tracking_no, note, shipped_by)
+		VALUES ($1, $2, $3, NULLIF($4,''), NULLIF($5,''))
+		RETURNING id, order_id, carrier, tracking_no, note, shipped_by, shipped_at
+	`, orderID, req.Carrier, req.TrackingNo, req.Note, shippedBy).
+		Scan(&s.ID, &s.OrderID, &s.Carrier, &s.TrackingNo, &s.Note, &s.ShippedBy, &s.ShippedAt)
+	if err != nil {
+		return s, err
+	}
+	for _, it := range s.Items {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO shipment_items (shipment_id, order_item_id, quantity) VALUES ($1, $2, $3)
+		`, s.ID, it.OrderItemID, it.Quantity); err != nil {
+			return s, err
+		}
+	}
+
+	status := models.OrderStatusShipped
+	if remaining > 0 {
+		status = models.OrderStatusPartiallyShipped
+	}
+	_, err = tx.Exec(ctx, `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`, status, orderID)
+	return s, err
+}
+
+func getShipments(ctx context.Context, conn *pgx.Conn, orderID int64) ([]models.Shipment, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT id, order_id, carrier, tracking_no, note, shipped_by, shipped_at
+		FROM shipments WHERE order_id = $1 ORDER BY id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.Shipment{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var s models.Shipment
+		if err := rows.Scan(&s.ID, &s.OrderID, &s.Carrier, &s.TrackingNo, &s.Note, &s.ShippedBy, &s.ShippedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[s.ID] = len(list)
+		list = append(list, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = conn.Query(ctx, `
+		SELECT si.shipment_id, oi.id, oi.product_id, oi.name, oi.variant, si.quantity
+		FROM shipment_items si
+		JOIN shipments s ON s.id = si.shipment_id
+		JOIN order_items oi ON oi.id = si.order_item_id
+		WHERE s.order_id = $1
+		ORDER BY si.shipment_id, oi.id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			shipmentID int64
+			it         models.ShipmentItem
+		)
+		if err := rows.Scan(&shipmentID, &it.OrderItemID, &it.ProductID, &it.Name, &it.Variant, &it.Quantity); err != nil {
+			return nil, err
+		}
+		if i, ok := index[shipmentID]; ok {
+			list[i].Items = append(list[i].Items, it)
+		}
+	}
+	return list, rows.Err()
+}
+
+// ====================
+// ส่งของ: บันทึกขนส่ง + เลขพัสดุ แล้วเปลี่ยนสถานะเป็น shipped / partially_shipped (หลังบ้าน)
+// POST /admin/orders/:order_id/ship   body: { "carrier": "KERRY", "tracking_no": "..." }
+// แยกส่ง: เพิ่ม "items": [{ "order_item_id": 12, "quantity": 1 }] (ไม่ส่ง items = ส่งที่เหลือทั้งหมด)
+// ====================
+func ShipOrder(c *fiber.Ctx) error {
+	var req models.ShipOrderReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	req.Carrier = strings.TrimSpace(req.Carrier)
+	req.TrackingNo = strings.TrimSpace(req.TrackingNo)
+	if req.Carrier == "" || req.TrackingNo == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "carrier and tracking_no are required"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		orderID       int64
+		status        string
+		paymentMethod string
+	)
+	err = tx.QueryRow(ctx, `SELECT id, status, payment_method FROM orders WHERE id = $1 FOR UPDATE`, c.Params("order_id")).
+		Scan(&orderID, &status, &paymentMethod)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !canShipOrder(status, paymentMethod) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "order cannot be shipped in status " + status})
+	}
+
+	staffID, _ := c.Locals("user_id").(string)
+	s, err := shipOrderTx(ctx, tx, orderID, req, staffID)
+	if status, ok := shipErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "create shipment failed"})
+	}
+	if err := tx.QueryRow(ctx, `SELECT status FROM orders WHERE id = $1`, orderID).Scan(&status); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "order shipped", "status": status, "shipment": s})
+}
+
+// shipErrorStatus แปลง error ของ shipOrderTx ที่เกิดจาก request เป็น HTTP status
+func shipErrorStatus(err error) (int, bool) {
+	switch err {
+	case errShipItemNotFound, errShipQtyExceeded:
+		return fiber.StatusBadRequest, true
+	case errNothingToShip:
+		return fiber.StatusConflict, true
+	}
+	return 0, false
+}
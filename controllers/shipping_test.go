@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"dog/models"
+	"testing"
+)
+
+func TestShippingFee(t *testing.T) {
+	freeOver := models.Money(100000)
+	weight := models.ShippingMethod{
+		RateType: models.ShipRateWeight,
+		BaseFee:  5000,
+		// ลำดับสลับกันโดยตั้งใจ และ UpTo = 0 คือไม่จำกัด
+		Tiers: []models.ShippingTier{{UpTo: 0, Fee: 15000}, {UpTo: 3000, Fee: 9000}, {UpTo: 1000, Fee: 4000}},
+	}
+	quantity := models.ShippingMethod{
+		RateType: models.ShipRateQuantity,
+		BaseFee:  5000,
+		Tiers:    []models.ShippingTier{{UpTo: 5, Fee: 6000}, {UpTo: 2, Fee: 3500}},
+	}
+
+	tests := []struct {
+		name     string
+		m        models.ShippingMethod
+		subtotal models.Money
+		qty      int
+		weight   int
+		want     models.Money
+	}{
+		{"flat", models.ShippingMethod{RateType: models.ShipRateFlat, BaseFee: 4500}, 50000, 3, 2500, 4500},
+		{"unknown rate type is flat", models.ShippingMethod{RateType: "zone", BaseFee: 4500}, 50000, 3, 2500, 4500},
+		{"free over threshold", models.ShippingMethod{RateType: models.ShipRateFlat, BaseFee: 4500, FreeOver: &freeOver}, 100000, 3, 2500, 0},
+		{"just under free threshold", models.ShippingMethod{RateType: models.ShipRateFlat, BaseFee: 4500, FreeOver: &freeOver}, 99999, 3, 2500, 4500},
+
+		{"weight first tier", weight, 50000, 1, 500, 4000},
+		{"weight on tier boundary", weight, 50000, 1, 1000, 4000},
+		{"weight second tier", weight, 50000, 1, 1001, 9000},
+		{"weight unlimited tier", weight, 50000, 1, 20000, 15000},
+		{"weight with no tiers", models.ShippingMethod{RateType: models.ShipRateWeight, BaseFee: 5000}, 50000, 1, 500, 5000},
+
+		{"quantity first tier", quantity, 50000, 2, 0, 3500},
+		{"quantity second tier", quantity, 50000, 5, 0, 6000},
+		{"quantity past last tier", quantity, 50000, 9, 0, 6000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shippingFee(tt.m, tt.subtotal, tt.qty, tt.weight); got != tt.want {
+				t.Errorf("shippingFee = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// ต้องไม่เรียงลำดับ tiers ของ method เดิม
+	if weight.Tiers[0].UpTo != 0 || weight.Tiers[2].UpTo != 1000 {
+		t.Errorf("tiers were reordered in place: %+v", weight.Tiers)
+	}
+}
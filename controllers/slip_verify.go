@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"dog/models"
+	"dog/slipqr"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ระยะเผื่อเวลาโอนที่ลูกค้าแจ้ง (นาฬิกาเครื่องลูกค้า/ธนาคารไม่ตรงกัน)
+const slipTimeTolerance = 10 * time.Minute
+
+// slipOrder ข้อมูลออเดอร์ที่ใช้เทียบกับสลิป
+type slipOrder struct {
+	ID        int64
+	Total     models.Money
+	Paid      models.Money // รับแล้วสุทธิ (orders.paid_amount)
+	CreatedAt time.Time
+}
+
+// outstanding ยอดที่ยังต้องจ่าย
+func (o slipOrder) outstanding() models.Money {
+	if o.Paid >= o.Total {
+		return 0
+	}
+	return o.Total - o.Paid
+}
+
+// slipVerification ผลตรวจสลิปอัตโนมัติ
+type slipVerification struct {
+	QR     slipqr.Slip
+	Result string
+	Notes  []string
+}
+
+func (v slipVerification) qrRef() string      { return v.QR.Ref }
+func (v slipVerification) qrBankCode() string { return v.QR.BankCode }
+
+// verifySlipTx ตัดสินผลตรวจจาก QR ที่อ่านได้ (qrErr = ผลของ slipqr.Decode ซึ่งทำก่อนเปิด tx เพราะช้า)
+// เลขอ้างอิงซ้ำ ยอดไม่ตรง หรือวันที่โอนผิดช่วง = suspicious ให้พนักงานดู ไม่ปฏิเสธเอง
+func verifySlipTx(ctx context.Context, tx pgx.Tx, o slipOrder, qr slipqr.Slip, qrErr error,
+	amount *models.Money, transferredAt *time.Time, bankRef string, now time.Time) (slipVerification, error) {
+	v := slipVerification{QR: qr, Notes: []string{}}
+	switch qrErr {
+	case nil:
+	case slipqr.ErrNotSlip:
+		v.Result = models.SlipSuspicious
+		v.Notes = append(v.Notes, "QR code on the image is not a bank slip reference")
+		return v, nil
+	default:
+		v.Result = models.SlipUnreadable
+		v.Notes = append(v.Notes, "slip QR code not found or unreadable")
+		return v, nil
+	}
+
+	// เลขอ้างอิงเดียวกันเคยใช้กับออเดอร์อื่น (สลิปที่ยังไม่ถูกปฏิเสธ หรือ payment_ref ของออเดอร์)
+	var dupOrder int64
+	err := tx.QueryRow(ctx, `
+		SELECT order_id FROM payment_slips
+		WHERE qr_ref = $1 AND order_id <> $2 AND status <> $3
+		UNION ALL
+		SELECT id FROM orders WHERE payment_ref = $1 AND id <> $2
+		LIMIT 1
+	`, qr.Ref, o.ID, models.SlipStatusRejected).Scan(&dupOrder)
+	if err != nil && err != pgx.ErrNoRows {
+		return v, err
+	}
+	if err == nil {
+		v.Notes = append(v.Notes, fmt.Sprintf("slip reference already used on order #%d", dupOrder))
+	}
+
+	if bankRef != "" && bankRef != qr.Ref {
+		v.Notes = append(v.Notes, "declared bank_ref does not match the slip QR reference")
+	}
+	if amount != nil && *amount != o.outstanding() {
+		v.Notes = append(v.Notes, fmt.Sprintf("declared amount %s does not match outstanding amount %s", *amount, o.outstanding()))
+	}
+
+	// วันที่: จากเลขอ้างอิง (ถ้าธนาคารนั้นใส่ไว้) และเวลาที่ลูกค้าแจ้ง ต้องอยู่ระหว่างสร้างออเดอร์ถึงตอนนี้
+	if qr.Date != nil {
+		created := o.CreatedAt.In(orderSearchZone)
+		orderDay := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, orderSearchZone)
+		if qr.Date.Before(orderDay) || qr.Date.After(now) {
+			v.Notes = append(v.Notes, fmt.Sprintf("slip date %s is outside the order period", qr.Date.Format("2006-01-02")))
+		}
+	}
+	if transferredAt != nil {
+		if transferredAt.Before(o.CreatedAt.Add(-slipTimeTolerance)) || transferredAt.After(now.Add(slipTimeTolerance)) {
+			v.Notes = append(v.Notes, "declared transfer time is outside the order period")
+		} else if qr.Date != nil && transferredAt.In(orderSearchZone).Format("20060102") != qr.Date.Format("20060102") {
+			v.Notes = append(v.Notes, "declared transfer date does not match the slip date")
+		}
+	}
+
+	v.Result = models.SlipVerified
+	if len(v.Notes) > 0 {
+		v.Result = models.SlipSuspicious
+	}
+	return v, nil
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errProductNotFound    = errors.New("product not found")
+	errInsufficientStock  = errors.New("insufficient stock")
+	errProductHasVariants = errors.New("stock of this product is managed per variant")
+)
+
+// stockMove ข้อมูลที่ใช้บันทึกลง stock_movements หนึ่งรายการ
+type stockMove struct {
+	ProductID string
+	VariantID *int64 // มีค่า = ตัด/เพิ่มสต็อกของ variant นั้นด้วย
+	Kind      string
+	Change    int // + เข้า, - ออก
+	RefType   string
+	RefID     string
+	Note      string
+	CreatedBy string
+}
+
+// moveStockTx อัปเดต products.quantity (และ product_variants.quantity ถ้าระบุ variant)
+// แล้วเขียน ledger ใน transaction เดียวกัน
+// ห้ามให้ยอดคงเหลือติดลบ; คืนค่ายอดคงเหลือระดับสินค้าหลังรายการ
+func moveStockTx(ctx context.Context, tx pgx.Tx, m stockMove) (int, error) {
+	if m.VariantID != nil {
+		var variantBalance int
+		err := tx.QueryRow(ctx, `
+			UPDATE product_variants SET quantity = quantity + $1, updated_at = NOW()
+			WHERE id = $2 AND product_id = $3 AND quantity + $1 >= 0
+			RETURNING quantity
+		`, m.Change, *m.VariantID, m.ProductID).Scan(&variantBalance)
+		if err == pgx.ErrNoRows {
+			var exists bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM product_variants WHERE id = $1 AND product_id = $2)`, *m.VariantID, m.ProductID).Scan(&exists); err != nil {
+				return 0, err
+			}
+			if !exists {
+				return 0, errVariantNotFound
+			}
+			return 0, errInsufficientStock
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var balance int
+	err := tx.QueryRow(ctx, `
+		UPDATE products SET quantity = quantity + $1, updated_at = NOW()
+		WHERE product_id = $2 AND quantity + $1 >= 0
+		RETURNING quantity
+	`, m.Change, m.ProductID).Scan(&balance)
+	if err == pgx.ErrNoRows {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE product_id = $1)`, m.ProductID).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, errProductNotFound
+		}
+		return 0, errInsufficientStock
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO stock_movements (product_id, variant_id, kind, quantity_change, balance_after, ref_type, ref_id, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6,''), NULLIF($7,''), NULLIF($8,''), NULLIF($9,''))
+	`, m.ProductID, m.VariantID, m.Kind, m.Change, balance, m.RefType, m.RefID, m.Note, m.CreatedBy); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// setStockTx ปรับยอดคงเหลือให้เท่ากับ target โดยเขียนผลต่างลง ledger
+// (ใช้กับฟอร์มหลังบ้านที่ส่งยอดคงเหลือมาแทนจำนวนที่เปลี่ยน)
+// สินค้าที่มี variant ต้องปรับผ่าน setVariantStockTx; ส่งยอดเดิมมาได้ (ไม่เปลี่ยนอะไร)
+func setStockTx(ctx context.Context, tx pgx.Tx, productID string, target int, kind, note, createdBy string) (int, error) {
+	var current int
+	var hasVariants bool
+	err := tx.QueryRow(ctx, `
+		SELECT quantity, EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.product_id)
+		FROM products p WHERE product_id = $1
+		FOR UPDATE
+	`, productID).Scan(&current, &hasVariants)
+	if err == pgx.ErrNoRows {
+		return 0, errProductNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if target == current {
+		return current, nil
+	}
+	if hasVariants {
+		return 0, errProductHasVariants
+	}
+	if kind == models.StockMoveReceive && target < current {
+		kind = models.StockMoveAdjustment
+	}
+	return moveStockTx(ctx, tx, stockMove{
+		ProductID: productID,
+		Kind:      kind,
+		Change:    target - current,
+		Note:      note,
+		CreatedBy: createdBy,
+	})
+}
+
+// setVariantStockTx เหมือน setStockTx แต่ปรับยอดของ variant เดียว (products.quantity เปลี่ยนตาม)
+func setVariantStockTx(ctx context.Context, tx pgx.Tx, variantID int64, target int, kind, note, createdBy string) (int, error) {
+	var productID string
+	var current int
+	err := tx.QueryRow(ctx, `SELECT product_id, quantity FROM product_variants WHERE id = $1 FOR UPDATE`, variantID).Scan(&productID, &current)
+	if err == pgx.ErrNoRows {
+		return 0, errVariantNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if target == current {
+		return current, nil
+	}
+	if kind == models.StockMoveReceive && target < current {
+		kind = models.StockMoveAdjustment
+	}
+	if _, err := moveStockTx(ctx, tx, stockMove{
+		ProductID: productID,
+		VariantID: &variantID,
+		Kind:      kind,
+		Change:    target - current,
+		Note:      note,
+		CreatedBy: createdBy,
+	}); err != nil {
+		return 0, err
+	}
+	return target, nil
+}
+
+// ====================
+// ประวัติการเคลื่อนไหวสต็อกรายสินค้า (หลังบ้าน)
+// GET /admin/products/:product_id/movements?limit=50&before_id=123
+// ====================
+func GetStockMovements(c *fiber.Ctx) error {
+	db, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to connect database"})
+	}
+	defer db.Close(context.Background())
+
+	productID := c.Params("product_id")
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	beforeID := c.QueryInt("before_id", 0)
+
+	ctx := context.Background()
+
+	var onHand, ledgerSum int
+	err = db.QueryRow(ctx, `
+		SELECT p.quantity, COALESCE((SELECT SUM(quantity_change) FROM stock_movements WHERE product_id = p.product_id), 0)
+		FROM products p WHERE p.product_id = $1
+	`, productID).Scan(&onHand, &ledgerSum)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT id, product_id, variant_id, kind, quantity_change, balance_after, ref_type, ref_id, note, created_by, created_at
+		FROM stock_movements
+		WHERE product_id = $1 AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, productID, beforeID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Query failed"})
+	}
+	defer rows.Close()
+
+	items := []models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &m.Kind, &m.QuantityChange, &m.BalanceAfter,
+			&m.RefType, &m.RefID, &m.Note, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Scan failed"})
+		}
+		items = append(items, m)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Rows error"})
+	}
+
+	return c.JSON(fiber.Map{
+		"product_id": productID,
+		"on_hand":    onHand,
+		"ledger_sum": ledgerSum,
+		"in_sync":    onHand == ledgerSum,
+		"items":      items,
+	})
+}
+
+// ====================
+// ตรวจยอดคงเหลือเทียบ ledger (หลังบ้าน) — แสดงเฉพาะสินค้า/variant ที่ยอดไม่ตรง
+// GET /admin/stock/reconcile
+// ====================
+func ReconcileStock(c *fiber.Ctx) error {
+	db, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to connect database"})
+	}
+	defer db.Close(context.Background())
+
+	rows, err := db.Query(context.Background(), `
+		SELECT p.product_id, p.name, p.quantity, COALESCE(SUM(m.quantity_change), 0) AS ledger_sum
+		FROM products p
+		LEFT JOIN stock_movements m ON m.product_id = p.product_id
+		GROUP BY p.product_id, p.name, p.quantity
+		HAVING p.quantity <> COALESCE(SUM(m.quantity_change), 0)
+		ORDER BY p.product_id
+	`)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Query failed"})
+	}
+	defer rows.Close()
+
+	type drift struct {
+		ProductID string `json:"product_id"`
+		Name      string `json:"name"`
+		OnHand    int    `json:"on_hand"`
+		LedgerSum int    `json:"ledger_sum"`
+		Diff      int    `json:"diff"`
+	}
+	items := []drift{}
+	for rows.Next() {
+		var d drift
+		if err := rows.Scan(&d.ProductID, &d.Name, &d.OnHand, &d.LedgerSum); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Scan failed"})
+		}
+		d.Diff = d.OnHand - d.LedgerSum
+		items = append(items, d)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Rows error"})
+	}
+	rows.Close()
+
+	// variant: ยอดคงเหลือเทียบ ledger ของ variant และผลรวม variant เทียบ products.quantity
+	vrows, err := db.Query(context.Background(), `
+		SELECT v.id, v.product_id, v.sku, v.quantity,
+		       COALESCE((SELECT SUM(m.quantity_change) FROM stock_movements m WHERE m.variant_id = v.id), 0) AS ledger_sum
+		FROM product_variants v
+		ORDER BY v.product_id, v.id
+	`)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Query failed"})
+	}
+	defer vrows.Close()
+
+	type variantDrift struct {
+		VariantID int64  `json:"variant_id"`
+		ProductID string `json:"product_id"`
+		SKU       string `json:"sku"`
+		OnHand    int    `json:"on_hand"`
+		LedgerSum int    `json:"ledger_sum"`
+		Diff      int    `json:"diff"`
+	}
+	variantItems := []variantDrift{}
+	for vrows.Next() {
+		var d variantDrift
+		if err := vrows.Scan(&d.VariantID, &d.ProductID, &d.SKU, &d.OnHand, &d.LedgerSum); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Scan failed"})
+		}
+		d.Diff = d.OnHand - d.LedgerSum
+		if d.Diff != 0 {
+			variantItems = append(variantItems, d)
+		}
+	}
+	if err := vrows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Rows error"})
+	}
+
+	return c.JSON(fiber.Map{
+		"in_sync":       len(items) == 0 && len(variantItems) == 0,
+		"drift":         items,
+		"variant_drift": variantItems,
+	})
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errCreditInsufficient = errors.New("insufficient store credit")
+	errCreditAmount       = errors.New("store credit amount must not be 0")
+	errCreditNegative     = errors.New("store_credit must not be negative")
+	errCreditReason       = errors.New("reason is required")
+	errCreditNoCustomer   = errors.New("store credit requires a customer account")
+)
+
+// creditMove ข้อมูลที่ใช้บันทึกลง store_credit_transactions หนึ่งรายการ
+type creditMove struct {
+	CustomerID string
+	Kind       string
+	Change     models.Money // + เข้า, - ใช้ไป
+	OrderID    int64
+	SaleID     string
+	ReturnID   int64
+	Reason     string
+	CreatedBy  string
+}
+
+// hasCreditAccount ลูกค้าที่มีบัญชีเท่านั้นที่ถือเครดิตได้ (guest checkout ไม่ได้)
+func hasCreditAccount(customerID string) bool {
+	return customerID != "" && !strings.HasPrefix(customerID, guestUserPrefix)
+}
+
+// moveStoreCreditTx อัปเดต store_credit_accounts.balance แล้วเขียน ledger ใน transaction เดียวกัน
+// UPDATE ล็อกแถวบัญชีไว้จน commit จึงใช้เครดิตซ้อนกันไม่ได้; ห้ามยอดติดลบ คืนค่ายอดคงเหลือหลังรายการ
+func moveStoreCreditTx(ctx context.Context, tx pgx.Tx, m creditMove) (models.Money, error) {
+	if !hasCreditAccount(m.CustomerID) {
+		return 0, errCreditNoCustomer
+	}
+	if m.Change == 0 {
+		return 0, errCreditAmount
+	}
+	if m.Change > 0 {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO store_credit_accounts (customer_id) VALUES ($1) ON CONFLICT (customer_id) DO NOTHING
+		`, m.CustomerID); err != nil {
+			return 0, err
+		}
+	}
+
+	var balance models.Money
+	err := tx.QueryRow(ctx, `
+		UPDATE store_credit_accounts SET balance = balance + $2::numeric, updated_at = NOW()
+		WHERE customer_id = $1 AND balance + $2::numeric >= 0
+		RETURNING balance
+	`, m.CustomerID, m.Change).Scan(&balance)
+	if err == pgx.ErrNoRows {
+		return 0, errCreditInsufficient
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var orderID, returnID *int64
+	if m.OrderID != 0 {
+		orderID = &m.OrderID
+	}
+	if m.ReturnID != 0 {
+		returnID = &m.ReturnID
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO store_credit_transactions (customer_id, kind, amount, balance_after, order_id, sale_id, return_id, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6,''), $7, NULLIF($8,''), NULLIF($9,''))
+	`, m.CustomerID, m.Kind, m.Change, balance, orderID, m.SaleID, returnID, m.Reason, m.CreatedBy); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// redeemStoreCreditTx ตัดเครดิตแล้วลงสมุดรับเงินเป็นการรับเงินช่องทาง STORE_CREDIT
+// ระบุ e.OrderID หรือ e.SaleID; ออเดอร์ต้อง syncOrderPaymentTx ต่อเอง
+func redeemStoreCreditTx(ctx context.Context, tx pgx.Tx, customerID string, e paymentEntry) error {
+	if e.Amount <= 0 {
+		return nil
+	}
+	if _, err := moveStoreCreditTx(ctx, tx, creditMove{
+		CustomerID: customerID, Kind: models.StoreCreditRedeem, Change: -e.Amount,
+		OrderID: e.OrderID, SaleID: e.SaleID, CreatedBy: e.CreatedBy,
+	}); err != nil {
+		return err
+	}
+	e.Method = models.PayMethodStoreCredit
+	e.Status = models.PaymentSucceeded
+	_, err := insertPaymentTx(ctx, tx, e)
+	return err
+}
+
+// restoreOrderCreditTx คืนเครดิตที่ออเดอร์ใช้ไป (หักส่วนที่คืนไปแล้ว) เข้าบัญชีลูกค้า พร้อมลงรายการคืนเงินในสมุดรับเงิน
+// ใช้ตอนยกเลิกออเดอร์; ต้องล็อกออเดอร์ก่อนเรียก
+func restoreOrderCreditTx(ctx context.Context, tx pgx.Tx, o lockedOrder, reason, by string) error {
+	return restoreCreditTx(ctx, tx, "order_id", o.ID, o.UserID, reason, "order cancelled", by)
+}
+
+// restoreCreditTx คืนเครดิตสุทธิที่ออเดอร์/การขาย (col = order_id | sale_id) ใช้ไปเข้าบัญชี customerID
+func restoreCreditTx(ctx context.Context, tx pgx.Tx, col string, id interface{}, customerID, reason, note, by string) error {
+	var net models.Money
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN kind = 'payment' THEN amount ELSE -amount END), 0)
+		FROM payments WHERE `+col+` = $1 AND method = $2 AND status = $3
+	`, id, models.PayMethodStoreCredit, models.PaymentSucceeded).Scan(&net); err != nil {
+		return err
+	}
+	if net <= 0 {
+		return nil
+	}
+	m := creditMove{CustomerID: customerID, Kind: models.StoreCreditRestore, Change: net, Reason: reason, CreatedBy: by}
+	e := paymentEntry{Kind: models.PaymentKindRefund, Method: models.PayMethodStoreCredit, Amount: net,
+		Status: models.PaymentSucceeded, Note: note, CreatedBy: by}
+	if col == "order_id" {
+		m.OrderID, e.OrderID = id.(int64), id.(int64)
+	} else {
+		m.SaleID, e.SaleID = id.(string), id.(string)
+	}
+	if _, err := moveStoreCreditTx(ctx, tx, m); err != nil {
+		return err
+	}
+	_, err := insertPaymentTx(ctx, tx, e)
+	return err
+}
+
+// paidOnlyWithCreditTx ออเดอร์ที่รับเงินแล้วเฉพาะจากเครดิตร้าน/บัตรของขวัญ (ยังไม่ได้รับเงินจริงเลย)
+func paidOnlyWithCreditTx(ctx context.Context, q querier, orderID int64) (bool, error) {
+	var other bool
+	err := q.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM payments WHERE order_id = $1 AND kind = 'payment' AND status = $2 AND method NOT IN ($3, $4))
+	`, orderID, models.PaymentSucceeded, models.PayMethodStoreCredit, models.PayMethodGiftCard).Scan(&other)
+	return !other, err
+}
+
+func storeCreditErrorStatus(err error) int {
+	switch err {
+	case errCreditInsufficient:
+		return fiber.StatusConflict
+	case errCreditAmount, errCreditNegative, errCreditReason, errCreditNoCustomer:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+func loadStoreCreditWallet(ctx context.Context, q querier, customerID string, limit int) (models.StoreCreditWallet, error) {
+	w := models.StoreCreditWallet{CustomerID: customerID, Transactions: []models.StoreCreditTransaction{}}
+	err := q.QueryRow(ctx, `SELECT balance FROM store_credit_accounts WHERE customer_id = $1`, customerID).Scan(&w.Balance)
+	if err == pgx.ErrNoRows {
+		return w, nil
+	}
+	if err != nil {
+		return w, err
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT id, customer_id, kind, amount, balance_after, order_id, sale_id, return_id, reason, created_by, created_at
+		FROM store_credit_transactions WHERE customer_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, customerID, limit)
+	if err != nil {
+		return w, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.StoreCreditTransaction
+		if err := rows.Scan(&t.ID, &t.CustomerID, &t.Kind, &t.Amount, &t.BalanceAfter, &t.OrderID, &t.SaleID, &t.ReturnID,
+			&t.Reason, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return w, err
+		}
+		w.Transactions = append(w.Transactions, t)
+	}
+	return w, rows.Err()
+}
+
+// ====================
+// ยอดเครดิตร้านและรายการล่าสุดของลูกค้า (ลูกค้าดูของตัวเอง, พนักงานดูได้ทุกคน)
+// GET /customers/:customer_id/store-credit?limit=50
+// ====================
+func GetStoreCredit(c *fiber.Ctx) error {
+	customerID := c.Params("customer_id")
+	if !canAccessCustomer(c, customerID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	w, err := loadStoreCreditWallet(context.Background(), conn, customerID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(w)
+}
+
+// ====================
+// พนักงานปรับเครดิตร้าน (+ เพิ่ม / - ลด) ต้องระบุเหตุผล
+// POST /admin/customers/:customer_id/store-credit   body: { "amount": -150, "reason": "ชดเชยค่าส่งล่าช้า" }
+// ====================
+func AdjustStoreCredit(c *fiber.Ctx) error {
+	staffID, _ := c.Locals("user_id").(string)
+	customerID := strings.TrimSpace(c.Params("customer_id"))
+
+	var req models.AdjustStoreCreditReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errCreditReason.Error()})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customer WHERE customer_id = $1)`, customerID).Scan(&exists); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "customer not found"})
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	balance, err := moveStoreCreditTx(ctx, tx, creditMove{
+		CustomerID: customerID, Kind: models.StoreCreditAdjust, Change: req.Amount, Reason: req.Reason, CreatedBy: staffID,
+	})
+	if err != nil {
+		return c.Status(storeCreditErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.JSON(fiber.Map{"customer_id": customerID, "balance": balance})
+}
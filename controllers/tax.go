@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"dog/pdfdoc"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var errTaxInvoiceUnavailable = errors.New("tax invoice is not available: no VAT was charged")
+
+// ค่าเริ่มต้นเมื่อยังไม่มีแถวใน tax_settings: จด VAT 7% ราคาขายรวม VAT แล้ว
+var defaultTaxSettings = models.TaxSettings{VATRegistered: true, VATRate: 7, PricesIncludeVAT: true}
+
+// เลขที่ใบกำกับภาษีเริ่มใหม่ทุกปีตามเวลาไทย
+var taxInvoiceZone = time.FixedZone("ICT", 7*60*60)
+
+func loadTaxSettings(ctx context.Context, q querier) (models.TaxSettings, error) {
+	var s models.TaxSettings
+	err := q.QueryRow(ctx, `
+		SELECT vat_registered, vat_rate, prices_include_vat, updated_by, updated_at FROM tax_settings WHERE id = 1
+	`).Scan(&s.VATRegistered, &s.VATRate, &s.PricesIncludeVAT, &s.UpdatedBy, &s.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return defaultTaxSettings, nil
+	}
+	return s, err
+}
+
+// normalizeTaxBuyer ตัดช่องว่าง ตรวจเลขประจำตัวผู้เสียภาษี 13 หลัก (check digit) และรหัสสาขา 5 หลัก
+// คืนข้อความ error ที่จะส่งให้ client (ว่าง = ผ่าน)
+func normalizeTaxBuyer(b *models.TaxBuyer) string {
+	b.Name = strings.TrimSpace(b.Name)
+	b.Address = strings.TrimSpace(b.Address)
+	b.TaxID = strings.NewReplacer("-", "", " ", "").Replace(b.TaxID)
+	b.Branch = strings.TrimSpace(b.Branch)
+	if b.Branch == "" {
+		b.Branch = pdfdoc.HeadOfficeBranch
+	}
+
+	switch {
+	case b.Name == "":
+		return "tax_invoice.name is required"
+	case b.Address == "":
+		return "tax_invoice.address is required"
+	case !validThaiTaxID(b.TaxID):
+		return "invalid tax_invoice.tax_id"
+	case len(b.Branch) != 5 || strings.Trim(b.Branch, "0123456789") != "":
+		return "tax_invoice.branch must be 5 digits"
+	}
+	return ""
+}
+
+// validThaiTaxID เลข 13 หลัก หลักสุดท้าย = (11 - Σ d[i]*(13-i) mod 11) mod 10
+func validThaiTaxID(id string) bool {
+	if len(id) != 13 || strings.Trim(id, "0123456789") != "" {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		sum += int(id[i]-'0') * (13 - i)
+	}
+	return (11-sum%11)%10 == int(id[12]-'0')
+}
+
+// nextTaxInvoiceNoTx จองเลขถัดไปของปีนี้ เช่น INV2025-000042
+// upsert ล็อกแถวของปีไว้จน tx จบ: คนอื่นต้องรอ และถ้า tx rollback เลขก็ไม่ถูกใช้ จึงไม่มีเลขขาดหาย
+func nextTaxInvoiceNoTx(ctx context.Context, tx pgx.Tx, at time.Time) (string, error) {
+	year := at.In(taxInvoiceZone).Year()
+	var n int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO tax_invoice_sequences (year, last_no) VALUES ($1, 1)
+		ON CONFLICT (year) DO UPDATE SET last_no = tax_invoice_sequences.last_no + 1
+		RETURNING last_no
+	`, year).Scan(&n)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("INV%d-%06d", year, n), nil
+}
+
+// issueTaxInvoice ออกเลขที่ใบกำกับภาษีให้ออเดอร์ (table = "orders", key = id) หรือการขาย (table = "sales", key = sale_id)
+// ถ้าเคยออกแล้วคืนเลขเดิม; ล็อกแถวก่อน ขอพร้อมกันจึงได้เลขเดียว
+func issueTaxInvoice(ctx context.Context, conn *pgx.Conn, table, keyColumn string, key interface{}) (string, time.Time, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		no      *string
+		at      *time.Time
+		vatRate int
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT tax_invoice_no, tax_invoice_at, vat_rate FROM `+table+` WHERE `+keyColumn+` = $1 FOR UPDATE
+	`, key).Scan(&no, &at, &vatRate)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if no != nil && at != nil {
+		return *no, *at, nil
+	}
+	if vatRate == 0 {
+		return "", time.Time{}, errTaxInvoiceUnavailable
+	}
+
+	now := time.Now()
+	newNo, err := nextTaxInvoiceNoTx(ctx, tx, now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE `+table+` SET tax_invoice_no = $1, tax_invoice_at = $2 WHERE `+keyColumn+` = $3
+	`, newNo, now, key); err != nil {
+		return "", time.Time{}, err
+	}
+	return newNo, now, tx.Commit(ctx)
+}
+
+// ====================
+// ตั้งค่า VAT (หลังบ้าน)
+// GET /admin/tax-settings
+// PUT /admin/tax-settings   body: { "vat_registered": true, "vat_rate": 7, "prices_include_vat": true } (ส่งเฉพาะที่จะแก้)
+// มีผลกับออเดอร์/การขายใหม่เท่านั้น; ของเดิมเก็บ VAT ณ ตอนขายไว้แล้ว
+// ====================
+func GetTaxSettings(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	s, err := loadTaxSettings(context.Background(), conn)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(s)
+}
+
+func UpdateTaxSettings(c *fiber.Ctx) error {
+	var req models.UpdateTaxSettingsReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	if req.VATRate != nil && (*req.VATRate < 0 || *req.VATRate > 100) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "vat_rate must be between 0 and 100"})
+	}
+	userID, _ := c.Locals("user_id").(string)
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	s, err := loadTaxSettings(ctx, conn)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.VATRegistered != nil {
+		s.VATRegistered = *req.VATRegistered
+	}
+	if req.VATRate != nil {
+		s.VATRate = *req.VATRate
+	}
+	if req.PricesIncludeVAT != nil {
+		s.PricesIncludeVAT = *req.PricesIncludeVAT
+	}
+
+	err = conn.QueryRow(ctx, `
+		INSERT INTO tax_settings (id, vat_registered, vat_rate, prices_include_vat, updated_by, updated_at)
+		VALUES (1, $1, $2, $3, NULLIF($4,''), NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			vat_registered = EXCLUDED.vat_registered, vat_rate = EXCLUDED.vat_rate,
+			prices_include_vat = EXCLUDED.prices_include_vat, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING updated_by, updated_at
+	`, s.VATRegistered, s.VATRate, s.PricesIncludeVAT, userID).Scan(&s.UpdatedBy, &s.UpdatedAt)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(s)
+}
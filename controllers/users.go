@@ -252,7 +252,7 @@ func Login(c *fiber.Ctx) error {
 	// 	})
 	// }
 
-	token, err := utils.GenerateJWTToken(emp.EmployeeID)
+	token, err := utils.GenerateJWTToken(emp.EmployeeID, models.EmployeeRole(emp.Position))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Token generation failed",
@@ -266,6 +266,7 @@ func Login(c *fiber.Ctx) error {
 		"employee": fiber.Map{
 			"employee_id": emp.EmployeeID,
 			"name":        emp.Name,
+			"role":        models.EmployeeRole(emp.Position),
 		},
 		"token": token,
 	})
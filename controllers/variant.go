@@ -0,0 +1,471 @@
+package controllers
+
+import (
+	"context"
+	"dog/condb"
+	"dog/models"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errVariantNotFound = errors.New("variant not found")
+	errVariantRequired = errors.New("variant is required for this product")
+)
+
+// querier ใช้ได้ทั้ง *pgx.Conn และ pgx.Tx
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+const variantColumns = `id, product_id, sku, options, quantity, price_override, barcode, active, created_at, updated_at`
+
+func scanVariant(row pgx.Row, v *models.ProductVariant) error {
+	return row.Scan(&v.ID, &v.ProductID, &v.SKU, &v.Options, &v.Quantity, &v.PriceOverride, &v.Barcode,
+		&v.Active, &v.CreatedAt, &v.UpdatedAt)
+}
+
+// variantLabel ป้ายชื่อ variant สำหรับเก็บในรายการสั่งซื้อ เช่น "42 / white"
+// size, color ขึ้นก่อน ที่เหลือเรียงตามชื่อ option
+func variantLabel(options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		if k != "size" && k != "color" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keys = append([]string{"size", "color"}, keys...)
+
+	parts := []string{}
+	for _, k := range keys {
+		if v := strings.TrimSpace(options[k]); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+// orderLine รายการสินค้าที่ตรวจราคา/สต็อกกับฐานข้อมูลแล้ว
+type orderLine struct {
+	ProductID   string
+	Name        string
+	VariantID   *int64
+	SKU         *string
+	Variant     string // ป้าย variant (หรือข้อความที่ลูกค้าส่งมา กรณีสินค้าไม่มี variant)
+	Price       models.Money
+	Qty         int
+	WeightGrams int
+	Available   int // สต็อกคงเหลือของ variant (หรือของสินค้าถ้าไม่มี variant)
+}
+
+// resolveOrderLine หา product/variant ของรายการหนึ่งแล้วคิดราคา
+// ลำดับการหา variant: variant_id → sku → ข้อความ variant เทียบ options.size
+// lock = true ใช้ใน transaction ตอนตัดสต็อก (ล็อก variant ก่อน product ให้ลำดับตรงกับ moveStockTx)
+func resolveOrderLine(ctx context.Context, q querier, it models.CreateOrderItemReq, lock bool) (orderLine, error) {
+	forUpdate := ""
+	if lock {
+		forUpdate = " FOR UPDATE"
+	}
+	l := orderLine{ProductID: it.ProductID, Qty: it.Quantity}
+	if it.Variant != nil {
+		l.Variant = strings.TrimSpace(*it.Variant)
+	}
+
+	// variant ที่ระบุด้วยข้อความ (แบบเก่า) → หา id ก่อน แล้วค่อยล็อกด้านล่าง
+	variantID := it.VariantID
+	if variantID == nil && it.SKU == "" && l.Variant != "" && it.ProductID != "" {
+		var ids []int64
+		rows, err := q.Query(ctx, `
+			SELECT id FROM product_variants
+			WHERE product_id = $1 AND active AND options->>'size' = $2
+			LIMIT 2
+		`, it.ProductID, l.Variant)
+		if err != nil {
+			return l, err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return l, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return l, err
+		}
+		if len(ids) == 1 {
+			variantID = &ids[0]
+		}
+	}
+
+	var v *models.ProductVariant
+	if variantID != nil || it.SKU != "" {
+		var pv models.ProductVariant
+		var err error
+		if variantID != nil {
+			err = scanVariant(q.QueryRow(ctx, `SELECT `+variantColumns+` FROM product_variants WHERE id = $1`+forUpdate, *variantID), &pv)
+		} else {
+			err = scanVariant(q.QueryRow(ctx, `SELECT `+variantColumns+` FROM product_variants WHERE sku = $1`+forUpdate, it.SKU), &pv)
+		}
+		if err == pgx.ErrNoRows {
+			return l, errVariantNotFound
+		}
+		if err != nil {
+			return l, err
+		}
+		if !pv.Active || (it.ProductID != "" && pv.ProductID != it.ProductID) {
+			return l, errVariantNotFound
+		}
+		v = &pv
+		l.ProductID = pv.ProductID
+	}
+
+	var (
+		stockQty    int
+		sellPrice   models.Money
+		hasVariants bool
+	)
+	err := q.QueryRow(ctx, `
+		SELECT name, quantity, sell_price, weight_grams,
+		       EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.product_id AND v.active)
+		FROM products p
+		WHERE product_id = $1`+forUpdate, l.ProductID).Scan(&l.Name, &stockQty, &sellPrice, &l.WeightGrams, &hasVariants)
+	if err == pgx.ErrNoRows {
+		return l, errProductNotFound
+	}
+	if err != nil {
+		return l, err
+	}
+
+	if v == nil {
+		if hasVariants {
+			return l, errVariantRequired
+		}
+		l.Price = sellPrice
+		l.Available = stockQty
+		return l, nil
+	}
+
+	l.VariantID = &v.ID
+	l.SKU = &v.SKU
+	l.Variant = variantLabel(v.Options)
+	l.Price = sellPrice
+	if v.PriceOverride != nil {
+		l.Price = *v.PriceOverride
+	}
+	l.Available = v.Quantity
+	return l, nil
+}
+
+// getVariantSizes ไซซ์ของสินค้าที่เปิดขาย (รวมไซซ์ที่หมดด้วย ให้หน้าร้านแสดงเป็นปุ่มกดไม่ได้)
+func getVariantSizes(ctx context.Context, conn *pgx.Conn, productID string) ([]models.VariantSize, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT v.id, v.sku, v.options->>'size', COALESCE(v.price_override, p.sell_price), v.quantity
+		FROM product_variants v
+		JOIN products p ON p.product_id = v.product_id
+		WHERE v.product_id = $1 AND v.active AND v.options ? 'size'
+		ORDER BY v.id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := []models.VariantSize{}
+	for rows.Next() {
+		var s models.VariantSize
+		if err := rows.Scan(&s.VariantID, &s.SKU, &s.Size, &s.Price, &s.Stock); err != nil {
+			return nil, err
+		}
+		s.InStock = s.Stock > 0
+		sizes = append(sizes, s)
+	}
+	return sizes, rows.Err()
+}
+
+func validateVariantReq(req *models.ProductVariantReq) string {
+	req.SKU = strings.TrimSpace(req.SKU)
+	if req.SKU == "" {
+		return "sku is required"
+	}
+	if len(req.Options) == 0 {
+		return "options is required"
+	}
+	for k, v := range req.Options {
+		if strings.TrimSpace(k) == "" || strings.TrimSpace(v) == "" {
+			return "option names and values must not be empty"
+		}
+	}
+	if req.PriceOverride != nil && *req.PriceOverride < 0 {
+		return "price_override must not be negative"
+	}
+	if req.Barcode != nil {
+		b := strings.TrimSpace(*req.Barcode)
+		if b == "" {
+			req.Barcode = nil
+		} else {
+			req.Barcode = &b
+		}
+	}
+	return ""
+}
+
+// ====================
+// รายการ variant ของสินค้า (หลังบ้าน รวมที่ปิดขาย)
+// GET /admin/products/:product_id/variants
+// ====================
+func GetProductVariants(c *fiber.Ctx) error {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(context.Background(),
+		`SELECT `+variantColumns+` FROM product_variants WHERE product_id = $1 ORDER BY id`, c.Params("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	items := []models.ProductVariant{}
+	for rows.Next() {
+		var v models.ProductVariant
+		if err := scanVariant(rows, &v); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		items = append(items, v)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// ====================
+// เพิ่ม variant ให้สินค้า (หลังบ้าน); quantity เริ่มต้นบันทึกเป็นรับของเข้า
+// POST /admin/products/:product_id/variants
+// ====================
+func CreateProductVariant(c *fiber.Ctx) error {
+	var req models.ProductVariantReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := validateVariantReq(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if req.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must not be negative"})
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	productID := c.Params("product_id")
+	var onHand int
+	var hasVariants bool
+	err = tx.QueryRow(ctx, `
+		SELECT quantity, EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.product_id)
+		FROM products p WHERE product_id = $1
+		FOR UPDATE
+	`, productID).Scan(&onHand, &hasVariants)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	// สต็อกเดิมระดับสินค้าไม่รู้ว่าเป็นไซซ์ไหน ต้องปรับเป็น 0 ก่อนแล้วรับเข้าราย variant
+	if !hasVariants && onHand != 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "product has stock not assigned to any variant; set its quantity to 0 first",
+			"on_hand": onHand,
+		})
+	}
+
+	var v models.ProductVariant
+	err = scanVariant(tx.QueryRow(ctx, `
+		INSERT INTO product_variants (product_id, sku, options, price_override, barcode, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+variantColumns,
+		productID, req.SKU, req.Options, req.PriceOverride, req.Barcode, active,
+	), &v)
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "sku, barcode or options already exist"})
+	}
+
+	if req.Quantity > 0 {
+		staffID, _ := c.Locals("user_id").(string)
+		if v.Quantity, err = setVariantStockTx(ctx, tx, v.ID, req.Quantity, models.StockMoveReceive, "new variant", staffID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Stock update failed"})
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "commit failed"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(v)
+}
+
+// ====================
+// แก้ไข variant (หลังบ้าน) — ไม่แก้สต็อก ใช้ /quantity แทน
+// PUT /admin/variants/:variant_id
+// ====================
+func UpdateProductVariant(c *fiber.Ctx) error {
+	var req models.ProductVariantReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := validateVariantReq(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	var v models.ProductVariant
+	err = scanVariant(conn.QueryRow(context.Background(), `
+		UPDATE product_variants
+		SET sku=$1, options=$2, price_override=$3, barcode=$4, active=COALESCE($5, active), updated_at=NOW()
+		WHERE id=$6
+		RETURNING `+variantColumns,
+		req.SKU, req.Options, req.PriceOverride, req.Barcode, req.Active, c.Params("variant_id"),
+	), &v)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Variant not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "sku, barcode or options already exist"})
+	}
+	return c.JSON(v)
+}
+
+// ====================
+// ปรับยอดคงเหลือของ variant (หลังบ้าน)
+// PATCH /admin/variants/:variant_id/quantity   body: { "quantity": 10, "note": "..." }
+// ====================
+func UpdateVariantQuantity(c *fiber.Ctx) error {
+	variantID, err := strconv.ParseInt(c.Params("variant_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid variant_id"})
+	}
+	var input struct {
+		Quantity int    `json:"quantity"`
+		Note     string `json:"note"`
+		Receive  bool   `json:"receive"` // true = รับของเข้า, false = ปรับยอด/นับสต็อก
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if input.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must not be negative"})
+	}
+	kind := models.StockMoveAdjustment
+	if input.Receive {
+		kind = models.StockMoveReceive
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "begin tx failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	staffID, _ := c.Locals("user_id").(string)
+	_, err = setVariantStockTx(ctx, tx, variantID, input.Quantity, kind, input.Note, staffID)
+	if err == errVariantNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Variant not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Update failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Update failed"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Variant quantity updated",
+		"variant_id": variantID,
+		"quantity":   input.Quantity,
+	})
+}
+
+// ====================
+// หา variant จาก SKU หรือบาร์โค้ด (POS สแกนสินค้า)
+// GET /admin/variants/lookup?code=...
+// ====================
+func LookupVariant(c *fiber.Ctx) error {
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "code is required"})
+	}
+
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB connection failed"})
+	}
+	defer conn.Close(context.Background())
+
+	var v models.ProductVariant
+	var name string
+	var sellPrice models.Money
+	err = conn.QueryRow(context.Background(), `
+		SELECT v.id, v.product_id, v.sku, v.options, v.quantity, v.price_override, v.barcode, v.active,
+		       v.created_at, v.updated_at, p.name, p.sell_price
+		FROM product_variants v
+		JOIN products p ON p.product_id = v.product_id
+		WHERE v.sku = $1 OR v.barcode = $1
+		LIMIT 1
+	`, code).Scan(&v.ID, &v.ProductID, &v.SKU, &v.Options, &v.Quantity, &v.PriceOverride, &v.Barcode, &v.Active,
+		&v.CreatedAt, &v.UpdatedAt, &name, &sellPrice)
+	if err == pgx.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Variant not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	price := sellPrice
+	if v.PriceOverride != nil {
+		price = *v.PriceOverride
+	}
+	return c.JSON(fiber.Map{
+		"variant": v,
+		"name":    name,
+		"label":   variantLabel(v.Options),
+		"price":   price,
+	})
+}
@@ -1,17 +1,34 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"strings"
 
+	"dog/controllers"
+	"dog/pdfdoc"
 	"dog/routes"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/joho/godotenv"
 )
 
 func main() {
+	// โหลด .env ก่อน เพื่อให้ค่าตั้งของงานเบื้องหลังอ่านได้ตั้งแต่เริ่ม (condb โหลดซ้ำเองทุกครั้งที่ต่อ DB)
+	godotenv.Load()
+
+	// ใบเสร็จ/ใบกำกับภาษีต้องมีฟอนต์ภาษาไทย (pdfdoc/fonts หรือ PDF_FONT_DIR); ไม่มีแค่เตือน endpoint PDF จะตอบ 503
+	if err := pdfdoc.CheckFonts(); err != nil {
+		log.Printf("warning: PDF documents disabled: %v", err)
+	}
+
+	// payment gateway ต้องพร้อมก่อนลงทะเบียน route (fake checkout มีเฉพาะตอนเปิด provider จำลอง)
+	if err := controllers.InitPaymentProviders(); err != nil {
+		log.Fatal(err)
+	}
+
 	app := fiber.New()
 
 	origins := os.Getenv("ALLOW_ORIGINS")
@@ -34,8 +51,8 @@ func main() {
 			return false
 		},
 		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		ExposeHeaders:    "Set-Cookie",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
+		ExposeHeaders:    "Set-Cookie, Idempotent-Replayed, Content-Disposition, Location, X-Total-Count, X-PromptPay-Payload",
 		AllowCredentials: true,
 	}))
 
@@ -43,5 +60,15 @@ func main() {
 
 	routes.RegisterRoutes(app)
 
+	// ยกเลิกออเดอร์ที่ไม่ชำระเงินภายในเวลา (ORDER_EXPIRY_SCHEDULER=off เพื่อปิด)
+	if !strings.EqualFold(os.Getenv("ORDER_EXPIRY_SCHEDULER"), "off") {
+		go controllers.StartOrderExpiryScheduler(context.Background())
+	}
+
+	// ทำงาน export ที่รอคิว (EXPORT_WORKER=off เพื่อปิดบน instance นี้)
+	if !strings.EqualFold(os.Getenv("EXPORT_WORKER"), "off") {
+		go controllers.StartExportWorker(context.Background())
+	}
+
 	log.Fatal(app.Listen(":8080"))
 }
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"dog/condb"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const maxIdempotencyKeyLen = 255
+
+// Idempotency กัน POST ซ้ำจากการ retry: เก็บ response แรกของแต่ละ Idempotency-Key
+// แล้วตอบซ้ำให้ request ที่เหมือนเดิมภายใน ttl; key เดิมแต่ body ต่าง → 409
+// ถ้าไม่ส่ง header มาจะทำงานตามปกติ (ไม่บังคับ)
+// ถ้าใช้คู่กับ JWTMiddleware ให้วางต่อจาก JWTMiddleware เพื่อแยก key ตาม user
+func Idempotency(ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Idempotency-Key too long"})
+		}
+
+		userID, _ := c.Locals("user_id").(string)
+		scope := c.Method() + " " + c.Path() + " " + userID
+
+		sum := sha256.Sum256(append([]byte(c.Method()+"\n"+c.Path()+"\n"), c.Body()...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		stored, err := reserveIdempotencyKey(scope, key, fingerprint, ttl)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "idempotency check failed"})
+		}
+		if stored != nil {
+			if stored.Fingerprint != fingerprint {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Idempotency-Key was used with a different request"})
+			}
+			if stored.StatusCode == nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "request with this Idempotency-Key is still in progress"})
+			}
+			c.Set("Idempotent-Replayed", "true")
+			if stored.ContentType != nil {
+				c.Set(fiber.HeaderContentType, *stored.ContentType)
+			}
+			return c.Status(*stored.StatusCode).Send(stored.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			releaseIdempotencyKey(scope, key)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			// server error: ปล่อย key ให้ client retry ได้
+			releaseIdempotencyKey(scope, key)
+			return nil
+		}
+		body := append([]byte(nil), c.Response().Body()...)
+		completeIdempotencyKey(scope, key, status, string(c.Response().Header.ContentType()), body)
+		return nil
+	}
+}
+
+type storedIdempotency struct {
+	Fingerprint string
+	StatusCode  *int
+	ContentType *string
+	Body        []byte
+}
+
+// reserveIdempotencyKey จอง key; คืน nil ถ้าจองได้ (request แรก) หรือข้อมูลที่เก็บไว้ถ้ามีอยู่แล้ว
+func reserveIdempotencyKey(scope, key, fingerprint string, ttl time.Duration) (*storedIdempotency, error) {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+
+	ctx := context.Background()
+
+	// key ที่หมดอายุแล้วถือว่าใช้ใหม่ได้
+	if _, err := conn.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND created_at < NOW() - make_interval(secs => $3)
+	`, scope, key, ttl.Seconds()); err != nil {
+		return nil, err
+	}
+
+	res, err := conn.Exec(ctx, `
+		INSERT INTO idempotency_keys (scope, key, fingerprint)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (scope, key) DO NOTHING
+	`, scope, key, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	var s storedIdempotency
+	err = conn.QueryRow(ctx, `
+		SELECT fingerprint, status_code, content_type, response_body
+		FROM idempotency_keys WHERE scope = $1 AND key = $2
+	`, scope, key).Scan(&s.Fingerprint, &s.StatusCode, &s.ContentType, &s.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func completeIdempotencyKey(scope, key string, status int, contentType string, body []byte) {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		log.Println("idempotency: store response failed:", err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(context.Background(), `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5, completed_at = NOW()
+		WHERE scope = $1 AND key = $2
+	`, scope, key, status, contentType, body); err != nil {
+		log.Println("idempotency: store response failed:", err)
+	}
+}
+
+func releaseIdempotencyKey(scope, key string) {
+	conn, err := condb.DB_Lek()
+	if err != nil {
+		return
+	}
+	defer conn.Close(context.Background())
+
+	conn.Exec(context.Background(), `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`, scope, key)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"dog/models"
 	"dog/utils"
 	"strings"
 
@@ -14,12 +15,44 @@ func JWTMiddleware(c *fiber.Ctx) error {
 	}
 
 	token := strings.TrimPrefix(auth, "Bearer ")
-	userID, err := utils.ParseJWTToken(token)
+	claims, err := utils.ParseJWTClaims(token)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
 	}
 
-	// เก็บ user_id เอาไว้ใช้ใน controller
-	c.Locals("user_id", userID)
+	// เก็บ user_id และ role เอาไว้ใช้ใน controller
+	c.Locals("user_id", claims.Issuer)
+	c.Locals("role", claims.Role)
+	return c.Next()
+}
+
+// OptionalJWT เหมือน JWTMiddleware แต่ไม่บังคับ: ไม่มี token หรือ token ไม่ถูกต้องก็ผ่านไปแบบ anonymous
+// (ใช้กับ endpoint ที่ลูกค้า login หรือไม่ก็ได้ เช่น ตะกร้าสินค้า)
+func OptionalJWT(c *fiber.Ctx) error {
+	auth := c.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		if claims, err := utils.ParseJWTClaims(strings.TrimPrefix(auth, "Bearer ")); err == nil {
+			c.Locals("user_id", claims.Issuer)
+			c.Locals("role", claims.Role)
+		}
+	}
+	return c.Next()
+}
+
+// RequireStaff ต้องวางต่อจาก JWTMiddleware; อนุญาตเฉพาะพนักงานและเจ้าของร้าน
+func RequireStaff(c *fiber.Ctx) error {
+	role, _ := c.Locals("role").(string)
+	if role != models.RoleStaff && role != models.RoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "staff only"})
+	}
+	return c.Next()
+}
+
+// RequireOwner ต้องวางต่อจาก JWTMiddleware; อนุญาตเฉพาะเจ้าของร้าน
+func RequireOwner(c *fiber.Ctx) error {
+	role, _ := c.Locals("role").(string)
+	if role != models.RoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "owner only"})
+	}
 	return c.Next()
 }
@@ -0,0 +1,6 @@
+-- ยกเลิกออเดอร์: เก็บเหตุผล เวลา และผู้ยกเลิก
+-- payment_status เพิ่มค่า 'voided' (ยังไม่ได้รับเงิน) และ 'refund_pending' (ได้รับเงินแล้ว รอคืน)
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS cancel_reason TEXT,
+    ADD COLUMN IF NOT EXISTS cancelled_at  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS cancelled_by  TEXT;
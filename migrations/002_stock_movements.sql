@@ -0,0 +1,35 @@
+-- Ledger การเคลื่อนไหวสต็อก (append-only)
+-- products.quantity คือยอดคงเหลือที่อัปเดตใน transaction เดียวกับการเขียน ledger
+-- ตาราง stock เดิมเลิกใช้แล้ว
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id              BIGSERIAL PRIMARY KEY,
+    product_id      TEXT        NOT NULL,
+    kind            TEXT        NOT NULL CHECK (kind IN ('sale', 'order', 'cancel', 'adjustment', 'receive', 'return')),
+    quantity_change INT         NOT NULL,
+    balance_after   INT         NOT NULL,
+    ref_type        TEXT,
+    ref_id          TEXT,
+    note            TEXT,
+    created_by      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, id);
+
+CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_movements_no_change ON stock_movements;
+CREATE TRIGGER stock_movements_no_change
+    BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
+
+-- ยอดยกมา: ให้ผลรวม ledger เท่ากับ products.quantity ปัจจุบัน
+INSERT INTO stock_movements (product_id, kind, quantity_change, balance_after, note)
+SELECT p.product_id, 'adjustment', p.quantity, p.quantity, 'opening balance'
+FROM products p
+WHERE p.quantity <> 0
+  AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.product_id);
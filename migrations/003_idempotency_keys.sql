@@ -0,0 +1,15 @@
+-- Idempotency-Key สำหรับ POST /orders และ POST /sales
+-- status_code เป็น NULL ระหว่างที่ request แรกยังทำงานอยู่
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope         TEXT        NOT NULL, -- method + path + user_id
+    key           TEXT        NOT NULL,
+    fingerprint   TEXT        NOT NULL, -- sha256 ของ method/path/body
+    status_code   INT,
+    content_type  TEXT,
+    response_body BYTEA,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at  TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_idx ON idempotency_keys (created_at);
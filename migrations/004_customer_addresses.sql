@@ -0,0 +1,22 @@
+-- สมุดที่อยู่ลูกค้า (ที่อยู่แบบไทย) + snapshot ที่อยู่จัดส่งบนออเดอร์
+CREATE TABLE IF NOT EXISTS customer_addresses (
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id TEXT        NOT NULL,
+    label       TEXT,
+    recipient   TEXT        NOT NULL,
+    phone       TEXT        NOT NULL,
+    house_no    TEXT        NOT NULL,
+    subdistrict TEXT        NOT NULL,
+    district    TEXT        NOT NULL,
+    province    TEXT        NOT NULL,
+    postcode    CHAR(5)     NOT NULL,
+    is_default  BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS customer_addresses_customer_idx ON customer_addresses (customer_id);
+-- default ได้แค่ที่อยู่เดียวต่อลูกค้า
+CREATE UNIQUE INDEX IF NOT EXISTS customer_addresses_one_default ON customer_addresses (customer_id) WHERE is_default;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address JSONB;
@@ -0,0 +1,37 @@
+-- วิธีจัดส่ง + ค่าส่งบนออเดอร์ + เลขพัสดุ
+CREATE TABLE IF NOT EXISTS shipping_methods (
+    id         BIGSERIAL PRIMARY KEY,
+    code       TEXT          NOT NULL UNIQUE,
+    name       TEXT          NOT NULL,
+    carrier    TEXT          NOT NULL DEFAULT '',
+    rate_type  TEXT          NOT NULL CHECK (rate_type IN ('flat', 'weight', 'quantity')),
+    base_fee   NUMERIC(12,2) NOT NULL DEFAULT 0,
+    tiers      JSONB         NOT NULL DEFAULT '[]', -- [{ "up_to": 1000, "fee": 40 }, { "up_to": 0, "fee": 80 }]
+    free_over  NUMERIC(12,2),
+    active     BOOLEAN       NOT NULL DEFAULT TRUE,
+    sort_order INT           NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS weight_grams INT NOT NULL DEFAULT 0;
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS subtotal        NUMERIC(12,2),
+    ADD COLUMN IF NOT EXISTS shipping_method TEXT,
+    ADD COLUMN IF NOT EXISTS shipping_fee    NUMERIC(12,2) NOT NULL DEFAULT 0;
+UPDATE orders SET subtotal = total WHERE subtotal IS NULL;
+ALTER TABLE orders ALTER COLUMN subtotal SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS shipments (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    BIGINT      NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    carrier     TEXT        NOT NULL,
+    tracking_no TEXT        NOT NULL,
+    note        TEXT,
+    shipped_by  TEXT,
+    shipped_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS shipments_order_idx ON shipments (order_id);
+CREATE INDEX IF NOT EXISTS shipments_tracking_idx ON shipments (tracking_no);
@@ -0,0 +1,15 @@
+-- เงินทุกคอลัมน์เป็น NUMERIC(12,2) (models.Money อ่าน/เขียนเป็นสตางค์แบบ exact)
+-- ข้อมูลเดิมที่เป็น float ปัดที่สตางค์ด้วย ROUND (half away from zero) ตรงกับกติกาใน models.Money
+ALTER TABLE products
+    ALTER COLUMN sell_price     TYPE NUMERIC(12,2) USING ROUND(sell_price::numeric, 2),
+    ALTER COLUMN cost_price     TYPE NUMERIC(12,2) USING ROUND(cost_price::numeric, 2),
+    ALTER COLUMN original_price TYPE NUMERIC(12,2) USING ROUND(original_price::numeric, 2);
+
+ALTER TABLE orders
+    ALTER COLUMN total TYPE NUMERIC(12,2) USING ROUND(total::numeric, 2);
+
+ALTER TABLE order_items
+    ALTER COLUMN price TYPE NUMERIC(12,2) USING ROUND(price::numeric, 2);
+
+ALTER TABLE sales
+    ALTER COLUMN total_price TYPE NUMERIC(12,2) USING ROUND(total_price::numeric, 2);
@@ -0,0 +1,28 @@
+-- ตัวเลือกสินค้า (ไซซ์/สี) สต็อกและราคาแยกราย variant
+-- สินค้าที่มี variant: products.quantity = ผลรวม product_variants.quantity (อัปเดตผ่าน ledger เท่านั้น)
+CREATE TABLE IF NOT EXISTS product_variants (
+    id             BIGSERIAL PRIMARY KEY,
+    product_id     TEXT          NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    sku            TEXT          NOT NULL UNIQUE,
+    options        JSONB         NOT NULL DEFAULT '{}', -- { "size": "42", "color": "white" }
+    quantity       INT           NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    price_override NUMERIC(12,2),
+    barcode        TEXT UNIQUE,
+    active         BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_variants_product_idx ON product_variants (product_id);
+-- ห้ามมี variant ที่ options ซ้ำกันในสินค้าเดียวกัน
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_options_uniq ON product_variants (product_id, options);
+
+-- ไม่ผูก FK กับ product_variants เพื่อให้ลบ variant/สินค้าได้โดยประวัติยังอยู่ (เหมือน product_id)
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS variant_id BIGINT;
+CREATE INDEX IF NOT EXISTS stock_movements_variant_idx ON stock_movements (variant_id, id) WHERE variant_id IS NOT NULL;
+
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS variant_id BIGINT,
+    ADD COLUMN IF NOT EXISTS sku        TEXT;
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS variant_id BIGINT;
@@ -0,0 +1,27 @@
+-- ตะกร้าสินค้าฝั่ง server: ลูกค้าที่ยังไม่ login ใช้ cookie cart_id, ลูกค้าที่ login ผูกกับ customer_id
+CREATE TABLE IF NOT EXISTS carts (
+    id          TEXT PRIMARY KEY, -- random token (เป็นความลับ ใช้แทนการ login สำหรับตะกร้า anonymous)
+    customer_id TEXT,
+    status      TEXT        NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'merged', 'checked_out')),
+    merged_into TEXT,
+    order_id    BIGINT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- ลูกค้าหนึ่งคนมีตะกร้าที่เปิดอยู่ได้ใบเดียว
+CREATE UNIQUE INDEX IF NOT EXISTS carts_customer_open_uniq ON carts (customer_id) WHERE status = 'open' AND customer_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id           BIGSERIAL PRIMARY KEY,
+    cart_id      TEXT          NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    product_id   TEXT          NOT NULL,
+    variant_id   BIGINT,
+    quantity     INT           NOT NULL CHECK (quantity > 0),
+    price_at_add NUMERIC(12,2) NOT NULL, -- ราคาตอนหยิบใส่ตะกร้า ใช้แจ้งลูกค้าเมื่อราคาเปลี่ยน
+    created_at   TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+-- สินค้า/variant เดียวกันรวมเป็นบรรทัดเดียว
+CREATE UNIQUE INDEX IF NOT EXISTS cart_items_line_uniq ON cart_items (cart_id, product_id, COALESCE(variant_id, 0));
@@ -0,0 +1,5 @@
+-- ออเดอร์ที่ยังไม่จ่ายจะหมดอายุและถูกยกเลิกอัตโนมัติ (คืนสต็อก) เมื่อถึง expires_at
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS orders_pending_expiry_idx ON orders (expires_at)
+    WHERE status = 'pending' AND expires_at IS NOT NULL;
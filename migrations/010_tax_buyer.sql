@@ -0,0 +1,3 @@
+-- ข้อมูลผู้ซื้อสำหรับออกใบกำกับภาษีเต็มรูป (ชื่อ, ที่อยู่, เลขประจำตัวผู้เสียภาษี, สาขา)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_buyer JSONB;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS tax_buyer JSONB;
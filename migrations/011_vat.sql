@@ -0,0 +1,43 @@
+-- ตั้งค่าภาษีมูลค่าเพิ่ม (แถวเดียว id = 1)
+CREATE TABLE IF NOT EXISTS tax_settings (
+    id                 SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    vat_registered     BOOLEAN NOT NULL DEFAULT TRUE,
+    vat_rate           INTEGER NOT NULL DEFAULT 7 CHECK (vat_rate BETWEEN 0 AND 100), -- เปอร์เซ็นต์
+    prices_include_vat BOOLEAN NOT NULL DEFAULT TRUE, -- products.sell_price รวม VAT แล้ว
+    updated_by         TEXT,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO tax_settings (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
+-- VAT ที่คิด ณ ตอนขาย; vat_rate = 0 คือไม่ได้คิด VAT (ร้านไม่ได้จด VAT)
+-- ข้อมูลเดิมถือว่าราคารวม VAT 7% อยู่แล้ว (เติมเฉพาะแถวที่ยังเป็น NULL จึงรันซ้ำได้)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS vat_rate INTEGER;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS vat_amount NUMERIC(12,2);
+UPDATE orders SET vat_rate = 7, vat_amount = ROUND(total * 7 / 107, 2) WHERE vat_rate IS NULL;
+ALTER TABLE orders
+    ALTER COLUMN vat_rate SET DEFAULT 0, ALTER COLUMN vat_rate SET NOT NULL,
+    ALTER COLUMN vat_amount SET DEFAULT 0, ALTER COLUMN vat_amount SET NOT NULL;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS vat_amount NUMERIC(12,2);
+UPDATE order_items SET vat_amount = ROUND(price * quantity * 7 / 107, 2) WHERE vat_amount IS NULL;
+ALTER TABLE order_items ALTER COLUMN vat_amount SET DEFAULT 0, ALTER COLUMN vat_amount SET NOT NULL;
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS vat_rate INTEGER;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS vat_amount NUMERIC(12,2);
+UPDATE sales SET vat_rate = 7, vat_amount = ROUND(total_price * 7 / 107, 2) WHERE vat_rate IS NULL;
+ALTER TABLE sales
+    ALTER COLUMN vat_rate SET DEFAULT 0, ALTER COLUMN vat_rate SET NOT NULL,
+    ALTER COLUMN vat_amount SET DEFAULT 0, ALTER COLUMN vat_amount SET NOT NULL;
+-- false = total_price ที่พนักงานกรอกยังไม่รวม VAT และระบบบวก vat_amount เข้าไปแล้ว
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS vat_inclusive BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- เลขที่ใบกำกับภาษีเต็มรูป: ออกครั้งเดียวต่อออเดอร์/การขาย, เรียงต่อเนื่องไม่ขาด เริ่มใหม่ทุกปี
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_invoice_no TEXT UNIQUE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_invoice_at TIMESTAMPTZ;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS tax_invoice_no TEXT UNIQUE;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS tax_invoice_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS tax_invoice_sequences (
+    year    INTEGER PRIMARY KEY,
+    last_no INTEGER NOT NULL
+);
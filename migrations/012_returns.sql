@@ -0,0 +1,54 @@
+-- คืนสินค้า / คืนเงิน (RMA) ของรายการในออเดอร์ออนไลน์หรือการขายหน้าร้าน
+-- requested → approved → received → refunded  (หรือ rejected / cancelled)
+CREATE TABLE IF NOT EXISTS return_requests (
+    id              BIGSERIAL PRIMARY KEY,
+    source          TEXT          NOT NULL CHECK (source IN ('order', 'sale')),
+    order_id        BIGINT        REFERENCES orders(id) ON DELETE CASCADE,
+    sale_id         TEXT,
+    customer_id     TEXT,
+    status          TEXT          NOT NULL DEFAULT 'requested'
+                    CHECK (status IN ('requested', 'approved', 'rejected', 'received', 'refunded', 'cancelled')),
+    reason          TEXT          NOT NULL,
+    note            TEXT,
+    requested_by    TEXT,
+    review_note     TEXT,
+    reviewed_by     TEXT,
+    reviewed_at     TIMESTAMPTZ,
+    received_by     TEXT,
+    received_at     TIMESTAMPTZ,
+    refund_amount   NUMERIC(12,2) NOT NULL DEFAULT 0,
+    refund_shipping BOOLEAN       NOT NULL DEFAULT FALSE,
+    refund_ref      TEXT,
+    refunded_by     TEXT,
+    refunded_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    CHECK ((source = 'order' AND order_id IS NOT NULL) OR (source = 'sale' AND sale_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS return_requests_order_idx ON return_requests (order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS return_requests_sale_idx ON return_requests (sale_id) WHERE sale_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS return_requests_customer_idx ON return_requests (customer_id, id);
+CREATE INDEX IF NOT EXISTS return_requests_status_idx ON return_requests (status, id);
+
+-- paid_amount = ยอดที่ลูกค้าจ่ายสำหรับจำนวนที่คืน (รวม VAT); refund_amount ≤ paid_amount (หักค่าเสียหายได้)
+CREATE TABLE IF NOT EXISTS return_items (
+    id            BIGSERIAL PRIMARY KEY,
+    return_id     BIGINT        NOT NULL REFERENCES return_requests(id) ON DELETE CASCADE,
+    order_item_id BIGINT,
+    product_id    TEXT          NOT NULL,
+    variant_id    BIGINT,
+    name          TEXT          NOT NULL,
+    quantity      INT           NOT NULL CHECK (quantity > 0),
+    reason        TEXT          NOT NULL,
+    paid_amount   NUMERIC(12,2) NOT NULL,
+    refund_amount NUMERIC(12,2) NOT NULL CHECK (refund_amount >= 0 AND refund_amount <= paid_amount),
+    disposition   TEXT          CHECK (disposition IN ('restock', 'write_off'))
+);
+
+CREATE INDEX IF NOT EXISTS return_items_return_idx ON return_items (return_id);
+CREATE INDEX IF NOT EXISTS return_items_order_item_idx ON return_items (order_item_id) WHERE order_item_id IS NOT NULL;
+
+-- ยอดที่คืนเงินไปแล้วรวมทุกใบคืนสินค้า
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- ค้นหา/เรียงออเดอร์หลังบ้าน (keyset pagination ตาม (created_at, id) และ (total, id))
+CREATE INDEX IF NOT EXISTS orders_created_idx ON orders (created_at, id);
+CREATE INDEX IF NOT EXISTS orders_total_idx ON orders (total, id);
+CREATE INDEX IF NOT EXISTS orders_status_created_idx ON orders (status, created_at);
+CREATE INDEX IF NOT EXISTS order_items_order_idx ON order_items (order_id);
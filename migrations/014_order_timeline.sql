@@ -0,0 +1,56 @@
+-- หมายเหตุจากลูกค้าตอนสั่งซื้อ (ลูกค้าและพนักงานเห็น)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_note TEXT;
+
+-- ความเห็นของพนักงานบนออเดอร์; internal = เห็นเฉพาะพนักงาน
+CREATE TABLE IF NOT EXISTS order_comments (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    BIGINT      NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    author_id   TEXT,
+    author_role TEXT,
+    body        TEXT        NOT NULL,
+    internal    BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS order_comments_order_idx ON order_comments (order_id, id);
+
+-- ประวัติการเปลี่ยน status / payment_status บันทึกด้วย trigger ทุกเส้นทางที่แก้ orders
+-- actor มาจาก set_config('app.actor', ..., true) ใน transaction เดียวกัน (ว่างได้)
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   BIGINT      NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    field      TEXT        NOT NULL CHECK (field IN ('status', 'payment_status')),
+    from_value TEXT,
+    to_value   TEXT        NOT NULL,
+    actor      TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_idx ON order_status_history (order_id, id);
+
+CREATE OR REPLACE FUNCTION orders_log_status() RETURNS trigger AS $$
+DECLARE
+    who TEXT := NULLIF(current_setting('app.actor', true), '');
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO order_status_history (order_id, field, from_value, to_value, actor)
+        VALUES (NEW.id, 'status', NULL, NEW.status, who),
+               (NEW.id, 'payment_status', NULL, NEW.payment_status, who);
+        RETURN NEW;
+    END IF;
+    IF NEW.status IS DISTINCT FROM OLD.status THEN
+        INSERT INTO order_status_history (order_id, field, from_value, to_value, actor)
+        VALUES (NEW.id, 'status', OLD.status, NEW.status, who);
+    END IF;
+    IF NEW.payment_status IS DISTINCT FROM OLD.payment_status THEN
+        INSERT INTO order_status_history (order_id, field, from_value, to_value, actor)
+        VALUES (NEW.id, 'payment_status', OLD.payment_status, NEW.payment_status, who);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS orders_status_history ON orders;
+CREATE TRIGGER orders_status_history
+    AFTER INSERT OR UPDATE OF status, payment_status ON orders
+    FOR EACH ROW EXECUTE FUNCTION orders_log_status();
@@ -0,0 +1,7 @@
+-- ออเดอร์ของ guest (ไม่มีบัญชี): user_id = 'guest:<อีเมลตัวเล็ก>' จนกว่าจะสมัคร/ผูกเข้าบัญชี
+-- guest_token_hash = sha256 ของ token สำหรับดูออเดอร์ (ไม่เก็บ token จริง)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS guest_email      TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS guest_phone      TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS guest_token_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS orders_guest_email_idx ON orders (LOWER(guest_email)) WHERE guest_email IS NOT NULL;
@@ -0,0 +1,26 @@
+-- งาน export ออเดอร์/การขายหน้าร้าน (CSV/XLSX) ที่ทำเบื้องหลัง
+-- worker หยิบงาน queued ด้วย FOR UPDATE SKIP LOCKED; ไฟล์เก็บใน EXPORT_DIR ลบเมื่อเลย expires_at
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id          BIGSERIAL PRIMARY KEY,
+    kind        TEXT        NOT NULL CHECK (kind IN ('orders', 'sales')),
+    format      TEXT        NOT NULL CHECK (format IN ('csv', 'xlsx')),
+    granularity TEXT        NOT NULL CHECK (granularity IN ('header', 'lines')),
+    params      JSONB       NOT NULL DEFAULT '{}',
+    status      TEXT        NOT NULL DEFAULT 'queued'
+                CHECK (status IN ('queued', 'running', 'done', 'failed', 'expired')),
+    row_count   INT,
+    file_name   TEXT,
+    file_size   BIGINT,
+    error       TEXT,
+    created_by  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    expires_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS export_jobs_queued_idx ON export_jobs (id) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS export_jobs_created_by_idx ON export_jobs (created_by, id DESC);
+
+-- ตัวกรองวันที่ของการขายหน้าร้าน
+CREATE INDEX IF NOT EXISTS sales_sale_date_idx ON sales (sale_date);
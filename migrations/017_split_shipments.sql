@@ -0,0 +1,20 @@
+-- ส่งของแยกหลายพัสดุ: แต่ละ shipment ระบุรายการ/จำนวนที่อยู่ในกล่องนั้น
+-- ออเดอร์ที่ส่งแล้วบางส่วนมีสถานะ partially_shipped
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id            BIGSERIAL PRIMARY KEY,
+    shipment_id   BIGINT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    order_item_id BIGINT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    quantity      INT    NOT NULL CHECK (quantity > 0),
+    UNIQUE (shipment_id, order_item_id)
+);
+
+CREATE INDEX IF NOT EXISTS shipment_items_order_item_idx ON shipment_items (order_item_id);
+
+-- shipment เดิม (ก่อนแยกพัสดุ) ส่งครบทุกรายการในกล่องแรก
+INSERT INTO shipment_items (shipment_id, order_item_id, quantity)
+SELECT s.id, oi.id, oi.quantity
+FROM order_items oi
+JOIN LATERAL (SELECT id FROM shipments WHERE order_id = oi.order_id ORDER BY id LIMIT 1) s ON TRUE
+WHERE NOT EXISTS (
+    SELECT 1 FROM shipment_items si JOIN shipments sh ON sh.id = si.shipment_id WHERE sh.order_id = oi.order_id
+);
@@ -0,0 +1,27 @@
+-- สลิปโอนเงิน (BANK_TRANSFER) และคิวตรวจสลิปของพนักงาน
+-- ไฟล์เก็บใน SLIP_DIR (ไม่อยู่ใต้ /static เพราะมีข้อมูลบัญชีลูกค้า) ดูผ่าน endpoint ที่ตรวจสิทธิ์
+CREATE TABLE IF NOT EXISTS payment_slips (
+    id             BIGSERIAL PRIMARY KEY,
+    order_id       BIGINT        NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    file_name      TEXT          NOT NULL,
+    content_type   TEXT          NOT NULL,
+    file_size      INT           NOT NULL,
+    sha256         TEXT          NOT NULL,
+    amount         NUMERIC(12,2),          -- ยอดที่ลูกค้าแจ้ง (ถ้ามี)
+    transferred_at TIMESTAMPTZ,
+    bank_ref       TEXT,
+    status         TEXT          NOT NULL DEFAULT 'submitted'
+                   CHECK (status IN ('submitted', 'approved', 'rejected', 'superseded')),
+    reason         TEXT,                   -- เหตุผลที่ปฏิเสธ (ลูกค้าเห็น)
+    uploaded_by    TEXT,
+    reviewed_by    TEXT,
+    reviewed_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS payment_slips_order_idx ON payment_slips (order_id, id);
+CREATE INDEX IF NOT EXISTS payment_slips_queue_idx ON payment_slips (id) WHERE status = 'submitted';
+CREATE INDEX IF NOT EXISTS payment_slips_sha_idx ON payment_slips (sha256);
+
+-- รอตรวจได้ครั้งละหนึ่งใบต่อออเดอร์ (อัปโหลดใหม่ = ใบเก่าเป็น superseded)
+CREATE UNIQUE INDEX IF NOT EXISTS payment_slips_one_submitted_idx ON payment_slips (order_id) WHERE status = 'submitted';
@@ -0,0 +1,30 @@
+-- รายการชำระเงินผ่าน payment gateway (CARD) และ webhook ที่รับแล้ว
+CREATE TABLE IF NOT EXISTS payment_intents (
+    id              BIGSERIAL PRIMARY KEY,
+    order_id        BIGINT        NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    provider        TEXT          NOT NULL,
+    provider_ref    TEXT          NOT NULL,
+    amount          NUMERIC(12,2) NOT NULL,
+    status          TEXT          NOT NULL DEFAULT 'requires_payment'
+                    CHECK (status IN ('requires_payment', 'succeeded', 'failed', 'refunded')),
+    refunded_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+    redirect_url    TEXT,
+    failure_reason  TEXT,
+    created_at      TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, provider_ref)
+);
+
+CREATE INDEX IF NOT EXISTS payment_intents_order_idx ON payment_intents (order_id, id);
+
+-- กันประมวลผล webhook ซ้ำ: (provider, event_id) ไม่ซ้ำ
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    provider     TEXT        NOT NULL,
+    event_id     TEXT        NOT NULL,
+    type         TEXT        NOT NULL,
+    intent_ref   TEXT,
+    payload      JSONB       NOT NULL,
+    result       TEXT,
+    received_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
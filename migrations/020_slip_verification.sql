@@ -0,0 +1,12 @@
+-- ตรวจสลิปอัตโนมัติจาก mini-QR บนสลิป (เลขอ้างอิงธุรกรรมของธนาคาร)
+-- verification: verified = อ่านได้และไม่พบสิ่งผิดปกติ, suspicious = มีจุดให้ตรวจ (ดู verification_notes),
+-- unreadable = อ่าน QR ไม่ได้; NULL = สลิปก่อนมีระบบนี้
+ALTER TABLE payment_slips
+    ADD COLUMN IF NOT EXISTS qr_ref             TEXT,
+    ADD COLUMN IF NOT EXISTS qr_bank_code       TEXT,
+    ADD COLUMN IF NOT EXISTS verification       TEXT
+        CHECK (verification IN ('verified', 'suspicious', 'unreadable')),
+    ADD COLUMN IF NOT EXISTS verification_notes TEXT[] NOT NULL DEFAULT '{}';
+
+-- เลขอ้างอิงเดียวกันห้ามใช้กับหลายออเดอร์
+CREATE INDEX IF NOT EXISTS payment_slips_qr_ref_idx ON payment_slips (qr_ref) WHERE qr_ref IS NOT NULL;
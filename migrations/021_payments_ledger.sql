@@ -0,0 +1,65 @@
+-- สมุดรายการรับ/คืนเงิน: ออเดอร์/การขายหนึ่งรายการมีได้หลายรายการ (มัดจำ, จ่ายซ้ำหลังล้มเหลว, คืนเงิน)
+-- orders.payment_status / paid_amount / payment_ref คำนวณจากตารางนี้ (syncOrderPaymentTx) ห้ามแก้ตรง
+CREATE TABLE IF NOT EXISTS payments (
+    id           BIGSERIAL PRIMARY KEY,
+    order_id     BIGINT        REFERENCES orders(id) ON DELETE RESTRICT, -- ลบออเดอร์ที่มีรายการเงินไม่ได้ (สมุดห้ามหาย)
+    sale_id      TEXT,                             -- sales.sale_id (POS)
+    kind         TEXT          NOT NULL DEFAULT 'payment' CHECK (kind IN ('payment', 'refund')),
+    method       TEXT          NOT NULL,           -- COD | BANK_TRANSFER | PROMPTPAY | CARD | CASH
+    amount       NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+    status       TEXT          NOT NULL
+                 CHECK (status IN ('pending', 'review', 'succeeded', 'failed', 'voided')),
+    provider     TEXT,                             -- gateway (payment_intents.provider) หรือ NULL
+    provider_ref TEXT,                             -- เลขอ้างอิงธนาคาร/gateway
+    slip_id      BIGINT        REFERENCES payment_slips(id) ON DELETE SET NULL,
+    note         TEXT,
+    created_by   TEXT,
+    settled_at   TIMESTAMPTZ,                      -- เวลาที่เงินเข้า/ออกจริง (status = succeeded)
+    created_at   TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    CHECK ((order_id IS NULL) <> (sale_id IS NULL)),
+    CHECK (status <> 'succeeded' OR settled_at IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS payments_order_idx ON payments (order_id, id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS payments_sale_idx ON payments (sale_id, id) WHERE sale_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS payments_settled_idx ON payments (settled_at) WHERE status = 'succeeded';
+CREATE INDEX IF NOT EXISTS payments_provider_ref_idx ON payments (provider, provider_ref) WHERE provider_ref IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS payments_slip_idx ON payments (slip_id) WHERE slip_id IS NOT NULL;
+
+-- ยอดรับสุทธิ (รับ - คืน) เก็บไว้ที่ออเดอร์เพื่อคิดยอดค้างชำระ
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS paid_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+-- ช่องทางชำระของการขายหน้าร้าน
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS payment_method TEXT NOT NULL DEFAULT 'CASH';
+
+-- ย้ายข้อมูลเดิม: ออเดอร์ที่รับเงินแล้ว 1 รายการ + ยอดคืนเงินรวม 1 รายการ
+INSERT INTO payments (order_id, kind, method, amount, status, provider_ref, note, settled_at, created_at)
+SELECT o.id, 'payment', UPPER(o.payment_method), o.total, 'succeeded', o.payment_ref, 'migrated', o.updated_at, o.updated_at
+FROM orders o
+WHERE o.payment_status IN ('paid', 'refund_pending') AND o.total > 0
+  AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.order_id = o.id);
+
+INSERT INTO payments (order_id, kind, method, amount, status, note, settled_at, created_at)
+SELECT o.id, 'refund', UPPER(o.payment_method), o.refunded_amount, 'succeeded', 'migrated', o.updated_at, o.updated_at
+FROM orders o
+WHERE o.refunded_amount > 0
+  AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.order_id = o.id AND p.kind = 'refund');
+
+UPDATE orders o
+SET paid_amount = COALESCE((
+        SELECT SUM(CASE WHEN p.kind = 'payment' THEN p.amount ELSE -p.amount END)
+        FROM payments p WHERE p.order_id = o.id AND p.status = 'succeeded'), 0)
+WHERE o.paid_amount = 0;
+
+INSERT INTO payments (sale_id, kind, method, amount, status, note, settled_at, created_at)
+SELECT s.sale_id, 'payment', s.payment_method, s.total_price, 'succeeded', 'migrated', s.sale_date, s.sale_date
+FROM sales s
+WHERE s.total_price > 0
+  AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.sale_id = s.sale_id);
+
+INSERT INTO payments (sale_id, kind, method, amount, status, note, settled_at, created_at)
+SELECT s.sale_id, 'refund', s.payment_method, s.refunded_amount, 'succeeded', 'migrated', s.sale_date, s.sale_date
+FROM sales s
+WHERE s.refunded_amount > 0
+  AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.sale_id = s.sale_id AND p.kind = 'refund');
@@ -0,0 +1,38 @@
+-- ยอดเก็บเงินปลายทาง (COD) ที่ขนส่งโอนคืนร้าน: นำเข้าจาก statement CSV ของขนส่ง แล้วจับคู่ออเดอร์ด้วยเลขพัสดุ
+-- บรรทัดที่จับคู่ได้ลงสมุดรับเงิน (payments, method COD) → payment_status คำนวณใหม่
+CREATE TABLE IF NOT EXISTS cod_remittances (
+    id             BIGSERIAL PRIMARY KEY,
+    carrier        TEXT          NOT NULL,
+    statement_ref  TEXT,                           -- เลขที่ statement/รอบโอนของขนส่ง
+    remitted_on    DATE          NOT NULL,         -- วันที่ขนส่งโอนเงิน
+    file_name      TEXT          NOT NULL,
+    sha256         TEXT          NOT NULL UNIQUE,  -- ไฟล์เดียวกันนำเข้าซ้ำไม่ได้
+    line_count     INT           NOT NULL DEFAULT 0,
+    matched_count  INT           NOT NULL DEFAULT 0,
+    cod_total      NUMERIC(12,2) NOT NULL DEFAULT 0, -- ยอดที่ขนส่งเก็บจากลูกค้า
+    fee_total      NUMERIC(12,2) NOT NULL DEFAULT 0, -- ค่าธรรมเนียม COD ที่ขนส่งหัก
+    expected_total NUMERIC(12,2) NOT NULL DEFAULT 0, -- ยอดค้างของออเดอร์ที่จับคู่ได้ ณ ตอนนำเข้า
+    imported_by    TEXT,
+    created_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS cod_remittance_lines (
+    id            BIGSERIAL PRIMARY KEY,
+    remittance_id BIGINT        NOT NULL REFERENCES cod_remittances(id) ON DELETE CASCADE,
+    line_no       INT           NOT NULL,           -- บรรทัดในไฟล์ (นับหัวตารางเป็นบรรทัด 1)
+    tracking_no   TEXT          NOT NULL,
+    cod_amount    NUMERIC(12,2) NOT NULL,
+    fee           NUMERIC(12,2) NOT NULL DEFAULT 0,
+    order_id      BIGINT        REFERENCES orders(id) ON DELETE SET NULL,
+    expected      NUMERIC(12,2),                    -- ยอดค้างของออเดอร์ก่อนบรรทัดนี้
+    result        TEXT          NOT NULL
+                  CHECK (result IN ('matched', 'short', 'over', 'not_found', 'not_cod', 'already_paid', 'duplicate')),
+    payment_id    BIGINT        REFERENCES payments(id) ON DELETE SET NULL,
+    UNIQUE (remittance_id, line_no)
+);
+
+CREATE INDEX IF NOT EXISTS cod_remittance_lines_order_idx ON cod_remittance_lines (order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS cod_remittance_lines_issue_idx ON cod_remittance_lines (remittance_id) WHERE result <> 'matched';
+
+-- จับคู่เลขพัสดุแบบไม่สนตัวพิมพ์เล็ก/ใหญ่
+CREATE INDEX IF NOT EXISTS shipments_tracking_upper_idx ON shipments (UPPER(tracking_no));
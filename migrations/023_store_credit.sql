@@ -0,0 +1,40 @@
+-- เครดิตร้านของลูกค้า (store credit): ยอดคงเหลือต่อลูกค้า + ledger การเคลื่อนไหว (append-only)
+-- store_credit_accounts.balance อัปเดตใน transaction เดียวกับการเขียน ledger (moveStoreCreditTx)
+CREATE TABLE IF NOT EXISTS store_credit_accounts (
+    customer_id TEXT          PRIMARY KEY,
+    balance     NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS store_credit_transactions (
+    id            BIGSERIAL PRIMARY KEY,
+    customer_id   TEXT          NOT NULL REFERENCES store_credit_accounts(customer_id),
+    kind          TEXT          NOT NULL CHECK (kind IN ('issue', 'redeem', 'restore', 'adjust')),
+    amount        NUMERIC(12,2) NOT NULL CHECK (amount <> 0), -- + เข้า, - ใช้ไป
+    balance_after NUMERIC(12,2) NOT NULL CHECK (balance_after >= 0),
+    order_id      BIGINT,                                     -- ไม่ผูก FK: ledger ห้ามแก้แม้ลบออเดอร์
+    sale_id       TEXT,
+    return_id     BIGINT,
+    reason        TEXT,                                       -- บังคับสำหรับ adjust
+    created_by    TEXT,
+    created_at    TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    CHECK (kind <> 'adjust' OR COALESCE(reason, '') <> '')
+);
+
+CREATE INDEX IF NOT EXISTS store_credit_transactions_customer_idx ON store_credit_transactions (customer_id, id);
+CREATE INDEX IF NOT EXISTS store_credit_transactions_order_idx ON store_credit_transactions (order_id) WHERE order_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION store_credit_transactions_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'store_credit_transactions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS store_credit_transactions_no_change ON store_credit_transactions;
+CREATE TRIGGER store_credit_transactions_no_change
+    BEFORE UPDATE OR DELETE ON store_credit_transactions
+    FOR EACH ROW EXECUTE FUNCTION store_credit_transactions_append_only();
+
+-- คืนเงินจากการคืนสินค้าเป็นเครดิตร้านแทนเงินได้
+ALTER TABLE return_requests ADD COLUMN IF NOT EXISTS refund_to_credit BOOLEAN NOT NULL DEFAULT FALSE;
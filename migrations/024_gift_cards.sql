@@ -0,0 +1,65 @@
+-- บัตรของขวัญ: ขายที่หน้าร้าน (POS) หรือซื้อออนไลน์พร้อมออเดอร์ ใช้จ่ายบางส่วนได้ทั้งออนไลน์และหน้าร้าน
+-- gift_cards.balance อัปเดตใน transaction เดียวกับการเขียน ledger (gift_card_transactions, append-only)
+CREATE TABLE IF NOT EXISTS gift_cards (
+    id             BIGSERIAL PRIMARY KEY,
+    code           TEXT          NOT NULL UNIQUE,     -- ตัวพิมพ์ใหญ่ ไม่มีขีด
+    initial_amount NUMERIC(12,2) NOT NULL CHECK (initial_amount > 0),
+    balance        NUMERIC(12,2) NOT NULL CHECK (balance >= 0),
+    status         TEXT          NOT NULL CHECK (status IN ('pending', 'active', 'void')),
+    channel        TEXT          NOT NULL CHECK (channel IN ('pos', 'online')),
+    payment_method TEXT,                              -- POS: ช่องทางที่ลูกค้าจ่ายค่าบัตร
+    order_id       BIGINT        REFERENCES orders(id) ON DELETE SET NULL, -- online: ออเดอร์ที่ซื้อบัตร
+    purchaser_id   TEXT,
+    recipient_name TEXT,
+    recipient_email TEXT,
+    message        TEXT,
+    expires_at     TIMESTAMPTZ,                       -- NULL = ยังไม่เปิดใช้ (pending)
+    activated_at   TIMESTAMPTZ,
+    issued_by      TEXT,
+    created_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    CHECK (status <> 'active' OR expires_at IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS gift_cards_order_idx ON gift_cards (order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS gift_cards_purchaser_idx ON gift_cards (purchaser_id, id) WHERE purchaser_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS gift_cards_liability_idx ON gift_cards (expires_at) WHERE status = 'active' AND balance > 0;
+
+CREATE TABLE IF NOT EXISTS gift_card_transactions (
+    id            BIGSERIAL PRIMARY KEY,
+    gift_card_id  BIGINT        NOT NULL REFERENCES gift_cards(id),
+    kind          TEXT          NOT NULL CHECK (kind IN ('issue', 'redeem', 'restore', 'void')),
+    amount        NUMERIC(12,2) NOT NULL CHECK (amount <> 0), -- + เข้า, - ใช้ไป
+    balance_after NUMERIC(12,2) NOT NULL CHECK (balance_after >= 0),
+    order_id      BIGINT,                                     -- ไม่ผูก FK: ledger ห้ามแก้แม้ลบออเดอร์
+    sale_id       TEXT,
+    note          TEXT,
+    created_by    TEXT,
+    created_at    TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS gift_card_transactions_card_idx ON gift_card_transactions (gift_card_id, id);
+CREATE INDEX IF NOT EXISTS gift_card_transactions_order_idx ON gift_card_transactions (order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS gift_card_transactions_sale_idx ON gift_card_transactions (sale_id) WHERE sale_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION gift_card_transactions_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'gift_card_transactions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS gift_card_transactions_no_change ON gift_card_transactions;
+CREATE TRIGGER gift_card_transactions_no_change
+    BEFORE UPDATE OR DELETE ON gift_card_transactions
+    FOR EACH ROW EXECUTE FUNCTION gift_card_transactions_append_only();
+
+-- ยอดบัตรของขวัญที่ซื้อในออเดอร์ (ไม่คิด VAT, ไม่นับใน subtotal)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS gift_card_total NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+-- ค่าบัตรที่ขายหน้าร้านลงสมุดรับเงินโดยอ้าง gift_card_id (ไม่มีออเดอร์/การขาย)
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS gift_card_id BIGINT REFERENCES gift_cards(id);
+-- payments_check = CHECK ((order_id IS NULL) <> (sale_id IS NULL)) จาก 021
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_check;
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_one_target;
+ALTER TABLE payments ADD CONSTRAINT payments_one_target
+    CHECK (num_nonnulls(order_id, sale_id, gift_card_id) = 1);
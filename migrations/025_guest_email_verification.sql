@@ -0,0 +1,13 @@
+-- ยืนยันอีเมลของ guest ก่อนสมัคร/ผูกออเดอร์เข้าบัญชี: ส่งรหัส 6 หลักไปที่อีเมลที่ใช้สั่ง (guest_token ไม่ได้พิสูจน์ความเป็นเจ้าของอีเมล)
+-- เก็บเฉพาะ hash ของรหัส; ใช้ได้ครั้งเดียว ผิดเกินกำหนดต้องขอรหัสใหม่
+CREATE TABLE IF NOT EXISTS guest_email_verifications (
+    id         BIGSERIAL PRIMARY KEY,
+    email      TEXT        NOT NULL, -- ตัวเล็ก
+    code_hash  TEXT        NOT NULL,
+    attempts   INT         NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS guest_email_verifications_email_idx ON guest_email_verifications (email, id);
@@ -0,0 +1,5 @@
+-- 021 เดิมผูก payments.order_id แบบ ON DELETE CASCADE: ลบออเดอร์แล้วรายการเงินหายไปด้วย ทำให้รายงานกระทบยอดย้อนหลังเปลี่ยน
+-- เปลี่ยนเป็น RESTRICT ให้ DB เดิมด้วย; ออเดอร์ที่มีรายการเงินต้องยกเลิก/คืนเงินแทนการลบ
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_order_id_fkey;
+ALTER TABLE payments ADD CONSTRAINT payments_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE RESTRICT;
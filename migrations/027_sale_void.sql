@@ -0,0 +1,5 @@
+-- ยกเลิกการขายหน้าร้าน (void) แทนการลบแถว: คืนของเข้าสต็อกผ่าน stock_movements และคืนเงินผ่านสมุดรับเงิน
+-- การขายที่ void แล้วไม่นับเป็นยอดคาดว่าจะได้ในรายงานกระทบยอด
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS voided_at   TIMESTAMPTZ;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS voided_by   TEXT;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS void_reason TEXT;
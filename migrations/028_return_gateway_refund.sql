@@ -0,0 +1,3 @@
+-- คืนเงินผ่าน gateway: ลงรายการคืนเงิน pending แล้ว commit ก่อนเรียก gateway
+-- ใบคืนสินค้าชี้ไปที่รายการนั้น เพื่อปิดรายการ/ลองใหม่ได้ถ้า gateway ล้มเหลว
+ALTER TABLE return_requests ADD COLUMN IF NOT EXISTS refund_payment_id BIGINT REFERENCES payments(id);
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ShippingAddress ที่อยู่จัดส่งแบบไทย; ใช้ทั้งในสมุดที่อยู่และ snapshot บนออเดอร์
+type ShippingAddress struct {
+	Recipient   string `json:"recipient"`   // ชื่อผู้รับ
+	Phone       string `json:"phone"`       // เบอร์ผู้รับ
+	HouseNo     string `json:"house_no"`    // บ้านเลขที่ หมู่ ซอย ถนน
+	Subdistrict string `json:"subdistrict"` // ตำบล/แขวง
+	District    string `json:"district"`    // อำเภอ/เขต
+	Province    string `json:"province"`    // จังหวัด
+	Postcode    string `json:"postcode"`    // รหัสไปรษณีย์ 5 หลัก
+}
+
+// CustomerAddress หนึ่งรายการในสมุดที่อยู่ของลูกค้า
+type CustomerAddress struct {
+	ID         int64   `json:"id"`
+	CustomerID string  `json:"customer_id"`
+	Label      *string `json:"label,omitempty"` // เช่น บ้าน, ที่ทำงาน
+	ShippingAddress
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CustomerAddressReq struct {
+	Label *string `json:"label,omitempty"`
+	ShippingAddress
+	IsDefault bool `json:"is_default"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// สถานะตะกร้า (carts.status)
+const (
+	CartStatusOpen       = "open"
+	CartStatusMerged     = "merged"      // ตะกร้า anonymous ที่รวมเข้าตะกร้าลูกค้าตอน login แล้ว
+	CartStatusCheckedOut = "checked_out" // สร้างออเดอร์จากตะกร้านี้แล้ว
+)
+
+// สถานะของแต่ละบรรทัดในตะกร้า (คำนวณใหม่ทุกครั้งที่อ่านตะกร้า)
+const (
+	CartLineOK                = "ok"
+	CartLineOutOfStock        = "out_of_stock"
+	CartLineInsufficientStock = "insufficient_stock" // มีของแต่ไม่พอจำนวนที่ใส่ไว้
+	CartLineUnavailable       = "unavailable"        // สินค้า/variant ถูกลบหรือปิดขาย
+)
+
+type CartItemReq struct {
+	ProductID string `json:"product_id"`
+	VariantID *int64 `json:"variant_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+type UpdateCartItemReq struct {
+	Quantity int `json:"quantity"` // 0 = ลบบรรทัดนี้
+}
+
+type MergeCartReq struct {
+	CartID string `json:"cart_id,omitempty"` // ว่าง = ใช้ cookie cart_id
+}
+
+// CartLine บรรทัดในตะกร้าพร้อมราคา/สต็อกปัจจุบัน
+type CartLine struct {
+	ID           int64   `json:"id"`
+	ProductID    string  `json:"product_id"`
+	VariantID    *int64  `json:"variant_id,omitempty"`
+	SKU          *string `json:"sku,omitempty"`
+	Name         string  `json:"name"`
+	Variant      string  `json:"variant,omitempty"`
+	Quantity     int     `json:"quantity"`
+	UnitPrice    Money   `json:"unit_price"`   // ราคาปัจจุบัน
+	PriceAtAdd   Money   `json:"price_at_add"` // ราคาตอนหยิบใส่ตะกร้า
+	PriceChanged bool    `json:"price_changed"`
+	LineTotal    Money   `json:"line_total"`
+	Available    int     `json:"available"`
+	Status       string  `json:"status"`
+}
+
+type Cart struct {
+	ID         string     `json:"cart_id,omitempty"` // ว่าง = ยังไม่มีตะกร้า
+	CustomerID *string    `json:"customer_id,omitempty"`
+	Items      []CartLine `json:"items"`
+	ItemCount  int        `json:"item_count"`
+	Subtotal   Money      `json:"subtotal"`   // รวมเฉพาะบรรทัดที่สั่งซื้อได้ (status = ok)
+	HasIssues  bool       `json:"has_issues"` // มีบรรทัดที่ของหมด/ไม่พอ/เลิกขาย
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
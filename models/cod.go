@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// ผลจับคู่บรรทัดใน statement COD
+const (
+	CODMatched     = "matched"      // ยอดตรงกับยอดค้าง
+	CODShort       = "short"        // ขนส่งโอนน้อยกว่ายอดค้าง
+	CODOver        = "over"         // ขนส่งโอนเกินยอดค้าง
+	CODNotFound    = "not_found"    // ไม่พบเลขพัสดุในระบบ
+	CODNotCOD      = "not_cod"      // ออเดอร์ไม่ได้เก็บเงินปลายทาง
+	CODAlreadyPaid = "already_paid" // ออเดอร์ได้รับเงินครบแล้ว (โอนซ้ำ)
+	CODDuplicate   = "duplicate"    // เลขพัสดุซ้ำในไฟล์เดียวกัน
+)
+
+// CODRemittance รอบการโอนเงิน COD หนึ่งไฟล์จากขนส่ง
+type CODRemittance struct {
+	ID            int64     `json:"id"`
+	Carrier       string    `json:"carrier"`
+	StatementRef  *string   `json:"statement_ref,omitempty"`
+	RemittedOn    time.Time `json:"remitted_on"`
+	FileName      string    `json:"file_name"`
+	LineCount     int       `json:"line_count"`
+	MatchedCount  int       `json:"matched_count"`
+	CODTotal      Money     `json:"cod_total"`
+	FeeTotal      Money     `json:"fee_total"`
+	NetTotal      Money     `json:"net_total"` // cod_total - fee_total = เงินที่ร้านได้รับจริง
+	ExpectedTotal Money     `json:"expected_total"`
+	ImportedBy    *string   `json:"imported_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type CODRemittanceLine struct {
+	ID         int64  `json:"id"`
+	LineNo     int    `json:"line_no"`
+	TrackingNo string `json:"tracking_no"`
+	CODAmount  Money  `json:"cod_amount"`
+	Fee        Money  `json:"fee"`
+	OrderID    *int64 `json:"order_id,omitempty"`
+	Expected   *Money `json:"expected,omitempty"`
+	Result     string `json:"result"`
+	PaymentID  *int64 `json:"payment_id,omitempty"`
+}
+
+// CODMissingOrder ออเดอร์ COD ที่ส่งกับขนส่งนี้นานเกินกำหนดแล้วยังไม่มียอดโอน
+type CODMissingOrder struct {
+	OrderID     int64     `json:"order_id"`
+	TrackingNo  string    `json:"tracking_no"`
+	ShippedAt   time.Time `json:"shipped_at"`
+	Outstanding Money     `json:"outstanding"`
+}
+
+// CODRemittanceReport รอบโอนพร้อมรายการที่ต้องตรวจ
+type CODRemittanceReport struct {
+	CODRemittance
+	Lines   []CODRemittanceLine `json:"lines"`
+	Issues  []CODRemittanceLine `json:"issues"`  // บรรทัดที่ไม่ใช่ matched
+	Missing []CODMissingOrder   `json:"missing"` // ส่งก่อน remitted_on - grace แต่ยังไม่ได้เงิน
+	Short   Money               `json:"short"`   // ผลรวมยอดที่ขาด
+	Over    Money               `json:"over"`    // ผลรวมยอดที่เกิน
+}
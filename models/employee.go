@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Employee struct {
 	ID         int       `json:"id"`
@@ -20,3 +23,20 @@ type User_input struct {
 	EmployeeID string `json:"employee_id"`
 	Password   string `json:"password"`
 }
+
+// ===== Roles (เก็บใน JWT) =====
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleOwner    = "owner"
+)
+
+// EmployeeRole แปลงตำแหน่งพนักงานเป็น role ใน JWT
+func EmployeeRole(position string) string {
+	switch strings.ToLower(strings.TrimSpace(position)) {
+	case "owner", "เจ้าของ", "เจ้าของร้าน":
+		return RoleOwner
+	default:
+		return RoleStaff
+	}
+}
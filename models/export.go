@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+const (
+	ExportKindOrders = "orders"
+	ExportKindSales  = "sales"
+
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+
+	ExportHeader = "header" // หนึ่งแถวต่อออเดอร์/การขาย
+	ExportLines  = "lines"  // หนึ่งแถวต่อรายการสินค้า
+
+	ExportStatusQueued  = "queued"
+	ExportStatusRunning = "running"
+	ExportStatusDone    = "done"
+	ExportStatusFailed  = "failed"
+	ExportStatusExpired = "expired" // ไฟล์ถูกลบแล้ว
+)
+
+// CreateExportReq params = ตัวกรองแบบเดียวกับ query string ของหน้าค้นหา เช่น {"from": "2025-01-01", "status": "paid"}
+type CreateExportReq struct {
+	Kind        string            `json:"kind"`                  // orders | sales
+	Format      string            `json:"format,omitempty"`      // csv (default) | xlsx
+	Granularity string            `json:"granularity,omitempty"` // header (default) | lines
+	Params      map[string]string `json:"params,omitempty"`
+}
+
+type ExportJob struct {
+	ID          int64             `json:"id"`
+	Kind        string            `json:"kind"`
+	Format      string            `json:"format"`
+	Granularity string            `json:"granularity"`
+	Params      map[string]string `json:"params"`
+	Status      string            `json:"status"`
+	RowCount    *int              `json:"row_count,omitempty"`
+	FileName    *string           `json:"file_name,omitempty"`
+	FileSize    *int64            `json:"file_size,omitempty"`
+	Error       *string           `json:"error,omitempty"`
+	CreatedBy   *string           `json:"created_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	DownloadURL string            `json:"download_url,omitempty"` // มีเมื่อ status = done
+}
@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// PayMethodGiftCard ชำระด้วยบัตรของขวัญ (ตัดยอดจาก gift_cards ตอนสร้างออเดอร์/การขาย)
+const PayMethodGiftCard = "GIFT_CARD"
+
+// Gift card status: pending (ซื้อออนไลน์ รอชำระ) → active → void
+const (
+	GiftCardPending = "pending"
+	GiftCardActive  = "active"
+	GiftCardVoid    = "void"
+
+	GiftCardChannelPOS    = "pos"
+	GiftCardChannelOnline = "online"
+)
+
+// Gift card transaction kind
+const (
+	GiftCardTxIssue   = "issue"   // เปิดใช้บัตร (ยอดตั้งต้น)
+	GiftCardTxRedeem  = "redeem"  // ใช้จ่ายออเดอร์/การขาย
+	GiftCardTxRestore = "restore" // คืนยอดเมื่อยกเลิกออเดอร์/คืนสินค้า
+	GiftCardTxVoid    = "void"    // ยกเลิกบัตร (ยอดคงเหลือเป็น 0)
+)
+
+// GiftCard บัตรของขวัญหนึ่งใบ; Code แสดงเต็มเฉพาะพนักงานและผู้ซื้อ
+type GiftCard struct {
+	ID             int64      `json:"id"`
+	Code           string     `json:"code"` // XXXX-XXXX-XXXX-XXXX
+	InitialAmount  Money      `json:"initial_amount"`
+	Balance        Money      `json:"balance"`
+	Status         string     `json:"status"`
+	Expired        bool       `json:"expired"`
+	Channel        string     `json:"channel"` // pos | online
+	PaymentMethod  *string    `json:"payment_method,omitempty"`
+	OrderID        *int64     `json:"order_id,omitempty"`
+	PurchaserID    *string    `json:"purchaser_id,omitempty"`
+	RecipientName  *string    `json:"recipient_name,omitempty"`
+	RecipientEmail *string    `json:"recipient_email,omitempty"`
+	Message        *string    `json:"message,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ActivatedAt    *time.Time `json:"activated_at,omitempty"`
+	IssuedBy       *string    `json:"issued_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// GiftCardTransaction หนึ่งรายการใน ledger บัตรของขวัญ (แก้ไข/ลบไม่ได้)
+type GiftCardTransaction struct {
+	ID           int64     `json:"id"`
+	GiftCardID   int64     `json:"gift_card_id"`
+	Kind         string    `json:"kind"`
+	Amount       Money     `json:"amount"` // + เข้า, - ใช้ไป
+	BalanceAfter Money     `json:"balance_after"`
+	OrderID      *int64    `json:"order_id,omitempty"`
+	SaleID       *string   `json:"sale_id,omitempty"`
+	Note         *string   `json:"note,omitempty"`
+	CreatedBy    *string   `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GiftCardBalance ผลตรวจยอดบัตร (ไม่ต้อง login) แสดงรหัสแบบปิดบางส่วน
+type GiftCardBalance struct {
+	Code      string     `json:"code"` // ****-****-****-ABCD
+	Balance   Money      `json:"balance"`
+	Status    string     `json:"status"`
+	Expired   bool       `json:"expired"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// IssueGiftCardReq ขายบัตรที่หน้าร้าน
+type IssueGiftCardReq struct {
+	Amount         Money  `json:"amount"`
+	PaymentMethod  string `json:"payment_method,omitempty"` // CASH (default) | CARD | PROMPTPAY | BANK_TRANSFER
+	CustomerID     string `json:"customer_id,omitempty"`
+	RecipientName  string `json:"recipient_name,omitempty"`
+	RecipientEmail string `json:"recipient_email,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// GiftCardPurchaseReq บัตรที่ซื้อพร้อมออเดอร์ออนไลน์ (เปิดใช้เมื่อออเดอร์ชำระครบ)
+type GiftCardPurchaseReq struct {
+	Amount         Money  `json:"amount"`
+	RecipientName  string `json:"recipient_name,omitempty"`
+	RecipientEmail string `json:"recipient_email,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// GiftCardLiabilityBucket ยอดคงค้างตามเดือนที่หมดอายุ
+type GiftCardLiabilityBucket struct {
+	Month   string `json:"month"` // YYYY-MM (เวลาไทย)
+	Cards   int    `json:"cards"`
+	Balance Money  `json:"balance"`
+}
+
+// GiftCardLiability ภาระหนี้บัตรของขวัญ ณ เวลาที่ดู
+type GiftCardLiability struct {
+	AsOf           time.Time                 `json:"as_of"`
+	ActiveCards    int                       `json:"active_cards"`
+	Outstanding    Money                     `json:"outstanding"`     // ยอดคงเหลือของบัตรที่ยังไม่หมดอายุ
+	ExpiredCards   int                       `json:"expired_cards"`   // หมดอายุแล้วแต่ยังมียอด
+	ExpiredBalance Money                     `json:"expired_balance"` // breakage
+	PendingCards   int                       `json:"pending_cards"`   // ซื้อออนไลน์ รอชำระ
+	PendingAmount  Money                     `json:"pending_amount"`
+	Issued         Money                     `json:"issued"`   // ยอดตั้งต้นรวมของบัตรที่เปิดใช้แล้ว
+	Redeemed       Money                     `json:"redeemed"` // ใช้ไปสุทธิ (หักยอดที่คืนบัตร)
+	ByExpiry       []GiftCardLiabilityBucket `json:"by_expiry"`
+}
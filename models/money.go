@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgtype"
+)
+
+// Money จำนวนเงินหน่วยสตางค์ (1 บาท = 100 สตางค์) เก็บเป็นจำนวนเต็มเพื่อไม่ให้ยอดเพี้ยนจาก float
+//
+// กติกาการปัดเศษ: ทุกครั้งที่ค่าละเอียดกว่าสตางค์ (เช่นอ่านจาก NUMERIC ที่มีทศนิยมเกิน 2 ตำแหน่ง,
+// รับ input จาก client หรือคิดเปอร์เซ็นต์) จะปัดที่หลักสตางค์แบบ half away from zero
+// เช่น 10.005 → 10.01, -10.005 → -10.01, 10.004 → 10.00
+//
+// JSON เป็นตัวเลขทศนิยม 2 ตำแหน่ง (1250.50) และรับได้ทั้งตัวเลขและ string
+// ใน Postgres ควรเป็น NUMERIC(12,2)
+type Money int64
+
+const (
+	Satang Money = 1
+	Baht   Money = 100
+)
+
+// ParseMoney แปลงข้อความทศนิยม (รองรับ "1250.5", "1e3", "12345e-2") เป็น Money แบบ exact แล้วปัดที่สตางค์
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid money value %q", s)
+	}
+	return moneyFromRat(r)
+}
+
+// MoneyFromFloat ใช้กับข้อมูลเก่าที่เป็น float เท่านั้น (ปัดจากค่าทศนิยมที่สั้นที่สุดของ float)
+func MoneyFromFloat(f float64) Money {
+	m, _ := ParseMoney(strconv.FormatFloat(f, 'f', -1, 64))
+	return m
+}
+
+func moneyFromRat(r *big.Rat) (Money, error) {
+	r = new(big.Rat).Mul(r, big.NewRat(100, 1))
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// half away from zero: เศษ*2 >= ตัวหาร → ปัดขึ้น
+	if rem.Lsh(rem, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	if !q.IsInt64() {
+		return 0, errors.New("money value out of range")
+	}
+	return Money(q.Int64()), nil
+}
+
+// Mul คูณด้วยจำนวนชิ้น
+func (m Money) Mul(qty int) Money {
+	return m * Money(qty)
+}
+
+// MulRatio คูณด้วยสัดส่วน num/den แล้วปัดที่สตางค์ (ใช้คิดเปอร์เซ็นต์/ภาษี)
+func (m Money) MulRatio(num, den int64) Money {
+	r := new(big.Rat).SetFrac(big.NewInt(int64(m)*num), big.NewInt(den*100))
+	out, _ := moneyFromRat(r)
+	return out
+}
+
+// Float64 ใช้สำหรับแสดงผล/กราฟเท่านั้น ห้ามนำไปคำนวณต่อ
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*m = 0
+		return nil
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Scan รับค่าจาก NUMERIC (pgx ส่งมาเป็น string) หรือคอลัมน์ float/int แบบเก่า
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case string:
+		out, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = out
+	case []byte:
+		out, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = out
+	case float64:
+		*m = MoneyFromFloat(v)
+	case float32:
+		*m = MoneyFromFloat(float64(v))
+	case int64:
+		*m = Money(v) * Baht
+	case int32:
+		*m = Money(v) * Baht
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// EncodeText ส่งเป็นข้อความทศนิยม ("1250.50") ให้ Postgres แปลงเป็น NUMERIC เอง
+// (ต้องมี ไม่งั้น pgx จะมองเป็น int64 แล้วส่งหน่วยสตางค์ไปเป็นบาท)
+func (m Money) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return append(buf, m.String()...), nil
+}
+
+// EncodeBinary ใช้กับ *Money (ค่าที่ไม่บังคับ) เท่านั้น: pgx ตรวจ encoder ก่อนเช็ค pointer nil
+// ถ้ามีแค่ EncodeText แบบ value receiver การส่ง *Money ที่เป็น nil จะ panic; nil = NULL
+// ค่าที่มีจะส่งเป็น NUMERIC แบบ binary (สตางค์, exponent -2)
+func (m *Money) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return pgtype.Numeric{Int: big.NewInt(int64(*m)), Exp: -2, Status: pgtype.Present}.EncodeBinary(ci, buf)
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+func TestMoneyPointerEncodesNull(t *testing.T) {
+	ci := pgtype.NewConnInfo()
+
+	var nilMoney *Money
+	// pgx เลือก BinaryEncoder ก่อน TextEncoder และก่อนเช็ค nil pointer
+	enc, ok := interface{}(nilMoney).(pgtype.BinaryEncoder)
+	if !ok {
+		t.Fatal("*Money must implement pgtype.BinaryEncoder")
+	}
+	buf, err := enc.EncodeBinary(ci, nil)
+	if err != nil || buf != nil {
+		t.Fatalf("nil *Money encoded to %v, %v; want NULL (nil, nil)", buf, err)
+	}
+
+	tests := []Money{0, 1, 99, 125050, -1005, 100000000}
+	for _, m := range tests {
+		m := m
+		buf, err := (&m).EncodeBinary(ci, nil)
+		if err != nil {
+			t.Fatalf("EncodeBinary(%s): %v", m, err)
+		}
+		var n pgtype.Numeric
+		if err := n.DecodeBinary(ci, buf); err != nil {
+			t.Fatalf("DecodeBinary(%s): %v", m, err)
+		}
+		var s string
+		if err := n.AssignTo(&s); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseMoney(s)
+		if err != nil || got != m {
+			t.Errorf("round trip %s: got %s (%q), err %v", m, got, s, err)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"1250.5", 125050, false},
+		{" 7 ", 700, false},
+		{"0.01", 1, false},
+		// ปัดที่สตางค์แบบ half away from zero
+		{"10.005", 1001, false},
+		{"-10.005", -1001, false},
+		{"10.004", 1000, false},
+		{"-10.004", -1000, false},
+		{"0.004", 0, false},
+		{"-0.005", -1, false},
+		{"1e3", 100000, false},
+		{"12345e-2", 12345, false},
+		{"1.2345e2", 12345, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"1,000", 0, true},
+		{"1e30", 0, true}, // เกิน int64
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %s, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        Money
+		num, den int64
+		want     Money
+	}{
+		{"7 percent", 10000, 7, 100, 700},
+		{"vat inside 107", 10700, 7, 107, 700},
+		{"vat inside rounds down", 10000, 7, 107, 654}, // 654.2
+		{"vat inside rounds up", 100, 7, 107, 7},       // 6.54
+		{"half rounds away from zero", 5, 1, 2, 3},
+		{"negative half rounds away from zero", -5, 1, 2, -3},
+		{"exact third", 333, 1, 3, 111},
+		{"below half a satang", 1, 1, 3, 0},
+		{"zero", 0, 7, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.MulRatio(tt.num, tt.den); got != tt.want {
+				t.Errorf("%s.MulRatio(%d, %d) = %d, want %d", tt.m, tt.num, tt.den, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{-100, "-1.00"},
+		{125050, "1250.50"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.m), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{`1250.5`, 125050, false},
+		{`"1250.50"`, 125050, false},
+		{`10.005`, 1001, false},
+		{`null`, 0, false},
+		{`""`, 0, false},
+		{`"abc"`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			m := Money(99) // ค่าเดิมต้องถูกเขียนทับ
+			err := json.Unmarshal([]byte(tt.in), &m)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %s, want error", tt.in, m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %v", tt.in, err)
+			}
+			if m != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, m, tt.want)
+			}
+		})
+	}
+}
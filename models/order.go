@@ -11,6 +11,8 @@ const (
 	OrderStatusShipped    = "shipped"
 	OrderStatusCancelled  = "cancelled"
 
+	OrderStatusPartiallyShipped = "partially_shipped" // ส่งแล้วบางรายการ ยังมีของค้างส่ง
+
 	// Payment method
 	PayMethodCOD          = "COD"
 	PayMethodBankTransfer = "BANK_TRANSFER"
@@ -22,6 +24,11 @@ const (
 	PayStatusReview  = "review"
 	PayStatusPaid    = "paid"
 	PayStatusFailed  = "failed"
+	PayStatusVoided  = "voided"         // ยกเลิกก่อนได้รับเงิน
+	PayStatusRefund  = "refund_pending" // ได้รับเงินแล้ว รอคืนเงิน
+
+	PayStatusPartial  = "partially_paid" // รับเงินแล้วบางส่วน (มัดจำ)
+	PayStatusRefunded = "refunded"       // คืนเงินครบยอดที่รับแล้ว
 
 	// Next action types
 	NextNone          = "NONE"
@@ -33,65 +40,164 @@ const (
 // ===== Requests =====
 
 type CreateOrderItemReq struct {
-	ProductID string  `json:"product_id"`        // required
-	Quantity  int     `json:"quantity"`          // required, > 0
-	Variant   *string `json:"variant,omitempty"` // optional; nil = ไม่ส่งมา, empty string = ระบุค่าว่าง
+	ProductID string  `json:"product_id"`           // required (ยกเว้นส่ง sku มา)
+	Quantity  int     `json:"quantity"`             // required, > 0
+	VariantID *int64  `json:"variant_id,omitempty"` // สินค้าที่มี variant ต้องระบุ variant_id หรือ sku
+	SKU       string  `json:"sku,omitempty"`
+	Variant   *string `json:"variant,omitempty"` // แบบเก่า: ไซซ์เป็นข้อความ (ใช้จับคู่ options.size ถ้าไม่ส่ง variant_id/sku)
 }
 
 type CreateOrderReq struct {
-	Items         []CreateOrderItemReq `json:"items"`                    // required
+	Items         []CreateOrderItemReq `json:"items"`                    // required (ยกเว้นส่ง cart_id มา)
+	CartID        string               `json:"cart_id,omitempty"`        // checkout จากตะกร้าฝั่ง server แทนการส่ง items
 	PaymentMethod string               `json:"payment_method,omitempty"` // COD | BANK_TRANSFER | PROMPTPAY | CARD; ถ้าเว้นไว้ backend ตั้งค่า default ให้
+
+	// ที่อยู่จัดส่ง: เลือกจากสมุดที่อยู่ (address_id) หรือส่งมาทั้งก้อน (shipping_address)
+	// ถ้าไม่ส่งทั้งคู่ จะใช้ที่อยู่ default ของลูกค้า
+	AddressID       *int64           `json:"address_id,omitempty"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+	SaveAddress     bool             `json:"save_address,omitempty"` // บันทึก shipping_address ลงสมุดที่อยู่ด้วย
+
+	ShippingMethod string `json:"shipping_method,omitempty"` // code ของ shipping_methods; ว่าง = วิธีแรกที่เปิดใช้
+
+	TaxInvoice *TaxBuyer `json:"tax_invoice,omitempty"` // ขอใบกำกับภาษีเต็มรูปในนามนี้
+
+	Note string `json:"note,omitempty"` // หมายเหตุถึงร้าน (ไม่เกิน 500 ตัวอักษร)
+
+	// ใช้เครดิตร้านจ่ายบางส่วน (ไม่เกินยอดชำระ) ที่เหลือจ่ายด้วย payment_method
+	// payment_method = STORE_CREDIT คือจ่ายด้วยเครดิตทั้งหมด
+	StoreCredit Money `json:"store_credit,omitempty"`
+
+	// ใช้บัตรของขวัญจ่าย: gift_card_amount = 0 คือใช้เท่าที่มี (ไม่เกินยอดชำระ)
+	GiftCardCode   string `json:"gift_card_code,omitempty"`
+	GiftCardAmount Money  `json:"gift_card_amount,omitempty"`
+
+	// ซื้อบัตรของขวัญในออเดอร์นี้ (ไม่ต้องมี items ก็ได้, ห้าม COD) เปิดใช้เมื่อชำระครบผ่าน gateway หรือสลิปที่อนุมัติแล้ว
+	GiftCards []GiftCardPurchaseReq `json:"gift_cards,omitempty"`
+}
+
+// GuestCheckoutReq สั่งซื้อโดยไม่มีบัญชี: ต้องส่ง shipping_address มาทั้งก้อน (ไม่มีสมุดที่อยู่)
+type GuestCheckoutReq struct {
+	CreateOrderReq
+	Email string `json:"email"` // ใช้ติดต่อและผูกออเดอร์เข้าบัญชีภายหลัง
+	Phone string `json:"phone"`
+}
+
+// ConvertGuestReq token = guest_token ที่ได้ตอนสั่งซื้อ, code = รหัสยืนยันที่ส่งไปทางอีเมล
+// ขอรหัสใช้แค่ token; ตอน claim ใช้ token + code
+type ConvertGuestReq struct {
+	Token     string `json:"token"`
+	Code      string `json:"code,omitempty"`
+	FirstName string `json:"firstname,omitempty"`
+	LastName  string `json:"lastname,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+type CancelOrderReq struct {
+	Reason string `json:"reason"` // เหตุผลการยกเลิก (admin ต้องระบุ)
 }
 
 // ===== Next Action =====
 
 type NextAction struct {
-	Type        string `json:"type,omitempty"`         // NONE | UPLOAD_SLIP | SHOW_PROMPTPAY | REDIRECT_GATEWAY
-	URL         string `json:"url,omitempty"`          // ใช้ตอน REDIRECT_GATEWAY
-	QRImageURL  string `json:"qr_image_url,omitempty"` // ใช้ตอน SHOW_PROMPTPAY
-	PayloadText string `json:"payload_text,omitempty"` // ข้อความกำกับ/อธิบาย
+	Type        string     `json:"type,omitempty"`         // NONE | UPLOAD_SLIP | SHOW_PROMPTPAY | REDIRECT_GATEWAY
+	URL         string     `json:"url,omitempty"`          // ใช้ตอน REDIRECT_GATEWAY
+	QRImageURL  string     `json:"qr_image_url,omitempty"` // ใช้ตอน SHOW_PROMPTPAY
+	PayloadText string     `json:"payload_text,omitempty"` // ข้อความกำกับ/อธิบาย
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`   // ต้องชำระก่อนเวลานี้ ไม่งั้นออเดอร์ถูกยกเลิกอัตโนมัติ
 }
 
 // ===== Order / Items (DB Models / API Models) =====
 
 type Order struct {
-	ID            int64     `json:"id"`
-	UserID        string    `json:"user_id"`
-	Total         float64   `json:"total"` // แนะนำให้เป็น NUMERIC(12,2) ใน Postgres
-	Status        string    `json:"status"`
-	PaymentMethod string    `json:"payment_method"`
-	PaymentStatus string    `json:"payment_status"`
-	PaymentRef    *string   `json:"payment_ref,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID              int64            `json:"id"`
+	UserID          string           `json:"user_id"`
+	Subtotal        Money            `json:"subtotal"` // ยอดสินค้า
+	ShippingMethod  *string          `json:"shipping_method,omitempty"`
+	ShippingFee     Money            `json:"shipping_fee"`
+	Total           Money            `json:"total"`           // subtotal + shipping_fee (+ vat_amount ถ้าราคาไม่รวม VAT) + gift_card_total
+	GiftCardTotal   Money            `json:"gift_card_total"` // บัตรของขวัญที่ซื้อในออเดอร์ (ไม่คิด VAT)
+	VATRate         int              `json:"vat_rate"`
+	VATAmount       Money            `json:"vat_amount"`
+	Status          string           `json:"status"`
+	PaymentMethod   string           `json:"payment_method"`
+	PaymentStatus   string           `json:"payment_status"`
+	PaymentRef      *string          `json:"payment_ref,omitempty"`
+	PaidAmount      Money            `json:"paid_amount"`                // รับสุทธิจากสมุดรับเงิน (รับ - คืน)
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"` // snapshot ณ ตอนสั่งซื้อ (JSONB)
+	CancelReason    *string          `json:"cancel_reason,omitempty"`
+	CancelledAt     *time.Time       `json:"cancelled_at,omitempty"`
+	CancelledBy     *string          `json:"cancelled_by,omitempty"`
+	ExpiresAt       *time.Time       `json:"expires_at,omitempty"` // ยังไม่จ่ายเกินเวลานี้ → ยกเลิกอัตโนมัติ
+	TaxBuyer        *TaxBuyer        `json:"tax_buyer,omitempty"`
+	TaxInvoiceNo    *string          `json:"tax_invoice_no,omitempty"` // ออกเมื่อขอใบกำกับภาษีเต็มรูปครั้งแรก
+	TaxInvoiceAt    *time.Time       `json:"tax_invoice_at,omitempty"`
+	RefundedAmount  Money            `json:"refunded_amount"` // คืนเงินแล้วรวมทุกใบคืนสินค้า
+	CustomerNote    *string          `json:"customer_note,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
 }
 
 type OrderItem struct {
 	ID        int64   `json:"id"`
 	OrderID   int64   `json:"order_id"`
 	ProductID string  `json:"product_id"`
+	VariantID *int64  `json:"variant_id,omitempty"`
+	SKU       *string `json:"sku,omitempty"`
 	Name      string  `json:"name"`
-	Price     float64 `json:"price"` // NUMERIC(12,2) ใน DB
+	Price     Money   `json:"price"`
 	Quantity  int     `json:"quantity"`
-	Variant   *string `json:"variant,omitempty"`
+	VATAmount Money   `json:"vat_amount"`
+	Variant   *string `json:"variant,omitempty"` // ป้ายชื่อ variant ณ ตอนสั่ง เช่น "42 / white"
+
+	ShippedQuantity int `json:"shipped_quantity"` // ส่งไปแล้วรวมทุกพัสดุ
 }
 
 // ใช้ตอบกลับตอนสร้างออเดอร์ เพื่อให้ฝั่ง UI แสดงรายละเอียดได้สะดวก
 type OrderLineResp struct {
 	ProductID string  `json:"product_id"`
 	Name      string  `json:"name"`
-	Price     float64 `json:"price"`
+	Price     Money   `json:"price"`
 	Quantity  int     `json:"quantity"`
 	Variant   *string `json:"variant,omitempty"`
-	LineTotal float64 `json:"line_total"`
+	LineTotal Money   `json:"line_total"`
 }
 
 // ===== Create Order Response =====
 
 type CreateOrderResp struct {
-	OrderID    int64           `json:"order_id"`
-	Total      float64         `json:"total"`
-	Message    string          `json:"message"`
-	NextAction *NextAction     `json:"next_action,omitempty"`
-	Items      []OrderLineResp `json:"items,omitempty"` // แทน interface{} ให้เป็นโครงที่แน่นอน
+	OrderID     int64           `json:"order_id"`
+	Subtotal    Money           `json:"subtotal"`
+	ShippingFee Money           `json:"shipping_fee"`
+	Total       Money           `json:"total"`
+	VATAmount   Money           `json:"vat_amount"`
+	StoreCredit Money           `json:"store_credit,omitempty"` // เครดิตร้านที่ตัดไปแล้ว
+	GiftCard    Money           `json:"gift_card,omitempty"`    // ยอดที่ตัดจากบัตรของขวัญ
+	AmountDue   Money           `json:"amount_due"`             // ยอดที่ต้องจ่ายเพิ่ม = total - store_credit - gift_card
+	Message     string          `json:"message"`
+	NextAction  *NextAction     `json:"next_action,omitempty"`
+	GuestToken  string          `json:"guest_token,omitempty"` // guest checkout: ใช้ดูออเดอร์ (แสดงครั้งเดียว)
+	LookupURL   string          `json:"lookup_url,omitempty"`
+	Items       []OrderLineResp `json:"items,omitempty"` // แทน interface{} ให้เป็นโครงที่แน่นอน
+}
+
+// OrderSearchRow หนึ่งแถวในผลค้นหาออเดอร์หลังบ้าน (ออเดอร์ + ข้อมูลลูกค้า)
+type OrderSearchRow struct {
+	Order
+	CustomerName  string  `json:"customer_name"`
+	CustomerEmail *string `json:"customer_email,omitempty"`
+	CustomerPhone *string `json:"customer_phone,omitempty"`
+	ItemCount     int     `json:"item_count"` // จำนวนชิ้นรวม
+}
+
+// OrderSearchSummary ยอดรวมของทุกออเดอร์ที่ตรงเงื่อนไข (ไม่ขึ้นกับหน้า)
+type OrderSearchSummary struct {
+	Count int64 `json:"count"`
+	Total Money `json:"total"`
+}
+
+type OrderSearchResp struct {
+	Items      []OrderSearchRow   `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"` // ว่าง = หน้าสุดท้าย
+	Summary    OrderSearchSummary `json:"summary"`
 }
@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// Payment intent status
+const (
+	IntentRequiresPayment = "requires_payment"
+	IntentSucceeded       = "succeeded"
+	IntentFailed          = "failed"
+	IntentRefunded        = "refunded" // คืนเงินครบยอดแล้ว
+)
+
+// PaymentIntent รายการชำระเงินของออเดอร์ที่ payment gateway
+type PaymentIntent struct {
+	ID             int64     `json:"id"`
+	OrderID        int64     `json:"order_id"`
+	Provider       string    `json:"provider"`
+	ProviderRef    string    `json:"provider_ref"`
+	Amount         Money     `json:"amount"`
+	Status         string    `json:"status"`
+	RefundedAmount Money     `json:"refunded_amount"`
+	RedirectURL    *string   `json:"redirect_url,omitempty"`
+	FailureReason  *string   `json:"failure_reason,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ===== Payments ledger =====
+
+// Payment entry kind
+const (
+	PaymentKindPayment = "payment"
+	PaymentKindRefund  = "refund"
+)
+
+// Payment entry status
+const (
+	PaymentPending   = "pending" // รอผลจาก gateway
+	PaymentReview    = "review"  // รอพนักงานตรวจ (สลิป)
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentVoided    = "voided" // ยกเลิกก่อนได้ผล เช่น อัปโหลดสลิปใหม่แทน
+)
+
+// PayMethodCash ช่องทางเงินสด (ขายหน้าร้าน)
+const PayMethodCash = "CASH"
+
+// Payment หนึ่งรายการในสมุดรับ/คืนเงินของออเดอร์หรือการขาย
+type Payment struct {
+	ID          int64      `json:"id"`
+	OrderID     *int64     `json:"order_id,omitempty"`
+	SaleID      *string    `json:"sale_id,omitempty"`
+	GiftCardID  *int64     `json:"gift_card_id,omitempty"` // ค่าบัตรของขวัญที่ขายหน้าร้าน
+	Kind        string     `json:"kind"`                   // payment | refund
+	Method      string     `json:"method"`
+	Amount      Money      `json:"amount"`
+	Status      string     `json:"status"`
+	Provider    *string    `json:"provider,omitempty"`
+	ProviderRef *string    `json:"provider_ref,omitempty"`
+	SlipID      *int64     `json:"slip_id,omitempty"`
+	Note        *string    `json:"note,omitempty"`
+	CreatedBy   *string    `json:"created_by,omitempty"`
+	SettledAt   *time.Time `json:"settled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// PaymentSummary ยอดรวมจากสมุดรับ/คืนเงิน
+type PaymentSummary struct {
+	Total         Money  `json:"total"`    // ยอดที่ต้องชำระ
+	Paid          Money  `json:"paid"`     // รับแล้ว (succeeded)
+	Refunded      Money  `json:"refunded"` // คืนแล้ว (succeeded)
+	Net           Money  `json:"net"`      // paid - refunded
+	Balance       Money  `json:"balance"`  // total - paid (ติดลบ = รับเกิน)
+	PaymentStatus string `json:"payment_status,omitempty"`
+}
+
+// RecordPaymentReq พนักงานบันทึกรับเงิน (มัดจำ/เงินสด/โอน) หรือคืนเงินเอง
+type RecordPaymentReq struct {
+	Kind   string `json:"kind,omitempty"`   // payment (default) | refund
+	Method string `json:"method,omitempty"` // ออเดอร์: ต้องระบุ; การขาย: default = ช่องทางของการขาย
+	Amount Money  `json:"amount"`
+	Ref    string `json:"ref,omitempty"` // ออเดอร์: ต้องระบุ
+	Note   string `json:"note,omitempty"`
+}
+
+// ReconciliationRow ยอดคาดว่าจะได้เทียบยอดที่ได้รับจริงของหนึ่งช่องทางในวันเดียว
+type ReconciliationRow struct {
+	Method      string `json:"method"`
+	Expected    Money  `json:"expected"`    // ออเดอร์ (ไม่ถูกยกเลิก) + การขาย + บัตรของขวัญที่ขายหน้าร้านในวันนั้น
+	Received    Money  `json:"received"`    // รับเงินสำเร็จในวันนั้น (ของทุกวันที่สั่ง)
+	Refunded    Money  `json:"refunded"`    // คืนเงินสำเร็จในวันนั้น
+	Net         Money  `json:"net"`         // received - refunded
+	Difference  Money  `json:"difference"`  // net - expected
+	Outstanding Money  `json:"outstanding"` // ยอดของรายการวันนั้นที่ยังไม่ได้รับ ณ ตอนนี้
+	Orders      int    `json:"orders"`
+	Sales       int    `json:"sales"` // รวมบัตรของขวัญที่ขายหน้าร้าน
+	Payments    int    `json:"payments"`
+}
+
+type ReconciliationReport struct {
+	Date   string              `json:"date"` // YYYY-MM-DD (เวลาไทย)
+	Rows   []ReconciliationRow `json:"rows"`
+	Totals ReconciliationRow   `json:"totals"`
+}
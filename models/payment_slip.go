@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Payment slip status
+const (
+	SlipStatusSubmitted  = "submitted" // รอพนักงานตรวจ
+	SlipStatusApproved   = "approved"
+	SlipStatusRejected   = "rejected"
+	SlipStatusSuperseded = "superseded" // ลูกค้าอัปโหลดใบใหม่แทนก่อนตรวจ
+)
+
+// ผลตรวจสลิปอัตโนมัติจาก QR
+const (
+	SlipVerified   = "verified"
+	SlipSuspicious = "suspicious" // ดูเหตุผลใน VerifyNotes
+	SlipUnreadable = "unreadable"
+)
+
+type PaymentSlip struct {
+	ID            int64      `json:"id"`
+	OrderID       int64      `json:"order_id"`
+	ContentType   string     `json:"content_type"`
+	FileSize      int        `json:"file_size"`
+	Amount        *Money     `json:"amount,omitempty"` // ยอดที่ลูกค้าแจ้ง
+	TransferredAt *time.Time `json:"transferred_at,omitempty"`
+	BankRef       *string    `json:"bank_ref,omitempty"`
+	QRRef         *string    `json:"qr_ref,omitempty"` // เลขอ้างอิงที่อ่านจาก QR บนสลิป
+	QRBankCode    *string    `json:"qr_bank_code,omitempty"`
+	Verification  *string    `json:"verification,omitempty"` // verified | suspicious | unreadable
+	VerifyNotes   []string   `json:"verification_notes"`     // เหตุผลที่ถูกตั้งเป็น suspicious/unreadable
+	Status        string     `json:"status"`
+	Reason        *string    `json:"reason,omitempty"`
+	UploadedBy    *string    `json:"uploaded_by,omitempty"`
+	ReviewedBy    *string    `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ImageURL      string     `json:"image_url"`
+}
+
+// PaymentSlipQueueItem หนึ่งแถวในคิวตรวจสลิป พร้อมข้อมูลออเดอร์ที่ต้องเทียบ
+type PaymentSlipQueueItem struct {
+	PaymentSlip
+	OrderTotal     Money   `json:"order_total"`
+	OrderStatus    string  `json:"order_status"`
+	PaymentStatus  string  `json:"payment_status"`
+	UserID         string  `json:"user_id"`
+	CustomerName   *string `json:"customer_name,omitempty"`
+	AmountMismatch bool    `json:"amount_mismatch"` // ยอดที่แจ้งไม่ตรงยอดออเดอร์
+}
+
+type ApproveSlipReq struct {
+	Ref    string `json:"ref,omitempty"`    // เลขอ้างอิงการโอน; ว่าง = ใช้ qr_ref, bank_ref ที่ลูกค้าแจ้ง หรือ SLIP-<id>
+	Amount Money  `json:"amount,omitempty"` // ยอดที่ได้รับจริง; ว่าง = ยอดที่ลูกค้าแจ้ง หรือยอดค้างชำระ
+}
+
+type RejectSlipReq struct {
+	Reason string `json:"reason"` // required; ลูกค้าเห็นข้อความนี้
+}
@@ -10,9 +10,10 @@ type Product struct {
 	Category      *string    `json:"category,omitempty"`
 	Gender        *string    `json:"gender,omitempty"`
 	Quantity      int        `json:"quantity"`
-	CostPrice     *float64   `json:"cost_price,omitempty"`
-	SellPrice     float64    `json:"sell_price"`
-	OriginalPrice *float64   `json:"original_price,omitempty"`
+	WeightGrams   int        `json:"weight_grams"` // ใช้คิดค่าส่งแบบตามน้ำหนัก
+	CostPrice     *Money     `json:"cost_price,omitempty"`
+	SellPrice     Money      `json:"sell_price"`
+	OriginalPrice *Money     `json:"original_price,omitempty"`
 	Image         *string    `json:"image,omitempty"`
 	Recommended   bool       `json:"recommended"`
 	Popularity    int        `json:"popularity_score"`
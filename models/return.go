@@ -0,0 +1,114 @@
+package models
+
+import "time"
+
+// Return (RMA) status: requested → approved → received → refunded
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received" // รับของคืนแล้ว รอคืนเงิน
+	ReturnStatusRefunded  = "refunded"
+	ReturnStatusCancelled = "cancelled" // ลูกค้ายกเลิกเองก่อนอนุมัติ
+
+	ReturnSourceOrder = "order"
+	ReturnSourceSale  = "sale"
+
+	DispositionRestock  = "restock"   // สภาพดี คืนเข้าสต็อก
+	DispositionWriteOff = "write_off" // เสียหาย/ขายต่อไม่ได้ ไม่คืนสต็อก
+)
+
+// ReturnReasons เหตุผลการคืนที่รับได้
+var ReturnReasons = []string{
+	"defective",          // สินค้าชำรุด
+	"damaged_in_transit", // เสียหายระหว่างขนส่ง
+	"wrong_item",         // ส่งผิดรายการ
+	"wrong_size",         // ไซซ์ไม่พอดี
+	"not_as_described",   // ไม่ตรงตามรายละเอียด
+	"changed_mind",       // เปลี่ยนใจ
+	"other",
+}
+
+// ===== Requests =====
+
+type ReturnItemReq struct {
+	OrderItemID *int64 `json:"order_item_id,omitempty"` // ออเดอร์: ต้องระบุ; การขายหน้าร้าน: ไม่ต้อง
+	Quantity    int    `json:"quantity"`
+	Reason      string `json:"reason,omitempty"` // ว่าง = ใช้ reason ของใบคืน
+}
+
+type CreateReturnReq struct {
+	OrderID int64           `json:"order_id,omitempty"` // admin: ระบุ order_id หรือ sale_id
+	SaleID  string          `json:"sale_id,omitempty"`
+	Reason  string          `json:"reason"`
+	Note    string          `json:"note,omitempty"`
+	Items   []ReturnItemReq `json:"items"`
+}
+
+type ReturnRefundAmountReq struct {
+	ItemID       int64 `json:"item_id"`
+	RefundAmount Money `json:"refund_amount"`
+}
+
+// ReviewReturnReq อนุมัติ/ปฏิเสธ; ตอนอนุมัติปรับยอดคืนรายบรรทัดได้ (ไม่เกินยอดที่จ่าย)
+type ReviewReturnReq struct {
+	Note  string                  `json:"note,omitempty"`
+	Items []ReturnRefundAmountReq `json:"items,omitempty"`
+}
+
+type ReturnDispositionReq struct {
+	ItemID      int64  `json:"item_id"`
+	Disposition string `json:"disposition"` // restock | write_off
+}
+
+type ReceiveReturnReq struct {
+	Items []ReturnDispositionReq `json:"items"` // ต้องระบุครบทุกบรรทัด
+}
+
+type RefundReturnReq struct {
+	RefundShipping bool   `json:"refund_shipping,omitempty"` // คืนค่าส่งด้วย (ออเดอร์เท่านั้น, ครั้งเดียวต่อออเดอร์)
+	Ref            string `json:"ref,omitempty"`             // เลขอ้างอิงการโอนคืน
+	StoreCredit    bool   `json:"store_credit,omitempty"`    // คืนเป็นเครดิตร้านแทนเงิน (ลูกค้าต้องมีบัญชี)
+}
+
+// ===== Responses / Entities =====
+
+type ReturnItem struct {
+	ID           int64   `json:"id"`
+	ReturnID     int64   `json:"return_id"`
+	OrderItemID  *int64  `json:"order_item_id,omitempty"`
+	ProductID    string  `json:"product_id"`
+	VariantID    *int64  `json:"variant_id,omitempty"`
+	Name         string  `json:"name"`
+	Quantity     int     `json:"quantity"`
+	Reason       string  `json:"reason"`
+	PaidAmount   Money   `json:"paid_amount"`   // ยอดที่จ่ายสำหรับจำนวนที่คืน
+	RefundAmount Money   `json:"refund_amount"` // ยอดที่จะคืน (≤ paid_amount)
+	Disposition  *string `json:"disposition,omitempty"`
+}
+
+type ReturnRequest struct {
+	ID             int64        `json:"id"`
+	Source         string       `json:"source"`
+	OrderID        *int64       `json:"order_id,omitempty"`
+	SaleID         *string      `json:"sale_id,omitempty"`
+	CustomerID     *string      `json:"customer_id,omitempty"`
+	Status         string       `json:"status"`
+	Reason         string       `json:"reason"`
+	Note           *string      `json:"note,omitempty"`
+	RequestedBy    *string      `json:"requested_by,omitempty"`
+	ReviewNote     *string      `json:"review_note,omitempty"`
+	ReviewedBy     *string      `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time   `json:"reviewed_at,omitempty"`
+	ReceivedBy     *string      `json:"received_by,omitempty"`
+	ReceivedAt     *time.Time   `json:"received_at,omitempty"`
+	RefundAmount   Money        `json:"refund_amount"` // ยอดที่คืนจริง (หลัง refunded)
+	RefundShipping bool         `json:"refund_shipping"`
+	RefundRef      *string      `json:"refund_ref,omitempty"`
+	RefundToCredit bool         `json:"refund_to_credit"` // คืนเป็นเครดิตร้าน
+	RefundedBy     *string      `json:"refunded_by,omitempty"`
+	RefundedAt     *time.Time   `json:"refunded_at,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Items          []ReturnItem `json:"items,omitempty"`
+}
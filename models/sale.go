@@ -3,13 +3,43 @@ package models
 import "time"
 
 type Sale struct {
-	ID         int       `json:"id"`
-	SaleID     string    `json:"sale_id"`
-	EmployeeID string    `json:"employee_id"`
-	CustomerID string    `json:"customer_id"`
-	ProductID  string    `json:"product_id"`
-	Quantity   int       `json:"quantity"`
-	TotalPrice float64   `json:"total_price"`
-	SaleDate   time.Time `json:"sale_date"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	SaleID         string     `json:"sale_id"`
+	EmployeeID     string     `json:"employee_id"` // ตอนสร้างใช้พนักงานจาก token
+	CustomerID     string     `json:"customer_id"`
+	ProductID      string     `json:"product_id"`
+	VariantID      *int64     `json:"variant_id,omitempty"`
+	Quantity       int        `json:"quantity"`
+	TotalPrice     Money      `json:"total_price"` // ยอดที่เก็บจากลูกค้า คิดจากราคาสินค้า/variant × จำนวน (ไม่รับจาก request); ราคาไม่รวม VAT ระบบบวก VAT ให้
+	VATRate        int        `json:"vat_rate"`
+	VATAmount      Money      `json:"vat_amount"`
+	VATInclusive   bool       `json:"vat_inclusive"`       // false = ราคาสินค้าไม่รวม VAT, total_price บวก VAT ให้แล้ว
+	TaxBuyer       *TaxBuyer  `json:"tax_buyer,omitempty"` // ขอใบกำกับภาษีเต็มรูป
+	TaxInvoiceNo   *string    `json:"tax_invoice_no,omitempty"`
+	TaxInvoiceAt   *time.Time `json:"tax_invoice_at,omitempty"`
+	RefundedAmount Money      `json:"refunded_amount"`            // คืนเงินแล้วจากการคืนสินค้า
+	PaymentMethod  string     `json:"payment_method"`             // CASH (default) | CARD | PROMPTPAY | BANK_TRANSFER | STORE_CREDIT | GIFT_CARD
+	StoreCredit    Money      `json:"store_credit,omitempty"`     // request: เครดิตร้านที่ใช้ (ต้องมี customer_id) ที่เหลือจ่ายด้วย payment_method
+	GiftCardCode   string     `json:"gift_card_code,omitempty"`   // request: บัตรของขวัญที่ใช้จ่าย (ตัดก่อนเครดิตร้าน)
+	GiftCardAmount Money      `json:"gift_card_amount,omitempty"` // request: ยอดที่ใช้จากบัตร; 0 = เท่าที่มี
+	VoidedAt       *time.Time `json:"voided_at,omitempty"`        // ยกเลิกการขายแล้ว (คืนของ+คืนเงินครบ)
+	VoidedBy       *string    `json:"voided_by,omitempty"`
+	VoidReason     *string    `json:"void_reason,omitempty"`
+	SaleDate       time.Time  `json:"sale_date"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// UpdateSaleReq แก้ได้เฉพาะลูกค้า; สินค้า/จำนวน/ยอดเงินผูกกับสต็อกและสมุดรับเงินแล้ว ต้อง void แล้วขายใหม่
+// ฟิลด์อื่นส่งมาได้ถ้าค่าเท่าเดิม (เช่นฟอร์มที่ส่งทั้งก้อน)
+type UpdateSaleReq struct {
+	CustomerID *string `json:"customer_id"`
+	EmployeeID *string `json:"employee_id"`
+	ProductID  *string `json:"product_id"`
+	Quantity   *int    `json:"quantity"`
+	TotalPrice *Money  `json:"total_price"`
+}
+
+// VoidSaleReq เหตุผลที่ยกเลิกการขาย (required)
+type VoidSaleReq struct {
+	Reason string `json:"reason"`
 }
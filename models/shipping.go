@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// วิธีคิดค่าส่ง (shipping_methods.rate_type)
+const (
+	ShipRateFlat     = "flat"     // ราคาเดียว base_fee
+	ShipRateWeight   = "weight"   // ขั้นตามน้ำหนักรวม (กรัม)
+	ShipRateQuantity = "quantity" // ขั้นตามจำนวนชิ้นรวม
+)
+
+// ShippingTier ขั้นราคา: ใช้ Fee เมื่อน้ำหนัก/จำนวน <= UpTo (UpTo = 0 คือไม่จำกัด)
+type ShippingTier struct {
+	UpTo int   `json:"up_to"`
+	Fee  Money `json:"fee"`
+}
+
+type ShippingMethod struct {
+	ID        int64          `json:"id"`
+	Code      string         `json:"code"` // เช่น KERRY_STD, FLASH, EMS
+	Name      string         `json:"name"`
+	Carrier   string         `json:"carrier"`
+	RateType  string         `json:"rate_type"` // flat | weight | quantity
+	BaseFee   Money          `json:"base_fee"`
+	Tiers     []ShippingTier `json:"tiers,omitempty"`     // JSONB
+	FreeOver  *Money         `json:"free_over,omitempty"` // ยอดสินค้าถึงเท่านี้ส่งฟรี
+	Active    bool           `json:"active"`
+	SortOrder int            `json:"sort_order"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Shipment การจัดส่งหนึ่งครั้ง (เลขพัสดุ) ของออเดอร์
+type Shipment struct {
+	ID         int64     `json:"id"`
+	OrderID    int64     `json:"order_id"`
+	Carrier    string    `json:"carrier"`
+	TrackingNo string    `json:"tracking_no"`
+	Note       *string   `json:"note,omitempty"`
+	ShippedBy  *string   `json:"shipped_by,omitempty"`
+	ShippedAt  time.Time `json:"shipped_at"`
+
+	Items []ShipmentItem `json:"items,omitempty"`
+}
+
+// ShipmentItem รายการสินค้าและจำนวนในพัสดุหนึ่งกล่อง
+type ShipmentItem struct {
+	OrderItemID int64   `json:"order_item_id"`
+	ProductID   string  `json:"product_id"`
+	Name        string  `json:"name"`
+	Variant     *string `json:"variant,omitempty"`
+	Quantity    int     `json:"quantity"`
+}
+
+type ShipItemReq struct {
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+}
+
+type ShipOrderReq struct {
+	Carrier    string `json:"carrier"`
+	TrackingNo string `json:"tracking_no"`
+	Note       string `json:"note,omitempty"`
+
+	// ว่าง = ส่งของที่เหลือทั้งหมด; ระบุเพื่อแยกส่งบางรายการ/บางจำนวน
+	Items []ShipItemReq `json:"items,omitempty"`
+}
+
+type ShippingQuoteReq struct {
+	Items []CreateOrderItemReq `json:"items"`
+}
+
+type ShippingQuote struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Carrier string `json:"carrier"`
+	Fee     Money  `json:"fee"`
+}
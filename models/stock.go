@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ประเภทการเคลื่อนไหวสต็อก (stock_movements.kind)
+const (
+	StockMoveSale       = "sale"       // ขายหน้าร้าน (POS)
+	StockMoveOrder      = "order"      // ออเดอร์ออนไลน์
+	StockMoveCancel     = "cancel"     // ยกเลิกออเดอร์ คืนของเข้าสต็อก
+	StockMoveAdjustment = "adjustment" // ปรับยอด/นับสต็อก
+	StockMoveReceive    = "receive"    // รับของเข้า
+	StockMoveReturn     = "return"     // ลูกค้าคืนสินค้า
+)
+
+// StockMovement หนึ่งแถวใน ledger (append-only; ห้าม UPDATE/DELETE)
+type StockMovement struct {
+	ID             int64     `json:"id"`
+	ProductID      string    `json:"product_id"`
+	VariantID      *int64    `json:"variant_id,omitempty"`
+	Kind           string    `json:"kind"`
+	QuantityChange int       `json:"quantity_change"` // + เข้า, - ออก
+	BalanceAfter   int       `json:"balance_after"`   // products.quantity หลังรายการนี้
+	RefType        *string   `json:"ref_type,omitempty"`
+	RefID          *string   `json:"ref_id,omitempty"`
+	Note           *string   `json:"note,omitempty"`
+	CreatedBy      *string   `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
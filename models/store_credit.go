@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// PayMethodStoreCredit ชำระด้วยเครดิตร้าน (ตัดจาก store_credit_accounts ตอนสร้างออเดอร์/การขาย)
+const PayMethodStoreCredit = "STORE_CREDIT"
+
+// Store credit transaction kind
+const (
+	StoreCreditIssue   = "issue"   // ออกเครดิตแทนการคืนเงิน (คืนสินค้า)
+	StoreCreditRedeem  = "redeem"  // ใช้จ่ายออเดอร์/การขาย
+	StoreCreditRestore = "restore" // คืนเครดิตที่ใช้ไปเมื่อออเดอร์ถูกยกเลิก
+	StoreCreditAdjust  = "adjust"  // พนักงานปรับยอด (ต้องมีเหตุผล)
+)
+
+// StoreCreditTransaction หนึ่งรายการใน ledger เครดิตร้าน (แก้ไข/ลบไม่ได้)
+type StoreCreditTransaction struct {
+	ID           int64     `json:"id"`
+	CustomerID   string    `json:"customer_id"`
+	Kind         string    `json:"kind"`
+	Amount       Money     `json:"amount"` // + เข้า, - ใช้ไป
+	BalanceAfter Money     `json:"balance_after"`
+	OrderID      *int64    `json:"order_id,omitempty"`
+	SaleID       *string   `json:"sale_id,omitempty"`
+	ReturnID     *int64    `json:"return_id,omitempty"`
+	Reason       *string   `json:"reason,omitempty"`
+	CreatedBy    *string   `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StoreCreditWallet ยอดคงเหลือพร้อมรายการล่าสุด
+type StoreCreditWallet struct {
+	CustomerID   string                   `json:"customer_id"`
+	Balance      Money                    `json:"balance"`
+	Transactions []StoreCreditTransaction `json:"transactions"`
+}
+
+// AdjustStoreCreditReq พนักงานเพิ่ม (+) หรือลด (-) เครดิต
+type AdjustStoreCreditReq struct {
+	Amount Money  `json:"amount"`
+	Reason string `json:"reason"` // required
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// TaxBuyer ข้อมูลผู้ซื้อที่ขอใบกำกับภาษีเต็มรูป
+type TaxBuyer struct {
+	Name    string `json:"name"`
+	TaxID   string `json:"tax_id"`           // เลขประจำตัวผู้เสียภาษี 13 หลัก
+	Branch  string `json:"branch,omitempty"` // รหัสสาขา 5 หลัก; ว่าง = สำนักงานใหญ่ (00000)
+	Address string `json:"address"`
+}
+
+// TaxSettings ตั้งค่าภาษีมูลค่าเพิ่มของร้าน
+type TaxSettings struct {
+	VATRegistered    bool      `json:"vat_registered"`     // ไม่ได้จด VAT = ไม่คิด VAT และออกใบกำกับภาษีไม่ได้
+	VATRate          int       `json:"vat_rate"`           // เปอร์เซ็นต์ เช่น 7
+	PricesIncludeVAT bool      `json:"prices_include_vat"` // true = ราคาขายรวม VAT แล้ว, false = บวก VAT เพิ่มตอนขาย
+	UpdatedBy        *string   `json:"updated_by,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type UpdateTaxSettingsReq struct {
+	VATRegistered    *bool `json:"vat_registered"`
+	VATRate          *int  `json:"vat_rate"`
+	PricesIncludeVAT *bool `json:"prices_include_vat"`
+}
+
+// EffectiveRate อัตรา VAT ที่ใช้คิดจริง (0 ถ้าไม่ได้จด VAT)
+func (s TaxSettings) EffectiveRate() int {
+	if !s.VATRegistered {
+		return 0
+	}
+	return s.VATRate
+}
+
+// VATOf VAT ของยอดเงิน amount ตามการตั้งค่า: ราคารวม VAT → amount×rate/(100+rate), ไม่รวม → amount×rate/100
+func (s TaxSettings) VATOf(amount Money) Money {
+	rate := int64(s.EffectiveRate())
+	if rate == 0 {
+		return 0
+	}
+	if s.PricesIncludeVAT {
+		return amount.MulRatio(rate, 100+rate)
+	}
+	return amount.MulRatio(rate, 100)
+}
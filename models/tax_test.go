@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestTaxSettingsVATOf(t *testing.T) {
+	inclusive := TaxSettings{VATRegistered: true, VATRate: 7, PricesIncludeVAT: true}
+	exclusive := TaxSettings{VATRegistered: true, VATRate: 7}
+
+	tests := []struct {
+		name   string
+		s      TaxSettings
+		amount Money
+		want   Money
+	}{
+		{"not registered", TaxSettings{VATRate: 7, PricesIncludeVAT: true}, 10700, 0},
+		{"registered at zero rate", TaxSettings{VATRegistered: true}, 10700, 0},
+
+		{"inclusive exact", inclusive, 10700, 700},
+		{"inclusive rounds down", inclusive, 10000, 654}, // 654.21
+		{"inclusive rounds up", inclusive, 100, 7},       // 6.54
+		{"inclusive small amount", inclusive, 50, 3},     // 3.27
+		{"inclusive refund", inclusive, -10000, -654},
+
+		{"exclusive exact", exclusive, 10000, 700},
+		{"exclusive rounds up", exclusive, 9999, 700}, // 699.93
+		{"exclusive half satang", exclusive, 50, 4},   // 3.5
+		{"exclusive below half satang", exclusive, 7, 0},
+		{"exclusive refund half satang", exclusive, -50, -4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.VATOf(tt.amount); got != tt.want {
+				t.Errorf("VATOf(%s) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxSettingsEffectiveRate(t *testing.T) {
+	if got := (TaxSettings{VATRate: 7}).EffectiveRate(); got != 0 {
+		t.Errorf("unregistered rate = %d, want 0", got)
+	}
+	if got := (TaxSettings{VATRegistered: true, VATRate: 7}).EffectiveRate(); got != 7 {
+		t.Errorf("registered rate = %d, want 7", got)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// ประเภทเหตุการณ์ใน timeline ของออเดอร์
+const (
+	TimelineCreated       = "created"
+	TimelineStatus        = "status"         // เปลี่ยนสถานะออเดอร์
+	TimelinePaymentStatus = "payment_status" // เปลี่ยนสถานะการชำระเงิน
+	TimelineShipment      = "shipment"
+	TimelineComment       = "comment"
+	TimelineReturn        = "return"
+	TimelineRefund        = "refund"
+	TimelineTaxInvoice    = "tax_invoice"
+)
+
+type OrderComment struct {
+	ID         int64     `json:"id"`
+	OrderID    int64     `json:"order_id"`
+	AuthorID   *string   `json:"author_id,omitempty"`
+	AuthorRole *string   `json:"author_role,omitempty"`
+	Body       string    `json:"body"`
+	Internal   bool      `json:"internal"` // true = เห็นเฉพาะพนักงาน
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CreateOrderCommentReq struct {
+	Body     string `json:"body"`
+	Internal *bool  `json:"internal,omitempty"` // default true
+}
+
+// TimelineEntry หนึ่งเหตุการณ์ของออเดอร์ เรียงตามเวลา
+type TimelineEntry struct {
+	At       time.Time   `json:"at"`
+	Type     string      `json:"type"`
+	Actor    *string     `json:"actor,omitempty"`
+	From     *string     `json:"from,omitempty"` // status / payment_status
+	To       string      `json:"to,omitempty"`
+	Text     string      `json:"text,omitempty"` // ข้อความ/รายละเอียด
+	Internal bool        `json:"internal,omitempty"`
+	Ref      interface{} `json:"ref,omitempty"` // เช่น shipment, comment id, return id
+}
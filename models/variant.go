@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ProductVariant ตัวเลือกของสินค้า (เช่น ไซซ์/สี) ที่มีสต็อกและราคาแยก
+// products.quantity = ผลรวม quantity ของทุก variant ของสินค้านั้น
+type ProductVariant struct {
+	ID            int64             `json:"id"`
+	ProductID     string            `json:"product_id"`
+	SKU           string            `json:"sku"`
+	Options       map[string]string `json:"options"` // เช่น {"size": "42", "color": "white"}
+	Quantity      int               `json:"quantity"`
+	PriceOverride *Money            `json:"price_override,omitempty"` // nil = ใช้ products.sell_price
+	Barcode       *string           `json:"barcode,omitempty"`
+	Active        bool              `json:"active"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+type ProductVariantReq struct {
+	SKU           string            `json:"sku"`
+	Options       map[string]string `json:"options"`
+	Quantity      int               `json:"quantity"` // ใช้ตอนสร้างเท่านั้น (รับของเข้า); แก้ยอดใช้ /quantity
+	PriceOverride *Money            `json:"price_override,omitempty"`
+	Barcode       *string           `json:"barcode,omitempty"`
+	Active        *bool             `json:"active,omitempty"`
+}
+
+// VariantSize ไซซ์ที่แสดงในหน้าร้าน
+type VariantSize struct {
+	VariantID int64  `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Size      string `json:"size"`
+	Price     Money  `json:"price"`
+	Stock     int    `json:"stock"`
+	InStock   bool   `json:"in_stock"`
+}
@@ -0,0 +1,159 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FakeName ชื่อ provider จำลอง
+const FakeName = "fake"
+
+// FakeSignatureHeader header ลายเซ็นของ webhook จาก provider จำลอง
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// ผลลัพธ์ที่จำลองได้จากหน้าจ่ายเงินปลอม
+const (
+	FakeSucceed   = "success"
+	FakeFail      = "fail"
+	FakeDuplicate = "duplicate" // แจ้ง success ซ้ำสองครั้ง (ทดสอบ idempotency)
+)
+
+// Fake provider จำลองสำหรับทดสอบแบบ offline: หน้าจ่ายเงินอยู่ในแอปเอง (CheckoutPath)
+// และส่ง webhook ที่เซ็นแล้วกลับมาที่ WebhookURL ของ intent เมื่อเรียก Simulate
+type Fake struct {
+	Secret       string
+	CheckoutPath string // เช่น /api/payments/fake/checkout; RedirectURL = CheckoutPath/<ref>
+	Client       *http.Client
+
+	mu      sync.Mutex
+	intents map[string]IntentRequest
+	refunds map[string]string // idempotency key → เลขอ้างอิงการคืนเงิน
+}
+
+// NewFake สร้าง provider จำลอง
+func NewFake(secret, checkoutPath string) *Fake {
+	return &Fake{
+		Secret:       secret,
+		CheckoutPath: checkoutPath,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		intents:      map[string]IntentRequest{},
+		refunds:      map[string]string{},
+	}
+}
+
+func (f *Fake) Name() string { return FakeName }
+
+func (f *Fake) CreateIntent(ctx context.Context, req IntentRequest) (Intent, error) {
+	ref := "fk_" + randomHex(12)
+	f.mu.Lock()
+	f.intents[ref] = req
+	f.mu.Unlock()
+	return Intent{Ref: ref, RedirectURL: f.CheckoutPath + "/" + ref}, nil
+}
+
+func (f *Fake) VerifyWebhook(body []byte, header func(string) string) (Event, error) {
+	var ev Event
+	if err := VerifySignature(f.Secret, header(FakeSignatureHeader), body, time.Now()); err != nil {
+		return ev, err
+	}
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return ev, fmt.Errorf("payment: invalid webhook body: %w", err)
+	}
+	return ev, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, intentRef string, amount int64, idempotencyKey string) (string, error) {
+	req, ok := f.Intent(intentRef)
+	if !ok {
+		return "", ErrUnknownIntent
+	}
+	if amount <= 0 || amount > req.Amount {
+		return "", fmt.Errorf("payment: invalid refund amount")
+	}
+	f.mu.Lock()
+	if ref, ok := f.refunds[idempotencyKey]; ok && idempotencyKey != "" {
+		f.mu.Unlock()
+		return ref, nil
+	}
+	refundRef := "fkr_" + randomHex(12)
+	if idempotencyKey != "" {
+		f.refunds[idempotencyKey] = refundRef
+	}
+	f.mu.Unlock()
+	// แจ้งผลคืนเงินแบบ async เหมือน gateway จริง
+	go f.deliver(req.WebhookURL, Event{
+		ID: "evt_" + randomHex(12), Type: EventRefundSucceeded, IntentRef: intentRef, Amount: amount, RefundRef: refundRef,
+	})
+	return refundRef, nil
+}
+
+// Intent ข้อมูลที่ใช้สร้าง intent (สำหรับหน้าจ่ายเงินปลอม)
+func (f *Fake) Intent(ref string) (IntentRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	req, ok := f.intents[ref]
+	return req, ok
+}
+
+// Simulate จำลองลูกค้าจ่ายเงิน: ส่ง webhook ผล outcome หลัง delay (0 = ทันที แต่ไม่บล็อก)
+func (f *Fake) Simulate(ref, outcome string, delay time.Duration) error {
+	req, ok := f.Intent(ref)
+	if !ok {
+		return ErrUnknownIntent
+	}
+	ev := Event{ID: "evt_" + randomHex(12), Type: EventPaymentSucceeded, IntentRef: ref, Amount: req.Amount}
+	times := 1
+	switch outcome {
+	case FakeSucceed:
+	case FakeDuplicate:
+		times = 2
+	case FakeFail:
+		ev.Type, ev.Reason = EventPaymentFailed, "card_declined"
+	default:
+		return fmt.Errorf("payment: unknown outcome %q", outcome)
+	}
+	time.AfterFunc(delay, func() {
+		for i := 0; i < times; i++ {
+			f.deliver(req.WebhookURL, ev)
+		}
+	})
+	return nil
+}
+
+// deliver POST event ที่เซ็นแล้วไปที่ url; ลองใหม่สูงสุด 3 ครั้งถ้าไม่ได้ 2xx
+func (f *Fake) deliver(url string, ev Event) {
+	body, _ := json.Marshal(ev)
+	for attempt := 1; attempt <= 3; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			log.Println("fake payment webhook:", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(FakeSignatureHeader, Sign(f.Secret, body, time.Now()))
+		resp, err := f.Client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 == 2 {
+				return
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		log.Printf("fake payment webhook %s (attempt %d): %v", ev.ID, attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
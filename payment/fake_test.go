@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFakeRefundIdempotency(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev Event
+		json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	f := NewFake("whsec_test", "/checkout")
+	ctx := context.Background()
+	in, err := f.CreateIntent(ctx, IntentRequest{OrderRef: "1", Amount: 50000, Currency: "THB", WebhookURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		amount  int64
+		key     string
+		wantErr bool
+		sameAs  string // key ที่ต้องได้เลขอ้างอิงเดียวกัน
+	}{
+		{"first refund", 10000, "return-1", false, ""},
+		{"retry with same key", 10000, "return-1", false, "return-1"},
+		{"another return", 5000, "return-2", false, ""},
+		{"more than paid", 60000, "return-3", true, ""},
+		{"zero amount", 0, "return-4", true, ""},
+	}
+	refs := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := f.Refund(ctx, in.Ref, tt.amount, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.sameAs != "" && ref != refs[tt.sameAs] {
+				t.Errorf("ref = %q, want %q from the first call", ref, refs[tt.sameAs])
+			}
+			if tt.sameAs == "" {
+				for k, r := range refs {
+					if r == ref {
+						t.Errorf("ref %q already used by %s", ref, k)
+					}
+				}
+			}
+			refs[tt.key] = ref
+		})
+	}
+
+	if _, err := f.Refund(ctx, "fk_unknown", 100, "return-9"); err != ErrUnknownIntent {
+		t.Errorf("unknown intent: err = %v, want %v", err, ErrUnknownIntent)
+	}
+
+	// การเรียกซ้ำด้วย key เดิมต้องไม่ส่ง webhook คืนเงินซ้ำ
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("got %d refund webhooks, want 2", len(events))
+	}
+	for _, ev := range events {
+		if ev.Type != EventRefundSucceeded {
+			t.Errorf("event type = %q", ev.Type)
+		}
+	}
+}
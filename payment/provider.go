@@ -0,0 +1,135 @@
+// Package payment กำหนด interface ของผู้ให้บริการรับชำระเงิน (payment gateway) และ provider จำลองสำหรับทดสอบ
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("payment: unknown provider")
+	ErrInvalidSignature = errors.New("payment: invalid webhook signature")
+	ErrUnknownIntent    = errors.New("payment: unknown payment intent")
+	ErrNoWebhookSecret  = errors.New("payment: webhook secret is not configured")
+)
+
+// ชนิด event จาก webhook
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventRefundSucceeded  = "refund.succeeded"
+)
+
+// IntentRequest ข้อมูลที่ใช้สร้างรายการชำระเงิน; จำนวนเงินเป็นสตางค์
+type IntentRequest struct {
+	OrderRef   string // เลขออเดอร์ของเรา
+	Amount     int64
+	Currency   string // THB
+	ReturnURL  string // หน้าที่ลูกค้ากลับมาหลังจ่าย
+	WebhookURL string // ให้ provider แจ้งผล
+}
+
+// Intent รายการชำระเงินฝั่ง provider
+type Intent struct {
+	Ref         string // id ของ provider
+	RedirectURL string // ส่งลูกค้าไปจ่ายที่นี่
+}
+
+// Event ผลที่ provider แจ้งกลับผ่าน webhook
+type Event struct {
+	ID        string `json:"id"` // ใช้กันประมวลผลซ้ำ
+	Type      string `json:"type"`
+	IntentRef string `json:"intent_ref"`
+	Amount    int64  `json:"amount"` // สตางค์
+	RefundRef string `json:"refund_ref,omitempty"`
+	Reason    string `json:"reason,omitempty"` // กรณีจ่ายไม่สำเร็จ
+}
+
+// Provider ผู้ให้บริการรับชำระเงิน
+type Provider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (Intent, error)
+	// VerifyWebhook ตรวจลายเซ็นแล้วแปลง body เป็น Event; header คืนค่า header ตามชื่อ
+	VerifyWebhook(body []byte, header func(string) string) (Event, error)
+	// Refund คืนเงินบางส่วนหรือทั้งหมดของ intent; คืนเลขอ้างอิงการคืนเงิน
+	// idempotencyKey เดิม = คำขอเดิม: gateway ต้องคืนผลเดิมโดยไม่คืนเงินซ้ำ (เรียกซ้ำได้หลังไม่รู้ผล)
+	Refund(ctx context.Context, intentRef string, amount int64, idempotencyKey string) (string, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+// Register เพิ่ม provider (ชื่อซ้ำ = แทนที่)
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Get คืน provider ตามชื่อ
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if p, ok := providers[name]; ok {
+		return p, nil
+	}
+	return nil, ErrUnknownProvider
+}
+
+// ===== ลายเซ็น webhook แบบ HMAC-SHA256 =====
+// header: "t=<unix>,v1=<hex(hmac(secret, "<unix>.<body>"))>"
+
+// SignatureTolerance อายุสูงสุดของลายเซ็น กัน replay
+const SignatureTolerance = 5 * time.Minute
+
+// Sign สร้างค่า header ลายเซ็นของ body ณ เวลา at
+func Sign(secret string, body []byte, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + hmacHex(secret, ts, body)
+}
+
+// VerifySignature ตรวจค่า header ลายเซ็นเทียบกับ body; secret ว่าง = ErrNoWebhookSecret (ไม่รับ event ใด ๆ)
+func VerifySignature(secret, header string, body []byte, now time.Time) error {
+	if secret == "" {
+		return ErrNoWebhookSecret
+	}
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > SignatureTolerance || d < -SignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(hmacHex(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func hmacHex(secret, ts string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"payment.succeeded","intent_ref":"fk_1","amount":12050}`)
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{"valid", secret, Sign(secret, body, now), body, nil},
+		{"valid with spaces", secret, strings.ReplaceAll(Sign(secret, body, now), ",", ", "), body, nil},
+		{"just inside past tolerance", secret, Sign(secret, body, now.Add(-SignatureTolerance)), body, nil},
+		{"just inside future tolerance", secret, Sign(secret, body, now.Add(SignatureTolerance)), body, nil},
+		{"expired", secret, Sign(secret, body, now.Add(-SignatureTolerance-time.Second)), body, ErrInvalidSignature},
+		{"too far in the future", secret, Sign(secret, body, now.Add(SignatureTolerance+time.Second)), body, ErrInvalidSignature},
+		{"tampered body", secret, Sign(secret, body, now), []byte(`{"type":"payment.succeeded","intent_ref":"fk_1","amount":99999}`), ErrInvalidSignature},
+		{"forged with another secret", secret, Sign("attacker", body, now), body, ErrInvalidSignature},
+		{"timestamp swapped after signing", secret, swapTimestamp(Sign(secret, body, now.Add(-time.Hour)), now), body, ErrInvalidSignature},
+		{"missing v1", secret, "t=" + strconv.FormatInt(now.Unix(), 10), body, ErrInvalidSignature},
+		{"missing t", secret, "v1=" + strings.Repeat("0", 64), body, ErrInvalidSignature},
+		{"non-numeric t", secret, "t=abc,v1=" + strings.Repeat("0", 64), body, ErrInvalidSignature},
+		{"empty header", secret, "", body, ErrInvalidSignature},
+		{"empty secret", "", Sign("", body, now), body, ErrNoWebhookSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.secret, tt.header, tt.body, now)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("VerifySignature: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFakeVerifyWebhook(t *testing.T) {
+	f := NewFake("whsec_test", "/checkout")
+	body := []byte(`{"type":"payment.succeeded","intent_ref":"fk_1"}`)
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr bool
+		wantSig bool // error ต้องเป็น ErrInvalidSignature
+	}{
+		{"valid", Sign(f.Secret, body, time.Now()), body, false, false},
+		{"forged", Sign("other", body, time.Now()), body, true, true},
+		{"signed but not json", Sign(f.Secret, []byte("nope"), time.Now()), []byte("nope"), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := f.VerifyWebhook(tt.body, func(k string) string {
+				if k == FakeSignatureHeader {
+					return tt.header
+				}
+				return ""
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if errors.Is(err, ErrInvalidSignature) != tt.wantSig {
+					t.Errorf("err = %v, want invalid signature = %v", err, tt.wantSig)
+				}
+				return
+			}
+			if ev.Type != EventPaymentSucceeded || ev.IntentRef != "fk_1" {
+				t.Errorf("event = %+v", ev)
+			}
+		})
+	}
+}
+
+// swapTimestamp เปลี่ยน t= ในลายเซ็นเป็นเวลาใหม่โดยคง v1 เดิม (replay ที่แก้เวลา)
+func swapTimestamp(header string, at time.Time) string {
+	_, sig, _ := strings.Cut(header, ",")
+	return "t=" + strconv.FormatInt(at.Unix(), 10) + "," + sig
+}
@@ -0,0 +1,151 @@
+// Package pdfdoc สร้างเอกสาร PDF ภาษาไทย: ใบเสร็จรับเงิน/ใบกำกับภาษีอย่างย่อ และใบกำกับภาษีเต็มรูป
+package pdfdoc
+
+import (
+	"dog/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ชนิดเอกสาร
+const (
+	KindReceipt    = "receipt"     // ใบเสร็จรับเงิน/ใบกำกับภาษีอย่างย่อ (กระดาษม้วน 80mm)
+	KindTaxInvoice = "tax_invoice" // ใบกำกับภาษี/ใบเสร็จรับเงิน เต็มรูป (A4)
+)
+
+// HeadOfficeBranch รหัสสาขาของสำนักงานใหญ่
+const HeadOfficeBranch = "00000"
+
+// Shop ข้อมูลผู้ขาย
+type Shop struct {
+	Name    string
+	Address string
+	TaxID   string
+	Branch  string // รหัสสาขา 5 หลัก; 00000 = สำนักงานใหญ่
+	Phone   string
+}
+
+// Buyer ข้อมูลผู้ซื้อบนใบกำกับภาษีเต็มรูป
+type Buyer struct {
+	Name    string
+	Address string
+	TaxID   string // ว่างได้ถ้าผู้ซื้อเป็นบุคคลธรรมดาที่ไม่ได้ขอ
+	Branch  string
+}
+
+type Line struct {
+	Name      string
+	Qty       int
+	UnitPrice models.Money
+	Amount    models.Money
+}
+
+// Document ข้อมูลทั้งหมดที่ต้องพิมพ์; ยอดเงินคำนวณมาแล้วจากผู้เรียก (pdfdoc ไม่คิดภาษีเอง)
+type Document struct {
+	Kind      string
+	Number    string
+	IssuedAt  time.Time
+	Reference string // เช่น "ออเดอร์ #123"
+	Shop      Shop
+	Buyer     *Buyer
+	Lines     []Line
+
+	Subtotal      models.Money // รวมค่าสินค้า
+	Shipping      models.Money
+	Total         models.Money // ยอดชำระ (รวม VAT แล้ว)
+	VATRate       int          // เปอร์เซ็นต์ เช่น 7; 0 = ไม่แสดงส่วนภาษี
+	VATBase       models.Money // มูลค่าก่อนภาษี
+	VAT           models.Money
+	PaymentMethod string
+}
+
+// Render สร้าง PDF ตามชนิดเอกสาร
+func Render(d Document) ([]byte, error) {
+	if d.Kind == KindTaxInvoice {
+		return renderTaxInvoice(d)
+	}
+	return renderReceipt(d)
+}
+
+// branchLabel ข้อความสาขาตามรูปแบบสรรพากร
+func branchLabel(branch string) string {
+	branch = strings.TrimSpace(branch)
+	if branch == "" || branch == HeadOfficeBranch {
+		return "สำนักงานใหญ่"
+	}
+	return "สาขาที่ " + branch
+}
+
+var thaiZone = time.FixedZone("ICT", 7*60*60)
+
+// thaiDate วันที่แบบ วว/ดด/ปปปป (พ.ศ.) เวลาไทย
+func thaiDate(t time.Time) string {
+	t = t.In(thaiZone)
+	return t.Format("02/01/") + strconv.Itoa(t.Year()+543)
+}
+
+func thaiDateTime(t time.Time) string {
+	return thaiDate(t) + " " + t.In(thaiZone).Format("15:04")
+}
+
+var (
+	thaiDigits = []string{"ศูนย์", "หนึ่ง", "สอง", "สาม", "สี่", "ห้า", "หก", "เจ็ด", "แปด", "เก้า"}
+	thaiPlaces = []string{"", "สิบ", "ร้อย", "พัน", "หมื่น", "แสน"}
+)
+
+// BahtText อ่านจำนวนเงินเป็นตัวหนังสือ เช่น 1250.50 → "หนึ่งพันสองร้อยห้าสิบบาทห้าสิบสตางค์"
+func BahtText(m models.Money) string {
+	prefix := ""
+	if m < 0 {
+		prefix = "ลบ"
+		m = -m
+	}
+	baht, satang := int64(m/models.Baht), int64(m%models.Baht)
+	if baht == 0 && satang == 0 {
+		return "ศูนย์บาทถ้วน"
+	}
+	s := prefix
+	if baht > 0 {
+		s += thaiNumber(baht) + "บาท"
+	}
+	if satang == 0 {
+		return s + "ถ้วน"
+	}
+	return s + thaiNumber(satang) + "สตางค์"
+}
+
+func thaiNumber(n int64) string {
+	if n >= 1000000 {
+		s := thaiNumber(n/1000000) + "ล้าน"
+		if low := n % 1000000; low > 0 {
+			s += thaiChunk(low, true)
+		}
+		return s
+	}
+	return thaiChunk(n, false)
+}
+
+// thaiChunk อ่านเลขไม่เกินหกหลัก; หลักหน่วยเป็น 1 ที่ตามหลังหลักอื่นอ่านว่า "เอ็ด"
+func thaiChunk(n int64, hasHigher bool) string {
+	var b strings.Builder
+	pow := int64(100000)
+	for p := 5; p >= 0; p-- {
+		d := (n / pow) % 10
+		pow /= 10
+		if d == 0 {
+			continue
+		}
+		switch {
+		case p == 1 && d == 1:
+			b.WriteString("สิบ")
+		case p == 1 && d == 2:
+			b.WriteString("ยี่สิบ")
+		case p == 0 && d == 1 && (hasHigher || n >= 10):
+			b.WriteString("เอ็ด")
+		default:
+			b.WriteString(thaiDigits[d] + thaiPlaces[p])
+		}
+	}
+	return b.String()
+}
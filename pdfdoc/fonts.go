@@ -0,0 +1,53 @@
+package pdfdoc
+
+import (
+	"embed"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/signintech/gopdf"
+)
+
+//go:embed fonts
+var embeddedFonts embed.FS
+
+const (
+	fontFamily      = "sarabun"
+	fontRegularFile = "Sarabun-Regular.ttf"
+	fontBoldFile    = "Sarabun-Bold.ttf"
+)
+
+// ErrFontMissing ไม่พบไฟล์ฟอนต์ภาษาไทย (ดู pdfdoc/fonts/README.md)
+var ErrFontMissing = errors.New("thai font not found: add " + fontRegularFile + " to pdfdoc/fonts or set PDF_FONT_DIR")
+
+// loadFont อ่านฟอนต์จากไฟล์ที่ embed ไว้ก่อน แล้วค่อยหาใน PDF_FONT_DIR
+func loadFont(name string) ([]byte, error) {
+	if b, err := embeddedFonts.ReadFile("fonts/" + name); err == nil {
+		return b, nil
+	}
+	if dir := os.Getenv("PDF_FONT_DIR"); dir != "" {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return b, nil
+		}
+	}
+	return nil, ErrFontMissing
+}
+
+// loadFonts คืนฟอนต์ปกติและตัวหนา (ไม่มีตัวหนาใช้ตัวปกติแทน)
+func loadFonts() (regular, bold []byte, err error) {
+	if regular, err = loadFont(fontRegularFile); err != nil {
+		return nil, nil, err
+	}
+	if bold, err = loadFont(fontBoldFile); err != nil {
+		bold = regular
+	}
+	return regular, bold, nil
+}
+
+// CheckFonts ตรวจว่ามีฟอนต์ภาษาไทยและเปิดได้จริง; main เรียกตอน start เพื่อเตือนใน log
+// และ handler เอกสารเรียกก่อนออกเลขที่ใบกำกับภาษี (ไม่มีฟอนต์ = 503 โดยไม่เสียเลขที่)
+func CheckFonts() error {
+	_, err := newCanvas(*gopdf.PageSizeA4)
+	return err
+}
@@ -0,0 +1,373 @@
+package pdfdoc
+
+import (
+	"dog/models"
+	"strconv"
+
+	"github.com/signintech/gopdf"
+)
+
+// canvas ห่อ gopdf ให้เขียนต่อกันได้โดยเก็บ error แรกไว้ตรวจตอนท้าย
+type canvas struct {
+	pdf *gopdf.GoPdf
+	err error
+}
+
+func newCanvas(page gopdf.Rect) (*canvas, error) {
+	regular, bold, err := loadFonts()
+	if err != nil {
+		return nil, err
+	}
+	pdf := &gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: page})
+	if err := pdf.AddTTFFontData(fontFamily, regular); err != nil {
+		return nil, err
+	}
+	if err := pdf.AddTTFFontDataWithOption(fontFamily, bold, gopdf.TtfOption{Style: gopdf.Bold}); err != nil {
+		return nil, err
+	}
+	pdf.AddPage()
+	return &canvas{pdf: pdf}, nil
+}
+
+func (c *canvas) font(bold bool, size float64) {
+	if c.err != nil {
+		return
+	}
+	style := ""
+	if bold {
+		style = "B"
+	}
+	c.err = c.pdf.SetFont(fontFamily, style, size)
+}
+
+// text เขียนข้อความหนึ่งบรรทัดในกรอบกว้าง w ที่ตำแหน่ง (x, y); align = gopdf.Left/Center/Right
+func (c *canvas) text(x, y, w, h float64, s string, align int) {
+	if c.err != nil {
+		return
+	}
+	c.pdf.SetXY(x, y)
+	c.err = c.pdf.CellWithOption(&gopdf.Rect{W: w, H: h}, s, gopdf.CellOption{Align: align | gopdf.Middle})
+}
+
+// wrap ตัดข้อความให้พอดีความกว้าง (ภาษาไทยไม่มีช่องว่าง ตัดตามความกว้างตัวอักษร)
+func (c *canvas) wrap(s string, w float64) []string {
+	if c.err != nil || s == "" {
+		return []string{s}
+	}
+	lines, err := c.pdf.SplitTextWithWordWrap(s, w)
+	if err != nil {
+		// คำยาวเกินบรรทัด → ตัดตามตัวอักษร
+		if lines, err = c.pdf.SplitText(s, w); err != nil {
+			c.err = err
+			return []string{s}
+		}
+	}
+	return lines
+}
+
+// paragraph เขียนข้อความหลายบรรทัด คืนค่า y ถัดไป
+func (c *canvas) paragraph(x, y, w, h float64, s string, align int) float64 {
+	for _, l := range c.wrap(s, w) {
+		c.text(x, y, w, h, l, align)
+		y += h
+	}
+	return y
+}
+
+func (c *canvas) line(x1, y1, x2, y2 float64) {
+	if c.err != nil {
+		return
+	}
+	c.pdf.Line(x1, y1, x2, y2)
+}
+
+func (c *canvas) bytes() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.pdf.GetBytesPdfReturnErr()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func money(m models.Money) string {
+	// 1234567.50 → 1,234,567.50
+	s := m.String()
+	neg := s[0] == '-'
+	if neg {
+		s = s[1:]
+	}
+	intPart, frac := s[:len(s)-3], s[len(s)-3:]
+	out := ""
+	for len(intPart) > 3 {
+		out = "," + intPart[len(intPart)-3:] + out
+		intPart = intPart[:len(intPart)-3]
+	}
+	out = intPart + out + frac
+	if neg {
+		out = "-" + out
+	}
+	return out
+}
+
+// ===== ใบกำกับภาษีเต็มรูป (A4) =====
+
+const (
+	a4Margin    = 40.0
+	a4Width     = 595.0
+	a4Height    = 842.0
+	a4Content   = a4Width - 2*a4Margin
+	a4RowHeight = 18.0
+)
+
+// คอลัมน์ตารางสินค้า: ลำดับ | รายการ | จำนวน | ราคาต่อหน่วย | จำนวนเงิน
+var a4Cols = []struct {
+	title string
+	width float64
+	align int
+}{
+	{"ลำดับ", 35, gopdf.Center},
+	{"รายการ", 260, gopdf.Left},
+	{"จำนวน", 50, gopdf.Right},
+	{"ราคาต่อหน่วย", 85, gopdf.Right},
+	{"จำนวนเงิน", 85, gopdf.Right},
+}
+
+func renderTaxInvoice(d Document) ([]byte, error) {
+	c, err := newCanvas(*gopdf.PageSizeA4)
+	if err != nil {
+		return nil, err
+	}
+
+	y := a4Margin
+	// หัวเอกสาร: ผู้ขายซ้าย, ชื่อเอกสารขวา
+	c.font(true, 16)
+	c.text(a4Margin, y, 300, 22, d.Shop.Name, gopdf.Left)
+	c.text(a4Width-a4Margin-220, y, 220, 22, "ใบกำกับภาษี / ใบเสร็จรับเงิน", gopdf.Right)
+	y += 22
+	c.font(false, 10)
+	c.text(a4Width-a4Margin-220, y, 220, 14, "TAX INVOICE / RECEIPT (ต้นฉบับ)", gopdf.Right)
+	sy := c.paragraph(a4Margin, y, 300, 14, d.Shop.Address, gopdf.Left)
+	sy = c.paragraph(a4Margin, sy, 300, 14, "เลขประจำตัวผู้เสียภาษี "+orDash(d.Shop.TaxID)+"  "+branchLabel(d.Shop.Branch), gopdf.Left)
+	if d.Shop.Phone != "" {
+		sy = c.paragraph(a4Margin, sy, 300, 14, "โทร "+d.Shop.Phone, gopdf.Left)
+	}
+
+	ry := y + 18
+	for _, kv := range [][2]string{
+		{"เลขที่", d.Number},
+		{"วันที่", thaiDate(d.IssuedAt)},
+		{"อ้างอิง", orDash(d.Reference)},
+	} {
+		c.text(a4Width-a4Margin-220, ry, 70, 14, kv[0], gopdf.Left)
+		c.text(a4Width-a4Margin-150, ry, 150, 14, kv[1], gopdf.Right)
+		ry += 14
+	}
+	y = maxf(sy, ry) + 10
+
+	// ผู้ซื้อ
+	c.line(a4Margin, y, a4Width-a4Margin, y)
+	y += 6
+	buyer := d.Buyer
+	if buyer == nil {
+		buyer = &Buyer{Name: "ลูกค้าทั่วไป"}
+	}
+	c.font(true, 11)
+	c.text(a4Margin, y, a4Content, 16, "ผู้ซื้อ: "+buyer.Name, gopdf.Left)
+	y += 16
+	c.font(false, 10)
+	if buyer.Address != "" {
+		y = c.paragraph(a4Margin, y, a4Content, 14, "ที่อยู่: "+buyer.Address, gopdf.Left)
+	}
+	if buyer.TaxID != "" {
+		c.text(a4Margin, y, a4Content, 14, "เลขประจำตัวผู้เสียภาษี "+buyer.TaxID+"  "+branchLabel(buyer.Branch), gopdf.Left)
+		y += 14
+	}
+	y += 8
+
+	// ตารางสินค้า
+	header := func() {
+		c.font(true, 10)
+		c.line(a4Margin, y, a4Width-a4Margin, y)
+		x := a4Margin
+		for _, col := range a4Cols {
+			c.text(x+4, y, col.width-8, a4RowHeight, col.title, col.align)
+			x += col.width
+		}
+		y += a4RowHeight
+		c.line(a4Margin, y, a4Width-a4Margin, y)
+		c.font(false, 10)
+	}
+	header()
+	for i, l := range d.Lines {
+		nameLines := c.wrap(l.Name, a4Cols[1].width-8)
+		rowH := float64(len(nameLines)) * 14
+		if rowH < a4RowHeight {
+			rowH = a4RowHeight
+		}
+		if y+rowH > a4Height-a4Margin-150 {
+			c.pdf.AddPage()
+			y = a4Margin
+			header()
+		}
+		x := a4Margin
+		c.text(x+4, y, a4Cols[0].width-8, a4RowHeight, strconv.Itoa(i+1), a4Cols[0].align)
+		x += a4Cols[0].width
+		for j, nl := range nameLines {
+			c.text(x+4, y+float64(j)*14+2, a4Cols[1].width-8, 14, nl, gopdf.Left)
+		}
+		x += a4Cols[1].width
+		c.text(x+4, y, a4Cols[2].width-8, a4RowHeight, strconv.Itoa(l.Qty), gopdf.Right)
+		x += a4Cols[2].width
+		c.text(x+4, y, a4Cols[3].width-8, a4RowHeight, money(l.UnitPrice), gopdf.Right)
+		x += a4Cols[3].width
+		c.text(x+4, y, a4Cols[4].width-8, a4RowHeight, money(l.Amount), gopdf.Right)
+		y += rowH
+	}
+	c.line(a4Margin, y, a4Width-a4Margin, y)
+	y += 6
+
+	// สรุปยอด
+	totals := [][2]string{{"รวมค่าสินค้า", money(d.Subtotal)}}
+	if d.Shipping != 0 {
+		totals = append(totals, [2]string{"ค่าจัดส่ง", money(d.Shipping)})
+	}
+	if d.VATRate > 0 {
+		totals = append(totals,
+			[2]string{"มูลค่าก่อนภาษี", money(d.VATBase)},
+			[2]string{"ภาษีมูลค่าเพิ่ม " + strconv.Itoa(d.VATRate) + "%", money(d.VAT)},
+		)
+	}
+	labelX := a4Width - a4Margin - 255
+	for _, kv := range totals {
+		c.text(labelX, y, 170, 16, kv[0], gopdf.Left)
+		c.text(labelX+170, y, 85-4, 16, kv[1], gopdf.Right)
+		y += 16
+	}
+	c.line(labelX, y+2, a4Width-a4Margin, y+2)
+	y += 4
+	c.font(true, 11)
+	c.text(labelX, y, 170, 18, "จำนวนเงินรวมทั้งสิ้น", gopdf.Left)
+	c.text(labelX+170, y, 85-4, 18, money(d.Total), gopdf.Right)
+	c.font(false, 10)
+	c.text(a4Margin, y, labelX-a4Margin-10, 18, "("+BahtText(d.Total)+")", gopdf.Left)
+	y += 30
+
+	if d.PaymentMethod != "" {
+		c.text(a4Margin, y, a4Content, 14, "ชำระโดย: "+d.PaymentMethod, gopdf.Left)
+	}
+
+	// ลายเซ็น
+	sigY := a4Height - a4Margin - 40
+	c.line(a4Width-a4Margin-180, sigY, a4Width-a4Margin, sigY)
+	c.text(a4Width-a4Margin-180, sigY+4, 180, 14, "ผู้รับเงิน / ผู้มีอำนาจลงนาม", gopdf.Center)
+
+	return c.bytes()
+}
+
+// ===== ใบเสร็จรับเงิน/ใบกำกับภาษีอย่างย่อ (กระดาษม้วน 80mm) =====
+
+const (
+	receiptWidth  = 226.77 // 80mm
+	receiptMargin = 10.0
+	receiptInner  = receiptWidth - 2*receiptMargin
+)
+
+func renderReceipt(d Document) ([]byte, error) {
+	// วาดรอบแรกบนหน้ายาว ๆ เพื่อวัดความสูงจริง แล้ววาดใหม่ให้หน้าพอดีเนื้อหา
+	c, err := newCanvas(gopdf.Rect{W: receiptWidth, H: 5000})
+	if err != nil {
+		return nil, err
+	}
+	height := drawReceipt(c, d)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c, err = newCanvas(gopdf.Rect{W: receiptWidth, H: height + receiptMargin}); err != nil {
+		return nil, err
+	}
+	drawReceipt(c, d)
+	return c.bytes()
+}
+
+func drawReceipt(c *canvas, d Document) float64 {
+	x, w := receiptMargin, receiptInner
+	y := receiptMargin
+
+	c.font(true, 12)
+	y = c.paragraph(x, y, w, 16, d.Shop.Name, gopdf.Center)
+	c.font(false, 8)
+	y = c.paragraph(x, y, w, 11, d.Shop.Address, gopdf.Center)
+	if d.Shop.TaxID != "" {
+		y = c.paragraph(x, y, w, 11, "TAX ID "+d.Shop.TaxID+" "+branchLabel(d.Shop.Branch), gopdf.Center)
+	}
+	if d.Shop.Phone != "" {
+		y = c.paragraph(x, y, w, 11, "โทร "+d.Shop.Phone, gopdf.Center)
+	}
+	y += 4
+	c.font(true, 9)
+	title := "ใบเสร็จรับเงิน"
+	if d.VATRate > 0 {
+		title = "ใบเสร็จรับเงิน/ใบกำกับภาษีอย่างย่อ"
+	}
+	y = c.paragraph(x, y, w, 13, title, gopdf.Center)
+	c.font(false, 8)
+	c.text(x, y, w/2, 11, "เลขที่ "+d.Number, gopdf.Left)
+	c.text(x+w/2, y, w/2, 11, thaiDateTime(d.IssuedAt), gopdf.Right)
+	y += 11
+	if d.Reference != "" {
+		c.text(x, y, w, 11, d.Reference, gopdf.Left)
+		y += 11
+	}
+	y += 3
+	c.line(x, y, x+w, y)
+	y += 3
+
+	for _, l := range d.Lines {
+		y = c.paragraph(x, y, w, 11, l.Name, gopdf.Left)
+		c.text(x+8, y, w/2, 11, strconv.Itoa(l.Qty)+" x "+money(l.UnitPrice), gopdf.Left)
+		c.text(x+w/2, y, w/2, 11, money(l.Amount), gopdf.Right)
+		y += 12
+	}
+	c.line(x, y, x+w, y)
+	y += 3
+
+	row := func(label, value string) {
+		c.text(x, y, w*0.6, 11, label, gopdf.Left)
+		c.text(x+w*0.6, y, w*0.4, 11, value, gopdf.Right)
+		y += 11
+	}
+	row("รวมค่าสินค้า", money(d.Subtotal))
+	if d.Shipping != 0 {
+		row("ค่าจัดส่ง", money(d.Shipping))
+	}
+	c.font(true, 10)
+	c.text(x, y, w*0.6, 14, "ยอดชำระ", gopdf.Left)
+	c.text(x+w*0.6, y, w*0.4, 14, money(d.Total), gopdf.Right)
+	y += 15
+	c.font(false, 8)
+	if d.VATRate > 0 {
+		row("มูลค่าก่อนภาษี", money(d.VATBase))
+		row("VAT "+strconv.Itoa(d.VATRate)+"% (รวมในยอดแล้ว)", money(d.VAT))
+	}
+	if d.PaymentMethod != "" {
+		row("ชำระโดย", d.PaymentMethod)
+	}
+	y += 6
+	y = c.paragraph(x, y, w, 11, "ขอบคุณที่ใช้บริการ", gopdf.Center)
+	return y
+}
+
+func maxf(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+// Package promptpay สร้าง payload QR พร้อมเพย์ตามมาตรฐาน EMVCo (Thai QR Payment) และรูป PNG
+package promptpay
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ErrInvalidTarget หมายเลขพร้อมเพย์ไม่ใช่เบอร์มือถือ 10 หลัก, เลขประจำตัว 13 หลัก หรือ e-Wallet 15 หลัก
+var ErrInvalidTarget = errors.New("promptpay: invalid target")
+
+// AID ของพร้อมเพย์ (โอนเงินให้บุคคล/นิติบุคคล)
+const aidMerchantPresented = "A000000677010111"
+
+// EMVCo tags ที่ใช้
+const (
+	tagPayloadFormat   = "00"
+	tagPointOfInit     = "01"
+	tagMerchantAccount = "29"
+	tagCurrency        = "53"
+	tagAmount          = "54"
+	tagCountry         = "58"
+	tagCRC             = "63"
+
+	// ภายใน tag 29
+	subAID     = "00"
+	subPhone   = "01"
+	subTaxID   = "02"
+	subEWallet = "03"
+)
+
+const (
+	pointOfInitStatic  = "11" // ใช้ซ้ำได้ ผู้โอนกรอกยอดเอง
+	pointOfInitDynamic = "12" // มียอดเงิน ใช้ครั้งเดียว
+	currencyTHB        = "764"
+	countryTH          = "TH"
+)
+
+// Payload สร้างข้อความ QR พร้อมเพย์; target = เบอร์มือถือ, เลขประจำตัวผู้เสียภาษี หรือเลข e-Wallet
+// amount เป็นสตางค์ (0 = QR ไม่ระบุยอด)
+func Payload(target string, amount int64) (string, error) {
+	sub, id, err := normalizeTarget(target)
+	if err != nil {
+		return "", err
+	}
+	if amount < 0 {
+		return "", fmt.Errorf("promptpay: negative amount")
+	}
+
+	var b strings.Builder
+	b.WriteString(field(tagPayloadFormat, "01"))
+	if amount > 0 {
+		b.WriteString(field(tagPointOfInit, pointOfInitDynamic))
+	} else {
+		b.WriteString(field(tagPointOfInit, pointOfInitStatic))
+	}
+	b.WriteString(field(tagMerchantAccount, field(subAID, aidMerchantPresented)+field(sub, id)))
+	b.WriteString(field(tagCurrency, currencyTHB))
+	if amount > 0 {
+		b.WriteString(field(tagAmount, fmt.Sprintf("%d.%02d", amount/100, amount%100)))
+	}
+	b.WriteString(field(tagCountry, countryTH))
+
+	// CRC คิดรวม tag และความยาวของตัวมันเอง ("6304")
+	b.WriteString(tagCRC + "04")
+	b.WriteString(fmt.Sprintf("%04X", CRC16(b.String())))
+	return b.String(), nil
+}
+
+// PNG เข้ารหัส payload เป็นรูป QR ขนาด size×size พิกเซล
+func PNG(payload string, size int) ([]byte, error) {
+	return qrcode.Encode(payload, qrcode.Medium, size)
+}
+
+// CRC16 แบบ CCITT-FALSE (poly 0x1021, init 0xFFFF) ตามที่ EMVCo กำหนด
+func CRC16(s string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// MaskTarget ซ่อนหมายเลขพร้อมเพย์บางส่วนสำหรับแสดงผล เช่น xxx-xxx-5678
+func MaskTarget(target string) string {
+	d := digits(target)
+	if len(d) <= 4 {
+		return d
+	}
+	return strings.Repeat("x", len(d)-4) + d[len(d)-4:]
+}
+
+func normalizeTarget(target string) (string, string, error) {
+	d := digits(target)
+	switch {
+	case len(d) == 10 && d[0] == '0':
+		// เบอร์มือถือ: 0066 + เบอร์ตัด 0 นำหน้า เติมเป็น 13 หลัก
+		return subPhone, "0066" + d[1:], nil
+	case len(d) == 11 && strings.HasPrefix(d, "66"):
+		return subPhone, "00" + d, nil
+	case len(d) == 13:
+		return subTaxID, d, nil
+	case len(d) == 15:
+		return subEWallet, d, nil
+	}
+	return "", "", ErrInvalidTarget
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func field(tag, value string) string {
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value)
+}
@@ -0,0 +1,112 @@
+package promptpay
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want uint16
+	}{
+		{"check value CRC-16/CCITT-FALSE", "123456789", 0x29B1},
+		{"empty string is the init value", "", 0xFFFF},
+		// payload จาก promptpay-qr (เบอร์ 080-123-4567, tag 58 มาก่อน 53)
+		{"published PromptPay payload", "00020101021129370016A000000677010111011300668012345675802TH53037646304", 0x6197},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CRC16(tt.in); got != tt.want {
+				t.Errorf("CRC16(%q) = %04X, want %04X", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		amount int64
+		want   string
+	}{
+		{
+			name:   "mobile static",
+			target: "081-234-5678",
+			want:   "00020101021129370016A0000006770101110113006681234567853037645802TH6304823E",
+		},
+		{
+			name:   "mobile with country code and amount",
+			target: "+66812345678",
+			amount: 12050,
+			want:   "00020101021229370016A0000006770101110113006681234567853037645406120.505802TH6304B23A",
+		},
+		{
+			name:   "tax id with amount",
+			target: "1234567890123",
+			amount: 100,
+			want:   "00020101021229370016A00000067701011102131234567890123530376454041.005802TH6304E9B7",
+		},
+		{
+			name:   "e-wallet static",
+			target: "123456789012345",
+			want:   "00020101021129390016A000000677010111031512345678901234553037645802TH6304AC13",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Payload(tt.target, tt.amount)
+			if err != nil {
+				t.Fatalf("Payload: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Payload(%q, %d)\n got %s\nwant %s", tt.target, tt.amount, got, tt.want)
+			}
+			body := got[:len(got)-4]
+			if crc := fmt.Sprintf("%04X", CRC16(body)); crc != got[len(got)-4:] {
+				t.Errorf("trailing CRC %s does not match computed %s", got[len(got)-4:], crc)
+			}
+		})
+	}
+}
+
+func TestPayloadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		amount  int64
+		wantErr error
+	}{
+		{"too short", "12345", 0, ErrInvalidTarget},
+		{"10 digits not starting with 0", "1812345678", 0, ErrInvalidTarget},
+		{"empty", "", 0, ErrInvalidTarget},
+		{"negative amount", "0812345678", -1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Payload(tt.target, tt.amount)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaskTarget(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"081-234-5678", "xxxxxx5678"},
+		{"1234", "1234"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MaskTarget(tt.in); got != tt.want {
+			t.Errorf("MaskTarget(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -3,18 +3,24 @@ package routes
 import (
 	"dog/controllers"
 	"dog/middleware"
-	"fmt"
+	"log"
+	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// เวลาที่เก็บ response ของ Idempotency-Key ไว้ตอบ retry
+const idempotencyTTL = 24 * time.Hour
+
 func RegisterRoutes(app *fiber.App) {
 	// POS
-	app.Post("/sales", controllers.CreateSale)
+	app.Post("/sales", middleware.JWTMiddleware, middleware.RequireStaff, middleware.Idempotency(idempotencyTTL), controllers.CreateSale)
 	app.Get("/sales", controllers.GetSales)
 	app.Get("/sales/:sale_id", controllers.GetSaleByID)
-	app.Put("/sales/:sale_id", controllers.UpdateSale)
-	app.Delete("/sales/:sale_id", controllers.DeleteSale)
+	app.Get("/sales/:sale_id/receipt.pdf", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetSaleReceiptPDF)
+	app.Put("/sales/:sale_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateSale)
+	app.Post("/sales/:sale_id/void", middleware.JWTMiddleware, middleware.RequireStaff, controllers.VoidSale) // แทนการลบ
 
 	// Login
 	app.Post("/Login", controllers.Login)
@@ -28,30 +34,88 @@ func RegisterRoutes(app *fiber.App) {
 	app.Get("/api/products", controllers.SearchProducts)
 	app.Get("/popular", controllers.GetPopularProducts)
 
+	// Shipping (ลูกค้า)
+	app.Get("/shipping-methods", controllers.GetShippingMethods)
+	app.Post("/shipping-methods/quote", controllers.QuoteShipping)
+
 	// Customers (ลูกค้า)
 	app.Post("/customers", controllers.CreateCustomer)
 	app.Get("/customers", controllers.GetCustomers)
 	app.Get("/customers/:customer_id", controllers.GetCustomerByID)
 	app.Put("/customers/:customer_id", controllers.UpdateCustomer)
+	app.Get("/customers/:customer_id/addresses", middleware.JWTMiddleware, controllers.GetCustomerAddresses)
+	app.Post("/customers/:customer_id/addresses", middleware.JWTMiddleware, controllers.CreateCustomerAddress)
+	app.Put("/customers/:customer_id/addresses/:address_id", middleware.JWTMiddleware, controllers.UpdateCustomerAddress)
+	app.Patch("/customers/:customer_id/addresses/:address_id/default", middleware.JWTMiddleware, controllers.SetDefaultCustomerAddress)
+	app.Delete("/customers/:customer_id/addresses/:address_id", middleware.JWTMiddleware, controllers.DeleteCustomerAddress)
+	app.Get("/customers/:customer_id/store-credit", middleware.JWTMiddleware, controllers.GetStoreCredit)
+
+	// Gift cards (ลูกค้า; ตรวจยอดไม่ต้อง login)
+	app.Get("/gift-cards/mine", middleware.JWTMiddleware, controllers.GetMyGiftCards)
+	app.Get("/gift-cards/:code/balance", controllers.GetGiftCardBalance)
+
+	// Cart (ลูกค้า; ยังไม่ login ใช้ cookie cart_id)
+	app.Get("/cart", middleware.OptionalJWT, controllers.GetCart)
+	app.Delete("/cart", middleware.OptionalJWT, controllers.ClearCart)
+	app.Post("/cart/items", middleware.OptionalJWT, controllers.AddCartItem)
+	app.Put("/cart/items/:item_id", middleware.OptionalJWT, controllers.UpdateCartItem)
+	app.Delete("/cart/items/:item_id", middleware.OptionalJWT, controllers.DeleteCartItem)
+	app.Post("/cart/merge", middleware.JWTMiddleware, controllers.MergeCart)
 
 	// Orders (ลูกค้า)
-	app.Post("/orders", middleware.JWTMiddleware, controllers.CreateOrder)
-	app.Get("/orders", controllers.GetOrders)
-	app.Get("/orders/:order_id", controllers.GetOrderByID)
-	app.Put("/orders/:order_id", controllers.UpdateOrder)
-	app.Delete("/orders/:order_id", controllers.DeleteOrder)
+	app.Post("/orders", middleware.JWTMiddleware, middleware.Idempotency(idempotencyTTL), controllers.CreateOrder)
+	app.Get("/orders", middleware.JWTMiddleware, controllers.GetOrders)
+	app.Get("/orders/:order_id", middleware.OptionalJWT, controllers.GetOrderByID)
+	app.Get("/orders/:order_id/invoice.pdf", middleware.JWTMiddleware, controllers.GetOrderInvoicePDF)
+	app.Get("/orders/:order_id/promptpay", middleware.OptionalJWT, controllers.GetOrderPromptPay)
+	app.Get("/orders/:order_id/promptpay-qr.png", middleware.OptionalJWT, controllers.GetOrderPromptPayQR)
+	app.Post("/orders/:order_id/payment-slip", middleware.OptionalJWT, controllers.UploadPaymentSlip)
+	app.Get("/orders/:order_id/payment-slips", middleware.OptionalJWT, controllers.GetOrderPaymentSlips)
+	app.Get("/payment-slips/:slip_id/image", middleware.OptionalJWT, controllers.GetPaymentSlipImage)
+	app.Post("/orders/:order_id/payment-intent", middleware.JWTMiddleware, controllers.CreatePaymentIntent)
+
+	// payment gateway: webhook ตรวจด้วยลายเซ็น, หน้าจ่ายเงินจำลองใช้ได้เมื่อ PAYMENT_PROVIDER=fake
+	app.Post("/payments/webhook/:provider", controllers.PaymentWebhook)
+	if controllers.FakePaymentsEnabled() {
+		app.Get("/payments/fake/checkout/:ref", controllers.FakeCheckoutPage)
+		app.Post("/payments/fake/checkout/:ref", controllers.FakeCheckoutComplete)
+	}
+	app.Put("/orders/:order_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateOrder)
+	app.Post("/orders/:order_id/cancel", middleware.JWTMiddleware, controllers.CancelOrder)
+	app.Post("/orders/:order_id/returns", middleware.JWTMiddleware, controllers.CreateOrderReturn)
+	app.Post("/orders/:order_id/claim", middleware.JWTMiddleware, controllers.ClaimGuestOrder)
+
+	// guest checkout (ไม่ต้องมีบัญชี) ดูออเดอร์ด้วย token ที่ได้ตอนสั่ง
+	app.Post("/guest/orders", middleware.Idempotency(idempotencyTTL), controllers.GuestCheckout)
+	app.Get("/guest/orders/:order_id", controllers.GetGuestOrder)
+	app.Post("/guest/orders/:order_id/verify-email", controllers.SendGuestEmailCode)
+	app.Post("/guest/orders/:order_id/convert", controllers.ConvertGuest)
+
+	// คืนสินค้า (ลูกค้า)
+	app.Get("/returns", middleware.JWTMiddleware, controllers.GetMyReturns)
+	app.Get("/returns/:return_id", middleware.JWTMiddleware, controllers.GetReturnByID)
+	app.Post("/returns/:return_id/cancel", middleware.JWTMiddleware, controllers.CancelReturn)
 
 	// ===== Admin/Backoffice API Group =====
 	admin := app.Group("/admin")
 
 	// Stock & Products (หลังบ้าน)
 	admin.Get("/products", controllers.GetProducts)
-	admin.Post("/products", controllers.AddStock)
-	admin.Put("/products/:product_id", controllers.UpdateStock)
-	admin.Patch("/products/:product_id/quantity", controllers.UpdateStockQuantity)
-	admin.Delete("/products/:product_id", controllers.DeleteStock)
+	admin.Post("/products", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AddStock)
+	admin.Put("/products/:product_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateStock)
+	admin.Patch("/products/:product_id/quantity", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateStockQuantity)
+	admin.Delete("/products/:product_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.DeleteStock)
 	admin.Patch("/products/:product_id/popular", controllers.UpdatePopularFlag)
 	admin.Patch("/products/:product_id/recommended", controllers.UpdateRecommended)
+	admin.Get("/products/:product_id/movements", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetStockMovements)
+	admin.Get("/stock/reconcile", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ReconcileStock)
+
+	// Variants ไซซ์/สี (หลังบ้าน)
+	admin.Get("/products/:product_id/variants", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetProductVariants)
+	admin.Post("/products/:product_id/variants", middleware.JWTMiddleware, middleware.RequireStaff, controllers.CreateProductVariant)
+	admin.Get("/variants/lookup", middleware.JWTMiddleware, middleware.RequireStaff, controllers.LookupVariant)
+	admin.Put("/variants/:variant_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateProductVariant)
+	admin.Patch("/variants/:variant_id/quantity", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateVariantQuantity)
 
 	// Employees (หลังบ้าน)
 	admin.Get("/employees", controllers.GetEmployees)
@@ -60,22 +124,82 @@ func RegisterRoutes(app *fiber.App) {
 	admin.Put("/Employee/:emp_id", controllers.UpdateEmployee)
 
 	// Orders (หลังบ้าน)
-	admin.Get("/orders", controllers.GetOrders)
-	admin.Get("/orders/:order_id", controllers.GetOrderByID)
-	admin.Put("/orders/:order_id", controllers.UpdateOrder)
-	admin.Delete("/orders/:order_id", controllers.DeleteOrder)
+	admin.Get("/orders", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminSearchOrders)
+	admin.Get("/orders/export", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ExportOrders)
+	admin.Get("/orders/:order_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetOrderByID)
+	admin.Post("/orders/:order_id/comments", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AddOrderComment)
+	admin.Put("/orders/:order_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateOrder)
+	admin.Post("/orders/:order_id/cancel", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminCancelOrder)
+	admin.Delete("/orders/:order_id", middleware.JWTMiddleware, middleware.RequireOwner, controllers.DeleteOrder) // purge ถาวร
+	admin.Post("/orders/:order_id/ship", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ShipOrder)
+
+	// Export สำหรับบัญชี (CSV/XLSX; ไฟล์ใหญ่ทำเบื้องหลัง)
+	admin.Get("/sales/export", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ExportSales)
+	admin.Get("/exports", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetExports)
+	admin.Post("/exports", middleware.JWTMiddleware, middleware.RequireStaff, controllers.CreateExport)
+	admin.Get("/exports/:job_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetExport)
+	admin.Get("/exports/:job_id/download", middleware.JWTMiddleware, middleware.RequireStaff, controllers.DownloadExport)
+
+	// ตรวจสลิปโอนเงิน (หลังบ้าน)
+	admin.Get("/payment-slips", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminGetPaymentSlips)
+	admin.Post("/payment-slips/:slip_id/approve", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ApprovePaymentSlip)
+	admin.Post("/payment-slips/:slip_id/reject", middleware.JWTMiddleware, middleware.RequireStaff, controllers.RejectPaymentSlip)
+
+	// สมุดรับ/คืนเงิน และกระทบยอดรายวัน (หลังบ้าน)
+	admin.Get("/orders/:order_id/payments", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetOrderPayments)
+	admin.Post("/orders/:order_id/payments", middleware.JWTMiddleware, middleware.RequireStaff, controllers.RecordOrderPayment)
+	admin.Get("/sales/:sale_id/payments", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetSalePayments)
+	admin.Post("/sales/:sale_id/payments", middleware.JWTMiddleware, middleware.RequireStaff, controllers.RecordSalePayment)
+	admin.Get("/reports/reconciliation", middleware.JWTMiddleware, middleware.RequireStaff, controllers.PaymentReconciliation)
+
+	// statement COD จากขนส่ง: นำเข้า/จับคู่ออเดอร์/รายงานยอดขาด-เกิน
+	admin.Post("/cod-remittances", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ImportCODRemittance)
+	admin.Get("/cod-remittances", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetCODRemittances)
+	admin.Get("/cod-remittances/:remittance_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetCODRemittance)
+
+	// Shipping methods (หลังบ้าน)
+	admin.Get("/shipping-methods", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminGetShippingMethods)
+	admin.Post("/shipping-methods", middleware.JWTMiddleware, middleware.RequireStaff, controllers.CreateShippingMethod)
+	admin.Put("/shipping-methods/:id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.UpdateShippingMethod)
+
+	// คืนสินค้า / คืนเงิน (หลังบ้าน)
+	admin.Get("/returns", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminGetReturns)
+	admin.Post("/returns", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminCreateReturn)
+	admin.Get("/returns/:return_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetReturnByID)
+	admin.Post("/returns/:return_id/approve", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ApproveReturn)
+	admin.Post("/returns/:return_id/reject", middleware.JWTMiddleware, middleware.RequireStaff, controllers.RejectReturn)
+	admin.Post("/returns/:return_id/receive", middleware.JWTMiddleware, middleware.RequireStaff, controllers.ReceiveReturn)
+	admin.Post("/returns/:return_id/refund", middleware.JWTMiddleware, middleware.RequireStaff, controllers.RefundReturn)
+	admin.Post("/returns/:return_id/refund/retry", middleware.JWTMiddleware, middleware.RequireStaff, controllers.RetryReturnRefund)
+
+	// ภาษีมูลค่าเพิ่ม (หลังบ้าน)
+	admin.Get("/tax-settings", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetTaxSettings)
+	admin.Put("/tax-settings", middleware.JWTMiddleware, middleware.RequireOwner, controllers.UpdateTaxSettings)
 
 	// Customers (หลังบ้าน)
 	admin.Get("/customers", controllers.GetCustomers)
 	admin.Get("/customers/:customer_id", controllers.GetCustomerByID)
 	admin.Put("/customers/:customer_id", controllers.UpdateCustomer)
+	admin.Get("/customers/:customer_id/store-credit", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GetStoreCredit)
+	admin.Post("/customers/:customer_id/store-credit", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdjustStoreCredit)
+
+	// Gift cards (หลังบ้าน: ขายหน้าร้าน, ค้นหา, ภาระหนี้คงค้าง)
+	admin.Post("/gift-cards", middleware.JWTMiddleware, middleware.RequireStaff, controllers.IssueGiftCard)
+	admin.Get("/gift-cards", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminGetGiftCards)
+	admin.Get("/gift-cards/:gift_card_id", middleware.JWTMiddleware, middleware.RequireStaff, controllers.AdminGetGiftCard)
+	admin.Get("/reports/gift-card-liability", middleware.JWTMiddleware, middleware.RequireStaff, controllers.GiftCardLiability)
 
-	// ===== Debug routes (เปิดใช้ชั่วคราวเวลาตามหา 404) =====
-	for _, r := range app.GetRoutes() {
-		println(r.Method, r.Path)
+	// ROUTES_DEBUG=1: log route ทั้งหมดตอน start และทุก request ที่ไม่เจอ route (ใช้ตามหา 404)
+	debug := os.Getenv("ROUTES_DEBUG") == "1"
+	if debug {
+		for _, r := range app.GetRoutes() {
+			log.Println("route:", r.Method, r.Path)
+		}
 	}
 	app.Use(func(c *fiber.Ctx) error {
-		fmt.Printf("NotFound -> %s %s\n", c.Method(), c.Path())
+		if debug {
+			log.Printf("not found: %s %s", c.Method(), c.Path())
+		}
 		return c.Status(404).JSON(fiber.Map{"error": "not found"})
 	})
 }
@@ -0,0 +1,158 @@
+// Package slipqr อ่าน mini-QR บนสลิปโอนเงินของธนาคารไทย (Slip Verification QR)
+// ข้อความใน QR มีแค่เลขอ้างอิงธุรกรรมและรหัสธนาคารผู้โอน ไม่มียอดเงิน
+package slipqr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"dog/promptpay"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+)
+
+var (
+	// ErrNoQR ไม่พบ QR ที่อ่านได้ในรูป
+	ErrNoQR = errors.New("slipqr: no readable QR code")
+	// ErrNotSlip มี QR แต่ไม่ใช่ QR ตรวจสอบสลิป (หรือ checksum ไม่ถูก)
+	ErrNotSlip = errors.New("slipqr: QR code is not a bank slip reference")
+)
+
+// tags ของ Slip Verification QR
+const (
+	tagData    = "00"
+	tagCountry = "51"
+	tagCRC     = "91"
+
+	// ภายใน tag 00
+	subAPIID    = "00"
+	subBankCode = "01"
+	subRef      = "02"
+
+	apiIDSlip = "000001"
+	countryTH = "TH"
+)
+
+// Slip ข้อมูลที่อ่านได้จาก QR
+type Slip struct {
+	Payload  string     // ข้อความเต็มใน QR
+	BankCode string     // รหัสธนาคารผู้โอน 3 หลัก เช่น 004 = กสิกรไทย, 014 = ไทยพาณิชย์
+	Ref      string     // เลขอ้างอิงธุรกรรม (ไม่ซ้ำกันในระบบธนาคาร)
+	Date     *time.Time // วันที่โอน ถ้าเลขอ้างอิงขึ้นต้นด้วย YYYYMMDD (บางธนาคาร), ไม่งั้น nil
+}
+
+// Decode หา QR ทั้งหมดในรูป แล้วคืน QR สลิปใบแรกที่ parse ได้
+// สลิปบางธนาคารมี QR โฆษณาอยู่ด้วย จึงอ่านทุก QR ไม่ใช่แค่ใบแรก
+func Decode(data []byte) (Slip, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Slip{}, fmt.Errorf("slipqr: %w", err)
+	}
+	payloads := scan(img)
+	if len(payloads) == 0 {
+		return Slip{}, ErrNoQR
+	}
+	for _, p := range payloads {
+		if s, err := Parse(p); err == nil {
+			return s, nil
+		}
+	}
+	return Slip{Payload: payloads[0]}, ErrNotSlip
+}
+
+// scan อ่านทั้งรูปก่อน ถ้าไม่เจอค่อยลองทีละครึ่งล่าง/ครึ่งขวา (QR สลิปเล็กมากเมื่อเทียบกับรูป)
+func scan(img image.Image) []string {
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	reader := multiqr.NewQRCodeMultiReader()
+
+	b := img.Bounds()
+	regions := []image.Rectangle{
+		b,
+		image.Rect(b.Min.X, b.Min.Y+b.Dy()/2, b.Max.X, b.Max.Y),
+		image.Rect(b.Min.X+b.Dx()/2, b.Min.Y, b.Max.X, b.Max.Y),
+	}
+	for _, r := range regions {
+		sub := img
+		if r != b {
+			si, ok := img.(interface {
+				SubImage(image.Rectangle) image.Image
+			})
+			if !ok {
+				break
+			}
+			sub = si.SubImage(r)
+		}
+		bmp, err := gozxing.NewBinaryBitmapFromImage(sub)
+		if err != nil {
+			continue
+		}
+		results, err := reader.DecodeMultiple(bmp, hints)
+		if err != nil || len(results) == 0 {
+			continue
+		}
+		out := make([]string, 0, len(results))
+		for _, res := range results {
+			out = append(out, res.GetText())
+		}
+		return out
+	}
+	return nil
+}
+
+// Parse ตรวจโครงสร้าง TLV และ CRC ของข้อความ QR สลิป
+func Parse(payload string) (Slip, error) {
+	payload = strings.TrimSpace(payload)
+	fields, err := tlv(payload)
+	if err != nil {
+		return Slip{}, ErrNotSlip
+	}
+	crc, ok := fields[tagCRC]
+	if !ok || !strings.HasSuffix(payload, crc) {
+		return Slip{}, ErrNotSlip
+	}
+	if fmt.Sprintf("%04X", promptpay.CRC16(payload[:len(payload)-len(crc)])) != strings.ToUpper(crc) {
+		return Slip{}, ErrNotSlip
+	}
+	if fields[tagCountry] != countryTH {
+		return Slip{}, ErrNotSlip
+	}
+	sub, err := tlv(fields[tagData])
+	if err != nil || sub[subAPIID] != apiIDSlip || sub[subRef] == "" {
+		return Slip{}, ErrNotSlip
+	}
+
+	s := Slip{Payload: payload, BankCode: sub[subBankCode], Ref: sub[subRef]}
+	if len(s.Ref) >= 8 && strings.HasPrefix(s.Ref, "20") {
+		if t, err := time.ParseInLocation("20060102", s.Ref[:8], bangkok); err == nil {
+			s.Date = &t
+		}
+	}
+	return s, nil
+}
+
+// tlv แยกข้อความ tag(2) length(2) value
+func tlv(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for i := 0; i < len(s); {
+		if i+4 > len(s) {
+			return nil, ErrNotSlip
+		}
+		n, err := strconv.Atoi(s[i+2 : i+4])
+		if err != nil || i+4+n > len(s) {
+			return nil, ErrNotSlip
+		}
+		out[s[i:i+2]] = s[i+4 : i+4+n]
+		i += 4 + n
+	}
+	return out, nil
+}
+
+var bangkok = time.FixedZone("ICT", 7*60*60)
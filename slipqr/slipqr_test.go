@@ -0,0 +1,160 @@
+package slipqr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"dog/promptpay"
+)
+
+// slipBody สร้างข้อความ QR สลิปก่อนต่อ CRC
+func slipBody(apiID, bank, ref, country string) string {
+	data := tlvField(subAPIID, apiID) + tlvField(subBankCode, bank)
+	if ref != "" {
+		data += tlvField(subRef, ref)
+	}
+	return tlvField(tagData, data) + tlvField(tagCountry, country)
+}
+
+func tlvField(tag, value string) string { return fmt.Sprintf("%s%02d%s", tag, len(value), value) }
+
+// withCRC ต่อ tag 91 พร้อม CRC ท้ายข้อความแบบเดียวกับที่ธนาคารทำ
+func withCRC(body string) string {
+	body += tagCRC + "04"
+	return body + fmt.Sprintf("%04X", promptpay.CRC16(body))
+}
+
+func TestParse(t *testing.T) {
+	kbankBody := slipBody(apiIDSlip, "004", "0014242082547BPM04988", countryTH)
+	kbank := withCRC(kbankBody)
+	scb := withCRC(slipBody(apiIDSlip, "014", "20230615123456789", countryTH))
+	noDate := withCRC(slipBody(apiIDSlip, "002", "ABCDEFGHIJKLMN", countryTH))
+	tampered := withCRC(slipBody(apiIDSlip, "004", "0014242082547BPM04989", countryTH))
+	tampered = kbank[:len(kbank)-4] + tampered[len(tampered)-4:]
+	if tampered == kbank {
+		t.Fatal("tampered payload has the same CRC")
+	}
+
+	tests := []struct {
+		name     string
+		payload  string
+		wantBank string
+		wantRef  string
+		wantDate string // YYYY-MM-DD หรือว่าง = ไม่มีวันที่
+		wantErr  error
+	}{
+		{"kbank", kbank, "004", "0014242082547BPM04988", "", nil},
+		{"ref starting with date", scb, "014", "20230615123456789", "2023-06-15", nil},
+		{"ref without date", noDate, "002", "ABCDEFGHIJKLMN", "", nil},
+		{"surrounding whitespace", "  " + kbank + "\n", "004", "0014242082547BPM04988", "", nil},
+		{"lowercase crc", kbank[:len(kbank)-4] + strings.ToLower(kbank[len(kbank)-4:]), "004", "0014242082547BPM04988", "", nil},
+
+		{"empty", "", "", "", "", ErrNotSlip},
+		{"bad crc", kbank[:len(kbank)-4] + "0000", "", "", "", ErrNotSlip},
+		{"crc of another slip", tampered, "", "", "", ErrNotSlip},
+		{"truncated", kbank[:20], "", "", "", ErrNotSlip},
+		{"length overruns", "0099000600000101", "", "", "", ErrNotSlip},
+		{"non-numeric length", "00xx0006000001", "", "", "", ErrNotSlip},
+		{"no crc tag", kbankBody, "", "", "", ErrNotSlip},
+		{"wrong country", withCRC(slipBody(apiIDSlip, "004", "0014242082547BPM04988", "US")), "", "", "", ErrNotSlip},
+		{"wrong api id", withCRC(slipBody("000002", "004", "0014242082547BPM04988", countryTH)), "", "", "", ErrNotSlip},
+		{"missing ref", withCRC(slipBody(apiIDSlip, "004", "", countryTH)), "", "", "", ErrNotSlip},
+		{"promptpay payload", mustPayload(t, "0812345678", 10000), "", "", "", ErrNotSlip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.payload)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if s.BankCode != tt.wantBank || s.Ref != tt.wantRef {
+				t.Errorf("got bank %q ref %q, want bank %q ref %q", s.BankCode, s.Ref, tt.wantBank, tt.wantRef)
+			}
+			gotDate := ""
+			if s.Date != nil {
+				gotDate = s.Date.Format("2006-01-02")
+				if _, off := s.Date.Zone(); off != 7*60*60 {
+					t.Errorf("date offset = %d, want +07:00", off)
+				}
+			}
+			if gotDate != tt.wantDate {
+				t.Errorf("date = %q, want %q", gotDate, tt.wantDate)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	slip := withCRC(slipBody(apiIDSlip, "004", "0014242082547BPM04988", countryTH))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantRef string
+		wantErr error
+	}{
+		{"slip QR", qrPNG(t, slip), "0014242082547BPM04988", nil},
+		{"non-slip QR", qrPNG(t, "https://example.com/promo"), "", ErrNotSlip},
+		{"blank image", blankPNG(t), "", ErrNoQR},
+		{"not an image", []byte("not an image"), "", image.ErrFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Decode(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if s.Ref != tt.wantRef {
+				t.Errorf("ref = %q, want %q", s.Ref, tt.wantRef)
+			}
+		})
+	}
+}
+
+func mustPayload(t *testing.T, target string, amount int64) string {
+	t.Helper()
+	p, err := promptpay.Payload(target, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func qrPNG(t *testing.T, payload string) []byte {
+	t.Helper()
+	b, err := promptpay.PNG(payload, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func blankPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF // พื้นขาวล้วน
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -9,10 +9,19 @@ import (
 
 var SecretKey = "Lek-secret-key"
 
-func GenerateJWTToken(userID string) (string, error) {
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		Issuer:    userID,
-		ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+// Claims เก็บ user_id ไว้ใน Issuer (แบบเดิม) และเพิ่ม role สำหรับแยกลูกค้า/พนักงาน
+type Claims struct {
+	Role string `json:"role,omitempty"`
+	jwt.StandardClaims
+}
+
+func GenerateJWTToken(userID string, role string) (string, error) {
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Role: role,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    userID,
+			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+		},
 	})
 
 	token, err := claims.SignedString([]byte(SecretKey))
@@ -35,19 +44,27 @@ func SetJWTCookie(c *fiber.Ctx, token string) {
 	c.Cookie(&cookie)
 }
 
-func ParseJWTToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+func ParseJWTClaims(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(SecretKey), nil
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(*jwt.StandardClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return "", fiber.ErrUnauthorized
+		return nil, fiber.ErrUnauthorized
 	}
 
+	return claims, nil
+}
+
+func ParseJWTToken(tokenString string) (string, error) {
+	claims, err := ParseJWTClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
 	return claims.Issuer, nil
 }
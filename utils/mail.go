@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+)
+
+var ErrMailNotConfigured = errors.New("mail is not configured")
+
+// SendMail ส่งอีเมลข้อความธรรมดาผ่าน SMTP ตาม SMTP_HOST, SMTP_PORT (default 587), SMTP_USER, SMTP_PASSWORD, SMTP_FROM
+// to ต้องผ่านการตรวจรูปแบบอีเมลมาแล้ว
+func SendMail(to, subject, body string) error {
+	host, from := os.Getenv("SMTP_HOST"), os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return ErrMailNotConfigured
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	msg := "From: " + from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body
+	return smtp.SendMail(net.JoinHostPort(host, port), auth, from, []string{to}, []byte(msg))
+}